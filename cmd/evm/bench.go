@@ -0,0 +1,103 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/pprof"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/bench"
+	"github.com/ethereum/go-ethereum/core/vm"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	BenchTimeFlag = cli.DurationFlag{
+		Name:  "bench.time",
+		Usage: "minimum time to run each workload for",
+		Value: time.Second,
+	}
+	BenchListFlag = cli.BoolFlag{
+		Name:  "bench.list",
+		Usage: "list the available workloads and exit",
+	}
+)
+
+var benchCommand = cli.Command{
+	Action:    benchCmd,
+	Name:      "bench",
+	Usage:     "benchmarks the interpreter on representative contract workloads",
+	ArgsUsage: "[workload...]",
+	Flags: []cli.Flag{
+		BenchTimeFlag,
+		BenchListFlag,
+	},
+	Description: `
+The bench command repeatedly calls a set of contracts with the Thunder chain
+rules and block gas limit, and reports the time, gas throughput and heap
+allocations per call. All workloads are run if none is named.`,
+}
+
+func benchCmd(ctx *cli.Context) error {
+	if ctx.Bool(BenchListFlag.Name) {
+		for _, w := range bench.Workloads {
+			fmt.Printf("%-10s %s\n", w.Name, w.Description)
+		}
+		return nil
+	}
+	workloads := bench.Workloads
+	if ctx.NArg() > 0 {
+		workloads = nil
+		for _, name := range ctx.Args() {
+			w := bench.Lookup(name)
+			if w == nil {
+				var names []string
+				for _, w := range bench.Workloads {
+					names = append(names, w.Name)
+				}
+				return fmt.Errorf("unknown workload %q, available: %s", name, strings.Join(names, ", "))
+			}
+			workloads = append(workloads, w)
+		}
+	}
+	if cpuProfilePath := ctx.GlobalString(CPUProfileFlag.Name); cpuProfilePath != "" {
+		f, err := os.Create(cpuProfilePath)
+		if err != nil {
+			return fmt.Errorf("could not create CPU profile: %v", err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("could not start CPU profile: %v", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+	out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "workload\tcalls\tns/call\tgas/call\tMgas/s\tallocs/call\tB/call\t")
+	for _, w := range workloads {
+		result, err := bench.Run(w, vm.Config{}, ctx.Duration(BenchTimeFlag.Name))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\t%.2f\t%d\t%d\t\n", result.Name, result.Calls, result.NsPerCall(),
+			result.GasPerCall(), result.MGasPerSecond(), result.AllocsPerCall(), result.BytesPerCall())
+	}
+	return out.Flush()
+}
@@ -0,0 +1,195 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package bench implements the interpreter benchmarks of the evm command.
+//
+// Every workload is a contract written in easm that is deployed into an in
+// memory state and called repeatedly with the Thunder chain rules and the
+// Thunder block gas limit, measuring the time, gas and allocations per call.
+package bench
+
+import (
+	"errors"
+	"fmt"
+	goruntime "runtime"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// gasLimit is the gas available to every call, the fixed Thunder block gas limit.
+const gasLimit = 10000000
+
+var (
+	contractAddress = common.HexToAddress("0xbe0c4")
+	originAddress   = common.HexToAddress("0x5e4de4")
+)
+
+// Runner executes the calls of a single workload.
+type Runner struct {
+	workload *Workload
+	cfg      *runtime.Config
+	calls    int
+}
+
+// NewRunner compiles the workload and deploys it into a fresh in memory state.
+func NewRunner(w *Workload, vmConfig vm.Config) (*Runner, error) {
+	code, err := compile(w.Name, w.Source)
+	if err != nil {
+		return nil, err
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	statedb.SetCode(contractAddress, code)
+	if w.Prepare != nil {
+		w.Prepare(statedb, contractAddress, originAddress)
+	}
+	statedb.Finalise(true)
+
+	return &Runner{
+		workload: w,
+		cfg: &runtime.Config{
+			ChainConfig: params.AllThunderProtocolChanges,
+			Origin:      originAddress,
+			BlockNumber: common.Big1,
+			GasLimit:    gasLimit,
+			EVMConfig:   vmConfig,
+			State:       statedb,
+		},
+	}, nil
+}
+
+// Call makes the next call of the workload and returns the gas it used.
+func (r *Runner) Call() (uint64, error) {
+	input := r.workload.Input(r.calls)
+	r.calls++
+
+	_, leftOver, err := runtime.Call(contractAddress, input, r.cfg)
+	if err != nil {
+		return 0, fmt.Errorf("%s: call %d failed: %v", r.workload.Name, r.calls-1, err)
+	}
+	// Separate calls the way transactions are separated in a block
+	r.cfg.State.Finalise(true)
+	return r.cfg.GasLimit - leftOver, nil
+}
+
+// Result is the outcome of benchmarking a workload.
+type Result struct {
+	Name     string
+	Calls    int
+	Duration time.Duration
+	GasUsed  uint64
+	Allocs   uint64
+	Bytes    uint64
+}
+
+// NsPerCall returns the average execution time of a call.
+func (r *Result) NsPerCall() int64 {
+	if r.Calls == 0 {
+		return 0
+	}
+	return r.Duration.Nanoseconds() / int64(r.Calls)
+}
+
+// GasPerCall returns the average gas used by a call.
+func (r *Result) GasPerCall() uint64 {
+	if r.Calls == 0 {
+		return 0
+	}
+	return r.GasUsed / uint64(r.Calls)
+}
+
+// MGasPerSecond returns the execution throughput in millions of gas per second.
+func (r *Result) MGasPerSecond() float64 {
+	if r.Duration == 0 {
+		return 0
+	}
+	return float64(r.GasUsed) / r.Duration.Seconds() / 1e6
+}
+
+// AllocsPerCall returns the average number of heap allocations of a call.
+func (r *Result) AllocsPerCall() uint64 {
+	if r.Calls == 0 {
+		return 0
+	}
+	return r.Allocs / uint64(r.Calls)
+}
+
+// BytesPerCall returns the average number of bytes allocated by a call.
+func (r *Result) BytesPerCall() uint64 {
+	if r.Calls == 0 {
+		return 0
+	}
+	return r.Bytes / uint64(r.Calls)
+}
+
+// Run calls the workload repeatedly for at least the given duration, after a
+// single warm up call that is not measured.
+func Run(w *Workload, vmConfig vm.Config, duration time.Duration) (*Result, error) {
+	runner, err := NewRunner(w, vmConfig)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runner.Call(); err != nil {
+		return nil, err
+	}
+	var before, after goruntime.MemStats
+	goruntime.GC()
+	goruntime.ReadMemStats(&before)
+
+	result := &Result{Name: w.Name}
+	start := time.Now()
+	for result.Duration < duration {
+		// Check the clock in batches to keep its cost out of cheap workloads
+		for i := 0; i < 16; i++ {
+			gas, err := runner.Call()
+			if err != nil {
+				return nil, err
+			}
+			result.GasUsed += gas
+			result.Calls++
+		}
+		result.Duration = time.Since(start)
+	}
+	goruntime.ReadMemStats(&after)
+	result.Allocs = after.Mallocs - before.Mallocs
+	result.Bytes = after.TotalAlloc - before.TotalAlloc
+
+	return result, nil
+}
+
+// compile assembles easm source into EVM bytecode.
+func compile(name, source string) ([]byte, error) {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex(name, []byte(source), false))
+
+	bin, errs := compiler.Compile()
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return nil, errors.New(name + ": " + strings.Join(msgs, ", "))
+	}
+	return hexutil.Decode("0x" + bin)
+}
@@ -0,0 +1,118 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package bench
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that the token workload moves balances and emits the transfer events.
+func TestERC20Workload(t *testing.T) {
+	runner, err := NewRunner(erc20Workload, vm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 300; i++ {
+		if _, err := runner.Call(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	balance := func(addr common.Address) *big.Int {
+		slot := crypto.Keccak256Hash(common.LeftPadBytes(addr.Bytes(), 32), make([]byte, 32))
+		return runner.cfg.State.GetState(contractAddress, slot).Big()
+	}
+	// Recipients rotate over the 256 low addresses, the first 44 got two tokens
+	if have := balance(common.BytesToAddress([]byte{5})); have.Cmp(big.NewInt(2)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want 2", have)
+	}
+	if have := balance(common.BytesToAddress([]byte{200})); have.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want 1", have)
+	}
+	want := new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 128), big.NewInt(300))
+	if have := balance(originAddress); have.Cmp(want) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", have, want)
+	}
+	if logs := runner.cfg.State.Logs(); len(logs) != 300 {
+		t.Errorf("log count mismatch: have %d, want 300", len(logs))
+	}
+	// Transfers exceeding the balance must revert
+	input := erc20Workload.Input(0)
+	input[4+32] = 0xff
+	if _, _, err := runtime.Call(contractAddress, input, runner.cfg); err == nil {
+		t.Error("overdrawing transfer succeeded")
+	}
+}
+
+// Tests that the keccak workload computes the expected hash chain.
+func TestKeccakWorkload(t *testing.T) {
+	runner, err := NewRunner(keccakWorkload, vm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, _, err := runtime.Call(contractAddress, keccakWorkload.Input(0), runner.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := make([]byte, 256)
+	for i := 0; i < 1000; i++ {
+		copy(mem, crypto.Keccak256(mem))
+	}
+	if !bytes.Equal(ret, mem[:32]) {
+		t.Errorf("hash chain mismatch: have %x, want %x", ret, mem[:32])
+	}
+}
+
+// Tests that the memory copy workload copies the whole call data.
+func TestMemcopyWorkload(t *testing.T) {
+	runner, err := NewRunner(memcopyWorkload, vm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := memcopyWorkload.Input(7)
+	ret, _, err := runtime.Call(contractAddress, input, runner.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ret, input[:32]) {
+		t.Errorf("copied word mismatch: have %x, want %x", ret, input[:32])
+	}
+}
+
+func BenchmarkWorkloads(b *testing.B) {
+	for _, w := range Workloads {
+		b.Run(w.Name, func(b *testing.B) {
+			runner, err := NewRunner(w, vm.Config{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := runner.Call(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package bench
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Workload is a contract together with the calls the benchmark makes to it.
+type Workload struct {
+	Name        string
+	Description string
+	Source      string // easm source of the contract's runtime code
+
+	// Input returns the call data of the i'th call.
+	Input func(i int) []byte
+
+	// Prepare, if set, seeds the state before the first call.
+	Prepare func(statedb *state.StateDB, contract, origin common.Address)
+}
+
+// Workloads is the list of benchmark workloads available to the evm command.
+var Workloads = []*Workload{
+	erc20Workload,
+	keccakWorkload,
+	memcopyWorkload,
+}
+
+// Lookup returns the workload with the given name, or nil if there is none.
+func Lookup(name string) *Workload {
+	for _, w := range Workloads {
+		if w.Name == name {
+			return w
+		}
+	}
+	return nil
+}
+
+// erc20Workload repeatedly calls transfer(address,uint256) on a minimal token
+// keeping its balances in a mapping at storage slot 0, the way the Solidity
+// compiler lays them out. Each call reads and writes two balances and emits a
+// Transfer event.
+var erc20Workload = &Workload{
+	Name:        "erc20",
+	Description: "ERC20 token transfers to 256 rotating recipients",
+	Source: `
+	;; selector = calldata[0:4]
+	push 0
+	calldataload
+	push 0x100000000000000000000000000000000000000000000000000000000
+	swap1
+	div
+	push 0xa9059cbb
+	eq
+	jumpi @transfer
+	push 0
+	dup1
+	revert
+
+transfer:
+	;; sender balance: balances[caller] at keccak256(caller . 0)
+	caller
+	push 0
+	mstore
+	push 0
+	push 32
+	mstore
+	push 64
+	push 0
+	sha3
+	dup1
+	sload
+	push 36
+	calldataload
+	dup1
+	dup3
+	lt
+	jumpi @fail
+
+	;; balances[caller] -= amount
+	dup1
+	swap2
+	sub
+	dup3
+	sstore
+	swap1
+	pop
+
+	;; balances[to] += amount
+	push 4
+	calldataload
+	push 0
+	mstore
+	push 64
+	push 0
+	sha3
+	dup1
+	sload
+	dup3
+	add
+	swap1
+	sstore
+
+	;; emit Transfer(caller, to, amount)
+	push 0
+	mstore
+	push 4
+	calldataload
+	caller
+	push 0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef
+	push 32
+	push 0
+	log3
+
+	;; return true
+	push 1
+	push 0
+	mstore
+	push 32
+	push 0
+	return
+
+fail:
+	push 0
+	dup1
+	revert
+`,
+	Input: func(i int) []byte {
+		input := make([]byte, 4+32+32)
+		copy(input, common.Hex2Bytes("a9059cbb"))
+		input[4+31] = byte(i) // recipient 0x00..00<i%256>
+		input[4+32+31] = 1    // amount
+		return input
+	},
+	Prepare: func(statedb *state.StateDB, contract, origin common.Address) {
+		slot := crypto.Keccak256Hash(common.LeftPadBytes(origin.Bytes(), 32), make([]byte, 32))
+		statedb.SetState(contract, slot, common.BigToHash(new(big.Int).Lsh(common.Big1, 128)))
+	},
+}
+
+// keccakWorkload chains keccak256 hashes of a 256 byte memory region.
+var keccakWorkload = &Workload{
+	Name:        "keccak",
+	Description: "1000 chained keccak256 hashes of 256 bytes of memory",
+	Source: `
+	push 0
+	calldataload
+loop:
+	dup1
+	iszero
+	jumpi @done
+	push 256
+	push 0
+	sha3
+	push 0
+	mstore
+	push 1
+	swap1
+	sub
+	jump @loop
+done:
+	push 32
+	push 0
+	return
+`,
+	Input: func(i int) []byte {
+		return common.LeftPadBytes(big.NewInt(1000).Bytes(), 32)
+	},
+}
+
+// memcopyWorkload copies its call data into memory and then copies it word by
+// word into a second region through the stack.
+var memcopyWorkload = &Workload{
+	Name:        "memcopy",
+	Description: "calldatacopy of 4KiB followed by a 32 byte word MLOAD/MSTORE copy loop",
+	Source: `
+	calldatasize
+	push 0
+	push 0
+	calldatacopy
+	push 0
+loop:
+	dup1
+	calldatasize
+	gt
+	iszero
+	jumpi @done
+	dup1
+	mload
+	dup2
+	push 0x2000
+	add
+	mstore
+	push 32
+	add
+	jump @loop
+done:
+	push 32
+	push 0x2000
+	return
+`,
+	Input: func(i int) []byte {
+		input := make([]byte, 4096)
+		for j := range input {
+			input[j] = byte(i + j)
+		}
+		return input
+	},
+}
@@ -0,0 +1,193 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+// Env is the block environment the transactions are executed in.
+type Env struct {
+	Coinbase    common.Address                      `json:"currentCoinbase"`
+	Difficulty  *math.HexOrDecimal256               `json:"currentDifficulty"`
+	GasLimit    math.HexOrDecimal64                 `json:"currentGasLimit"`
+	Number      math.HexOrDecimal64                 `json:"currentNumber"`
+	Timestamp   math.HexOrDecimal64                 `json:"currentTimestamp"`
+	BlockHashes map[math.HexOrDecimal64]common.Hash `json:"blockHashes,omitempty"`
+}
+
+// Prestate is the state the transactions are executed on top of.
+type Prestate struct {
+	Env Env               `json:"env"`
+	Pre core.GenesisAlloc `json:"pre"`
+}
+
+// ExecutionResult contains the roots and receipts of a state transition.
+type ExecutionResult struct {
+	StateRoot   common.Hash         `json:"stateRoot"`
+	TxRoot      common.Hash         `json:"txRoot"`
+	ReceiptRoot common.Hash         `json:"receiptRoot"`
+	LogsHash    common.Hash         `json:"logsHash"`
+	Bloom       types.Bloom         `json:"logsBloom"`
+	GasUsed     math.HexOrDecimal64 `json:"gasUsed"`
+	Receipts    types.Receipts      `json:"receipts"`
+	Rejected    []int               `json:"rejected,omitempty"` // Indices of transactions that could not be included
+}
+
+// Apply executes the transactions on top of the prestate and returns the
+// resulting state together with the execution result. Transactions that are
+// invalid in the current state (bad nonce, insufficient funds, block gas limit
+// exceeded, ...) are skipped and reported as rejected.
+func (pre *Prestate) Apply(vmConfig vm.Config, chainConfig *params.ChainConfig, txs types.Transactions) (*state.StateDB, *ExecutionResult, error) {
+	var (
+		statedb  = tests.MakePreState(ethdb.NewMemDatabase(), pre.Pre)
+		signer   = types.MakeSigner(chainConfig, new(big.Int).SetUint64(uint64(pre.Env.Number)))
+		gaspool  = new(core.GasPool).AddGas(uint64(pre.Env.GasLimit))
+		header   = pre.header()
+		included types.Transactions
+		receipts types.Receipts
+		rejected []int
+		gasUsed  uint64
+	)
+	for i, tx := range txs {
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			log.Info("Rejected transaction", "index", i, "hash", tx.Hash(), "err", err)
+			rejected = append(rejected, i)
+			continue
+		}
+		context := core.NewEVMContext(msg, header, nil, &pre.Env.Coinbase)
+		context.GetHash = pre.getHash
+		evm := vm.NewEVM(context, statedb, chainConfig, vmConfig)
+
+		statedb.Prepare(tx.Hash(), common.Hash{}, len(included))
+		snapshot := statedb.Snapshot()
+
+		_, gas, failed, err := core.ApplyMessage(evm, msg, gaspool)
+		if err != nil {
+			statedb.RevertToSnapshot(snapshot)
+			log.Info("Rejected transaction", "index", i, "hash", tx.Hash(), "from", msg.From(), "err", err)
+			rejected = append(rejected, i)
+			continue
+		}
+		included = append(included, tx)
+
+		var root []byte
+		if chainConfig.IsByzantium(header.Number) {
+			statedb.Finalise(true)
+		} else {
+			root = statedb.IntermediateRoot(chainConfig.IsEIP158(header.Number)).Bytes()
+		}
+		gasUsed += gas
+
+		receipt := types.NewReceipt(root, failed, gasUsed)
+		receipt.TxHash = tx.Hash()
+		receipt.GasUsed = gas
+		if msg.To() == nil {
+			receipt.ContractAddress = crypto.CreateAddress(evm.Context.Origin, tx.Nonce())
+		}
+		receipt.Logs = statedb.GetLogs(tx.Hash())
+		receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+		receipts = append(receipts, receipt)
+	}
+	root, err := statedb.Commit(chainConfig.IsEIP158(header.Number))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not commit state: %v", err)
+	}
+	result := &ExecutionResult{
+		StateRoot:   root,
+		TxRoot:      types.DeriveSha(included),
+		ReceiptRoot: types.DeriveSha(receipts),
+		LogsHash:    rlpHash(statedb.Logs()),
+		Bloom:       types.CreateBloom(receipts),
+		GasUsed:     math.HexOrDecimal64(gasUsed),
+		Receipts:    receipts,
+		Rejected:    rejected,
+	}
+	if result.Receipts == nil {
+		result.Receipts = types.Receipts{}
+	}
+	return statedb, result, nil
+}
+
+// header assembles the header of the block being built from the environment.
+func (pre *Prestate) header() *types.Header {
+	header := &types.Header{
+		Coinbase:   pre.Env.Coinbase,
+		Difficulty: new(big.Int),
+		GasLimit:   uint64(pre.Env.GasLimit),
+		Number:     new(big.Int).SetUint64(uint64(pre.Env.Number)),
+		Time:       new(big.Int).SetUint64(uint64(pre.Env.Timestamp)),
+	}
+	if pre.Env.Difficulty != nil {
+		header.Difficulty = (*big.Int)(pre.Env.Difficulty)
+	}
+	return header
+}
+
+// getHash implements BLOCKHASH using the hashes provided in the environment.
+func (pre *Prestate) getHash(num uint64) common.Hash {
+	return pre.Env.BlockHashes[math.HexOrDecimal64(num)]
+}
+
+// DumpAlloc converts the accounts of a state database into a genesis alloc.
+func DumpAlloc(statedb *state.StateDB) core.GenesisAlloc {
+	alloc := make(core.GenesisAlloc)
+	for addr, account := range statedb.RawDump().Accounts {
+		balance, _ := new(big.Int).SetString(account.Balance, 10)
+		ga := core.GenesisAccount{
+			Balance: balance,
+			Nonce:   account.Nonce,
+			Code:    common.Hex2Bytes(account.Code),
+		}
+		if len(account.Storage) > 0 {
+			ga.Storage = make(map[common.Hash]common.Hash)
+			for key, value := range account.Storage {
+				// Storage values are dumped in their RLP encoded form
+				var content []byte
+				if err := rlp.DecodeBytes(common.Hex2Bytes(value), &content); err != nil {
+					content = common.Hex2Bytes(value)
+				}
+				ga.Storage[common.HexToHash(key)] = common.BytesToHash(content)
+			}
+		}
+		alloc[common.HexToAddress(addr)] = ga
+	}
+	return alloc
+}
+
+func rlpHash(x interface{}) (h common.Hash) {
+	hw := sha3.NewKeccak256()
+	rlp.Encode(hw, x)
+	hw.Sum(h[:0])
+	return h
+}
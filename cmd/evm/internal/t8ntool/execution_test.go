@@ -0,0 +1,124 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+)
+
+const testKey = "45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8"
+
+func TestTransition(t *testing.T) {
+	var (
+		key, _   = crypto.HexToECDSA(testKey)
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		receiver = common.HexToAddress("0xbb")
+		config   = params.AllThunderProtocolChanges
+		signer   = types.MakeSigner(config, big.NewInt(1))
+	)
+	raw := []json.RawMessage{
+		json.RawMessage(`{"nonce": "0x0", "gasPrice": "0x1", "gas": "0x5208", "to": "0x00000000000000000000000000000000000000bb", "value": "0x10", "input": "0x", "secretKey": "0x` + testKey + `"}`),
+		// Reusing the nonce must get the transaction rejected
+		json.RawMessage(`{"nonce": "0x0", "gasPrice": "0x1", "gas": "0x5208", "to": "0x00000000000000000000000000000000000000bb", "value": "0x10", "input": "0x", "secretKey": "0x` + testKey + `"}`),
+	}
+	txs, err := ParseTransactions(raw, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pre Prestate
+	if err := json.Unmarshal([]byte(`{
+		"env": {"currentCoinbase": "0x00000000000000000000000000000000000000cc", "currentDifficulty": "0x1",
+		        "currentGasLimit": "0x989680", "currentNumber": "0x1", "currentTimestamp": "0x3e8"},
+		"pre": {"`+sender.Hex()+`": {"balance": "0x100000"}}
+	}`), &pre); err != nil {
+		t.Fatal(err)
+	}
+	statedb, result, err := pre.Apply(vm.Config{}, config, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Receipts) != 1 || len(result.Rejected) != 1 || result.Rejected[0] != 1 {
+		t.Fatalf("unexpected inclusion: %d receipts, rejected %v", len(result.Receipts), result.Rejected)
+	}
+	if result.GasUsed != 21000 {
+		t.Errorf("gas used mismatch: have %d, want %d", result.GasUsed, 21000)
+	}
+	if result.TxRoot != types.DeriveSha(txs[:1]) {
+		t.Errorf("tx root mismatch")
+	}
+	alloc := DumpAlloc(statedb)
+	if balance := alloc[receiver].Balance; balance == nil || balance.Uint64() != 0x10 {
+		t.Errorf("receiver balance mismatch: have %v, want %d", balance, 0x10)
+	}
+	if nonce := alloc[sender].Nonce; nonce != 1 {
+		t.Errorf("sender nonce mismatch: have %d, want 1", nonce)
+	}
+	// Re-applying on top of the dumped alloc must yield the same state root
+	if root := tests.MakePreState(ethdb.NewMemDatabase(), alloc).IntermediateRoot(true); root != result.StateRoot {
+		t.Errorf("dumped alloc root mismatch: have %x, want %x", root, result.StateRoot)
+	}
+}
+
+func TestFill(t *testing.T) {
+	key, _ := crypto.HexToECDSA(testKey)
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	filler := `{
+		"env": {"currentCoinbase": "00000000000000000000000000000000000000cc", "currentDifficulty": "0x1",
+		        "currentGasLimit": "0x989680", "currentNumber": "0x1", "currentTimestamp": "0x3e8"},
+		"pre": {
+			"0x00000000000000000000000000000000000000bb": {"balance": "0x0", "code": "0x6001600055", "nonce": "0x0", "storage": {}},
+			"` + sender.Hex() + `": {"balance": "0xffffffff", "code": "0x", "nonce": "0x0", "storage": {}}
+		},
+		"transaction": {"data": ["0x", "0x01"], "gasLimit": ["0x186a0"], "gasPrice": "0x1", "nonce": "0x0",
+		                "secretKey": "0x` + testKey + `", "to": "0x00000000000000000000000000000000000000bb", "value": ["0x0"]}
+	}`
+	var test tests.StateTest
+	if err := json.Unmarshal([]byte(filler), &test); err != nil {
+		t.Fatal(err)
+	}
+	if err := test.Fill([]string{"Thunder", "Byzantium"}, vm.Config{}); err != nil {
+		t.Fatal(err)
+	}
+	blob, err := json.Marshal(&test)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixture tests.StateTest
+	if err := json.Unmarshal(blob, &fixture); err != nil {
+		t.Fatal(err)
+	}
+	subtests := fixture.Subtests()
+	if len(subtests) != 4 {
+		t.Fatalf("subtest count mismatch: have %d, want 4", len(subtests))
+	}
+	for _, subtest := range subtests {
+		if _, err := fixture.Run(subtest, vm.Config{}); err != nil {
+			t.Errorf("filled subtest %v failed: %v", subtest, err)
+		}
+	}
+}
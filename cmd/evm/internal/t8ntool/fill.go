@@ -0,0 +1,65 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package t8ntool
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/tests"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	FillForksFlag = cli.StringFlag{
+		Name:  "state.forks",
+		Usage: "comma separated list of forks to fill the expected post states for",
+		Value: "Thunder",
+	}
+	OutputTestFlag = cli.StringFlag{
+		Name:  "output.test",
+		Usage: "`file` to write the filled fixture to, 'stdout' or 'stderr' for standard streams",
+		Value: "stdout",
+	}
+)
+
+// Fill is the action of the fill command. It reads a state test filler, which
+// is a regular general state test (as run by the statetest command) without
+// the post section, and writes it back as a fixture with the expected post
+// state roots and logs hashes of every subtest.
+func Fill(ctx *cli.Context) error {
+	if len(ctx.Args().First()) == 0 {
+		return errors.New("path-to-filler argument required")
+	}
+	var fillers map[string]*tests.StateTest
+	if err := readJSON(ctx.Args().First(), &fillers); err != nil {
+		return err
+	}
+	forks := strings.Split(ctx.String(FillForksFlag.Name), ",")
+	for _, fork := range forks {
+		if _, err := forkConfig(fork); err != nil {
+			return err
+		}
+	}
+	for _, test := range fillers {
+		if err := test.Fill(forks, vm.Config{}); err != nil {
+			return err
+		}
+	}
+	return writeJSON(ctx.String(OutputTestFlag.Name), fillers)
+}
@@ -0,0 +1,222 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package t8ntool implements the state transition tool of the evm command.
+//
+// The transition tool reads three JSON inputs:
+//
+//	alloc: the pre-state, in genesis alloc format
+//	       {"0x<address>": {"balance": "0x..", "nonce": "0x..", "code": "0x..", "storage": {"0x<key>": "0x<value>"}}}
+//	env:   the block environment
+//	       {"currentCoinbase": "0x..", "currentDifficulty": "0x..", "currentGasLimit": "0x..",
+//	        "currentNumber": "0x..", "currentTimestamp": "0x..", "blockHashes": {"<number>": "0x<hash>"}}
+//	txs:   a list of transactions. Signed transactions use the RPC format with v, r
+//	       and s; unsigned ones carry a "secretKey" field and are signed by the tool.
+//
+// It outputs the post-state alloc in the same format as the input and a result
+// object with the state, transaction and receipt roots, the logs hash and bloom,
+// the receipts and the indices of rejected transactions.
+package t8ntool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/tests"
+	cli "gopkg.in/urfave/cli.v1"
+)
+
+var (
+	InputAllocFlag = cli.StringFlag{
+		Name:  "input.alloc",
+		Usage: "`file` with the pre-state alloc, 'stdin' to read a {alloc, env, txs} object from standard input",
+		Value: "alloc.json",
+	}
+	InputEnvFlag = cli.StringFlag{
+		Name:  "input.env",
+		Usage: "`file` with the block environment",
+		Value: "env.json",
+	}
+	InputTxsFlag = cli.StringFlag{
+		Name:  "input.txs",
+		Usage: "`file` with the transactions to apply",
+		Value: "txs.json",
+	}
+	OutputAllocFlag = cli.StringFlag{
+		Name:  "output.alloc",
+		Usage: "`file` to write the post-state alloc to, 'stdout' or 'stderr' for standard streams",
+		Value: "alloc.json",
+	}
+	OutputResultFlag = cli.StringFlag{
+		Name:  "output.result",
+		Usage: "`file` to write the execution result to, 'stdout' or 'stderr' for standard streams",
+		Value: "result.json",
+	}
+	ForkFlag = cli.StringFlag{
+		Name:  "state.fork",
+		Usage: "name of the chain rules to apply, one of the forks known to the state tests",
+		Value: "Thunder",
+	}
+)
+
+// input is the combined input object accepted on standard input.
+type input struct {
+	Alloc core.GenesisAlloc `json:"alloc"`
+	Env   Env               `json:"env"`
+	Txs   []json.RawMessage `json:"txs"`
+}
+
+// Main is the action of the t8n command.
+func Main(ctx *cli.Context) error {
+	chainConfig, err := forkConfig(ctx.String(ForkFlag.Name))
+	if err != nil {
+		return err
+	}
+	var in input
+	if ctx.String(InputAllocFlag.Name) == "stdin" {
+		if err := json.NewDecoder(os.Stdin).Decode(&in); err != nil {
+			return fmt.Errorf("failed to read input: %v", err)
+		}
+	} else {
+		if err := readJSON(ctx.String(InputAllocFlag.Name), &in.Alloc); err != nil {
+			return err
+		}
+		if err := readJSON(ctx.String(InputEnvFlag.Name), &in.Env); err != nil {
+			return err
+		}
+		if err := readJSON(ctx.String(InputTxsFlag.Name), &in.Txs); err != nil {
+			return err
+		}
+	}
+	signer := types.MakeSigner(chainConfig, new(big.Int).SetUint64(uint64(in.Env.Number)))
+	txs, err := ParseTransactions(in.Txs, signer)
+	if err != nil {
+		return err
+	}
+	prestate := &Prestate{Env: in.Env, Pre: in.Alloc}
+	statedb, result, err := prestate.Apply(vm.Config{}, chainConfig, txs)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(ctx.String(OutputAllocFlag.Name), DumpAlloc(statedb)); err != nil {
+		return err
+	}
+	return writeJSON(ctx.String(OutputResultFlag.Name), result)
+}
+
+// unsignedTx is a transaction to be signed by the tool with the given key.
+type unsignedTx struct {
+	Nonce     math.HexOrDecimal64   `json:"nonce"`
+	GasPrice  *math.HexOrDecimal256 `json:"gasPrice"`
+	Gas       math.HexOrDecimal64   `json:"gas"`
+	To        *common.Address       `json:"to"`
+	Value     *math.HexOrDecimal256 `json:"value"`
+	Input     hexutil.Bytes         `json:"input"`
+	SecretKey *hexutil.Bytes        `json:"secretKey"`
+}
+
+// ParseTransactions decodes a list of JSON transactions, signing the ones that
+// carry a secret key.
+func ParseTransactions(raw []json.RawMessage, signer types.Signer) (types.Transactions, error) {
+	txs := make(types.Transactions, 0, len(raw))
+	for i, blob := range raw {
+		var utx unsignedTx
+		if err := json.Unmarshal(blob, &utx); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		if utx.SecretKey == nil {
+			tx := new(types.Transaction)
+			if err := json.Unmarshal(blob, tx); err != nil {
+				return nil, fmt.Errorf("transaction %d: %v", i, err)
+			}
+			txs = append(txs, tx)
+			continue
+		}
+		key, err := crypto.ToECDSA(*utx.SecretKey)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: invalid secret key: %v", i, err)
+		}
+		var (
+			price = new(big.Int)
+			value = new(big.Int)
+		)
+		if utx.GasPrice != nil {
+			price = (*big.Int)(utx.GasPrice)
+		}
+		if utx.Value != nil {
+			value = (*big.Int)(utx.Value)
+		}
+		var tx *types.Transaction
+		if utx.To == nil {
+			tx = types.NewContractCreation(uint64(utx.Nonce), value, uint64(utx.Gas), price, utx.Input)
+		} else {
+			tx = types.NewTransaction(uint64(utx.Nonce), *utx.To, value, uint64(utx.Gas), price, utx.Input)
+		}
+		if tx, err = types.SignTx(tx, signer, key); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(blob, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	blob, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	blob = append(blob, '\n')
+	switch path {
+	case "stdout":
+		_, err = os.Stdout.Write(blob)
+	case "stderr":
+		_, err = os.Stderr.Write(blob)
+	default:
+		err = ioutil.WriteFile(path, blob, 0644)
+	}
+	return err
+}
+
+// forkConfig returns the chain configuration of the named fork.
+func forkConfig(fork string) (*params.ChainConfig, error) {
+	config, ok := tests.Forks[fork]
+	if !ok {
+		return nil, tests.UnsupportedForkError{Name: fork}
+	}
+	return config, nil
+}
@@ -58,7 +58,10 @@ func (l *JSONLogger) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cos
 		log.Memory = memory.Data()
 	}
 	if !l.cfg.DisableStack {
-		log.Stack = stack.Data()
+		log.Stack = make([]*big.Int, len(stack.Data()))
+		for i, item := range stack.Data() {
+			log.Stack[i] = item.ToBig()
+		}
 	}
 	return l.encoder.Encode(log)
 }
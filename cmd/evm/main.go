@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"os"
 
+	"github.com/ethereum/go-ethereum/cmd/evm/internal/t8ntool"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -110,8 +111,42 @@ var (
 		Name:  "nostack",
 		Usage: "disable stack output",
 	}
+	ProfileFlag = cli.StringFlag{
+		Name:  "profile",
+		Usage: "prints a gas profile and writes its call stacks in flamegraph folded format to the given path",
+	}
+	ArtifactsFlag = cli.StringFlag{
+		Name:  "artifacts",
+		Usage: "solc --combined-json output (bin,bin-runtime,srcmap,srcmap-runtime) used to print a Solidity stack trace on failure",
+	}
 )
 
+var transitionCommand = cli.Command{
+	Name:    "transition",
+	Aliases: []string{"t8n"},
+	Usage:   "executes a full state transition",
+	Action:  t8ntool.Main,
+	Flags: []cli.Flag{
+		t8ntool.InputAllocFlag,
+		t8ntool.InputEnvFlag,
+		t8ntool.InputTxsFlag,
+		t8ntool.OutputAllocFlag,
+		t8ntool.OutputResultFlag,
+		t8ntool.ForkFlag,
+	},
+}
+
+var fillCommand = cli.Command{
+	Name:      "fill",
+	Usage:     "fills the expected post states of a state test filler",
+	ArgsUsage: "<file>",
+	Action:    t8ntool.Fill,
+	Flags: []cli.Flag{
+		t8ntool.FillForksFlag,
+		t8ntool.OutputTestFlag,
+	},
+}
+
 func init() {
 	app.Flags = []cli.Flag{
 		CreateFlag,
@@ -133,12 +168,17 @@ func init() {
 		ReceiverFlag,
 		DisableMemoryFlag,
 		DisableStackFlag,
+		ProfileFlag,
+		ArtifactsFlag,
 	}
 	app.Commands = []cli.Command{
+		benchCommand,
 		compileCommand,
 		disasmCommand,
 		runCommand,
 		stateTestCommand,
+		transitionCommand,
+		fillCommand,
 	}
 }
 
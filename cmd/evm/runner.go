@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	ethtracers "github.com/ethereum/go-ethereum/eth/tracers"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -96,6 +97,19 @@ func runCmd(ctx *cli.Context) error {
 	} else {
 		debugLogger = vm.NewStructLogger(logconfig)
 	}
+	var (
+		tracers       = []vm.Tracer{tracer}
+		failureTracer *vm.FailureTracer
+		profiler      *vm.GasProfiler
+	)
+	if ctx.GlobalString(ArtifactsFlag.Name) != "" {
+		failureTracer = vm.NewFailureTracer()
+		tracers = append(tracers, failureTracer)
+	}
+	if ctx.GlobalString(ProfileFlag.Name) != "" {
+		profiler = vm.NewGasProfiler()
+		tracers = append(tracers, profiler)
+	}
 	if ctx.GlobalString(GenesisFlag.Name) != "" {
 		gen := readGenesis(ctx.GlobalString(GenesisFlag.Name))
 		db := ethdb.NewMemDatabase()
@@ -164,8 +178,8 @@ func runCmd(ctx *cli.Context) error {
 		Value:       utils.GlobalBig(ctx, ValueFlag.Name),
 		BlockNumber: new(big.Int).SetUint64(blockNumber),
 		EVMConfig: vm.Config{
-			Tracer: tracer,
-			Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name),
+			Tracer: newTracerMux(tracers...),
+			Debug:  ctx.GlobalBool(DebugFlag.Name) || ctx.GlobalBool(MachineFlag.Name) || len(tracers) > 1,
 		},
 	}
 
@@ -225,6 +239,21 @@ func runCmd(ctx *cli.Context) error {
 		vm.WriteLogs(os.Stderr, statedb.Logs())
 	}
 
+	if profiler != nil {
+		if perr := writeGasProfile(ctx.GlobalString(ProfileFlag.Name), profiler.Profile()); perr != nil {
+			fmt.Fprintf(os.Stderr, "could not write gas profile: %v\n", perr)
+		}
+	}
+
+	if failureTracer != nil && err != nil {
+		mapper, merr := loadSourceMapper(ctx.GlobalString(ArtifactsFlag.Name))
+		if merr != nil {
+			fmt.Fprintf(os.Stderr, "could not load artifacts: %v\n", merr)
+		}
+		fmt.Fprintln(os.Stderr, "#### STACK TRACE ####")
+		fmt.Fprint(os.Stderr, ethtracers.NewStackTrace(failureTracer, mapper))
+	}
+
 	if ctx.GlobalBool(StatDumpFlag.Name) {
 		var mem goruntime.MemStats
 		goruntime.ReadMemStats(&mem)
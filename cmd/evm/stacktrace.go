@@ -0,0 +1,55 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// loadSourceMapper reads a solc --combined-json artifact along with the source
+// files it lists. Sources are looked up relative to the working directory
+// first and to the directory of the artifact second.
+func loadSourceMapper(path string) (*compiler.SourceMapper, error) {
+	artifact, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var output struct {
+		SourceList []string `json:"sourceList"`
+	}
+	if err := json.Unmarshal(artifact, &output); err != nil {
+		return nil, err
+	}
+	sources := make(map[string]string)
+	for _, name := range output.SourceList {
+		content, err := ioutil.ReadFile(name)
+		if err != nil && !filepath.IsAbs(name) {
+			content, err = ioutil.ReadFile(filepath.Join(filepath.Dir(path), name))
+		}
+		if err != nil {
+			log.Warn("Solidity source unavailable, omitting line numbers", "file", name, "err", err)
+			continue
+		}
+		sources[name] = string(content)
+	}
+	return compiler.NewSourceMapper([][]byte{artifact}, sources)
+}
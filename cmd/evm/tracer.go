@@ -0,0 +1,135 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// tracerMux is an EVM tracer forwarding every event to a list of tracers, so
+// that trace logs, stack traces and profiles can be collected in a single run.
+type tracerMux []vm.Tracer
+
+// newTracerMux combines the given tracers, skipping nil ones. It returns nil if
+// no tracer remains and the tracer itself if only one remains.
+func newTracerMux(tracers ...vm.Tracer) vm.Tracer {
+	var mux tracerMux
+	for _, tracer := range tracers {
+		if tracer != nil {
+			mux = append(mux, tracer)
+		}
+	}
+	switch len(mux) {
+	case 0:
+		return nil
+	case 1:
+		return mux[0]
+	}
+	return mux
+}
+
+func (mux tracerMux) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	for _, tracer := range mux {
+		if err := tracer.CaptureStart(from, to, create, input, gas, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mux tracerMux) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	for _, tracer := range mux {
+		if err := tracer.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mux tracerMux) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	for _, tracer := range mux {
+		if err := tracer.CaptureFault(env, pc, op, gas, cost, memory, stack, contract, depth, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mux tracerMux) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	for _, tracer := range mux {
+		if err := tracer.CaptureEnd(output, gasUsed, t, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGasProfile prints a summary of the gas profile to stderr and writes its
+// folded call stacks to the given path.
+func writeGasProfile(path string, profile *vm.GasProfile) error {
+	fmt.Fprintln(os.Stderr, "#### GAS PROFILE ####")
+	fmt.Fprintf(os.Stderr, "gas used: %d, refund: %d\n", profile.GasUsed, profile.Refund)
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CONTRACT\tFUNCTION\tCALLS\tGAS\tREFUND")
+	addrs := make([]common.Address, 0, len(profile.Contracts))
+	for addr := range profile.Contracts {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return profile.Contracts[addrs[i]].Gas > profile.Contracts[addrs[j]].Gas })
+	for _, addr := range addrs {
+		contract := profile.Contracts[addr]
+		fmt.Fprintf(w, "%x\t*\t%d\t%d\t%d\n", addr, contract.Count, contract.Gas, contract.Refund)
+
+		selectors := make([]string, 0, len(contract.Functions))
+		for selector := range contract.Functions {
+			selectors = append(selectors, selector)
+		}
+		sort.Strings(selectors)
+		for _, selector := range selectors {
+			fn := contract.Functions[selector]
+			fmt.Fprintf(w, "\t%s\t%d\t%d\t%d\n", selector, fn.Count, fn.Gas, fn.Refund)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "OPCODE\tCOUNT\tGAS\tREFUND")
+	ops := make([]string, 0, len(profile.Opcodes))
+	for op := range profile.Opcodes {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return profile.Opcodes[ops[i]].Gas > profile.Opcodes[ops[j]].Gas })
+	for _, op := range ops {
+		stat := profile.Opcodes[op]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", op, stat.Count, stat.Gas, stat.Refund)
+	}
+	w.Flush()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return profile.WriteFolded(f)
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/console"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
@@ -36,7 +37,6 @@ import (
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/trie"
-	"github.com/syndtr/goleveldb/leveldb/util"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -48,6 +48,7 @@ var (
 		ArgsUsage: "<genesisPath>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -64,11 +65,15 @@ It expects the genesis file as argument.`,
 		ArgsUsage: "<filename> (<filename 2> ... <filename N>) ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.GCModeFlag,
+			utils.NoSnapshotFlag,
+			utils.TxLookupLimitFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
+			utils.CacheJournalFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -85,6 +90,7 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 		ArgsUsage: "<filename> [<blockNumFirst> <blockNumLast>]",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -95,6 +101,54 @@ Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
 be gzipped.`,
+	}
+	importArchiveCommand = cli.Command{
+		Action:    utils.MigrateFlags(importArchive),
+		Name:      "import-archive",
+		Usage:     "Import a blockchain archive directory",
+		ArgsUsage: "<dir>",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			utils.GCModeFlag,
+			utils.NoSnapshotFlag,
+			utils.TxLookupLimitFlag,
+			utils.CacheDatabaseFlag,
+			utils.CacheGCFlag,
+			utils.CacheJournalFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The import-archive command imports the blocks of an archive created by export-archive.
+The checksums of the archive chunks, along with the receipts and total difficulty of
+every block are verified before the blocks are processed.
+
+Blocks already present in the chain are skipped, so an interrupted import can be resumed
+by running the command again.`,
+	}
+	exportArchiveCommand = cli.Command{
+		Action:    utils.MigrateFlags(exportArchive),
+		Name:      "export-archive",
+		Usage:     "Export blockchain into an archive directory",
+		ArgsUsage: "<dir> [<blockNumFirst> <blockNumLast>]",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.DBEngineFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The export-archive command writes the headers, bodies, receipts and total difficulties
+of the blocks into a directory of indexed and checksummed chunk files, giving random
+access to any block by number.
+
+Requires a first argument of the directory to write to. Optional second and third
+arguments control the first and last block to write, the whole chain is exported by
+default. Intact chunks left over by a previous export are kept, so an interrupted
+export can be resumed by running the command again.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -103,6 +157,7 @@ be gzipped.`,
 		ArgsUsage: "<datafile>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -117,6 +172,7 @@ be gzipped.`,
 		ArgsUsage: "<dumpfile>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -131,6 +187,7 @@ The export-preimages command export hash preimages to an RLP encoded stream`,
 		ArgsUsage: "<sourceChaindataDir>",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 			utils.FakePoWFlag,
@@ -148,6 +205,8 @@ The first argument must be the directory containing the blockchain to download f
 		ArgsUsage: " ",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.DBEngineFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -160,6 +219,7 @@ Remove blockchain and state databases`,
 		ArgsUsage: "[<blockHash> | <blockNum>]...",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -175,6 +235,7 @@ Use "ethereum dump 0" to dump the genesis block.`,
 		ArgsUsage: "[<blockHash> | <blockNum>]...",
 		Flags: []cli.Flag{
 			utils.DataDirFlag,
+			utils.DBEngineFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
 		},
@@ -260,19 +321,7 @@ func importChain(ctx *cli.Context) error {
 	fmt.Printf("Import done in %v.\n\n", time.Since(start))
 
 	// Output pre-compaction stats mostly to see the import trashing
-	db := chainDb.(*ethdb.LDBDatabase)
-
-	stats, err := db.LDB().GetProperty("leveldb.stats")
-	if err != nil {
-		utils.Fatalf("Failed to read database stats: %v", err)
-	}
-	fmt.Println(stats)
-
-	ioStats, err := db.LDB().GetProperty("leveldb.iostats")
-	if err != nil {
-		utils.Fatalf("Failed to read database iostats: %v", err)
-	}
-	fmt.Println(ioStats)
+	showDatabaseStats(chainDb)
 
 	fmt.Printf("Trie cache misses:  %d\n", trie.CacheMisses())
 	fmt.Printf("Trie cache unloads: %d\n\n", trie.CacheUnloads())
@@ -293,24 +342,27 @@ func importChain(ctx *cli.Context) error {
 	// Compact the entire database to more accurately measure disk io and print the stats
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = db.LDB().CompactRange(util.Range{}); err != nil {
+	if err := chainDb.Compact(nil, nil); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
 
-	stats, err = db.LDB().GetProperty("leveldb.stats")
+	showDatabaseStats(chainDb)
+	return nil
+}
+
+// showDatabaseStats prints the general stats of a database, along with the io
+// stats if the storage engine collects them.
+func showDatabaseStats(db ethdb.Stater) {
+	stats, err := db.Stat("stats")
 	if err != nil {
 		utils.Fatalf("Failed to read database stats: %v", err)
 	}
 	fmt.Println(stats)
 
-	ioStats, err = db.LDB().GetProperty("leveldb.iostats")
-	if err != nil {
-		utils.Fatalf("Failed to read database iostats: %v", err)
+	if ioStats, err := db.Stat("iostats"); err == nil {
+		fmt.Println(ioStats)
 	}
-	fmt.Println(ioStats)
-
-	return nil
 }
 
 func exportChain(ctx *cli.Context) error {
@@ -346,12 +398,57 @@ func exportChain(ctx *cli.Context) error {
 }
 
 // importPreimages imports preimage data from the specified file.
+func importArchive(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	err := utils.ImportArchive(chain, ctx.Args().First())
+	chain.Stop()
+
+	if err != nil {
+		utils.Fatalf("Import error: %v", err)
+	}
+	fmt.Printf("Import done in %v\n", time.Since(start))
+	return nil
+}
+
+func exportArchive(ctx *cli.Context) error {
+	if len(ctx.Args()) < 1 {
+		utils.Fatalf("This command requires an argument.")
+	}
+	stack := makeFullNode(ctx)
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	first, last := uint64(0), chain.CurrentBlock().NumberU64()
+	if len(ctx.Args()) >= 3 {
+		var ferr, lerr error
+		first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+		last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+		if ferr != nil || lerr != nil {
+			utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+		}
+	}
+	start := time.Now()
+	if err := utils.ExportArchive(chain, ctx.Args().First(), first, last); err != nil {
+		utils.Fatalf("Export error: %v\n", err)
+	}
+	fmt.Printf("Export done in %v\n", time.Since(start))
+	return nil
+}
+
 func importPreimages(ctx *cli.Context) error {
 	if len(ctx.Args()) < 1 {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*ethdb.LDBDatabase)
+	diskdb := rawdb.KeyValueStore(utils.MakeChainDatabase(ctx, stack))
 
 	start := time.Now()
 	if err := utils.ImportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -367,7 +464,7 @@ func exportPreimages(ctx *cli.Context) error {
 		utils.Fatalf("This command requires an argument.")
 	}
 	stack := makeFullNode(ctx)
-	diskdb := utils.MakeChainDatabase(ctx, stack).(*ethdb.LDBDatabase)
+	diskdb := rawdb.KeyValueStore(utils.MakeChainDatabase(ctx, stack))
 
 	start := time.Now()
 	if err := utils.ExportPreimages(diskdb, ctx.Args().First()); err != nil {
@@ -417,7 +514,7 @@ func copyDb(ctx *cli.Context) error {
 	// Compact the entire database to remove any sync overhead
 	start = time.Now()
 	fmt.Println("Compacting entire database...")
-	if err = chainDb.(*ethdb.LDBDatabase).LDB().CompactRange(util.Range{}); err != nil {
+	if err = chainDb.Compact(nil, nil); err != nil {
 		utils.Fatalf("Compaction failed: %v", err)
 	}
 	fmt.Printf("Compaction done in %v.\n\n", time.Since(start))
@@ -428,7 +525,11 @@ func copyDb(ctx *cli.Context) error {
 func removeDB(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 
-	for _, name := range []string{"chaindata", "lightchaindata"} {
+	names := []string{"chaindata", "lightchaindata"}
+	if ancient := ctx.GlobalString(utils.AncientFlag.Name); ancient != "" {
+		names = append(names, ancient) // Ancient chain segments outside of chaindata
+	}
+	for _, name := range names {
 		// Ensure the database exists in the first place
 		logger := log.New("database", name)
 
@@ -0,0 +1,236 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	dbFlags = []cli.Flag{
+		utils.DataDirFlag,
+		utils.AncientFlag,
+		utils.DBEngineFlag,
+		utils.CacheFlag,
+		utils.SyncModeFlag,
+	}
+	dbCommand = cli.Command{
+		Name:     "db",
+		Usage:    "Low level chain database operations",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Offline inspection and manual editing of the chain database. The node must not
+be running.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "inspect",
+				Usage:     "Show the size of every category of chain data",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(inspectDB),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags:     dbFlags,
+				Description: `
+    geth db inspect
+
+walks the entire chain database and reports the number of entries and the space
+taken by every category of data, classified by the database schema. Entries of
+no known format are reported as unaccounted.`,
+			},
+			{
+				Name:      "get",
+				Usage:     "Show the value of a database key",
+				ArgsUsage: "<hex-key>",
+				Action:    utils.MigrateFlags(dbGet),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags:     dbFlags,
+			},
+			{
+				Name:      "put",
+				Usage:     "Set the value of a database key",
+				ArgsUsage: "<hex-key> <hex-value>",
+				Action:    utils.MigrateFlags(dbPut),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags:     dbFlags,
+				Description: `
+    geth db put <hex-key> <hex-value>
+
+overwrites the value of a key of the chain database. Use with care, wrong values
+can corrupt the database.`,
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete a database key",
+				ArgsUsage: "<hex-key>",
+				Action:    utils.MigrateFlags(dbDelete),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags:     dbFlags,
+				Description: `
+    geth db delete <hex-key>
+
+removes a key from the chain database. Use with care, missing entries can corrupt
+the database.`,
+			},
+			{
+				Name:      "repair",
+				Usage:     "Rewind the head block to the newest one with complete state",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(repairDB),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags:     append(dbFlags, utils.CacheJournalFlag),
+				Description: `
+    geth db repair
+
+searches the chain backwards from the head block for the newest block whose state
+is complete, either on disk or in the trie cache journal, and makes it the head
+block. The blocks above it are kept and reprocessed on the next start.`,
+			},
+		},
+	}
+)
+
+// inspectDB prints the number and size of the entries of every category of
+// chain data.
+func inspectDB(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	stats, err := rawdb.InspectDatabase(chainDb)
+	if err != nil {
+		utils.Fatalf("Database inspection failed: %v", err)
+	}
+	var (
+		table = tablewriter.NewWriter(os.Stdout)
+		count uint64
+		size  common.StorageSize
+	)
+	table.SetHeader([]string{"Category", "Entries", "Size"})
+	table.SetAutoFormatHeaders(false)
+	for _, stat := range stats {
+		table.Append([]string{stat.Category, fmt.Sprint(stat.Count), stat.Size.String()})
+		count += stat.Count
+		size += stat.Size
+	}
+	table.SetFooter([]string{"Total", fmt.Sprint(count), size.String()})
+	table.Render()
+	return nil
+}
+
+// parseHexArg decodes a hex encoded command line argument.
+func parseHexArg(arg string) []byte {
+	blob, err := hexutil.Decode(arg)
+	if err != nil {
+		utils.Fatalf("Invalid hex argument %q: %v", arg, err)
+	}
+	return blob
+}
+
+// dbGet prints the value of a database key.
+func dbGet(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires exactly one argument")
+	}
+	key := parseHexArg(ctx.Args().First())
+
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	value, err := chainDb.Get(key)
+	if err != nil {
+		utils.Fatalf("Failed to read key %#x: %v", key, err)
+	}
+	fmt.Println(hexutil.Encode(value))
+	return nil
+}
+
+// dbPut sets the value of a database key.
+func dbPut(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		utils.Fatalf("This command requires exactly two arguments")
+	}
+	key, value := parseHexArg(ctx.Args().Get(0)), parseHexArg(ctx.Args().Get(1))
+
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	if old, err := chainDb.Get(key); err == nil {
+		fmt.Printf("Previous value: %s\n", hexutil.Encode(old))
+	}
+	if err := chainDb.Put(key, value); err != nil {
+		utils.Fatalf("Failed to write key %#x: %v", key, err)
+	}
+	return nil
+}
+
+// dbDelete removes a database key.
+func dbDelete(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		utils.Fatalf("This command requires exactly one argument")
+	}
+	key := parseHexArg(ctx.Args().First())
+
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	if old, err := chainDb.Get(key); err == nil {
+		fmt.Printf("Previous value: %s\n", hexutil.Encode(old))
+	}
+	if err := chainDb.Delete(key); err != nil {
+		utils.Fatalf("Failed to delete key %#x: %v", key, err)
+	}
+	return nil
+}
+
+// repairDB rewinds the head block to the newest block with complete state and
+// reports the blocks rolled back.
+func repairDB(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	var journal string
+	if path := ctx.GlobalString(utils.CacheJournalFlag.Name); path != "" {
+		journal = stack.ResolvePath(path)
+	}
+	repair, err := core.RepairHead(chainDb, journal)
+	if err != nil {
+		utils.Fatalf("Repair failed: %v", err)
+	}
+	head, repaired := repair.Head, repair.Repaired
+	if head.Hash() == repaired.Hash() {
+		fmt.Printf("Head block #%d [%x] has complete state, nothing to roll back\n", head.Number, head.Hash())
+	} else {
+		fmt.Printf("Rewound head block from #%d [%x] to #%d [%x], %d blocks rolled back\n",
+			head.Number, head.Hash(), repaired.Number, repaired.Hash(), head.Number.Uint64()-repaired.Number.Uint64())
+	}
+	if repair.Restored {
+		fmt.Println("State of the new head restored from the trie cache journal")
+	}
+	return nil
+}
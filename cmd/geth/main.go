@@ -60,6 +60,8 @@ var (
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
 		utils.DataDirFlag,
+		utils.AncientFlag,
+		utils.DBEngineFlag,
 		utils.KeyStoreDirFlag,
 		utils.NoUSBFlag,
 		utils.DashboardEnabledFlag,
@@ -75,6 +77,7 @@ var (
 		utils.TxPoolLocalsFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
+		utils.TxPoolPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
@@ -83,14 +86,21 @@ var (
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolAllowFlag,
+		utils.TxPoolDenyFlag,
 		utils.SyncModeFlag,
 		utils.GCModeFlag,
+		utils.NoSnapshotFlag,
+		utils.TxLookupLimitFlag,
+		utils.SnapSyncFlag,
 		utils.LightServFlag,
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheGCFlag,
+		utils.CacheJournalFlag,
+		utils.CacheRejournalFlag,
 		utils.TrieCacheGenFlag,
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
@@ -110,6 +120,8 @@ var (
 		utils.MinerLegacyExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerTxOrderFlag,
+		utils.MinerPriorityFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -118,6 +130,8 @@ var (
 		utils.NodeKeyHexFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
+		utils.ForkURLFlag,
+		utils.ForkBlockFlag,
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.VMEnableDebugFlag,
@@ -174,11 +188,17 @@ func init() {
 		initCommand,
 		importCommand,
 		exportCommand,
+		importArchiveCommand,
+		exportArchiveCommand,
 		importPreimagesCommand,
 		exportPreimagesCommand,
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		// See snapshot.go:
+		snapshotCommand,
+		// See dbcmd.go:
+		dbCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
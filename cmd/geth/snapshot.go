@@ -0,0 +1,128 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/trie"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "Manage the state data of the chain database",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+Offline maintenance of the state data of the chain database. The node must not
+be running.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "prune-state",
+				Usage:     "Delete the state data unreachable from the recent blocks",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(pruneState),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.DBEngineFlag,
+					utils.CacheFlag,
+					utils.PruneRetainFlag,
+				},
+				Description: `
+    geth snapshot prune-state [--prune.retain <blocks>]
+
+deletes all the state trie nodes and contract code that are not reachable from
+the states of the given number of most recent canonical blocks. If none of these
+states is on disk, the most recent older one is kept instead.
+
+The kept states are recorded before anything is deleted. If the pruning gets
+interrupted, it is finished when the node starts up next.`,
+			},
+			{
+				Name:      "verify-state",
+				Usage:     "Check the flat state snapshot against the state trie",
+				ArgsUsage: "[<root>]",
+				Action:    utils.MigrateFlags(verifyState),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.DBEngineFlag,
+					utils.CacheFlag,
+				},
+				Description: `
+    geth snapshot verify-state [<root>]
+
+checks that the flat state snapshot on disk holds exactly the accounts and
+storage slots of the state trie of the given root, which defaults to the root
+the snapshot was last flattened into.`,
+			},
+		},
+	}
+)
+
+// pruneState deletes the state data unreachable from the recent blocks.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	start := time.Now()
+	if err := pruner.Prune(chainDb, ctx.Uint64(utils.PruneRetainFlag.Name)); err != nil {
+		utils.Fatalf("State pruning failed: %v", err)
+	}
+	fmt.Printf("State pruning done in %v\n", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// verifyState checks the flat state snapshot against the state trie.
+func verifyState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	root := rawdb.ReadSnapshotRoot(chainDb)
+	if ctx.NArg() > 1 {
+		utils.Fatalf("This command accepts at most one argument")
+	}
+	if ctx.NArg() == 1 {
+		blob, err := hexutil.Decode(ctx.Args().First())
+		if err != nil || len(blob) != common.HashLength {
+			utils.Fatalf("Invalid state root %q", ctx.Args().First())
+		}
+		root = common.BytesToHash(blob)
+	}
+	if root == (common.Hash{}) {
+		utils.Fatalf("No state snapshot found")
+	}
+	start := time.Now()
+	if err := snapshot.VerifyState(chainDb, trie.NewDatabase(chainDb), root); err != nil {
+		utils.Fatalf("State snapshot verification failed: %v", err)
+	}
+	fmt.Printf("State snapshot of %x verified in %v\n", root, common.PrettyDuration(time.Since(start)))
+	return nil
+}
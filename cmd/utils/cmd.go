@@ -29,6 +29,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/blockarchive"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -237,8 +238,44 @@ func ExportAppendChain(blockchain *core.BlockChain, fn string, first uint64, las
 	return nil
 }
 
+// ImportArchive imports the blocks of a chain archive directory. The import stops
+// at the next batch if interrupted, and can be resumed by running it again.
+func ImportArchive(chain *core.BlockChain, dir string) error {
+	stop, release := watchInterrupt("Interrupted during import, stopping at next batch")
+	defer release()
+
+	return blockarchive.Import(chain, dir, stop)
+}
+
+// ExportArchive exports the blocks in the range [first, last] into a chain archive
+// directory, reusing the intact chunks left over by a previous export.
+func ExportArchive(chain *core.BlockChain, dir string, first uint64, last uint64) error {
+	stop, release := watchInterrupt("Interrupted during export, stopping at next chunk")
+	defer release()
+
+	return blockarchive.Export(chain, dir, first, last, stop)
+}
+
+// watchInterrupt returns a channel closed on Ctrl-C, logging the given message,
+// along with a function to stop watching.
+func watchInterrupt(msg string) (chan struct{}, func()) {
+	interrupt := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info(msg)
+		}
+		close(stop)
+	}()
+	return stop, func() {
+		signal.Stop(interrupt)
+		close(interrupt)
+	}
+}
+
 // ImportPreimages imports a batch of exported hash preimages into the database.
-func ImportPreimages(db *ethdb.LDBDatabase, fn string) error {
+func ImportPreimages(db ethdb.Database, fn string) error {
 	log.Info("Importing preimages", "file", fn)
 
 	// Open the file handle and potentially unwrap the gzip stream
@@ -285,7 +322,7 @@ func ImportPreimages(db *ethdb.LDBDatabase, fn string) error {
 
 // ExportPreimages exports all known hash preimages into the specified file,
 // truncating any data already present in the file.
-func ExportPreimages(db *ethdb.LDBDatabase, fn string) error {
+func ExportPreimages(db ethdb.Database, fn string) error {
 	log.Info("Exporting preimages", "file", fn)
 
 	// Open the file handle and potentially wrap with a gzip stream
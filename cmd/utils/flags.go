@@ -81,6 +81,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/consensus/thunder"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -94,6 +95,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/metrics/influxdb"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discover"
@@ -164,6 +166,15 @@ var (
 		Usage: "Data directory for the databases and keystore",
 		Value: DirectoryString{node.DefaultDataDir()},
 	}
+	AncientFlag = DirectoryFlag{
+		Name:  "datadir.ancient",
+		Usage: "Data directory for ancient chain segments (default = inside chaindata)",
+	}
+	DBEngineFlag = cli.StringFlag{
+		Name:  "db.engine",
+		Usage: "Storage engine of the databases (" + strings.Join(ethdb.Engines(), ", ") + ")",
+		Value: ethdb.DefaultEngine,
+	}
 	KeyStoreDirFlag = DirectoryFlag{
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
@@ -193,6 +204,14 @@ var (
 		Name:  "dev.period",
 		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
+	ForkURLFlag = cli.StringFlag{
+		Name:  "fork.url",
+		Usage: "RPC endpoint of the node to fork the state of in developer mode",
+	}
+	ForkBlockFlag = cli.Uint64Flag{
+		Name:  "fork.block",
+		Usage: "Block to fork the state at in developer mode (0 = latest)",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -213,6 +232,23 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	NoSnapshotFlag = cli.BoolFlag{
+		Name:  "nosnapshot",
+		Usage: "Disables the flat state snapshot, reading all state from the trie",
+	}
+	TxLookupLimitFlag = cli.Uint64Flag{
+		Name:  "txlookuplimit",
+		Usage: "Number of recent blocks to keep the transaction lookups of (default = all blocks)",
+	}
+	SnapSyncFlag = cli.BoolFlag{
+		Name:  "snapsync",
+		Usage: "Retrieves the state during fast sync as snapshot ranges instead of trie nodes",
+	}
+	PruneRetainFlag = cli.Uint64Flag{
+		Name:  "prune.retain",
+		Usage: "Number of recent block states kept by state pruning",
+		Value: 128,
+	}
 	LightServFlag = cli.IntFlag{
 		Name:  "lightserv",
 		Usage: "Maximum percentage of time allowed for serving LES requests (0-90)",
@@ -291,6 +327,10 @@ var (
 		Usage: "Disk journal for local transaction to survive node restarts",
 		Value: core.DefaultTxPoolConfig.Journal,
 	}
+	TxPoolPoolJournalFlag = cli.StringFlag{
+		Name:  "txpool.pooljournal",
+		Usage: "Disk journal for the full pool, remote transactions included, to survive node restarts",
+	}
 	TxPoolRejournalFlag = cli.DurationFlag{
 		Name:  "txpool.rejournal",
 		Usage: "Time interval to regenerate the local transaction journal",
@@ -331,6 +371,14 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: eth.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolAllowFlag = cli.StringFlag{
+		Name:  "txpool.allow",
+		Usage: "Comma separated accounts whose transactions are the only ones admitted into the pool",
+	}
+	TxPoolDenyFlag = cli.StringFlag{
+		Name:  "txpool.deny",
+		Usage: "Comma separated accounts whose transactions, sent by or to them, are refused by the pool",
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -347,6 +395,16 @@ var (
 		Usage: "Percentage of cache memory allowance to use for trie pruning",
 		Value: 25,
 	}
+	CacheJournalFlag = cli.StringFlag{
+		Name:  "cache.journal",
+		Usage: "Disk journal for the in-memory trie cache to survive node crashes and restarts",
+		Value: eth.DefaultConfig.TrieJournal,
+	}
+	CacheRejournalFlag = cli.DurationFlag{
+		Name:  "cache.rejournal",
+		Usage: "Time interval to regenerate the trie cache journal (0 = only on shutdown)",
+		Value: eth.DefaultConfig.TrieRejournal,
+	}
 	TrieCacheGenFlag = cli.IntFlag{
 		Name:  "trie-cache-gens",
 		Usage: "Number of trie node generations to keep in memory",
@@ -423,6 +481,15 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerTxOrderFlag = cli.StringFlag{
+		Name:  "miner.txorder",
+		Usage: `Ordering of the transactions of different senders in mined blocks ("price", "fifo" or "price-fifo")`,
+		Value: miner.TxOrderPrice,
+	}
+	MinerPriorityFlag = cli.StringFlag{
+		Name:  "miner.priority",
+		Usage: "Comma separated accounts whose transactions are mined first, in the listed order",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -1018,6 +1085,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 		cfg.DataDir = filepath.Join(node.DefaultDataDir(), "rinkeby")
 	}
 
+	if ctx.GlobalIsSet(DBEngineFlag.Name) {
+		cfg.DBEngine = ctx.GlobalString(DBEngineFlag.Name)
+	}
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
@@ -1055,6 +1125,9 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolJournalFlag.Name) {
 		cfg.Journal = ctx.GlobalString(TxPoolJournalFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolPoolJournalFlag.Name) {
+		cfg.PoolJournal = ctx.GlobalString(TxPoolPoolJournalFlag.Name)
+	}
 	if ctx.GlobalIsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.GlobalDuration(TxPoolRejournalFlag.Name)
 	}
@@ -1079,6 +1152,27 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolAllowFlag.Name) || ctx.GlobalIsSet(TxPoolDenyFlag.Name) {
+		allow := splitAccounts(ctx, TxPoolAllowFlag.Name)
+		deny := splitAccounts(ctx, TxPoolDenyFlag.Name)
+		cfg.Policies = append(cfg.Policies, core.NewAccountPolicy(allow, deny))
+	}
+}
+
+// splitAccounts parses the comma separated accounts of a flag.
+func splitAccounts(ctx *cli.Context, name string) []common.Address {
+	if !ctx.GlobalIsSet(name) {
+		return nil
+	}
+	var accounts []common.Address
+	for _, account := range strings.Split(ctx.GlobalString(name), ",") {
+		trimmed := strings.TrimSpace(account)
+		if !common.IsHexAddress(trimmed) {
+			Fatalf("Invalid account in --%s: %s", name, trimmed)
+		}
+		accounts = append(accounts, common.HexToAddress(trimmed))
+	}
+	return accounts
 }
 
 func setEthash(ctx *cli.Context, cfg *eth.Config) {
@@ -1178,6 +1272,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		cfg.NetworkId = ctx.GlobalUint64(NetworkIdFlag.Name)
 	}
 
+	if ctx.GlobalIsSet(AncientFlag.Name) {
+		cfg.DatabaseFreezer = ctx.GlobalString(AncientFlag.Name)
+	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheDatabaseFlag.Name) {
 		cfg.DatabaseCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheDatabaseFlag.Name) / 100
 	}
@@ -1187,10 +1284,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
 	}
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
+	cfg.NoSnapshot = ctx.GlobalBool(NoSnapshotFlag.Name)
+	cfg.TxLookupLimit = ctx.GlobalUint64(TxLookupLimitFlag.Name)
+	cfg.SnapSync = ctx.GlobalBool(SnapSyncFlag.Name)
 
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalIsSet(CacheJournalFlag.Name) {
+		cfg.TrieJournal = ctx.GlobalString(CacheJournalFlag.Name)
+	}
+	if ctx.GlobalIsSet(CacheRejournalFlag.Name) {
+		cfg.TrieRejournal = ctx.GlobalDuration(CacheRejournalFlag.Name)
+	}
 	if ctx.GlobalIsSet(MinerNotifyFlag.Name) {
 		cfg.MinerNotify = strings.Split(ctx.GlobalString(MinerNotifyFlag.Name), ",")
 	}
@@ -1224,6 +1330,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(MinerNoVerfiyFlag.Name) {
 		cfg.MinerNoverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerTxOrderFlag.Name) {
+		cfg.MinerTxOrder = ctx.GlobalString(MinerTxOrderFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerPriorityFlag.Name) {
+		cfg.MinerPriority = splitAccounts(ctx, MinerPriorityFlag.Name)
+	}
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
 		// TODO(fjl): force-enable this in --dev mode
 		cfg.EnablePreimageRecording = ctx.GlobalBool(VMEnableDebugFlag.Name)
@@ -1267,6 +1379,13 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		if !ctx.GlobalIsSet(MinerGasPriceFlag.Name) && !ctx.GlobalIsSet(MinerLegacyGasPriceFlag.Name) {
 			cfg.MinerGasPrice = big.NewInt(1)
 		}
+		if ctx.GlobalIsSet(ForkURLFlag.Name) {
+			cfg.ForkURL = ctx.GlobalString(ForkURLFlag.Name)
+			cfg.ForkBlock = ctx.GlobalUint64(ForkBlockFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(ForkURLFlag.Name) && !ctx.GlobalBool(DeveloperFlag.Name) {
+		Fatalf("Forking is only supported in developer mode (--%s)", DeveloperFlag.Name)
 	}
 	// TODO(fjl): move trie cache generations into config
 	if gen := ctx.GlobalInt(TrieCacheGenFlag.Name); gen > 0 {
@@ -1371,6 +1490,14 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node) ethdb.Database {
 	if err != nil {
 		Fatalf("Could not open database: %v", err)
 	}
+	if name == "lightchaindata" {
+		return chainDb
+	}
+	if freezer := eth.FreezerPath(stack.ResolvePath, name, ctx.GlobalString(AncientFlag.Name)); freezer != "" {
+		if chainDb, err = rawdb.NewDatabaseWithFreezer(chainDb, freezer); err != nil {
+			Fatalf("Could not open ancient database: %v", err)
+		}
+	}
 	return chainDb
 }
 
@@ -1421,10 +1548,16 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		Disabled:      ctx.GlobalString(GCModeFlag.Name) == "archive",
 		TrieNodeLimit: eth.DefaultConfig.TrieCache,
 		TrieTimeLimit: eth.DefaultConfig.TrieTimeout,
+		Snapshot:      !ctx.GlobalBool(NoSnapshotFlag.Name),
+		TxLookupLimit: ctx.GlobalUint64(TxLookupLimitFlag.Name),
+		TrieRejournal: ctx.GlobalDuration(CacheRejournalFlag.Name),
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieNodeLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if journal := ctx.GlobalString(CacheJournalFlag.Name); journal != "" {
+		cache.TrieJournal = stack.ResolvePath(journal)
+	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
 	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg)
 	if err != nil {
@@ -0,0 +1,215 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ExecutionCoverage is the execution coverage of a single bytecode.
+type ExecutionCoverage struct {
+	Code     []byte
+	Hits     map[uint64]uint64    // Execution count by program counter
+	Branches map[uint64][2]uint64 // JUMPI outcomes by program counter: fallthrough, jump
+}
+
+// fileCoverage accumulates the coverage of a single source file.
+type fileCoverage struct {
+	lines     map[int]uint64           // Hits by line number
+	functions map[string]*funcCoverage // Function coverage by name
+	branches  map[int]*branchCoverage  // Branch coverage by line number
+}
+
+type funcCoverage struct {
+	line int
+	hits uint64
+}
+
+type branchCoverage struct {
+	outcomes [][2]uint64
+	reached  []bool
+}
+
+// WriteLCOV maps the given execution coverage through the loaded source maps
+// and writes it as an lcov tracefile. Every contract of the loaded artifacts
+// is reported, including ones that never executed.
+func (m *SourceMapper) WriteLCOV(w io.Writer, coverage []*ExecutionCoverage) error {
+	files := make(map[string]*fileCoverage)
+
+	for _, contract := range m.contracts {
+		for _, mc := range []*mappedCode{contract.runtime, contract.creation} {
+			if mc == nil {
+				continue
+			}
+			var matches []*ExecutionCoverage
+			for _, cov := range coverage {
+				if mc.matches(cov.Code, mc == contract.creation) {
+					matches = append(matches, cov)
+				}
+			}
+			m.accumulate(files, contract, mc, matches)
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		writeFileCoverage(bw, name, files[name])
+	}
+	return bw.Flush()
+}
+
+// accumulate adds the coverage of a single compiled bytecode to the per file
+// coverage records.
+func (m *SourceMapper) accumulate(files map[string]*fileCoverage, contract *mappedContract, mc *mappedCode, matches []*ExecutionCoverage) {
+	pcs := make([]uint64, 0, len(mc.indices))
+	for pc := range mc.indices {
+		pcs = append(pcs, pc)
+	}
+	sort.Slice(pcs, func(i, j int) bool { return pcs[i] < pcs[j] })
+
+	for _, pc := range pcs {
+		index := mc.indices[pc]
+		if index >= len(mc.srcmap) {
+			continue
+		}
+		entry := mc.srcmap[index]
+		if entry.File < 0 || entry.File >= len(contract.sources) {
+			continue
+		}
+		name := contract.sources[entry.File]
+		src, ok := m.sources[name]
+		if !ok {
+			continue
+		}
+		fc, ok := files[name]
+		if !ok {
+			fc = &fileCoverage{
+				lines:     make(map[int]uint64),
+				functions: make(map[string]*funcCoverage),
+				branches:  make(map[int]*branchCoverage),
+			}
+			files[name] = fc
+		}
+		var (
+			hits     uint64
+			outcomes [2]uint64
+		)
+		for _, cov := range matches {
+			hits += cov.Hits[pc]
+			branch := cov.Branches[pc]
+			outcomes[0] += branch[0]
+			outcomes[1] += branch[1]
+		}
+		line, _ := src.line(entry.Start)
+		if hits > fc.lines[line] {
+			fc.lines[line] = hits
+		} else if _, ok := fc.lines[line]; !ok {
+			fc.lines[line] = 0
+		}
+		if span := src.span(entry.Start); span != nil {
+			fn, ok := fc.functions[span.name]
+			if !ok {
+				start, _ := src.line(span.start)
+				fn = &funcCoverage{line: start}
+				fc.functions[span.name] = fn
+			}
+			if hits > fn.hits {
+				fn.hits = hits
+			}
+		}
+		// JUMPI instructions are the branch points of the code
+		if mc.code[pc] == 0x57 {
+			bc, ok := fc.branches[line]
+			if !ok {
+				bc = new(branchCoverage)
+				fc.branches[line] = bc
+			}
+			bc.outcomes = append(bc.outcomes, outcomes)
+			bc.reached = append(bc.reached, hits > 0)
+		}
+	}
+}
+
+// writeFileCoverage writes the lcov record of a single source file.
+func writeFileCoverage(w io.Writer, name string, fc *fileCoverage) {
+	fmt.Fprintf(w, "TN:\nSF:%s\n", name)
+
+	fnames := make([]string, 0, len(fc.functions))
+	for fname := range fc.functions {
+		fnames = append(fnames, fname)
+	}
+	sort.Slice(fnames, func(i, j int) bool {
+		fi, fj := fc.functions[fnames[i]], fc.functions[fnames[j]]
+		return fi.line < fj.line || (fi.line == fj.line && fnames[i] < fnames[j])
+	})
+	fnhit := 0
+	for _, fname := range fnames {
+		fmt.Fprintf(w, "FN:%d,%s\n", fc.functions[fname].line, fname)
+	}
+	for _, fname := range fnames {
+		fmt.Fprintf(w, "FNDA:%d,%s\n", fc.functions[fname].hits, fname)
+		if fc.functions[fname].hits > 0 {
+			fnhit++
+		}
+	}
+	fmt.Fprintf(w, "FNF:%d\nFNH:%d\n", len(fnames), fnhit)
+
+	lines := make([]int, 0, len(fc.lines))
+	for line := range fc.lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+
+	brfound, brhit := 0, 0
+	for _, line := range lines {
+		bc, ok := fc.branches[line]
+		if !ok {
+			continue
+		}
+		for block, outcomes := range bc.outcomes {
+			for branch, taken := range outcomes {
+				brfound++
+				if !bc.reached[block] {
+					fmt.Fprintf(w, "BRDA:%d,%d,%d,-\n", line, block, branch)
+					continue
+				}
+				if taken > 0 {
+					brhit++
+				}
+				fmt.Fprintf(w, "BRDA:%d,%d,%d,%d\n", line, block, branch, taken)
+			}
+		}
+	}
+	fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", brfound, brhit)
+
+	lhit := 0
+	for _, line := range lines {
+		fmt.Fprintf(w, "DA:%d,%d\n", line, fc.lines[line])
+		if fc.lines[line] > 0 {
+			lhit++
+		}
+	}
+	fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", len(lines), lhit)
+}
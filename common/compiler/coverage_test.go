@@ -0,0 +1,80 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestWriteLCOV(t *testing.T) {
+	var (
+		fn     = strings.Index(sourceMapTestSource, "function transfer")
+		req    = strings.Index(sourceMapTestSource, "require")
+		srcmap = fmt.Sprintf("%d:80:0:-;;%d:37:0:-;-1:0:-1:-", fn, req)
+	)
+	artifact := `{
+		"contracts": {
+			"token.sol:Token": {"bin-runtime": "600060015700", "srcmap-runtime": "` + srcmap + `"}
+		},
+		"sourceList": ["token.sol"]
+	}`
+	mapper, err := NewSourceMapper([][]byte{[]byte(artifact)}, map[string]string{"token.sol": sourceMapTestSource})
+	if err != nil {
+		t.Fatal(err)
+	}
+	coverage := []*ExecutionCoverage{{
+		Code:     common.Hex2Bytes("600060015700"),
+		Hits:     map[uint64]uint64{0: 2, 2: 2, 4: 2, 5: 2},
+		Branches: map[uint64][2]uint64{4: {2, 0}},
+	}}
+	var buf bytes.Buffer
+	if err := mapper.WriteLCOV(&buf, coverage); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:token.sol
+FN:5,transfer
+FNDA:2,transfer
+FNF:1
+FNH:1
+BRDA:6,0,0,2
+BRDA:6,0,1,0
+BRF:2
+BRH:1
+DA:5,2
+DA:6,2
+LF:2
+LH:2
+end_of_record
+`
+	if buf.String() != want {
+		t.Errorf("lcov mismatch:\nhave:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	// Contracts that never executed are reported as uncovered
+	buf.Reset()
+	if err := mapper.WriteLCOV(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "BRDA:6,0,0,-\n") || !strings.Contains(buf.String(), "LH:0\n") {
+		t.Errorf("unexpected lcov for unexecuted contract:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,423 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SourceMapEntry is a single decompressed element of a solc source map. Each
+// entry describes the source range an EVM instruction was generated from.
+type SourceMapEntry struct {
+	Start  int  // Byte offset of the range in the source file
+	Length int  // Length of the range in bytes
+	File   int  // Index into the source list, -1 if the instruction is compiler generated
+	Jump   byte // 'i' for a jump into a function, 'o' for a return, '-' otherwise
+}
+
+// ParseSourceMap decompresses a solc source map (the srcmap or srcmap-runtime
+// fields of the combined-json output) into one entry per instruction.
+func ParseSourceMap(srcmap string) ([]SourceMapEntry, error) {
+	if srcmap == "" {
+		return nil, nil
+	}
+	var (
+		items   = strings.Split(srcmap, ";")
+		entries = make([]SourceMapEntry, 0, len(items))
+		last    = SourceMapEntry{File: -1, Jump: '-'}
+	)
+	for i, item := range items {
+		fields := strings.Split(item, ":")
+		for j, field := range fields {
+			if field == "" {
+				continue
+			}
+			var err error
+			switch j {
+			case 0:
+				last.Start, err = strconv.Atoi(field)
+			case 1:
+				last.Length, err = strconv.Atoi(field)
+			case 2:
+				last.File, err = strconv.Atoi(field)
+			case 3:
+				last.Jump = field[0]
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid source map entry %d: %v", i, err)
+			}
+		}
+		entries = append(entries, last)
+	}
+	return entries, nil
+}
+
+// InstructionIndices maps each instruction-starting program counter of the
+// given bytecode to the ordinal number of that instruction, which is how solc
+// source maps are indexed.
+func InstructionIndices(code []byte) map[uint64]int {
+	indices := make(map[uint64]int)
+	for pc, n := uint64(0), 0; pc < uint64(len(code)); pc, n = pc+1, n+1 {
+		indices[pc] = n
+		// PUSH1 (0x60) to PUSH32 (0x7f) carry their immediate data inline
+		if op := code[pc]; op >= 0x60 && op <= 0x7f {
+			pc += uint64(op - 0x5f)
+		}
+	}
+	return indices
+}
+
+// SourceLocation is a position in a Solidity source file resolved from a
+// program counter.
+type SourceLocation struct {
+	Contract string `json:"contract"`           // Name of the compiled contract the code belongs to
+	Function string `json:"function,omitempty"` // Enclosing function or modifier, if any
+	File     string `json:"file,omitempty"`     // Source file the instruction originates from
+	Line     int    `json:"line,omitempty"`     // 1-based line number in the source file
+	Snippet  string `json:"snippet,omitempty"`  // Trimmed source line
+}
+
+// String implements fmt.Stringer, formatting the location like a stack frame.
+func (loc *SourceLocation) String() string {
+	name := loc.Contract
+	if loc.Function != "" {
+		name += "." + loc.Function
+	}
+	if loc.File == "" {
+		return name
+	}
+	return fmt.Sprintf("%s (%s:%d)", name, loc.File, loc.Line)
+}
+
+// solc --combined-json output fields needed for source mapping
+type solcSourceMapOutput struct {
+	Contracts map[string]struct {
+		Bin           string `json:"bin"`
+		BinRuntime    string `json:"bin-runtime"`
+		Srcmap        string `json:"srcmap"`
+		SrcmapRuntime string `json:"srcmap-runtime"`
+	}
+	SourceList []string `json:"sourceList"`
+}
+
+// mappedCode is a compiled bytecode blob together with its source map.
+type mappedCode struct {
+	code    []byte
+	mask    []bool // bytes to ignore when matching (unresolved library links)
+	srcmap  []SourceMapEntry
+	indices map[uint64]int
+}
+
+// mappedContract is a single contract of a compiler artifact.
+type mappedContract struct {
+	name     string
+	sources  []string // Source list of the artifact the contract originates from
+	creation *mappedCode
+	runtime  *mappedCode
+}
+
+// SourceMapper resolves program counters of executing bytecode to Solidity
+// source locations using solc combined-json artifacts.
+type SourceMapper struct {
+	contracts []*mappedContract
+	sources   map[string]*sourceFile
+}
+
+// NewSourceMapper creates a source mapper from a set of solc --combined-json
+// outputs. These must be compiled with at least the bin, bin-runtime, srcmap
+// and srcmap-runtime outputs. The sources map holds the contents of the files
+// named in the artifacts' sourceList fields; files missing from it are still
+// attributed to their contract, but without line information.
+func NewSourceMapper(artifacts [][]byte, sources map[string]string) (*SourceMapper, error) {
+	mapper := &SourceMapper{sources: make(map[string]*sourceFile)}
+	for name, content := range sources {
+		mapper.sources[name] = newSourceFile(content)
+	}
+	for _, artifact := range artifacts {
+		var output solcSourceMapOutput
+		if err := json.Unmarshal(artifact, &output); err != nil {
+			return nil, err
+		}
+		// Iterate the contracts in a stable order for deterministic matching
+		names := make([]string, 0, len(output.Contracts))
+		for name := range output.Contracts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			info := output.Contracts[name]
+			contract := &mappedContract{name: name, sources: output.SourceList}
+			if i := strings.LastIndex(name, ":"); i >= 0 {
+				contract.name = name[i+1:]
+			}
+			var err error
+			if contract.creation, err = newMappedCode(info.Bin, info.Srcmap); err != nil {
+				return nil, fmt.Errorf("solc: invalid creation code of %s: %v", name, err)
+			}
+			if contract.runtime, err = newMappedCode(info.BinRuntime, info.SrcmapRuntime); err != nil {
+				return nil, fmt.Errorf("solc: invalid runtime code of %s: %v", name, err)
+			}
+			if contract.creation != nil || contract.runtime != nil {
+				mapper.contracts = append(mapper.contracts, contract)
+			}
+		}
+	}
+	return mapper, nil
+}
+
+// newMappedCode decodes a hex encoded bytecode blob, masking out any library
+// link placeholders (__Library______________________________) it contains.
+func newMappedCode(bin string, srcmap string) (*mappedCode, error) {
+	bin = strings.TrimPrefix(bin, "0x")
+	if bin == "" {
+		return nil, nil
+	}
+	if len(bin)%2 != 0 {
+		return nil, fmt.Errorf("odd length hex code")
+	}
+	mc := &mappedCode{
+		code: make([]byte, len(bin)/2),
+		mask: make([]bool, len(bin)/2),
+	}
+	for i := 0; i < len(bin); i += 2 {
+		if bin[i] == '_' {
+			mc.mask[i/2] = true
+			continue
+		}
+		if _, err := hex.Decode(mc.code[i/2:i/2+1], []byte(bin[i:i+2])); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := ParseSourceMap(srcmap)
+	if err != nil {
+		return nil, err
+	}
+	mc.srcmap = entries
+	mc.indices = InstructionIndices(mc.code)
+	return mc, nil
+}
+
+// matches reports whether the given executing code was produced from this
+// compiled blob. Creation code is matched by prefix since constructor arguments
+// are appended to it; the trailing swarm metadata is ignored for runtime code.
+func (mc *mappedCode) matches(code []byte, prefix bool) bool {
+	if mc == nil {
+		return false
+	}
+	want, have := stripMetadata(mc.code), code
+	if prefix {
+		if len(have) < len(want) {
+			return false
+		}
+		have = have[:len(want)]
+	} else {
+		have = stripMetadata(have)
+	}
+	if len(have) != len(want) {
+		return false
+	}
+	for i := range want {
+		if !mc.mask[i] && want[i] != have[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// stripMetadata removes the CBOR encoded metadata solc appends to the code. Its
+// length is stored big endian in the last two bytes.
+func stripMetadata(code []byte) []byte {
+	if len(code) < 2 {
+		return code
+	}
+	size := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	if size+2 > len(code) {
+		return code
+	}
+	// Metadata is a CBOR map with one (0xa1) or two (0xa2) entries
+	if start := len(code) - size - 2; code[start] == 0xa1 || code[start] == 0xa2 {
+		return code[:start]
+	}
+	return code
+}
+
+// Lookup resolves the given program counter of an executing bytecode. The
+// create flag indicates that the code is running as contract initialisation
+// code. It returns nil if the code is not part of any loaded artifact.
+func (m *SourceMapper) Lookup(code []byte, pc uint64, create bool) *SourceLocation {
+	for _, contract := range m.contracts {
+		mc := contract.runtime
+		if create {
+			mc = contract.creation
+		}
+		if !mc.matches(code, create) {
+			continue
+		}
+		loc := &SourceLocation{Contract: contract.name}
+
+		index, ok := mc.indices[pc]
+		if !ok || index >= len(mc.srcmap) {
+			return loc
+		}
+		entry := mc.srcmap[index]
+		if entry.File < 0 || entry.File >= len(contract.sources) {
+			return loc
+		}
+		loc.File = contract.sources[entry.File]
+		if src, ok := m.sources[loc.File]; ok {
+			loc.Line, loc.Snippet = src.line(entry.Start)
+			loc.Function = src.function(entry.Start)
+		}
+		return loc
+	}
+	return nil
+}
+
+// functionRegexp matches the start of function-like Solidity definitions.
+var functionRegexp = regexp.MustCompile(`\b(function|modifier|constructor)\b\s*([A-Za-z_$][A-Za-z0-9_$]*)?`)
+
+// sourceSpan is a named byte range of a source file.
+type sourceSpan struct {
+	name       string
+	start, end int
+}
+
+// sourceFile is a Solidity source file indexed for line and function lookups.
+type sourceFile struct {
+	content   string
+	lines     []int // Byte offsets of line starts
+	functions []sourceSpan
+}
+
+func newSourceFile(content string) *sourceFile {
+	src := &sourceFile{content: content, lines: []int{0}}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			src.lines = append(src.lines, i+1)
+		}
+	}
+	blanked := blankCommentsAndStrings(content)
+	for _, match := range functionRegexp.FindAllStringSubmatchIndex(blanked, -1) {
+		name := "constructor"
+		switch {
+		case blanked[match[2]:match[3]] == "modifier" && match[4] >= 0:
+			name = "modifier " + blanked[match[4]:match[5]]
+		case blanked[match[2]:match[3]] == "function":
+			name = "fallback"
+			if match[4] >= 0 {
+				name = blanked[match[4]:match[5]]
+			}
+		}
+		// Find the function body, skipping bodyless declarations
+		open := strings.IndexAny(blanked[match[1]:], "{;")
+		if open < 0 || blanked[match[1]+open] == ';' {
+			continue
+		}
+		open += match[1]
+		depth, end := 0, len(blanked)
+		for i := open; i < len(blanked); i++ {
+			if blanked[i] == '{' {
+				depth++
+			} else if blanked[i] == '}' {
+				if depth--; depth == 0 {
+					end = i + 1
+					break
+				}
+			}
+		}
+		src.functions = append(src.functions, sourceSpan{name, match[0], end})
+	}
+	return src
+}
+
+// line returns the 1-based line number of the given byte offset along with the
+// trimmed contents of that line.
+func (src *sourceFile) line(offset int) (int, string) {
+	n := sort.Search(len(src.lines), func(i int) bool { return src.lines[i] > offset })
+	start, end := src.lines[n-1], len(src.content)
+	if n < len(src.lines) {
+		end = src.lines[n] - 1
+	}
+	if start > end {
+		return n, ""
+	}
+	return n, strings.TrimSpace(src.content[start:end])
+}
+
+// function returns the name of the innermost function containing the offset.
+func (src *sourceFile) function(offset int) string {
+	if span := src.span(offset); span != nil {
+		return span.name
+	}
+	return ""
+}
+
+// span returns the innermost function definition containing the offset.
+func (src *sourceFile) span(offset int) *sourceSpan {
+	var best *sourceSpan
+	for i, span := range src.functions {
+		if span.start <= offset && offset < span.end {
+			if best == nil || span.start > best.start {
+				best = &src.functions[i]
+			}
+		}
+	}
+	return best
+}
+
+// blankCommentsAndStrings replaces the contents of comments and string literals
+// with spaces, so that braces and keywords within them are not interpreted
+// while keeping all byte offsets intact.
+func blankCommentsAndStrings(content string) string {
+	out := []byte(content)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case bytes.HasPrefix(out[i:], []byte("//")):
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case bytes.HasPrefix(out[i:], []byte("/*")):
+			for ; i < len(out) && !bytes.HasPrefix(out[i:], []byte("*/")); i++ {
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+			if i < len(out) {
+				out[i], out[i+1] = ' ', ' '
+				i++
+			}
+		case out[i] == '"' || out[i] == '\'':
+			quote := out[i]
+			for i++; i < len(out) && out[i] != quote && out[i] != '\n'; i++ {
+				if out[i] == '\\' && i+1 < len(out) {
+					out[i] = ' '
+					i++
+				}
+				out[i] = ' '
+			}
+		}
+	}
+	return string(out)
+}
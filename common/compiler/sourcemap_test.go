@@ -0,0 +1,118 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package compiler
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestParseSourceMap(t *testing.T) {
+	entries, err := ParseSourceMap("1:2:0:-;:9;2:1:1;;-1:0:-1:o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SourceMapEntry{
+		{1, 2, 0, '-'},
+		{1, 9, 0, '-'},
+		{2, 1, 1, '-'},
+		{2, 1, 1, '-'},
+		{-1, 0, -1, 'o'},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("entry mismatch: have %v, want %v", entries, want)
+	}
+	if _, err := ParseSourceMap("1:x"); err == nil {
+		t.Error("expected error for invalid source map")
+	}
+}
+
+func TestInstructionIndices(t *testing.T) {
+	// PUSH1 0x01 PUSH2 0x0000 SSTORE STOP
+	indices := InstructionIndices(common.Hex2Bytes("60016100005500"))
+	want := map[uint64]int{0: 0, 2: 1, 5: 2, 6: 3}
+	if !reflect.DeepEqual(indices, want) {
+		t.Errorf("index mismatch: have %v, want %v", indices, want)
+	}
+}
+
+const sourceMapTestSource = `pragma solidity ^0.4.24;
+
+contract Token {
+    // function fake() { }
+    function transfer(uint amount) public {
+        require(amount > 0, "zero {amount}");
+    }
+}
+`
+
+func TestSourceMapperLookup(t *testing.T) {
+	var (
+		offset = strings.Index(sourceMapTestSource, "require")
+		srcmap = "0:140:0:-;" + strconv.Itoa(offset) + ":37:0:-;;-1:0:-1:-"
+	)
+	artifact := `{
+		"contracts": {
+			"token.sol:Token": {
+				"bin": "6001",
+				"bin-runtime": "6001600055fe",
+				"srcmap": "0:140:0:-",
+				"srcmap-runtime": "` + srcmap + `"
+			}
+		},
+		"sourceList": ["token.sol"]
+	}`
+	mapper, err := NewSourceMapper([][]byte{[]byte(artifact)}, map[string]string{"token.sol": sourceMapTestSource})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Runtime code with appended swarm metadata must still be recognised
+	code := common.Hex2Bytes("6001600055fe" + "a165627a7a72305820" + strings.Repeat("00", 32) + "0029")
+
+	loc := mapper.Lookup(code, 2, false)
+	if loc == nil {
+		t.Fatal("runtime code not recognised")
+	}
+	want := &SourceLocation{
+		Contract: "Token",
+		Function: "transfer",
+		File:     "token.sol",
+		Line:     6,
+		Snippet:  `require(amount > 0, "zero {amount}");`,
+	}
+	if !reflect.DeepEqual(loc, want) {
+		t.Errorf("location mismatch: have %+v, want %+v", loc, want)
+	}
+	if have := loc.String(); have != "Token.transfer (token.sol:6)" {
+		t.Errorf("string mismatch: have %q", have)
+	}
+	// Compiler generated code is attributed to the contract only
+	if loc := mapper.Lookup(code, 5, false); loc == nil || loc.File != "" || loc.Contract != "Token" {
+		t.Errorf("unexpected location for generated code: %+v", loc)
+	}
+	// Creation code is matched by prefix, ignoring constructor arguments
+	if loc := mapper.Lookup(common.Hex2Bytes("6001ffff"), 0, true); loc == nil || loc.Line != 1 {
+		t.Errorf("unexpected location for creation code: %+v", loc)
+	}
+	if loc := mapper.Lookup(common.Hex2Bytes("6002600055fe"), 0, false); loc != nil {
+		t.Errorf("unknown code resolved to %+v", loc)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 Thunder Token Inc., The ThunderCore™ Authors
+// This file comprises an original work of authorship that may make use of, or
+// interface with another work licensed under a GNU or third party license, but
+// which is not otherwise based on said another work.
+
+// To the extent that portions of this file contains source code that is subject
+// to the terms of the GNU or third party license, the minimal corresponding source
+// code for those portions can be freely redistributed and/or modified under the
+// terms of the respective license, either of GNU Lesser General Public License version 3
+// or (at your option) any later version.
+
+// The remaining code for the ThunderCore™ network application is not a contribution
+// to be incorporated into said another work.  Rather, it is open source and licensed
+// from Thunder Token Inc. to you, the recipient, to copy, modify and distribute the
+// original or modified work without a fee, subject to reciprocity and recipient’s
+// (i) promise and covenant not to sue Thunder Token Inc., its assigns, successors,
+// affiliates and subsidiaries (hereinafter “Thunder Token”) on claims arising from
+// any of their use of recipient’s code, if any; (ii) promise and ongoing commitment
+// to not unfairly compete against or interfere with Thunder Token’s business or commercial
+// relationships; and (iii) promise and ongoing commitment to not challenge the validity,
+// enforceability, title, or ownership (by Thunder Token) of any intellectual property
+// rights arising from or relating to the ThunderCore™ network application.  Further, you,
+// the recipient, agree to and must do the following: (1) give prominent notice and
+// attribution to Thunder Token Inc. and the ThunderCore™ Authors for their work on the
+// original work and include any appropriate copyright, trademark, patent notices,
+// (2) accompany the original or modified work with a copy of this notice (TT license v1.0
+// or, at your option, any later version) in its entirety or a link directing the user to
+// the same, (3) accompany the modified work with a prominent notice indicating that it
+// has been modified and that it was based off of the original work; and (4) convey or
+// otherwise make freely available the source code corresponding to the modified work
+// under the same conditions and restrictions on the exercise of rights granted or
+// affirmed under this license.
+
+// Your copying, reverse-engineering, debugging, modifying, or distributing the original
+// or modified work constitutes assent and agreement to these terms.  You may not use this
+// file in any way except in compliance with the terms of this license.
+
+// The code is distributed AS-IS in the hope that it will be useful, but WITHOUT ANY WARRANTY;
+// without even the implied warranty of MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE or
+// TITLE or of non-infringement.  Thunder Token Inc. and any contributors to the software shall
+// not be liable for any direct, indirect, incidental, special, punitive, exemplary, or
+// consequential damages (including, without limitation, procurement of substitute goods or
+// services, loss of use, data or profits or business interruption) however caused and under
+// any theory of liability, whether in contract, strict liability, or tort (including negligence)
+// or otherwise arising in any way out of the use of or inability to use the software, even if
+// advised of the possibility of such damage.  The foregoing limitations of liability shall apply
+// even if deemed to fail of their essential purpose.  The software may only be distributed under
+// these terms and this disclaimer.
+
+// This license does not grant permission to use the trade names, trademarks, service marks, or
+// product names of ThunderCore™ or of Thunder Token Inc., except as required for reasonable and
+// customary use in describing the origin of the work and reproducing the content of this file.
+
+// Thunder Token Inc. and The ThunderCore™ Authors may publish revised and/or new versions of
+// this TT license from time to time.
+
+// You should have received a copy of the specific GNU license along with this file,
+// the ThunderCore™ library, or the go-ethereum library.  If not, then see, e.g.,
+// <https://www.gnu.org/licenses/lgpl-3.0.en.html> and/or <http://www.gnu.org/licenses/>.
+
+package thunder
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+)
+
+// errTimestampInPast is returned when pinning the next block to a timestamp
+// before that of the chain head.
+var errTimestampInPast = errors.New("timestamp before the chain head")
+
+// devClock is the clock stamping the blocks of dev chains, which can be moved
+// forward or frozen to test time dependent contracts.
+type devClock struct {
+	offset   time.Duration // Shift of the clock against the system clock
+	frozen   bool          // Whether the clock stands still
+	frozenAt time.Time     // System time the clock was frozen at
+
+	nextNumber uint64   // Number of the block pinned to a timestamp
+	nextTime   *big.Int // Timestamp of the pinned block, nil if none
+
+	lock sync.Mutex
+}
+
+// now returns the current time of the clock.
+func (c *devClock) now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.frozen {
+		return c.frozenAt.Add(c.offset)
+	}
+	return time.Now().Add(c.offset)
+}
+
+// blockTime returns the timestamp of the block with the given number, as long
+// as it is not before the parent's. The pinned timestamp also carries over to
+// later blocks while the parent is older, as blocks sealing dev_set* changes
+// keep the time of their parent.
+func (c *devClock) blockTime(number uint64, parent *big.Int) *big.Int {
+	c.lock.Lock()
+	pinned := c.nextTime
+	if pinned != nil && number != c.nextNumber && (number < c.nextNumber || pinned.Cmp(parent) <= 0) {
+		pinned = nil
+	}
+	c.lock.Unlock()
+
+	if pinned == nil {
+		pinned = big.NewInt(c.now().Unix())
+	}
+	if pinned.Cmp(parent) < 0 {
+		return new(big.Int).Set(parent)
+	}
+	return new(big.Int).Set(pinned)
+}
+
+// increase moves the clock forward.
+func (c *devClock) increase(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.offset += d
+}
+
+// pin sets the timestamp of the block with the given number, moving the clock
+// forward so that later blocks continue from there.
+func (c *devClock) pin(number uint64, timestamp *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	if c.frozen {
+		now = c.frozenAt
+	}
+	if shift := time.Unix(timestamp.Int64(), 0).Sub(now.Add(c.offset)); shift > 0 {
+		c.offset += shift
+	}
+	c.nextNumber, c.nextTime = number, new(big.Int).Set(timestamp)
+}
+
+// freeze stops or restarts the clock. A restarted clock continues from the time
+// it was frozen at.
+func (c *devClock) freeze(frozen bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch {
+	case frozen && !c.frozen:
+		c.frozenAt = time.Now()
+	case !frozen && c.frozen:
+		c.offset -= time.Since(c.frozenAt)
+	}
+	c.frozen = frozen
+}
+
+// ClockState is the state of the clock of a dev chain, to restore it with when
+// reverting the chain.
+type ClockState struct {
+	offset   time.Duration
+	frozen   bool
+	frozenAt time.Time
+
+	nextNumber uint64
+	nextTime   *big.Int
+}
+
+// state returns the current state of the clock.
+func (c *devClock) state() *ClockState {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return &ClockState{
+		offset:     c.offset,
+		frozen:     c.frozen,
+		frozenAt:   c.frozenAt,
+		nextNumber: c.nextNumber,
+		nextTime:   c.nextTime,
+	}
+}
+
+// restore moves the clock back to a past state. A running clock keeps running
+// with the old offset, so it continues from the time it was restored at.
+func (c *devClock) restore(state *ClockState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.offset, c.frozen, c.frozenAt = state.offset, state.frozen, state.frozenAt
+	c.nextNumber, c.nextTime = state.nextNumber, state.nextTime
+}
+
+// DevAPI controls the block timestamps of dev chains.
+type DevAPI struct {
+	chain consensus.ChainReader
+	clock *devClock
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next block, which must not be
+// before that of the chain head. Later blocks continue from that time.
+func (api *DevAPI) SetNextBlockTimestamp(timestamp hexutil.Uint64) error {
+	head := api.chain.CurrentHeader()
+	time := new(big.Int).SetUint64(uint64(timestamp))
+	if time.Cmp(head.Time) < 0 {
+		return errTimestampInPast
+	}
+	api.clock.pin(head.Number.Uint64()+1, time)
+	return nil
+}
+
+// IncreaseTime moves the clock forward by the given number of seconds and
+// returns the current time of the clock.
+func (api *DevAPI) IncreaseTime(seconds hexutil.Uint64) hexutil.Uint64 {
+	api.clock.increase(time.Duration(seconds) * time.Second)
+	return hexutil.Uint64(api.clock.now().Unix())
+}
+
+// FreezeClock stops the clock, making all new blocks share the same timestamp
+// until moved explicitly, or restarts it.
+func (api *DevAPI) FreezeClock(frozen bool) {
+	api.clock.freeze(frozen)
+}
+
+// Now returns the current time of the clock.
+func (api *DevAPI) Now() hexutil.Uint64 {
+	return hexutil.Uint64(api.clock.now().Unix())
+}
@@ -89,10 +89,8 @@ const (
 )
 
 var (
-	zeroUncleHash = types.EmptyUncleHash
-	// Currently to zero
-	zeroCoinbaseAddress = common.Address{}
-	unityDifficulty     = big.NewInt(1)
+	zeroUncleHash   = types.EmptyUncleHash
+	unityDifficulty = big.NewInt(1)
 	// Used by ethhash for DAO header. Clique uses it for it's own thing.
 	// We don't need it in thunder.
 	zeroExtraData = make([]byte, 0)
@@ -115,10 +113,12 @@ var (
 	// If child block's timestamp < parent block's timestamp
 	errBackwardBlockTime = errors.New("block timestamp less than parent's timestamp")
 
+	// The coinbase is fixed by the fee recipients of the engine config
+	errInvalidCoinbase = errors.New("invalid coinbase address")
+
 	// Errors for unused fields not set to zero/empty
 
 	errNonEmptyUncleHash = errors.New("non empty uncle hash")
-	errNonEmptyAddress   = errors.New("non empty coinbase address")
 	// Thunder PoS doesn't have difficulty as in PoW.
 	errNonZeroDifficulty = errors.New("non zero difficulty")
 	errNonEmptyExtra     = errors.New("non empty extra")
@@ -129,20 +129,43 @@ var (
 // Thunder is the proof-of-stake consensus engine.
 type Thunder struct {
 	config *params.ThunderConfig // Consensus engine configuration parameters
+	clock  *devClock             // Controllable block clock, dev chains only
 }
 
 // New creates a Thunder proof-of-stake consensus engine.
 func New(config *params.ThunderConfig) *Thunder {
-	return &Thunder{config: config}
+	thunder := &Thunder{config: config}
+	if config.Dev {
+		thunder.clock = new(devClock)
+	}
+	return thunder
+}
+
+// ClockState returns the state of the block clock of dev chains, nil if the
+// chain has no such clock.
+func (thunder *Thunder) ClockState() *ClockState {
+	if thunder.clock == nil {
+		return nil
+	}
+	return thunder.clock.state()
+}
+
+// RestoreClock moves the block clock of dev chains back to a past state.
+func (thunder *Thunder) RestoreClock(state *ClockState) {
+	if thunder.clock == nil || state == nil {
+		return
+	}
+	thunder.clock.restore(state)
 }
 
 //////////////////////////////////
 // consensus.Engine implementation
 //////////////////////////////////
 
-// Author implements consensus.Engine.
+// Author implements consensus.Engine, returning the account that is credited
+// with the transaction fees of the block.
 func (thunder *Thunder) Author(header *types.Header) (common.Address, error) {
-	return zeroCoinbaseAddress, nil
+	return thunder.config.Coinbase(), nil
 }
 
 // CalcDifficulty implements consensus.Engine
@@ -180,9 +203,6 @@ func verifyHeaderUnusedFieldsAreZero(header *types.Header) error {
 	if header.UncleHash != zeroUncleHash {
 		return errNonEmptyUncleHash
 	}
-	if header.Coinbase != zeroCoinbaseAddress {
-		return errNonEmptyAddress
-	}
 	// Ensure that the block's difficulty is zero.
 	if header.Difficulty.Cmp(unityDifficulty) != 0 {
 		return errNonZeroDifficulty
@@ -222,8 +242,9 @@ func (thunder *Thunder) VerifyHeader(chain consensus.ChainReader, header *types.
 	if diff := new(big.Int).Sub(header.Number, parent.Number); diff.Cmp(big.NewInt(1)) != 0 {
 		return consensus.ErrInvalidNumber
 	}
-	// Don't waste time checking blocks from the future
-	if header.Time.Cmp(big.NewInt(time.Now().Add(allowedFutureBlockTime).Unix())) > 0 {
+	// Don't waste time checking blocks from the future, unless the clock of dev
+	// chains was moved forward
+	if !thunder.config.Dev && header.Time.Cmp(big.NewInt(time.Now().Add(allowedFutureBlockTime).Unix())) > 0 {
 		return consensus.ErrFutureBlock
 	}
 	if header.Time.Cmp(parent.Time) < 0 {
@@ -232,6 +253,10 @@ func (thunder *Thunder) VerifyHeader(chain consensus.ChainReader, header *types.
 	if err := verifyHeaderUnusedFieldsAreZero(header); err != nil {
 		return err
 	}
+	// Fees must go to the configured recipients, the state root verifies the split
+	if header.Coinbase != thunder.config.Coinbase() {
+		return errInvalidCoinbase
+	}
 	if header.GasLimit != blockGasLimit {
 		return fmt.Errorf("invalid gasLimit: have %v, max %v", header.GasLimit,
 			blockGasLimit)
@@ -275,7 +300,6 @@ func (thunder *Thunder) VerifySeal(chain consensus.ChainReader, header *types.He
 // All header fields which are not relevant in Thunder protocol are set to predefined zero values.
 func setHeaderUnusedFieldsToZero(header *types.Header) {
 	header.UncleHash = zeroUncleHash
-	header.Coinbase = zeroCoinbaseAddress
 	header.Difficulty = unityDifficulty
 	header.Extra = zeroExtraData
 	header.MixDigest = zeroMixDigest
@@ -286,6 +310,7 @@ func setHeaderUnusedFieldsToZero(header *types.Header) {
 // header for running the transactions on top.
 func (thunder *Thunder) Prepare(chain consensus.ChainReader, header *types.Header) error {
 	setHeaderUnusedFieldsToZero(header)
+	header.Coinbase = thunder.config.Coinbase()
 	number := header.Number.Uint64()
 
 	// Ensure the timestamp has the correct delay
@@ -293,27 +318,69 @@ func (thunder *Thunder) Prepare(chain consensus.ChainReader, header *types.Heade
 	if parent == nil {
 		return consensus.ErrUnknownAncestor
 	}
-	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(0))
-	if header.Time.Int64() < time.Now().Unix() {
-		header.Time = big.NewInt(time.Now().Unix())
+	if thunder.clock != nil {
+		header.Time = thunder.clock.blockTime(number, parent.Time)
+	} else {
+		header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(0))
+		if header.Time.Int64() < time.Now().Unix() {
+			header.Time = big.NewInt(time.Now().Unix())
+		}
 	}
 	header.GasLimit = blockGasLimit
 	return nil
 }
 
-// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
-// rewards given, and returns the final block.
+// Finalize implements consensus.Engine, distributing the transaction fees and
+// block rewards between the fee recipients, ensuring no uncles are set, and
+// returns the final block.
 func (thunder *Thunder) Finalize(chain consensus.ChainReader, header *types.Header,
 	state *state.StateDB, txs []*types.Transaction, uncles []*types.Header,
 	receipts []*types.Receipt) (*types.Block, error) {
-	// No block rewards in Thunder, so the state remains as is and uncles are dropped
-	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	setHeaderUnusedFieldsToZero(header)
+	header.Coinbase = thunder.config.Coinbase()
 
-	// Assemble and return the final block for sealing
+	distributeFees(thunder.config, state, header, txs, receipts)
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+
+	// Assemble and return the final block for sealing, uncles are dropped
 	return types.NewBlock(header, txs, nil, receipts), nil
 }
 
+// distributeFees mints the block reward to the coinbase and then moves the
+// shares of the other fee recipients out of the reward and the transaction fees
+// the coinbase was credited with. Rounding leftovers stay with the coinbase.
+func distributeFees(config *params.ThunderConfig, state *state.StateDB, header *types.Header,
+	txs []*types.Transaction, receipts []*types.Receipt) {
+	total := new(big.Int)
+	if config.BlockReward != nil && config.BlockReward.Sign() > 0 {
+		state.AddBalance(header.Coinbase, config.BlockReward)
+		total.Add(total, config.BlockReward)
+	}
+	if len(config.FeeRecipients) == 0 {
+		return
+	}
+	for i, receipt := range receipts {
+		fee := new(big.Int).SetUint64(receipt.GasUsed)
+		total.Add(total, fee.Mul(fee, txs[i].GasPrice()))
+	}
+	var weights uint64
+	for _, recipient := range config.FeeRecipients {
+		weights += recipient.Weight
+	}
+	if total.Sign() == 0 || weights == 0 {
+		return
+	}
+	for _, recipient := range config.FeeRecipients[1:] {
+		share := new(big.Int).SetUint64(recipient.Weight)
+		share.Mul(share, total).Div(share, new(big.Int).SetUint64(weights))
+		if share.Sign() == 0 {
+			continue
+		}
+		state.SubBalance(header.Coinbase, share)
+		state.AddBalance(recipient.Address, share)
+	}
+}
+
 // Seal implements consensus.Engine.
 func (thunder *Thunder) Seal(chain consensus.ChainReader, block *types.Block, results chan<- *types.Block,
 	stop <-chan struct{}) error {
@@ -380,12 +447,21 @@ func (api *API) GetBlockInterval() float64 {
 }
 
 // APIs implements consensus.Engine, returning the user facing RPC API to allow
-// controlling the signer voting.
+// controlling the signer voting, and the block clock on dev chains.
 func (thunder *Thunder) APIs(chain consensus.ChainReader) []rpc.API {
-	return []rpc.API{{
+	apis := []rpc.API{{
 		Namespace: "thunder",
 		Version:   "0.1",
 		Service:   &API{chain: chain, thunder: thunder},
 		Public:    false,
 	}}
+	if thunder.clock != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "dev",
+			Version:   "1.0",
+			Service:   &DevAPI{chain: chain, clock: thunder.clock},
+			Public:    false,
+		})
+	}
+	return apis
 }
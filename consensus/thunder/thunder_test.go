@@ -67,6 +67,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/state"
@@ -85,7 +86,7 @@ func TestAuthor(t *testing.T) {
 
 	coinbase, err := thunder.Author(nil)
 
-	assert.Equal(coinbase, zeroCoinbaseAddress, "error")
+	assert.Equal(coinbase, common.Address{}, "error")
 	assert.Equal(err, nil, "error")
 }
 
@@ -148,7 +149,7 @@ func TestThunderPrepare(t *testing.T) {
 	err := thunder.Prepare(blockchain, header)
 	assert.Equal(err, nil)
 	assert.Equal(header.UncleHash, zeroUncleHash)
-	assert.Equal(header.Coinbase, zeroCoinbaseAddress)
+	assert.Equal(header.Coinbase, common.Address{})
 	assert.Equal(header.Difficulty, unityDifficulty)
 	assert.Equal(header.Extra, zeroExtraData)
 	assert.Equal(header.MixDigest, zeroMixDigest)
@@ -170,7 +171,7 @@ func TestThunderFinalize(t *testing.T) {
 	block, err := thunder.Finalize(blockchain, header, state, nil, nil, nil)
 	assert.Equal(err, nil)
 	assert.Equal(block.UncleHash(), zeroUncleHash)
-	assert.Equal(block.Coinbase(), zeroCoinbaseAddress)
+	assert.Equal(block.Coinbase(), common.Address{})
 	assert.Equal(block.Difficulty(), unityDifficulty)
 	assert.Equal(block.Extra(), zeroExtraData)
 	assert.Equal(block.MixDigest(), zeroMixDigest)
@@ -211,6 +212,134 @@ func TestVerifyHeader(t *testing.T) {
 	header.GasUsed = blockGasLimit + 1
 	assert.Errorf(thunder.VerifyHeader(blockchain, header, false),
 		fmt.Sprintf("invalid gasUsed: have %d, gasLimit %d", header.GasUsed, header.GasLimit))
+	header.GasUsed = 0
+
+	header.Coinbase = common.Address{1}
+	assert.Equal(thunder.VerifyHeader(blockchain, header, false), errInvalidCoinbase)
+}
+
+var (
+	feeKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	feeSender = crypto.PubkeyToAddress(feeKey.PublicKey)
+	feeAddr   = common.HexToAddress("0xc4F3c85Bb93F33A485344959CF03002B63D7c4E3")
+)
+
+// feeTestChain creates a chain running Thunder with the given engine config,
+// and a single block on top of its genesis transferring to the zero address.
+func feeTestChain(t *testing.T, config *params.ThunderConfig, gasPrice int64) (*core.BlockChain, *types.Block) {
+	chainConfig := *params.TestThunderChainConfig
+	chainConfig.Thunder = config
+
+	db := ethdb.NewMemDatabase()
+	gspec := &core.Genesis{
+		Config:   &chainConfig,
+		Alloc:    core.GenesisAlloc{feeSender: {Balance: big.NewInt(1000000000)}},
+		GasLimit: blockGasLimit,
+	}
+	genesis := gspec.MustCommit(db)
+	engine := New(config)
+
+	blockchain, err := core.NewBlockChain(db, nil, &chainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	blocks, _ := core.GenerateChain(&chainConfig, genesis, engine, db, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(config.Coinbase())
+		tx := types.NewTransaction(b.TxNonce(feeSender), common.Address{}, big.NewInt(1), params.TxGas, big.NewInt(gasPrice), nil)
+		tx, _ = types.SignTx(tx, types.MakeSigner(&chainConfig, b.Number()), feeKey)
+		b.AddTx(tx)
+	})
+	return blockchain, blocks[0]
+}
+
+// Tests that fees and block rewards are split between the fee recipients.
+func TestFeeDistribution(t *testing.T) {
+	config := &params.ThunderConfig{
+		FeeRecipients: []params.ThunderFeeRecipient{
+			{Address: feeAddr, Weight: 3},
+			{Address: state.VaultTPCAddress, Weight: 1},
+		},
+		BlockReward: big.NewInt(1001),
+	}
+	blockchain, block := feeTestChain(t, config, 10)
+	defer blockchain.Stop()
+
+	if block.Coinbase() != feeAddr {
+		t.Fatalf("coinbase mismatch: have %x, want %x", block.Coinbase(), feeAddr)
+	}
+	if _, err := blockchain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	statedb, _ := blockchain.State()
+
+	// 21000 gas at 10 wei plus the reward, a quarter of which goes to the vault
+	total := int64(params.TxGas*10 + 1001)
+	if have, want := statedb.GetBalance(state.VaultTPCAddress), big.NewInt(total/4); have.Cmp(want) != 0 {
+		t.Errorf("vault balance mismatch: have %v, want %v", have, want)
+	}
+	if have, want := statedb.GetBalance(feeAddr), big.NewInt(total-total/4); have.Cmp(want) != 0 {
+		t.Errorf("fee address balance mismatch: have %v, want %v", have, want)
+	}
+	if have, want := statedb.GetBalance(common.Address{}), big.NewInt(1); have.Cmp(want) != 0 {
+		t.Errorf("zero address balance mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that without fee recipients the fees are burnt to the zero coinbase.
+func TestFeeBurning(t *testing.T) {
+	blockchain, block := feeTestChain(t, new(params.ThunderConfig), 10)
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	statedb, _ := blockchain.State()
+	if have, want := statedb.GetBalance(common.Address{}), big.NewInt(int64(params.TxGas*10+1)); have.Cmp(want) != 0 {
+		t.Errorf("zero address balance mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that block rewards are minted even without fee recipients to split them.
+func TestBlockRewardBurning(t *testing.T) {
+	blockchain, block := feeTestChain(t, &params.ThunderConfig{BlockReward: big.NewInt(1001)}, 10)
+	defer blockchain.Stop()
+
+	if _, err := blockchain.InsertChain(types.Blocks{block}); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	statedb, _ := blockchain.State()
+	if have, want := statedb.GetBalance(common.Address{}), big.NewInt(int64(params.TxGas*10+1+1001)); have.Cmp(want) != 0 {
+		t.Errorf("zero address balance mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that blocks distributing fees differently from the local config are rejected.
+func TestFeeDistributionMismatch(t *testing.T) {
+	local := &params.ThunderConfig{
+		FeeRecipients: []params.ThunderFeeRecipient{
+			{Address: feeAddr, Weight: 1},
+			{Address: state.VaultTPCAddress, Weight: 1},
+		},
+	}
+	blockchain, _ := feeTestChain(t, local, 10)
+	defer blockchain.Stop()
+
+	// A block burning the fees has the wrong coinbase
+	_, block := feeTestChain(t, new(params.ThunderConfig), 10)
+	if _, err := blockchain.InsertChain(types.Blocks{block}); err != errInvalidCoinbase {
+		t.Errorf("burning block error mismatch: have %v, want %v", err, errInvalidCoinbase)
+	}
+	// A block with the same coinbase but another split has the wrong state root
+	remote := &params.ThunderConfig{
+		FeeRecipients: []params.ThunderFeeRecipient{
+			{Address: feeAddr, Weight: 1},
+			{Address: state.VaultTPCAddress, Weight: 3},
+		},
+	}
+	_, block = feeTestChain(t, remote, 10)
+	if _, err := blockchain.InsertChain(types.Blocks{block}); err == nil {
+		t.Error("block with mismatching fee split inserted")
+	}
 }
 
 func TestSeal(t *testing.T) {
@@ -237,3 +366,47 @@ func TestSeal(t *testing.T) {
 	elapsed := time.Since(start)
 	assert.True(elapsed.Seconds() >= blockInterval.Seconds())
 }
+
+func TestDevClock(t *testing.T) {
+	assert := assert.New(t)
+
+	blockchain := makeThunderTestChain()
+	thunder := New(&params.ThunderConfig{Dev: true})
+	api := &DevAPI{chain: blockchain, clock: thunder.clock}
+
+	prepare := func() *types.Header {
+		header := makeNewHeader(blockchain)
+		assert.Nil(thunder.Prepare(blockchain, header))
+		return header
+	}
+	// Pin the next block past the future block limit of regular chains
+	next := uint64(time.Now().Add(2 * allowedFutureBlockTime).Unix())
+	assert.Nil(api.SetNextBlockTimestamp(hexutil.Uint64(next)))
+	header := prepare()
+	assert.Equal(next, header.Time.Uint64())
+	assert.Nil(thunder.VerifyHeader(blockchain, header, false))
+	assert.Equal(consensus.ErrFutureBlock, New(new(params.ThunderConfig)).VerifyHeader(blockchain, header, false))
+
+	// The pinned timestamp sticks until the block is part of the chain
+	assert.Equal(next, prepare().Time.Uint64())
+
+	// The pinned timestamp carries over blocks keeping their parent's time
+	number := header.Number.Uint64()
+	parent := new(big.Int).Sub(header.Time, big.NewInt(3600))
+	assert.Equal(next, thunder.clock.blockTime(number+1, parent).Uint64())
+
+	// A frozen clock stamps all blocks alike until moved forward
+	thunder = New(&params.ThunderConfig{Dev: true})
+	api = &DevAPI{chain: blockchain, clock: thunder.clock}
+	api.FreezeClock(true)
+	first, second := prepare(), prepare()
+	assert.Equal(first.Time, second.Time)
+
+	now := api.IncreaseTime(3600)
+	assert.Equal(first.Time.Uint64()+3600, uint64(now))
+	assert.Equal(uint64(now), prepare().Time.Uint64())
+
+	// A restarted clock continues from the frozen time
+	api.FreezeClock(false)
+	assert.True(prepare().Time.Uint64() >= uint64(now))
+}
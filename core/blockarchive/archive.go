@@ -0,0 +1,435 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package blockarchive implements a portable archive format for the chain history.
+//
+// An archive is a directory holding a manifest and a number of chunk files. The
+// blocks are split into chunks of a fixed number of consecutive block numbers, so
+// that the chunk holding any block is known without reading the others. A chunk
+// file may hold only a part of its range, at the edges of an export.
+//
+// A chunk file is laid out as follows, integers being big endian:
+//
+//	entry_0 ... entry_n-1     snappy compressed RLP of every block entry
+//	offset_0 ... offset_n     start offset of every entry, and end of the last
+//	first (8 bytes)           number of the first block in the chunk
+//	count (8 bytes)           number of blocks in the chunk
+//	checksum (32 bytes)       keccak256 hash of all the preceding bytes
+//	magic (8 bytes)           chunkMagic
+//
+// Every entry holds the header, body, receipts and total difficulty of a block.
+package blockarchive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/golang/snappy"
+)
+
+const (
+	// Version is the version of the archive format.
+	Version = 1
+
+	// DefaultChunkSize is the number of block numbers covered by a chunk of a
+	// newly created archive.
+	DefaultChunkSize = 8192
+
+	manifestName = "archive.json" // File name of the archive manifest
+	chunkExt     = ".chunk"       // File extension of the chunk files
+	trailerSize  = 8 + 8 + 32 + 8 // Size of the trailer of a chunk file
+)
+
+// chunkMagic closes every chunk file, to tell complete files apart from others.
+var chunkMagic = []byte("gethblks")
+
+var (
+	// errNotFound is returned if a block is not contained in the archive.
+	errNotFound = errors.New("block not found in archive")
+
+	// errCorruptChunk is returned if a chunk file is malformed.
+	errCorruptChunk = errors.New("corrupt chunk file")
+)
+
+// Entry is the archived history of a single block.
+type Entry struct {
+	Header   *types.Header
+	Body     *types.Body
+	Receipts types.Receipts
+	TD       *big.Int
+}
+
+// entryRLP is the archived encoding of an entry.
+type entryRLP struct {
+	Header   *types.Header
+	Body     *types.Body
+	Receipts []*types.ReceiptForStorage
+	TD       *big.Int
+}
+
+// NewEntry creates the archive entry of a block.
+func NewEntry(block *types.Block, receipts types.Receipts, td *big.Int) *Entry {
+	return &Entry{Header: block.Header(), Body: block.Body(), Receipts: receipts, TD: td}
+}
+
+// Block assembles the block of the entry.
+func (e *Entry) Block() *types.Block {
+	return types.NewBlockWithHeader(e.Header).WithBody(e.Body.Transactions, e.Body.Uncles)
+}
+
+// Verify checks that the body and receipts of the entry match the roots of its
+// header.
+func (e *Entry) Verify() error {
+	number := e.Header.Number.Uint64()
+	if hash := types.DeriveSha(types.Transactions(e.Body.Transactions)); hash != e.Header.TxHash {
+		return fmt.Errorf("block #%d: transaction root mismatch: have %x, want %x", number, hash, e.Header.TxHash)
+	}
+	if hash := types.CalcUncleHash(e.Body.Uncles); hash != e.Header.UncleHash {
+		return fmt.Errorf("block #%d: uncle hash mismatch: have %x, want %x", number, hash, e.Header.UncleHash)
+	}
+	if len(e.Receipts) != len(e.Body.Transactions) {
+		return fmt.Errorf("block #%d: receipt count mismatch: have %d, want %d", number, len(e.Receipts), len(e.Body.Transactions))
+	}
+	if hash := types.DeriveSha(e.Receipts); hash != e.Header.ReceiptHash {
+		return fmt.Errorf("block #%d: receipt root mismatch: have %x, want %x", number, hash, e.Header.ReceiptHash)
+	}
+	return nil
+}
+
+func encodeEntry(e *Entry) ([]byte, error) {
+	enc := &entryRLP{Header: e.Header, Body: e.Body, TD: e.TD}
+	for _, receipt := range e.Receipts {
+		enc.Receipts = append(enc.Receipts, (*types.ReceiptForStorage)(receipt))
+	}
+	blob, err := rlp.EncodeToBytes(enc)
+	if err != nil {
+		return nil, err
+	}
+	return snappy.Encode(nil, blob), nil
+}
+
+func decodeEntry(blob []byte) (*Entry, error) {
+	blob, err := snappy.Decode(nil, blob)
+	if err != nil {
+		return nil, err
+	}
+	var dec entryRLP
+	if err := rlp.DecodeBytes(blob, &dec); err != nil {
+		return nil, err
+	}
+	e := &Entry{Header: dec.Header, Body: dec.Body, TD: dec.TD}
+	for _, receipt := range dec.Receipts {
+		e.Receipts = append(e.Receipts, (*types.Receipt)(receipt))
+	}
+	return e, nil
+}
+
+// manifest describes the contents of an archive.
+type manifest struct {
+	Version   uint        `json:"version"`
+	Genesis   common.Hash `json:"genesis"`
+	ChunkSize uint64      `json:"chunkSize"`
+}
+
+// Archive is a directory of chain history chunks.
+type Archive struct {
+	dir      string
+	manifest manifest
+}
+
+// Create opens the archive in the given directory, creating it for the chain of
+// the given genesis block if it does not exist yet.
+func Create(dir string, genesis common.Hash, chunkSize uint64) (*Archive, error) {
+	archive, err := Open(dir)
+	if err == nil {
+		if archive.manifest.Genesis != genesis {
+			return nil, fmt.Errorf("archive genesis mismatch: have %x, want %x", archive.manifest.Genesis, genesis)
+		}
+		return archive, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if chunkSize == 0 {
+		return nil, errors.New("zero chunk size")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	archive = &Archive{
+		dir:      dir,
+		manifest: manifest{Version: Version, Genesis: genesis, ChunkSize: chunkSize},
+	}
+	blob, err := json.MarshalIndent(&archive.manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, manifestName), blob, 0644); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
+
+// Open opens an existing archive.
+func Open(dir string) (*Archive, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{dir: dir}
+	if err := json.Unmarshal(blob, &archive.manifest); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest: %v", err)
+	}
+	if archive.manifest.Version != Version {
+		return nil, fmt.Errorf("unsupported archive version %d", archive.manifest.Version)
+	}
+	if archive.manifest.ChunkSize == 0 {
+		return nil, errors.New("invalid archive manifest: zero chunk size")
+	}
+	return archive, nil
+}
+
+// Genesis returns the hash of the genesis block of the archived chain.
+func (a *Archive) Genesis() common.Hash {
+	return a.manifest.Genesis
+}
+
+// ChunkSize returns the number of block numbers covered by a chunk.
+func (a *Archive) ChunkSize() uint64 {
+	return a.manifest.ChunkSize
+}
+
+// Chunks returns the indexes of the chunks present in the archive, in ascending
+// order.
+func (a *Archive) Chunks() ([]uint64, error) {
+	files, err := ioutil.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	var chunks []uint64
+	for _, file := range files {
+		var index uint64
+		if n, _ := fmt.Sscanf(file.Name(), "%d"+chunkExt, &index); n == 1 && file.Name() == chunkName(index) {
+			chunks = append(chunks, index)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i] < chunks[j] })
+	return chunks, nil
+}
+
+// chunkName returns the file name of a chunk.
+func chunkName(index uint64) string {
+	return fmt.Sprintf("%08d%s", index, chunkExt)
+}
+
+// chunkPath returns the path of the file of a chunk.
+func (a *Archive) chunkPath(index uint64) string {
+	return filepath.Join(a.dir, chunkName(index))
+}
+
+// Entry retrieves the archived history of a block.
+func (a *Archive) Entry(number uint64) (*Entry, error) {
+	c, err := a.openChunk(number / a.manifest.ChunkSize)
+	if os.IsNotExist(err) {
+		return nil, errNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	if number < c.first || number >= c.first+c.count {
+		return nil, errNotFound
+	}
+	return c.entry(number)
+}
+
+// ReadChunk loads all the entries of a chunk, verifying its checksum.
+func (a *Archive) ReadChunk(index uint64) ([]*Entry, error) {
+	blob, err := ioutil.ReadFile(a.chunkPath(index))
+	if err != nil {
+		return nil, err
+	}
+	first, count, offsets, err := parseTrailer(int64(len(blob)), func(buf []byte, off int64) error {
+		copy(buf, blob[off:])
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("chunk %d: %v", index, err)
+	}
+	end := len(blob) - len(chunkMagic) - 32
+	if hash := crypto.Keccak256(blob[:end]); !bytes.Equal(hash, blob[end:end+32]) {
+		return nil, fmt.Errorf("chunk %d: checksum mismatch: have %x, want %x", index, hash, blob[end:end+32])
+	}
+	if first/a.manifest.ChunkSize != index || (first+count-1)/a.manifest.ChunkSize != index {
+		return nil, fmt.Errorf("chunk %d: blocks #%d-#%d outside of chunk", index, first, first+count-1)
+	}
+	entries := make([]*Entry, count)
+	for i := range entries {
+		if entries[i], err = decodeEntry(blob[offsets[i]:offsets[i+1]]); err != nil {
+			return nil, fmt.Errorf("block #%d: %v", first+uint64(i), err)
+		}
+		if number := entries[i].Header.Number.Uint64(); number != first+uint64(i) {
+			return nil, fmt.Errorf("chunk %d: block number mismatch: have %d, want %d", index, number, first+uint64(i))
+		}
+	}
+	return entries, nil
+}
+
+// chunk is an open chunk file, allowing random access to its entries.
+type chunk struct {
+	file    *os.File
+	first   uint64
+	count   uint64
+	offsets []uint64
+}
+
+// openChunk opens a chunk file, loading its index.
+func (a *Archive) openChunk(index uint64) (*chunk, error) {
+	file, err := os.Open(a.chunkPath(index))
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	first, count, offsets, err := parseTrailer(stat.Size(), func(buf []byte, off int64) error {
+		_, err := file.ReadAt(buf, off)
+		return err
+	})
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("chunk %d: %v", index, err)
+	}
+	return &chunk{file: file, first: first, count: count, offsets: offsets}, nil
+}
+
+// parseTrailer decodes the trailer and the entry offsets of a chunk file of the
+// given size, using read to access the file contents.
+func parseTrailer(size int64, read func(buf []byte, off int64) error) (uint64, uint64, []uint64, error) {
+	if size < trailerSize+8 {
+		return 0, 0, nil, errCorruptChunk
+	}
+	trailer := make([]byte, trailerSize)
+	if err := read(trailer, size-trailerSize); err != nil {
+		return 0, 0, nil, err
+	}
+	if !bytes.Equal(trailer[trailerSize-len(chunkMagic):], chunkMagic) {
+		return 0, 0, nil, errCorruptChunk
+	}
+	first := binary.BigEndian.Uint64(trailer[0:8])
+	count := binary.BigEndian.Uint64(trailer[8:16])
+
+	indexSize := (count + 1) * 8
+	if count == 0 || indexSize > uint64(size-trailerSize) {
+		return 0, 0, nil, errCorruptChunk
+	}
+	index := make([]byte, indexSize)
+	if err := read(index, size-trailerSize-int64(indexSize)); err != nil {
+		return 0, 0, nil, err
+	}
+	offsets := make([]uint64, count+1)
+	for i := range offsets {
+		offsets[i] = binary.BigEndian.Uint64(index[i*8:])
+		if (i > 0 && offsets[i] < offsets[i-1]) || offsets[i] > uint64(size-trailerSize)-indexSize {
+			return 0, 0, nil, errCorruptChunk
+		}
+	}
+	return first, count, offsets, nil
+}
+
+// entry reads the entry of a block contained in the chunk.
+func (c *chunk) entry(number uint64) (*Entry, error) {
+	i := number - c.first
+	blob := make([]byte, c.offsets[i+1]-c.offsets[i])
+	if _, err := c.file.ReadAt(blob, int64(c.offsets[i])); err != nil {
+		return nil, err
+	}
+	e, err := decodeEntry(blob)
+	if err != nil {
+		return nil, fmt.Errorf("block #%d: %v", number, err)
+	}
+	if e.Header.Number.Uint64() != number {
+		return nil, fmt.Errorf("block number mismatch: have %d, want %d", e.Header.Number, number)
+	}
+	return e, nil
+}
+
+func (c *chunk) close() error {
+	return c.file.Close()
+}
+
+// writeChunk atomically writes the entries of consecutive blocks into the file
+// of a chunk, replacing any previous one.
+func (a *Archive) writeChunk(index uint64, entries []*Entry) error {
+	var (
+		buf     bytes.Buffer
+		offsets []uint64
+	)
+	for _, e := range entries {
+		blob, err := encodeEntry(e)
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, uint64(buf.Len()))
+		buf.Write(blob)
+	}
+	offsets = append(offsets, uint64(buf.Len()))
+
+	var num [8]byte
+	for _, offset := range offsets {
+		binary.BigEndian.PutUint64(num[:], offset)
+		buf.Write(num[:])
+	}
+	binary.BigEndian.PutUint64(num[:], entries[0].Header.Number.Uint64())
+	buf.Write(num[:])
+	binary.BigEndian.PutUint64(num[:], uint64(len(entries)))
+	buf.Write(num[:])
+	buf.Write(crypto.Keccak256(buf.Bytes()))
+	buf.Write(chunkMagic)
+
+	path := a.chunkPath(index)
+	f, err := os.OpenFile(path+".tmp", os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
@@ -0,0 +1,182 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package blockarchive
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+	testGenesis = &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000000)}},
+	}
+)
+
+// newTestChain creates a chain with the given number of blocks holding a
+// transaction each.
+func newTestChain(t *testing.T, n int) *core.BlockChain {
+	db := ethdb.NewMemDatabase()
+	genesis := testGenesis.MustCommit(db)
+	signer := types.NewEIP155Signer(testGenesis.Config.ChainID)
+
+	blocks, _ := core.GenerateChain(testGenesis.Config, genesis, ethash.NewFaker(), db, n, func(i int, block *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		block.AddTx(tx)
+	})
+	chain := newEmptyChain(t)
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	return chain
+}
+
+// newEmptyChain creates a chain holding only the test genesis block.
+func newEmptyChain(t *testing.T) *core.BlockChain {
+	db := ethdb.NewMemDatabase()
+	testGenesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, testGenesis.Config, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return chain
+}
+
+// Tests that an exported archive gives random access to every block, and that
+// importing it recreates the chain.
+func TestExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blockarchive-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chain := newTestChain(t, 100)
+	defer chain.Stop()
+
+	if _, err := Create(dir, chain.Genesis().Hash(), 16); err != nil {
+		t.Fatalf("failed to create archive: %v", err)
+	}
+	if err := Export(chain, dir, 0, 100, nil); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	archive, err := Open(dir)
+	if err != nil {
+		t.Fatalf("failed to open archive: %v", err)
+	}
+	if chunks, _ := archive.Chunks(); len(chunks) != 7 {
+		t.Fatalf("chunk count mismatch: have %d, want %d", len(chunks), 7)
+	}
+	for number := uint64(0); number <= 100; number++ {
+		e, err := archive.Entry(number)
+		if err != nil {
+			t.Fatalf("block #%d: failed to read entry: %v", number, err)
+		}
+		if hash := chain.GetHeaderByNumber(number).Hash(); e.Header.Hash() != hash {
+			t.Errorf("block #%d: hash mismatch: have %x, want %x", number, e.Header.Hash(), hash)
+		}
+		if err := e.Verify(); err != nil {
+			t.Errorf("block #%d: verification failed: %v", number, err)
+		}
+	}
+	if _, err := archive.Entry(101); err != errNotFound {
+		t.Errorf("block #101: error mismatch: have %v, want %v", err, errNotFound)
+	}
+	// Import the archive in two runs, as if the first one was interrupted
+	imported := newEmptyChain(t)
+	defer imported.Stop()
+
+	if err := insertBlocks(imported, types.Blocks{chain.GetBlockByNumber(1), chain.GetBlockByNumber(2)}, nil); err != nil {
+		t.Fatalf("failed to insert blocks: %v", err)
+	}
+	if err := Import(imported, dir, nil); err != nil {
+		t.Fatalf("failed to import archive: %v", err)
+	}
+	if head := imported.CurrentBlock(); head.Hash() != chain.CurrentBlock().Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), chain.CurrentBlock().NumberU64())
+	}
+}
+
+// Tests that corrupted chunks and entries are rejected.
+func TestCorruption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blockarchive-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	chain := newTestChain(t, 8)
+	defer chain.Stop()
+
+	if err := Export(chain, dir, 0, 8, nil); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	archive, _ := Open(dir)
+
+	// Receipts not matching the header must be rejected
+	e, err := archive.Entry(5)
+	if err != nil {
+		t.Fatalf("failed to read entry: %v", err)
+	}
+	e.Receipts[0].CumulativeGasUsed++
+	if err := e.Verify(); err == nil {
+		t.Errorf("tampered receipts accepted")
+	}
+	// A flipped bit anywhere in a chunk must fail its checksum
+	blob, err := ioutil.ReadFile(archive.chunkPath(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob[len(blob)/3] ^= 0x01
+	if err := ioutil.WriteFile(archive.chunkPath(0), blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := archive.ReadChunk(0); err == nil {
+		t.Errorf("corrupted chunk accepted")
+	}
+	imported := newEmptyChain(t)
+	defer imported.Stop()
+
+	if err := Import(imported, dir, nil); err == nil {
+		t.Errorf("corrupted archive imported")
+	}
+	// Exporting again must replace the corrupted chunk
+	if err := Export(chain, dir, 0, 8, nil); err != nil {
+		t.Fatalf("failed to export chain: %v", err)
+	}
+	if _, err := archive.ReadChunk(0); err != nil {
+		t.Errorf("failed to read repaired chunk: %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package blockarchive
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// importBatchSize is the number of blocks inserted into the chain at once.
+const importBatchSize = 2500
+
+// ErrInterrupted is returned if an export or import is interrupted. Both can be
+// resumed by running them again.
+var ErrInterrupted = errors.New("interrupted")
+
+// Export writes the canonical blocks in the range [first, last] of the chain into
+// the archive in the given directory, creating it if needed. Chunks left over by
+// a previous export of the same blocks are kept, so an interrupted export resumes
+// where it stopped.
+func Export(chain *core.BlockChain, dir string, first uint64, last uint64, interrupt <-chan struct{}) error {
+	if head := chain.CurrentBlock().NumberU64(); last > head {
+		return fmt.Errorf("export range #%d-#%d beyond chain head #%d", first, last, head)
+	}
+	if first > last {
+		return fmt.Errorf("invalid export range #%d-#%d", first, last)
+	}
+	archive, err := Create(dir, chain.Genesis().Hash(), DefaultChunkSize)
+	if err != nil {
+		return err
+	}
+	log.Info("Exporting chain archive", "dir", dir, "first", first, "last", last)
+
+	var (
+		size    = archive.ChunkSize()
+		start   = time.Now()
+		logged  = time.Now()
+		skipped int
+	)
+	for index := first / size; index <= last/size; index++ {
+		from, to := index*size, (index+1)*size-1
+		if from < first {
+			from = first
+		}
+		if to > last {
+			to = last
+		}
+		if archive.hasChunk(chain, index, from, to) {
+			skipped++
+			continue
+		}
+		entries := make([]*Entry, 0, to-from+1)
+		for number := from; number <= to; number++ {
+			block := chain.GetBlockByNumber(number)
+			if block == nil {
+				return fmt.Errorf("export failed on #%d: block not found", number)
+			}
+			entries = append(entries, NewEntry(block, chain.GetReceiptsByHash(block.Hash()), chain.GetTd(block.Hash(), number)))
+		}
+		if err := archive.writeChunk(index, entries); err != nil {
+			return err
+		}
+		select {
+		case <-interrupt:
+			return ErrInterrupted
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting chain archive", "exported", to-first+1, "left", last-to, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Exported chain archive", "dir", dir, "blocks", last-first+1, "reused", skipped, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// hasChunk returns whether the archive already holds an intact chunk of exactly
+// the given blocks of the chain.
+func (a *Archive) hasChunk(chain *core.BlockChain, index uint64, first uint64, last uint64) bool {
+	entries, err := a.ReadChunk(index)
+	if err != nil || entries[0].Header.Number.Uint64() != first || uint64(len(entries)) != last-first+1 {
+		return false
+	}
+	header := chain.GetHeaderByNumber(last)
+	return header != nil && header.Hash() == entries[len(entries)-1].Header.Hash()
+}
+
+// Import inserts the blocks of the archive in the given directory into the chain,
+// verifying the checksums of the chunks and the receipts and total difficulty
+// of every block on the way. Blocks already present in the chain are skipped, so
+// an interrupted import resumes where it stopped.
+func Import(chain *core.BlockChain, dir string, interrupt <-chan struct{}) error {
+	archive, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	if genesis := chain.Genesis().Hash(); archive.Genesis() != genesis {
+		return fmt.Errorf("archive genesis mismatch: have %x, want %x", archive.Genesis(), genesis)
+	}
+	chunks, err := archive.Chunks()
+	if err != nil {
+		return err
+	}
+	log.Info("Importing chain archive", "dir", dir, "chunks", len(chunks))
+
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		imported int
+		parent   *types.Header
+		parentTd *big.Int
+	)
+	for _, index := range chunks {
+		// Skip the chunk if the chain already has its last block
+		last, err := archive.lastHeader(index)
+		if err != nil {
+			return err
+		}
+		if chain.HasBlock(last.Hash(), last.Number.Uint64()) && last.Number.Uint64() <= chain.CurrentBlock().NumberU64() {
+			parent = nil
+			continue
+		}
+		entries, err := archive.ReadChunk(index)
+		if err != nil {
+			return err
+		}
+		blocks := make(types.Blocks, 0, importBatchSize)
+		for _, e := range entries {
+			// Verify the entry against its header and the preceding block
+			if err := e.Verify(); err != nil {
+				return err
+			}
+			number := e.Header.Number.Uint64()
+			if number == 0 {
+				parent, parentTd = e.Header, e.TD
+				continue
+			}
+			if parent == nil || parent.Number.Uint64()+1 != number {
+				if parent = chain.GetHeaderByHash(e.Header.ParentHash); parent == nil {
+					return fmt.Errorf("block #%d: unknown parent %x", number, e.Header.ParentHash)
+				}
+				parentTd = chain.GetTd(parent.Hash(), parent.Number.Uint64())
+			}
+			if parent.Hash() != e.Header.ParentHash {
+				return fmt.Errorf("block #%d: parent hash mismatch: have %x, want %x", number, e.Header.ParentHash, parent.Hash())
+			}
+			if td := new(big.Int).Add(parentTd, e.Header.Difficulty); e.TD == nil || td.Cmp(e.TD) != 0 {
+				return fmt.Errorf("block #%d: total difficulty mismatch: have %v, want %v", number, e.TD, td)
+			}
+			parent, parentTd = e.Header, e.TD
+
+			if number <= chain.CurrentBlock().NumberU64() && chain.HasBlock(e.Header.Hash(), number) {
+				continue
+			}
+			blocks = append(blocks, e.Block())
+			if len(blocks) == cap(blocks) {
+				if err := insertBlocks(chain, blocks, interrupt); err != nil {
+					return err
+				}
+				imported += len(blocks)
+				blocks = blocks[:0]
+			}
+		}
+		if len(blocks) > 0 {
+			if err := insertBlocks(chain, blocks, interrupt); err != nil {
+				return err
+			}
+			imported += len(blocks)
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing chain archive", "chunk", index, "imported", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	log.Info("Imported chain archive", "dir", dir, "blocks", imported, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// insertBlocks inserts a batch of blocks into the chain, unless the import was
+// interrupted.
+func insertBlocks(chain *core.BlockChain, blocks types.Blocks, interrupt <-chan struct{}) error {
+	select {
+	case <-interrupt:
+		return ErrInterrupted
+	default:
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		if n < len(blocks) {
+			return fmt.Errorf("invalid block #%d: %v", blocks[n].NumberU64(), err)
+		}
+		return err
+	}
+	return nil
+}
+
+// lastHeader returns the header of the last block of a chunk.
+func (a *Archive) lastHeader(index uint64) (*types.Header, error) {
+	c, err := a.openChunk(index)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close()
+
+	e, err := c.entry(c.first + c.count - 1)
+	if err != nil {
+		return nil, err
+	}
+	return e.Header, nil
+}
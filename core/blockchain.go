@@ -33,6 +33,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -70,6 +71,12 @@ type CacheConfig struct {
 	Disabled      bool          // Whether to disable trie write caching (archive node)
 	TrieNodeLimit int           // Memory limit (MB) at which to flush the current in-memory trie to disk
 	TrieTimeLimit time.Duration // Time limit after which to flush the current in-memory trie to disk
+	Snapshot      bool          // Whether to maintain a flat snapshot of the recent states for fast reads
+	TxLookupLimit uint64        // Number of recent blocks to keep the transaction lookups of, 0 for all blocks
+	TrieJournal   string        // Path of the journal of the in-memory tries surviving crashes, empty to disable
+	TrieRejournal time.Duration // Time interval to regenerate the trie journal at, 0 to only journal on shutdown
+
+	Fork state.RemoteState // Remote state lazily filling the state of chains forked from another, nil if not forked
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -112,6 +119,7 @@ type BlockChain struct {
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache   state.Database // State database to reuse between imports (contains state cache)
+	snaps        *snapshot.Tree // Flat snapshot of the recent states, nil if disabled
 	bodyCache    *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	blockCache   *lru.Cache     // Cache for the most recent entire blocks
@@ -147,12 +155,16 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 	badBlocks, _ := lru.New(badBlockLimit)
 
+	stateCache := state.NewDatabase(db)
+	if cacheConfig.Fork != nil {
+		stateCache = state.NewForkDatabase(db, cacheConfig.Fork)
+	}
 	bc := &BlockChain{
 		chainConfig:  chainConfig,
 		cacheConfig:  cacheConfig,
 		db:           db,
 		triegc:       prque.New(nil),
-		stateCache:   state.NewDatabase(db),
+		stateCache:   stateCache,
 		quit:         make(chan struct{}),
 		bodyCache:    bodyCache,
 		bodyRLPCache: bodyRLPCache,
@@ -174,6 +186,13 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if bc.genesisBlock == nil {
 		return nil, ErrNoGenesis
 	}
+	// Restore the recent tries cached in memory before the last shutdown or crash,
+	// so the head state doesn't fall back to the last one flushed to disk
+	if bc.trieJournaled() {
+		if err := bc.loadTrieJournal(); err != nil {
+			log.Warn("Failed to load trie cache journal", "err", err)
+		}
+	}
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
@@ -190,6 +209,21 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			}
 		}
 	}
+	// Load the flat snapshot of the head state or generate it in the background.
+	// Forked chains fill their state lazily, so it cannot be walked to generate it.
+	if cacheConfig.Snapshot && cacheConfig.Fork == nil {
+		bc.snaps = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.CurrentBlock().Root())
+	}
+	// Start maintaining the transaction index if it is to be limited, or was
+	// limited before and has to be completed
+	if tail := rawdb.ReadTxIndexTail(bc.db); cacheConfig.TxLookupLimit > 0 || (tail != nil && *tail > 0) {
+		bc.wg.Add(1)
+		go bc.maintainTxIndex()
+	}
+	if bc.trieJournaled() && cacheConfig.TrieRejournal > 0 {
+		bc.wg.Add(1)
+		go bc.rejournalTries()
+	}
 	// Take ownership of this particular state
 	go bc.update()
 	return bc, nil
@@ -275,6 +309,13 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.hc.SetHead(head, delFn)
 	currentHeader := bc.hc.CurrentHeader()
 
+	// Drop the frozen blocks above the new head too
+	if db, ok := bc.db.(ethdb.AncientWriter); ok {
+		if err := db.TruncateAncients(currentHeader.Number.Uint64() + 1); err != nil {
+			return err
+		}
+	}
+
 	// Clear out any stale content from the caches
 	bc.bodyCache.Purge()
 	bc.bodyRLPCache.Purge()
@@ -308,7 +349,11 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	rawdb.WriteHeadBlockHash(bc.db, currentBlock.Hash())
 	rawdb.WriteHeadFastBlockHash(bc.db, currentFastBlock.Hash())
 
-	return bc.loadLastState()
+	if err := bc.loadLastState(); err != nil {
+		return err
+	}
+	bc.rebuildSnapshot(bc.CurrentBlock())
+	return nil
 }
 
 // FastSyncCommitHead sets the current head block to the one defined by the hash
@@ -325,6 +370,7 @@ func (bc *BlockChain) FastSyncCommitHead(hash common.Hash) error {
 	// If all checks out, manually set the head block
 	bc.mu.Lock()
 	bc.currentBlock.Store(block)
+	bc.rebuildSnapshot(block)
 	bc.mu.Unlock()
 
 	log.Info("Committed new head block", "number", block.Number(), "hash", hash)
@@ -376,6 +422,21 @@ func (bc *BlockChain) Processor() Processor {
 	return bc.processor
 }
 
+// VMConfig returns the EVM configuration used to process blocks.
+func (bc *BlockChain) VMConfig() vm.Config {
+	bc.procmu.RLock()
+	defer bc.procmu.RUnlock()
+	return bc.vmConfig
+}
+
+// SetVMConfig sets the EVM configuration used to process blocks, allowing
+// tracers to be attached to a running chain.
+func (bc *BlockChain) SetVMConfig(cfg vm.Config) {
+	bc.procmu.Lock()
+	defer bc.procmu.Unlock()
+	bc.vmConfig = cfg
+}
+
 // State returns a new mutable state based on the current HEAD block.
 func (bc *BlockChain) State() (*state.StateDB, error) {
 	return bc.StateAt(bc.CurrentBlock().Root())
@@ -383,7 +444,40 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
+}
+
+// StateCache returns the caching database underpinning the blockchain instance.
+func (bc *BlockChain) StateCache() state.Database {
+	return bc.stateCache
+}
+
+// Snapshots returns the flat snapshot tree of the recent states, or nil if the
+// snapshot is disabled.
+func (bc *BlockChain) Snapshots() *snapshot.Tree {
+	return bc.snaps
+}
+
+// rebuildSnapshot regenerates the state snapshot for a new head block in the
+// background, if the chain jumped to a state the snapshot tree does not cover.
+func (bc *BlockChain) rebuildSnapshot(head *types.Block) {
+	if bc.snaps == nil || bc.snaps.Snapshot(head.Root()) != nil {
+		return
+	}
+	bc.snaps.Rebuild(head.Root())
+}
+
+// capSnapshots flattens the snapshot layers below the recent states into the disk
+// layer, dropping the layers of the chains abandoned at the same time. If that
+// fails, the snapshot is disabled until it is regenerated on the next start.
+func (bc *BlockChain) capSnapshots(head *types.Block) {
+	if bc.snaps == nil {
+		return
+	}
+	if err := bc.snaps.Cap(head.Root(), triesInMemory); err != nil {
+		log.Warn("Failed to cap state snapshot, disabling", "number", head.Number(), "hash", head.Hash(), "err", err)
+		bc.snaps.Disable()
+	}
 }
 
 // Reset purges the entire blockchain, restoring it to its genesis state.
@@ -657,6 +751,22 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Journal the tries cached in memory to resume from the current head even if
+	// the node crashes before flushing them again
+	if bc.trieJournaled() {
+		if err := bc.journalTries(); err != nil {
+			log.Error("Failed to journal trie cache", "err", err)
+		}
+	}
+	// Flatten the whole snapshot into the disk layer, as diff layers are not
+	// persisted across restarts
+	if bc.snaps != nil {
+		head := bc.CurrentBlock()
+		if err := bc.snaps.Cap(head.Root(), 0); err != nil {
+			log.Error("Failed to flatten state snapshot", "number", head.Number(), "hash", head.Hash(), "err", err)
+		}
+		bc.snaps.Stop()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -739,7 +849,7 @@ func (bc *BlockChain) Rollback(chain []common.Hash) {
 
 // SetReceiptsData computes all the non-consensus fields of the receipts
 func SetReceiptsData(config *params.ChainConfig, block *types.Block, receipts types.Receipts) error {
-	signer := types.MakeSigner(config, block.Number())
+	signer := MakeSigner(config, block.Number())
 
 	transactions, logIndex := block.Transactions(), uint(0)
 	if len(transactions) != len(receipts) {
@@ -792,11 +902,16 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	}
 
 	var (
-		stats = struct{ processed, ignored int32 }{}
-		start = time.Now()
-		bytes = 0
-		batch = bc.db.NewBatch()
+		stats      = struct{ processed, ignored int32 }{}
+		start      = time.Now()
+		bytes      = 0
+		batch      = bc.db.NewBatch()
+		headNumber = bc.CurrentHeader().Number.Uint64()
+		indexTail  uint64
 	)
+	if tail := rawdb.ReadTxIndexTail(bc.db); tail != nil {
+		indexTail = *tail
+	}
 	for i, block := range blockChain {
 		receipts := receiptChain[i]
 		// Short circuit insertion if shutting down or processing failed
@@ -819,7 +934,14 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 		// Write all the data out into the database
 		rawdb.WriteBody(batch, block.Hash(), block.NumberU64(), block.Body())
 		rawdb.WriteReceipts(batch, block.Hash(), block.NumberU64(), receipts)
-		rawdb.WriteTxLookupEntries(batch, block)
+		if bc.txIndexed(block.NumberU64(), headNumber) {
+			rawdb.WriteTxLookupEntries(batch, block)
+		} else if tail := block.NumberU64() + 1; tail > indexTail {
+			// Move the index tail past the skipped block along with its data, the
+			// blocks are inserted in order so those after it are all indexed
+			rawdb.WriteTxIndexTail(batch, tail)
+			indexTail = tail
+		}
 
 		stats.processed++
 
@@ -989,6 +1111,7 @@ func (bc *BlockChain) WriteBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+		bc.capSnapshots(block)
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -1053,7 +1176,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 	defer close(abort)
 
 	// Start a parallel signature recovery (signer will fluke on fork transition, minimal perf loss)
-	senderCacher.recoverFromBlocks(types.MakeSigner(bc.chainConfig, chain[0].Number()), chain)
+	senderCacher.recoverFromBlocks(MakeSigner(bc.chainConfig, chain[0].Number()), chain)
 
 	// Iterate over the blocks and insert when the verifier permits
 	for i, block := range chain {
@@ -1144,12 +1267,12 @@ func (bc *BlockChain) insertChain(chain types.Blocks) (int, []interface{}, []*ty
 		} else {
 			parent = chain[i-1]
 		}
-		state, err := state.New(parent.Root(), bc.stateCache)
+		state, err := state.NewWithSnapshot(parent.Root(), bc.stateCache, bc.snaps)
 		if err != nil {
 			return i, events, coalescedLogs, err
 		}
 		// Process block using the parent state as reference point.
-		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.vmConfig)
+		receipts, logs, usedGas, err := bc.processor.Process(block, state, bc.VMConfig())
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			return i, events, coalescedLogs, err
@@ -1383,6 +1506,9 @@ func (bc *BlockChain) PostChainEvents(events []interface{}, logs []*types.Log) {
 
 		case ChainSideEvent:
 			bc.chainSideFeed.Send(ev)
+
+		case RemovedLogsEvent:
+			bc.rmLogsFeed.Send(ev)
 		}
 	}
 }
@@ -1400,6 +1526,75 @@ func (bc *BlockChain) update() {
 	}
 }
 
+// txIndexed returns whether the transactions of a block are to be indexed, given
+// the number of the head block.
+func (bc *BlockChain) txIndexed(number uint64, head uint64) bool {
+	limit := bc.cacheConfig.TxLookupLimit
+	return limit == 0 || number+limit > head
+}
+
+// maintainTxIndex keeps the transaction lookups covering the configured number
+// of most recent blocks, indexing or unindexing the blocks at the tail of the
+// index as the chain progresses. Updates of the index run in the background,
+// heads arriving meanwhile are caught up with on the next one.
+func (bc *BlockChain) maintainTxIndex() {
+	defer bc.wg.Done()
+
+	headCh := make(chan ChainHeadEvent, 1)
+	sub := bc.SubscribeChainHeadEvent(headCh)
+	defer sub.Unsubscribe()
+
+	done := make(chan struct{}) // Non-nil while an update is running
+	go bc.updateTxIndex(bc.CurrentBlock().NumberU64(), done)
+
+	for {
+		select {
+		case head := <-headCh:
+			if done == nil {
+				done = make(chan struct{})
+				go bc.updateTxIndex(head.Block.NumberU64(), done)
+			}
+		case <-done:
+			done = nil
+
+		case <-bc.quit:
+			if done != nil {
+				<-done
+			}
+			return
+		}
+	}
+}
+
+// updateTxIndex moves the tail of the transaction index to the oldest block to
+// be indexed given the head block, closing done when finished. Databases not
+// tracking the tail have all their transactions indexed. While fast syncing, the
+// fast block counts as the head, so that the skipped blocks are not reindexed.
+func (bc *BlockChain) updateTxIndex(head uint64, done chan struct{}) {
+	defer close(done)
+
+	if fast := bc.CurrentFastBlock().NumberU64(); fast > head {
+		head = fast
+	}
+	var tail, want uint64
+	if stored := rawdb.ReadTxIndexTail(bc.db); stored != nil {
+		tail = *stored
+	}
+	if limit := bc.cacheConfig.TxLookupLimit; limit > 0 && head >= limit {
+		want = head - limit + 1
+	}
+	var err error
+	switch {
+	case want < tail:
+		err = rawdb.IndexTransactions(bc.db, want, tail, bc.quit)
+	case want > tail:
+		err = rawdb.UnindexTransactions(bc.db, tail, want, bc.quit)
+	}
+	if err != nil {
+		log.Error("Failed to update transaction index", "tail", tail, "want", want, "err", err)
+	}
+}
+
 // BadBlocks returns a list of the last 'bad blocks' that the client has seen on the network
 func (bc *BlockChain) BadBlocks() []*types.Block {
 	blocks := make([]*types.Block, 0, bc.badBlocks.Len())
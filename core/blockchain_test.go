@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -1320,6 +1321,113 @@ func TestTrieForkGC(t *testing.T) {
 	}
 }
 
+// Tests that the flat state snapshot follows the canonical chain across reorgs,
+// and that it matches the head state after being flattened on shutdown.
+func TestSnapshotFollowsChain(t *testing.T) {
+	// Generate a canonical chain and a bunch of single block side forks from it
+	engine := ethash.NewFaker()
+
+	db := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2*triesInMemory, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	forks := make([]*types.Block, len(blocks))
+	for i := 0; i < len(forks); i++ {
+		parent := genesis
+		if i > 0 {
+			parent = blocks[i-1]
+		}
+		fork, _ := GenerateChain(params.TestChainConfig, parent, engine, db, 1, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{2}) })
+		forks[i] = fork[0]
+	}
+	// Import the canonical and fork chain side by side, reorging back and forth
+	diskdb := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256 * 1024 * 1024, TrieTimeLimit: 5 * time.Minute, Snapshot: true}
+	chain, err := NewBlockChain(diskdb, cacheConfig, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	for i := 0; i < len(blocks); i++ {
+		if _, err := chain.InsertChain(blocks[i : i+1]); err != nil {
+			t.Fatalf("block %d: failed to insert into chain: %v", i, err)
+		}
+		if _, err := chain.InsertChain(forks[i : i+1]); err != nil {
+			t.Fatalf("fork %d: failed to insert into chain: %v", i, err)
+		}
+	}
+	head := chain.CurrentBlock()
+	if chain.snaps.Snapshot(head.Root()) == nil {
+		t.Fatalf("head state missing from the snapshot tree")
+	}
+	statedb, err := chain.StateAt(head.Root())
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	if balance := statedb.GetBalance(head.Coinbase()); balance.Sign() == 0 {
+		t.Fatalf("coinbase balance missing from head state")
+	}
+	chain.snaps.WaitGeneration()
+	chain.Stop()
+
+	if err := snapshot.VerifyState(diskdb, chain.stateCache.TrieDB(), head.Root()); err != nil {
+		t.Fatalf("flattened snapshot mismatch: %v", err)
+	}
+}
+
+// Tests that rewinding the head keeps the snapshot layers of recent states, and
+// regenerates the snapshot in the background for older ones.
+func TestSnapshotSetHead(t *testing.T) {
+	engine := ethash.NewFaker()
+
+	db := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2*triesInMemory, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+
+	diskdb := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(diskdb)
+
+	cacheConfig := &CacheConfig{Disabled: true, Snapshot: true}
+	chain, err := NewBlockChain(diskdb, cacheConfig, params.TestChainConfig, engine, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	chain.snaps.WaitGeneration()
+
+	// Rewinding within the diff layers must not touch the snapshot on disk
+	root := rawdb.ReadSnapshotRoot(diskdb)
+	if err := chain.SetHead(uint64(len(blocks) - 5)); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if chain.snaps.Snapshot(chain.CurrentBlock().Root()) == nil {
+		t.Fatalf("recent head state missing from the snapshot tree")
+	}
+	if have := rawdb.ReadSnapshotRoot(diskdb); have != root {
+		t.Fatalf("snapshot regenerated for recent head: have root %x, want %x", have, root)
+	}
+	// Rewinding past them regenerates the snapshot of the new head
+	if err := chain.SetHead(10); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	head := chain.CurrentBlock()
+	if head.NumberU64() != 10 {
+		t.Fatalf("head block mismatch: have #%d, want #10", head.NumberU64())
+	}
+	if chain.snaps.Snapshot(head.Root()) == nil {
+		t.Fatalf("old head state missing from the snapshot tree")
+	}
+	chain.snaps.WaitGeneration()
+	if err := snapshot.VerifyState(diskdb, chain.stateCache.TrieDB(), head.Root()); err != nil {
+		t.Fatalf("regenerated snapshot mismatch: %v", err)
+	}
+}
+
 // Tests that doing large reorgs works even if the state associated with the
 // forking point is not available any more.
 func TestLargeReorgTrieGC(t *testing.T) {
@@ -1483,3 +1591,110 @@ func BenchmarkBlockChain_1x1000Executions(b *testing.B) {
 
 	benchmarkLargeNumberOfValueToNonexisting(b, numTxs, numBlocks, recipientFn, dataFn)
 }
+
+// Tests that only the transactions of the configured number of recent blocks are
+// indexed, and that lifting the limit indexes the older blocks again.
+func TestTxLookupLimit(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		gendb   = ethdb.NewMemDatabase()
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 64, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	// waitTail waits for the background indexer to move the tail of the index
+	waitTail := func(want uint64) {
+		for i := 0; i < 100; i++ {
+			if tail := rawdb.ReadTxIndexTail(db); tail != nil && *tail == want {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("transaction index tail mismatch: have %v, want %d", rawdb.ReadTxIndexTail(db), want)
+	}
+	// checkIndexed verifies that the transactions of blocks from the given one are
+	// indexed and those of the blocks before are not
+	checkIndexed := func(from uint64) {
+		for _, block := range blocks {
+			hash := block.Transactions()[0].Hash()
+			if tx, _, _, _ := rawdb.ReadTransaction(db, hash); (tx != nil) != (block.NumberU64() >= from) {
+				t.Errorf("block #%d: transaction indexed %v, want %v", block.NumberU64(), tx != nil, block.NumberU64() >= from)
+			}
+		}
+	}
+	chain, _ := NewBlockChain(db, &CacheConfig{TxLookupLimit: 16}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	waitTail(64 - 16 + 1)
+	chain.Stop()
+	checkIndexed(64 - 16 + 1)
+
+	// Restart the chain without a limit, and check that everything is indexed
+	chain, _ = NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{})
+	waitTail(0)
+	chain.Stop()
+	checkIndexed(0)
+}
+
+// Tests that fast sync skips indexing the transactions of blocks beyond the limit
+// and records the tail of the index for them.
+func TestTxLookupLimitFastSync(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(1000000000)}},
+		}
+		gendb   = ethdb.NewMemDatabase()
+		genesis = gspec.MustCommit(gendb)
+		signer  = types.NewEIP155Signer(gspec.Config.ChainID)
+	)
+	blocks, receipts := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 64, func(i int, block *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(block.TxNonce(address), common.Address{0x00}, big.NewInt(1000), params.TxGas, nil, nil), signer, key)
+		if err != nil {
+			panic(err)
+		}
+		block.AddTx(tx)
+	})
+	db := ethdb.NewMemDatabase()
+	gspec.MustCommit(db)
+
+	chain, _ := NewBlockChain(db, &CacheConfig{TxLookupLimit: 16}, gspec.Config, ethash.NewFaker(), vm.Config{})
+	defer chain.Stop()
+
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	if n, err := chain.InsertHeaderChain(headers, 1); err != nil {
+		t.Fatalf("failed to insert header %d: %v", n, err)
+	}
+	if n, err := chain.InsertReceiptChain(blocks, receipts); err != nil {
+		t.Fatalf("failed to insert receipt %d: %v", n, err)
+	}
+	if tail := rawdb.ReadTxIndexTail(db); tail == nil || *tail != 64-16+1 {
+		t.Fatalf("transaction index tail mismatch: have %v, want %d", tail, 64-16+1)
+	}
+	for _, block := range blocks {
+		hash := block.Transactions()[0].Hash()
+		if tx, _, _, _ := rawdb.ReadTransaction(db, hash); (tx != nil) != (block.NumberU64() > 64-16) {
+			t.Errorf("block #%d: transaction indexed %v, want %v", block.NumberU64(), tx != nil, block.NumberU64() > 64-16)
+		}
+	}
+}
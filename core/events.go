@@ -24,6 +24,49 @@ import (
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// TxEvictionReason is the cause of transactions leaving the pool unincluded.
+type TxEvictionReason string
+
+const (
+	TxEvictionReplaced    TxEvictionReason = "replaced"      // Superseded by a transaction with the same nonce
+	TxEvictionUnderpriced TxEvictionReason = "underpriced"   // Pushed out of a full pool by better paying ones
+	TxEvictionLifetime    TxEvictionReason = "lifetime"      // Queued for longer than the pool lifetime
+	TxEvictionRemoved     TxEvictionReason = "removed"       // Removed on request of the node operator
+	TxEvictionNonceTooLow TxEvictionReason = "nonce-too-low" // Nonce used up by another transaction of the sender
+	TxEvictionNoFunds     TxEvictionReason = "nofunds"       // Sender can no longer pay for the transaction
+	TxEvictionRateLimit   TxEvictionReason = "ratelimit"     // Over the slots allowed for the sender or the pool
+)
+
+// EvictedTxsEvent is posted when a batch of transactions is evicted from the
+// transaction pool.
+type EvictedTxsEvent struct {
+	Txs    []*types.Transaction
+	Reason TxEvictionReason
+}
+
+// TxLifecycleStage is a step in the life of a transaction in the pool.
+type TxLifecycleStage string
+
+const (
+	TxLifecycleQueued             TxLifecycleStage = "queued"                // Entered the non-executable queue
+	TxLifecyclePromoted           TxLifecycleStage = "promoted"              // Became executable
+	TxLifecycleIncluded           TxLifecycleStage = "included"              // Included in a new chain head
+	TxLifecycleReplaced           TxLifecycleStage = "replaced"              // See TxEvictionReplaced
+	TxLifecycleDroppedUnderpriced TxLifecycleStage = "dropped-underpriced"   // See TxEvictionUnderpriced
+	TxLifecycleDroppedLifetime    TxLifecycleStage = "dropped-lifetime"      // See TxEvictionLifetime
+	TxLifecycleDroppedRemoved     TxLifecycleStage = "dropped-removed"       // See TxEvictionRemoved
+	TxLifecycleDroppedNonceTooLow TxLifecycleStage = "dropped-nonce-too-low" // See TxEvictionNonceTooLow
+	TxLifecycleDroppedNoFunds     TxLifecycleStage = "dropped-nofunds"       // See TxEvictionNoFunds
+	TxLifecycleDroppedRateLimit   TxLifecycleStage = "dropped-ratelimit"     // See TxEvictionRateLimit
+)
+
+// TxLifecycleEvent is posted when a batch of pooled transactions reaches a new
+// stage of its life.
+type TxLifecycleEvent struct {
+	Txs   []*types.Transaction
+	Stage TxLifecycleStage
+}
+
 // PendingLogsEvent is posted pre mining and notifies of pending logs.
 type PendingLogsEvent struct {
 	Logs []*types.Log
@@ -377,11 +377,25 @@ func DefaultRinkebyGenesisBlock() *Genesis {
 	}
 }
 
+// ForkGenesisBlock returns the genesis of a local chain forked from the state of
+// a remote one at the given block. The allocations of the base genesis override
+// the remote state, and the hash of the remote block is kept as mix digest to
+// tell apart the chains forked at different blocks.
+func ForkGenesisBlock(base *Genesis, header *types.Header) *Genesis {
+	genesis := *base
+	genesis.Timestamp = header.Time.Uint64()
+	genesis.Mixhash = header.Hash()
+	return &genesis
+}
+
 // DeveloperGenesisBlock returns the 'geth --dev' genesis block. Note, this must
 // be seeded with the
 func DeveloperGenesisBlock(period uint64, faucet common.Address) *Genesis {
 	// Override the default period to the user requested one
 	config := *params.AllThunderProtocolChanges
+	thunder := *config.Thunder
+	thunder.Dev = true
+	config.Thunder = &thunder
 
 	// Assemble and return the genesis with the precompiles and faucet pre-funded
 	return &Genesis{
@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Transactions sent on behalf of accounts without a key on dev chains carry a
+// placeholder signature instead of a real one: the sender address as R and one
+// as S. The sender can be told from the transaction alone, surviving restarts,
+// and transactions of different senders never share a hash. Only the signers of
+// dev chains accept such signatures, no other node does, and the pool admits them
+// for the accounts it was told to impersonate only.
+
+// Impersonate returns a copy of the transaction with the placeholder signature
+// of the given sender. The signer only serves to derive the V value, the sender
+// is resolved by the signers returned by MakeSigner and WithImpersonation.
+func Impersonate(tx *types.Transaction, signer types.Signer, from common.Address) (*types.Transaction, error) {
+	sig := make([]byte, 65)
+	copy(sig[32-common.AddressLength:32], from[:])
+	sig[63] = 1
+
+	return tx.WithSignature(signer, sig)
+}
+
+// impersonatedSender returns the sender of a transaction with a placeholder
+// signature, if it has one.
+func impersonatedSender(tx *types.Transaction) (common.Address, bool) {
+	_, r, s := tx.RawSignatureValues()
+	if s.Cmp(common.Big1) != 0 || r.BitLen() > 8*common.AddressLength {
+		return common.Address{}, false
+	}
+	return common.BigToAddress(r), true
+}
+
+// impersonationSigner resolves the senders of transactions with a placeholder
+// signature, deferring to the wrapped signer for all others.
+type impersonationSigner struct {
+	types.Signer
+}
+
+// Sender implements types.Signer, returning the impersonated sender of the
+// transaction, or the one recovered from its signature.
+func (s impersonationSigner) Sender(tx *types.Transaction) (common.Address, error) {
+	if from, ok := impersonatedSender(tx); ok {
+		return from, nil
+	}
+	return s.Signer.Sender(tx)
+}
+
+// Equal implements types.Signer. Senders resolved by the impersonation signer
+// are valid for the wrapped signer too, but not the other way around, as only
+// the former knows the placeholder signatures.
+func (s impersonationSigner) Equal(s2 types.Signer) bool {
+	if other, ok := s2.(impersonationSigner); ok {
+		s2 = other.Signer
+	}
+	return s.Signer.Equal(s2)
+}
+
+// WithImpersonation wraps the signer to resolve impersonated senders if the
+// chain is a dev chain, returning it unchanged otherwise.
+func WithImpersonation(config *params.ChainConfig, signer types.Signer) types.Signer {
+	if config.Thunder == nil || !config.Thunder.Dev {
+		return signer
+	}
+	return impersonationSigner{signer}
+}
+
+// MakeSigner returns the signer of the given chain config and block number like
+// types.MakeSigner, resolving the impersonated senders of dev chains.
+func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) types.Signer {
+	return WithImpersonation(config, types.MakeSigner(config, blockNumber))
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests that impersonated transactions of different senders don't collide, and
+// that their senders are resolved from the transactions alone on dev chains only.
+func TestImpersonation(t *testing.T) {
+	devConfig := *params.TestThunderChainConfig
+	devConfig.Thunder = &params.ThunderConfig{Dev: true}
+
+	senders := []common.Address{common.HexToAddress("0xb0b"), common.HexToAddress("0xa11ce")}
+	txs := make([]*types.Transaction, len(senders))
+	for i, from := range senders {
+		tx := types.NewTransaction(0, common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+		impersonated, err := Impersonate(tx, types.MakeSigner(&devConfig, common.Big0), from)
+		if err != nil {
+			t.Fatalf("failed to impersonate %x: %v", from, err)
+		}
+		txs[i] = impersonated
+	}
+	if txs[0].Hash() == txs[1].Hash() {
+		t.Fatalf("impersonated transactions of different senders share hash %x", txs[0].Hash())
+	}
+	for i, tx := range txs {
+		// Decoded copies have no cached sender, as after a restart
+		blob, err := rlp.EncodeToBytes(tx)
+		if err != nil {
+			t.Fatalf("tx %d: failed to encode: %v", i, err)
+		}
+		dev, plain := new(types.Transaction), new(types.Transaction)
+		if err := rlp.DecodeBytes(blob, dev); err != nil {
+			t.Fatalf("tx %d: failed to decode: %v", i, err)
+		}
+		if err := rlp.DecodeBytes(blob, plain); err != nil {
+			t.Fatalf("tx %d: failed to decode: %v", i, err)
+		}
+		if from, err := types.Sender(MakeSigner(&devConfig, common.Big1), dev); err != nil || from != senders[i] {
+			t.Errorf("tx %d: dev sender mismatch: have %x (%v), want %x", i, from, err, senders[i])
+		}
+		if from, _ := types.Sender(MakeSigner(params.TestThunderChainConfig, common.Big1), plain); from == senders[i] {
+			t.Errorf("tx %d: impersonated sender resolved outside of dev chain", i)
+		}
+	}
+	// The pool of a dev chain accepts them from impersonated accounts only
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	pool := NewTxPool(testTxPoolConfig, &devConfig, blockchain)
+	defer pool.Stop()
+
+	for _, from := range senders {
+		pool.currentState.AddBalance(from, big.NewInt(1000000000))
+	}
+	for i, err := range pool.AddRemotes(txs) {
+		if err != ErrInvalidSender {
+			t.Errorf("tx %d: non-impersonated transaction error mismatch: have %v, want %v", i, err, ErrInvalidSender)
+		}
+	}
+	for _, from := range senders {
+		pool.Impersonate(from)
+	}
+	pool.StopImpersonating(senders[1])
+
+	if err := pool.AddRemote(txs[0]); err != nil {
+		t.Errorf("failed to add impersonated transaction: %v", err)
+	}
+	if err := pool.AddRemote(txs[1]); err != ErrInvalidSender {
+		t.Errorf("no longer impersonated transaction error mismatch: have %v, want %v", err, ErrInvalidSender)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+}
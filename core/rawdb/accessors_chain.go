@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -30,12 +31,41 @@ import (
 // ReadCanonicalHash retrieves the hash assigned to a canonical block number.
 func ReadCanonicalHash(db DatabaseReader, number uint64) common.Hash {
 	data, _ := db.Get(headerHashKey(number))
+	if len(data) == 0 {
+		data = readAncient(db, freezerHashTable, number)
+	}
 	if len(data) == 0 {
 		return common.Hash{}
 	}
 	return common.BytesToHash(data)
 }
 
+// readAncient retrieves a frozen item of a canonical block, if the database has
+// a freezer.
+func readAncient(db DatabaseReader, kind string, number uint64) []byte {
+	if db, ok := db.(ethdb.AncientReader); ok {
+		data, _ := db.Ancient(kind, number)
+		return data
+	}
+	return nil
+}
+
+// isAncient returns whether the block with the given hash is frozen, i.e. it is
+// the canonical block of its number and older than the blocks in the key-value
+// store.
+func isAncient(db DatabaseReader, hash common.Hash, number uint64) bool {
+	data := readAncient(db, freezerHashTable, number)
+	return len(data) > 0 && common.BytesToHash(data) == hash
+}
+
+// readAncientOf retrieves a frozen item of the block with the given hash.
+func readAncientOf(db DatabaseReader, kind string, hash common.Hash, number uint64) []byte {
+	if !isAncient(db, hash, number) {
+		return nil
+	}
+	return readAncient(db, kind, number)
+}
+
 // WriteCanonicalHash stores the hash assigned to a canonical block number.
 func WriteCanonicalHash(db DatabaseWriter, hash common.Hash, number uint64) {
 	if err := db.Put(headerHashKey(number), hash.Bytes()); err != nil {
@@ -129,13 +159,16 @@ func WriteFastTrieProgress(db DatabaseWriter, count uint64) {
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(number, hash))
+	if len(data) == 0 {
+		data = readAncientOf(db, freezerHeaderTable, hash, number)
+	}
 	return data
 }
 
 // HasHeader verifies the existence of a block header corresponding to the hash.
 func HasHeader(db DatabaseReader, hash common.Hash, number uint64) bool {
 	if has, err := db.Has(headerKey(number, hash)); !has || err != nil {
-		return false
+		return isAncient(db, hash, number)
 	}
 	return true
 }
@@ -191,6 +224,9 @@ func DeleteHeader(db DatabaseDeleter, hash common.Hash, number uint64) {
 // ReadBodyRLP retrieves the block body (transactions and uncles) in RLP encoding.
 func ReadBodyRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(blockBodyKey(number, hash))
+	if len(data) == 0 {
+		data = readAncientOf(db, freezerBodiesTable, hash, number)
+	}
 	return data
 }
 
@@ -204,7 +240,7 @@ func WriteBodyRLP(db DatabaseWriter, hash common.Hash, number uint64, rlp rlp.Ra
 // HasBody verifies the existence of a block body corresponding to the hash.
 func HasBody(db DatabaseReader, hash common.Hash, number uint64) bool {
 	if has, err := db.Has(blockBodyKey(number, hash)); !has || err != nil {
-		return false
+		return isAncient(db, hash, number)
 	}
 	return true
 }
@@ -241,7 +277,7 @@ func DeleteBody(db DatabaseDeleter, hash common.Hash, number uint64) {
 
 // ReadTd retrieves a block's total difficulty corresponding to the hash.
 func ReadTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
-	data, _ := db.Get(headerTDKey(number, hash))
+	data := ReadTdRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -253,6 +289,15 @@ func ReadTd(db DatabaseReader, hash common.Hash, number uint64) *big.Int {
 	return td
 }
 
+// ReadTdRLP retrieves a block's total difficulty in its raw RLP database encoding.
+func ReadTdRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(headerTDKey(number, hash))
+	if len(data) == 0 {
+		data = readAncientOf(db, freezerDifficultyTable, hash, number)
+	}
+	return data
+}
+
 // WriteTd stores the total difficulty of a block into the database.
 func WriteTd(db DatabaseWriter, hash common.Hash, number uint64, td *big.Int) {
 	data, err := rlp.EncodeToBytes(td)
@@ -274,7 +319,7 @@ func DeleteTd(db DatabaseDeleter, hash common.Hash, number uint64) {
 // ReadReceipts retrieves all the transaction receipts belonging to a block.
 func ReadReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Receipts {
 	// Retrieve the flattened receipt slice
-	data, _ := db.Get(blockReceiptsKey(number, hash))
+	data := ReadReceiptsRLP(db, hash, number)
 	if len(data) == 0 {
 		return nil
 	}
@@ -291,6 +336,16 @@ func ReadReceipts(db DatabaseReader, hash common.Hash, number uint64) types.Rece
 	return receipts
 }
 
+// ReadReceiptsRLP retrieves all the transaction receipts belonging to a block in
+// their raw RLP database encoding.
+func ReadReceiptsRLP(db DatabaseReader, hash common.Hash, number uint64) rlp.RawValue {
+	data, _ := db.Get(blockReceiptsKey(number, hash))
+	if len(data) == 0 {
+		data = readAncientOf(db, freezerReceiptTable, hash, number)
+	}
+	return data
+}
+
 // WriteReceipts stores all the transaction receipts belonging to a block.
 func WriteReceipts(db DatabaseWriter, hash common.Hash, number uint64, receipts types.Receipts) {
 	// Convert the receipts into their storage form and serialize them
@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReadForkAccount retrieves the cached RLP encoding of an account of the remote
+// state a chain is forked from, empty if the account does not exist remotely.
+// The second return value reports whether the account was cached at all.
+func ReadForkAccount(db DatabaseReader, addr common.Address) ([]byte, bool) {
+	data, err := db.Get(forkAccountKey(addr))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// WriteForkAccount caches the RLP encoding of an account of the remote state a
+// chain is forked from.
+func WriteForkAccount(db DatabaseWriter, addr common.Address, enc []byte) {
+	if err := db.Put(forkAccountKey(addr), enc); err != nil {
+		log.Crit("Failed to store fork account", "err", err)
+	}
+}
+
+// IterateForkAccounts calls fn with the address and cached RLP encoding of every
+// account of the remote state a chain is forked from, stopping at the first error.
+func IterateForkAccounts(db ethdb.Database, fn func(addr common.Address, enc []byte) error) error {
+	it := KeyValueStore(db).NewIteratorWithPrefix(forkAccountPrefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(forkAccountPrefix)+common.AddressLength {
+			continue
+		}
+		if err := fn(common.BytesToAddress(key[len(forkAccountPrefix):]), it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// ReadForkStorage retrieves a cached storage slot of the remote state a chain is
+// forked from. The second return value reports whether the slot was cached.
+func ReadForkStorage(db DatabaseReader, addrHash common.Hash, slot common.Hash) (common.Hash, bool) {
+	data, err := db.Get(forkStorageKey(addrHash, slot))
+	if err != nil {
+		return common.Hash{}, false
+	}
+	return common.BytesToHash(data), true
+}
+
+// WriteForkStorage caches a storage slot of the remote state a chain is forked
+// from.
+func WriteForkStorage(db DatabaseWriter, addrHash common.Hash, slot common.Hash, value common.Hash) {
+	if err := db.Put(forkStorageKey(addrHash, slot), value.Bytes()); err != nil {
+		log.Crit("Failed to store fork storage slot", "err", err)
+	}
+}
+
+// HasForkDeleted reports whether an account was deleted since the chain was
+// forked from the remote state, so that its remote storage is gone too.
+func HasForkDeleted(db DatabaseReader, addrHash common.Hash) bool {
+	has, _ := db.Has(forkDeletedKey(addrHash))
+	return has
+}
+
+// WriteForkDeleted marks an account as deleted since the chain was forked from
+// the remote state.
+func WriteForkDeleted(db DatabaseWriter, addrHash common.Hash) {
+	if err := db.Put(forkDeletedKey(addrHash), []byte{0x01}); err != nil {
+		log.Crit("Failed to store fork account deletion", "err", err)
+	}
+}
@@ -17,6 +17,8 @@
 package rawdb
 
 import (
+	"encoding/binary"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
@@ -57,6 +59,25 @@ func WriteTxLookupEntries(db DatabaseWriter, block *types.Block) {
 	}
 }
 
+// ReadTxIndexTail retrieves the number of the oldest block whose transactions
+// are indexed, or nil if it was never recorded.
+func ReadTxIndexTail(db DatabaseReader) *uint64 {
+	data, _ := db.Get(txIndexTailKey)
+	if len(data) != 8 {
+		return nil
+	}
+	number := binary.BigEndian.Uint64(data)
+	return &number
+}
+
+// WriteTxIndexTail stores the number of the oldest block whose transactions are
+// indexed.
+func WriteTxIndexTail(db DatabaseWriter, number uint64) {
+	if err := db.Put(txIndexTailKey, encodeBlockNumber(number)); err != nil {
+		log.Crit("Failed to store transaction index tail", "err", err)
+	}
+}
+
 // DeleteTxLookupEntry removes all transaction data associated with a hash.
 func DeleteTxLookupEntry(db DatabaseDeleter, hash common.Hash) {
 	db.Delete(txLookupKey(hash))
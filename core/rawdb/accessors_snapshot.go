@@ -0,0 +1,91 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ReadSnapshotRoot retrieves the root of the state the flat snapshot on disk
+// belongs to.
+func ReadSnapshotRoot(db DatabaseReader) common.Hash {
+	data, _ := db.Get(snapshotRootKey)
+	if len(data) != common.HashLength {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteSnapshotRoot stores the root of the state the flat snapshot on disk
+// belongs to.
+func WriteSnapshotRoot(db DatabaseWriter, root common.Hash) {
+	if err := db.Put(snapshotRootKey, root.Bytes()); err != nil {
+		log.Crit("Failed to store snapshot root", "err", err)
+	}
+}
+
+// DeleteSnapshotRoot invalidates the flat snapshot on disk.
+func DeleteSnapshotRoot(db DatabaseDeleter) {
+	if err := db.Delete(snapshotRootKey); err != nil {
+		log.Crit("Failed to remove snapshot root", "err", err)
+	}
+}
+
+// ReadAccountSnapshot retrieves the account trie value of an account from the
+// flat snapshot.
+func ReadAccountSnapshot(db DatabaseReader, hash common.Hash) []byte {
+	data, _ := db.Get(snapshotAccountKey(hash))
+	return data
+}
+
+// WriteAccountSnapshot stores the account trie value of an account into the
+// flat snapshot.
+func WriteAccountSnapshot(db DatabaseWriter, hash common.Hash, entry []byte) {
+	if err := db.Put(snapshotAccountKey(hash), entry); err != nil {
+		log.Crit("Failed to store snapshot account", "err", err)
+	}
+}
+
+// DeleteAccountSnapshot removes an account from the flat snapshot.
+func DeleteAccountSnapshot(db DatabaseDeleter, hash common.Hash) {
+	if err := db.Delete(snapshotAccountKey(hash)); err != nil {
+		log.Crit("Failed to delete snapshot account", "err", err)
+	}
+}
+
+// ReadStorageSnapshot retrieves the storage trie value of a storage slot from
+// the flat snapshot.
+func ReadStorageSnapshot(db DatabaseReader, accountHash, storageHash common.Hash) []byte {
+	data, _ := db.Get(snapshotStorageKey(accountHash, storageHash))
+	return data
+}
+
+// WriteStorageSnapshot stores the storage trie value of a storage slot into the
+// flat snapshot.
+func WriteStorageSnapshot(db DatabaseWriter, accountHash, storageHash common.Hash, entry []byte) {
+	if err := db.Put(snapshotStorageKey(accountHash, storageHash), entry); err != nil {
+		log.Crit("Failed to store snapshot storage", "err", err)
+	}
+}
+
+// DeleteStorageSnapshot removes a storage slot from the flat snapshot.
+func DeleteStorageSnapshot(db DatabaseDeleter, accountHash, storageHash common.Hash) {
+	if err := db.Delete(snapshotStorageKey(accountHash, storageHash)); err != nil {
+		log.Crit("Failed to delete snapshot storage", "err", err)
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// errUnknownTable is returned if the user attempts to read from a table that
+	// is not tracked by the freezer.
+	errUnknownTable = errors.New("unknown table")
+
+	// errGenesisMismatch is returned if the frozen chain data does not belong to
+	// the chain of the key-value store.
+	errGenesisMismatch = errors.New("frozen genesis mismatch")
+)
+
+const (
+	// freezerRecheckInterval is the frequency to check the key-value database for
+	// chain progression that might permit new blocks to be frozen into immutable
+	// storage.
+	freezerRecheckInterval = time.Minute
+
+	// freezerBatchLimit is the maximum number of blocks to freeze in one batch
+	// before syncing the tables and deleting the data from the key-value store.
+	freezerBatchLimit = 30000
+)
+
+// freezer is a store of immutable chain data, with one append-only table per
+// kind of item and one item per block. Blocks are moved into it from the
+// key-value store once they are old enough to never be reorganised.
+type freezer struct {
+	frozen    uint64 // Number of blocks already frozen (atomically accessed)
+	threshold uint64 // Number of recent blocks kept in the key-value store
+
+	tables     map[string]*freezerTable // Data tables for storing everything
+	lock       sync.Mutex               // Lock serializing appends and truncations
+	freezeLock sync.Mutex               // Lock held across freezing batches, serializing them with truncations
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFreezer creates a chain freezer in the given directory, truncating all its
+// tables to the blocks that were fully frozen.
+func newFreezer(datadir string) (*freezer, error) {
+	freezer := &freezer{
+		threshold: params.ImmutabilityThreshold,
+		tables:    make(map[string]*freezerTable),
+		quit:      make(chan struct{}),
+	}
+	for name, disableSnappy := range freezerNoSnappy {
+		table, err := newFreezerTable(datadir, name, !disableSnappy)
+		if err != nil {
+			for _, table := range freezer.tables {
+				table.Close()
+			}
+			return nil, err
+		}
+		freezer.tables[name] = table
+	}
+	if err := freezer.repair(); err != nil {
+		freezer.Close()
+		return nil, err
+	}
+	log.Info("Opened ancient database", "database", datadir, "frozen", freezer.frozen)
+	return freezer, nil
+}
+
+// repair truncates all tables to the same number of items.
+func (f *freezer) repair() error {
+	min := uint64(0)
+	for i, table := range f.valueTables() {
+		if items := table.Items(); i == 0 || items < min {
+			min = items
+		}
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(min); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, min)
+	return nil
+}
+
+// valueTables returns the tables of the freezer as a slice.
+func (f *freezer) valueTables() []*freezerTable {
+	tables := make([]*freezerTable, 0, len(f.tables))
+	for _, table := range f.tables {
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// Close stops the freezing and closes all the tables.
+func (f *freezer) Close() error {
+	select {
+	case <-f.quit:
+	default:
+		close(f.quit)
+	}
+	f.wg.Wait()
+
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// HasAncient returns whether an item of the given kind is frozen.
+func (f *freezer) HasAncient(kind string, number uint64) (bool, error) {
+	if table := f.tables[kind]; table != nil {
+		return number < table.Items(), nil
+	}
+	return false, nil
+}
+
+// Ancient retrieves a frozen item of the given kind.
+func (f *freezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if table := f.tables[kind]; table != nil {
+		return table.Retrieve(number)
+	}
+	return nil, errUnknownTable
+}
+
+// Ancients returns the number of frozen blocks.
+func (f *freezer) Ancients() (uint64, error) {
+	return atomic.LoadUint64(&f.frozen), nil
+}
+
+// AppendAncient freezes the next block. If any of its items fails to be added,
+// the tables are rolled back to the previous block.
+func (f *freezer) AppendAncient(number uint64, hash, header, body, receipts, td []byte) (err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if frozen := atomic.LoadUint64(&f.frozen); number != frozen {
+		return fmt.Errorf("%v: have %d, got %d", errOutOrderInsertion, frozen, number)
+	}
+	defer func() {
+		if err != nil {
+			for _, table := range f.tables {
+				table.truncate(number)
+			}
+		}
+	}()
+	items := map[string][]byte{
+		freezerHashTable:       hash,
+		freezerHeaderTable:     header,
+		freezerBodiesTable:     body,
+		freezerReceiptTable:    receipts,
+		freezerDifficultyTable: td,
+	}
+	for name, item := range items {
+		if err := f.tables[name].Append(number, item); err != nil {
+			return fmt.Errorf("failed to append %s #%d: %v", name, number, err)
+		}
+	}
+	atomic.AddUint64(&f.frozen, 1)
+	return nil
+}
+
+// TruncateAncients discards all frozen blocks from the given number on, waiting
+// for a running freezing batch to finish first.
+func (f *freezer) TruncateAncients(items uint64) error {
+	f.freezeLock.Lock()
+	defer f.freezeLock.Unlock()
+
+	return f.truncate(items)
+}
+
+// truncate discards all frozen blocks from the given number on.
+func (f *freezer) truncate(items uint64) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if atomic.LoadUint64(&f.frozen) <= items {
+		return nil
+	}
+	for _, table := range f.tables {
+		if err := table.truncate(items); err != nil {
+			return err
+		}
+	}
+	atomic.StoreUint64(&f.frozen, items)
+	return nil
+}
+
+// Sync flushes all the tables to disk.
+func (f *freezer) Sync() error {
+	var errs []error
+	for _, table := range f.tables {
+		if err := table.Sync(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
+
+// freeze is a background loop moving the blocks which became immutable out of
+// the key-value store into the freezer.
+func (f *freezer) freeze(db ethdb.Database) {
+	defer f.wg.Done()
+
+	for {
+		frozen, err := f.freezeBatch(db)
+		if err != nil {
+			log.Error("Failed to freeze chain segment", "err", err)
+		}
+		if err != nil || frozen == 0 {
+			select {
+			case <-time.After(freezerRecheckInterval):
+			case <-f.quit:
+				return
+			}
+		}
+		select {
+		case <-f.quit:
+			return
+		default:
+		}
+	}
+}
+
+// freezeBatch moves the next batch of immutable blocks into the freezer and
+// returns their number. The blocks are deleted from the key-value store only
+// once they are synced to disk. The genesis block is kept in the key-value store
+// for the chain configuration lookups. Truncations wait for the whole batch, so
+// a chain rewound meanwhile is noticed before the blocks are deleted.
+func (f *freezer) freezeBatch(db ethdb.Database) (int, error) {
+	f.freezeLock.Lock()
+	defer f.freezeLock.Unlock()
+
+	hash := ReadHeadBlockHash(db)
+	if hash == (common.Hash{}) {
+		return 0, nil
+	}
+	head := ReadHeaderNumber(db, hash)
+	if head == nil || *head < f.threshold {
+		return 0, nil
+	}
+	first, _ := f.Ancients()
+	limit := *head - f.threshold
+	if limit < first {
+		return 0, nil
+	}
+	if limit-first >= freezerBatchLimit {
+		limit = first + freezerBatchLimit - 1
+	}
+	var hashes []common.Hash
+	for number := first; number <= limit; number++ {
+		hash := ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) {
+			return len(hashes), fmt.Errorf("canonical hash missing #%d", number)
+		}
+		header := ReadHeaderRLP(db, hash, number)
+		if len(header) == 0 {
+			return len(hashes), fmt.Errorf("block header missing #%d [%x…]", number, hash[:4])
+		}
+		body := ReadBodyRLP(db, hash, number)
+		if len(body) == 0 {
+			return len(hashes), fmt.Errorf("block body missing #%d [%x…]", number, hash[:4])
+		}
+		receipts := ReadReceiptsRLP(db, hash, number)
+		if len(receipts) == 0 {
+			return len(hashes), fmt.Errorf("block receipts missing #%d [%x…]", number, hash[:4])
+		}
+		td := ReadTdRLP(db, hash, number)
+		if len(td) == 0 {
+			return len(hashes), fmt.Errorf("total difficulty missing #%d [%x…]", number, hash[:4])
+		}
+		if err := f.AppendAncient(number, hash.Bytes(), header, body, receipts, td); err != nil {
+			return len(hashes), err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := f.Sync(); err != nil {
+		log.Crit("Failed to flush frozen tables", "err", err)
+	}
+	// Make sure the chain was not rewound while freezing, dropping the batch from
+	// the freezer otherwise and keeping the blocks in the key-value store
+	rewound := true
+	if hash := ReadHeadBlockHash(db); hash != (common.Hash{}) {
+		if head := ReadHeaderNumber(db, hash); head != nil && *head >= limit+f.threshold {
+			rewound = false
+		}
+	}
+	for i := 0; i < len(hashes) && !rewound; i++ {
+		rewound = ReadCanonicalHash(db, first+uint64(i)) != hashes[i]
+	}
+	if rewound {
+		log.Warn("Chain rewound while freezing, dropping segment", "number", first, "blocks", len(hashes))
+		if err := f.truncate(first); err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+	// Wipe the frozen blocks from the key-value store, keeping the hash to number
+	// mappings for the hash based lookups.
+	batch := db.NewBatch()
+	for i, hash := range hashes {
+		number := first + uint64(i)
+		if number == 0 {
+			continue
+		}
+		batch.Delete(headerKey(number, hash))
+		batch.Delete(headerTDKey(number, hash))
+		batch.Delete(headerHashKey(number))
+		batch.Delete(blockBodyKey(number, hash))
+		batch.Delete(blockReceiptsKey(number, hash))
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete frozen canonical blocks", "err", err)
+	}
+	log.Info("Froze chain segment", "blocks", len(hashes), "number", limit, "hash", hashes[len(hashes)-1])
+	return len(hashes), nil
+}
+
+// freezerdb is a database wrapper that enables freezer data retrievals.
+type freezerdb struct {
+	ethdb.Database
+	*freezer
+}
+
+// Close implements ethdb.Database, closing both the freezer and the key-value
+// store.
+func (db *freezerdb) Close() {
+	if err := db.freezer.Close(); err != nil {
+		log.Error("Failed to close ancient database", "err", err)
+	}
+	db.Database.Close()
+}
+
+// NewDatabaseWithFreezer wraps a key-value store with a freezer in the given
+// directory, into which the blocks of the chain are moved once immutable.
+func NewDatabaseWithFreezer(db ethdb.Database, freezer string) (ethdb.Database, error) {
+	frdb, err := newFreezer(freezer)
+	if err != nil {
+		return nil, err
+	}
+	// Refuse to mix the frozen blocks of one chain with the recent ones of another
+	if frozen, _ := frdb.Ancients(); frozen > 0 {
+		if kvgenesis := ReadCanonicalHash(db, 0); kvgenesis != (common.Hash{}) {
+			if frgenesis, _ := frdb.Ancient(freezerHashTable, 0); common.BytesToHash(frgenesis) != kvgenesis {
+				frdb.Close()
+				return nil, fmt.Errorf("%v: have %x, key-value store %x", errGenesisMismatch, frgenesis, kvgenesis)
+			}
+		}
+	}
+	frdb.wg.Add(1)
+	go frdb.freeze(db)
+
+	return &freezerdb{Database: db, freezer: frdb}, nil
+}
+
+// KeyValueStore returns the key-value store of a database, unwrapping it from
+// its freezer if it has one.
+func KeyValueStore(db ethdb.Database) ethdb.Database {
+	if frdb, ok := db.(*freezerdb); ok {
+		return frdb.Database
+	}
+	return db
+}
@@ -0,0 +1,289 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/golang/snappy"
+)
+
+var (
+	// errOutOfBounds is returned if the item requested is not contained within
+	// the freezer table.
+	errOutOfBounds = errors.New("out of bounds")
+
+	// errClosed is returned if an operation attempts to use a closed freezer
+	// table.
+	errClosed = errors.New("closed")
+
+	// errOutOrderInsertion is returned if an item is appended with a number
+	// other than the next one of the freezer table.
+	errOutOrderInsertion = errors.New("the append operation is out-order")
+)
+
+// indexEntrySize is the size of the index entry of a freezer table item: the
+// big endian end offset of the item in the data file.
+const indexEntrySize = 8
+
+// freezerTable is an append-only store of numbered binary blobs. The blobs are
+// concatenated into a data file, and an index file holds the offset each one
+// ends at, so that any item can be retrieved with two reads.
+type freezerTable struct {
+	name       string
+	compressed bool // Whether the items are snappy compressed
+
+	index *os.File // File descriptor of the item end offsets
+	data  *os.File // File descriptor of the concatenated items
+
+	items uint64 // Number of items stored in the table
+	size  uint64 // Size of the data file, the end offset of the last item
+
+	lock   sync.RWMutex
+	logger log.Logger
+}
+
+// newFreezerTable opens the given table within a freezer directory, creating
+// it if it does not exist yet. Any partial write left over from a crash is
+// discarded.
+func newFreezerTable(dir, name string, compressed bool) (*freezerTable, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ext := ".rdat"
+	if compressed {
+		ext = ".cdat"
+	}
+	index, err := os.OpenFile(filepath.Join(dir, name+".ridx"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.OpenFile(filepath.Join(dir, name+ext), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	tab := &freezerTable{
+		name:       name,
+		compressed: compressed,
+		index:      index,
+		data:       data,
+		logger:     log.New("table", name),
+	}
+	if err := tab.repair(); err != nil {
+		tab.Close()
+		return nil, err
+	}
+	return tab, nil
+}
+
+// repair cross checks the index and data files, truncating both to the last
+// item that was fully written.
+func (t *freezerTable) repair() error {
+	stat, err := t.index.Stat()
+	if err != nil {
+		return err
+	}
+	origIndexSize := uint64(stat.Size())
+	indexSize := origIndexSize / indexEntrySize * indexEntrySize
+
+	if stat, err = t.data.Stat(); err != nil {
+		return err
+	}
+	dataSize := uint64(stat.Size())
+
+	// Drop the index entries pointing past the end of the data file
+	var end uint64
+	for indexSize > 0 {
+		if end, err = t.offset(indexSize/indexEntrySize - 1); err != nil {
+			return err
+		}
+		if end <= dataSize {
+			break
+		}
+		indexSize -= indexEntrySize
+	}
+	if indexSize == 0 {
+		end = 0
+	}
+	if indexSize != origIndexSize || dataSize != end {
+		t.logger.Warn("Truncating dangling freezer items", "items", indexSize/indexEntrySize, "size", end)
+	}
+	if err := t.index.Truncate(int64(indexSize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(end)); err != nil {
+		return err
+	}
+	t.items, t.size = indexSize/indexEntrySize, end
+	return nil
+}
+
+// offset reads the end offset of an item from the index file.
+func (t *freezerTable) offset(item uint64) (uint64, error) {
+	var buf [indexEntrySize]byte
+	if _, err := t.index.ReadAt(buf[:], int64(item*indexEntrySize)); err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(buf[:]), nil
+}
+
+// Items returns the number of items in the table.
+func (t *freezerTable) Items() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.items
+}
+
+// Size returns the disk space taken by the table, its data and index files.
+func (t *freezerTable) Size() uint64 {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.size + t.items*indexEntrySize
+}
+
+// Append adds the next item to the table. The item is written to the data file
+// before it is indexed, so a crash in between is undone by the next repair.
+func (t *freezerTable) Append(item uint64, blob []byte) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if item != t.items {
+		return fmt.Errorf("%v: %s have %d, got %d", errOutOrderInsertion, t.name, t.items, item)
+	}
+	if t.compressed {
+		blob = snappy.Encode(nil, blob)
+	}
+	if _, err := t.data.WriteAt(blob, int64(t.size)); err != nil {
+		return err
+	}
+	var buf [indexEntrySize]byte
+	binary.BigEndian.PutUint64(buf[:], t.size+uint64(len(blob)))
+	if _, err := t.index.WriteAt(buf[:], int64(t.items*indexEntrySize)); err != nil {
+		return err
+	}
+	t.items++
+	t.size += uint64(len(blob))
+	return nil
+}
+
+// Retrieve looks up an item of the table.
+func (t *freezerTable) Retrieve(item uint64) ([]byte, error) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return nil, errClosed
+	}
+	if item >= t.items {
+		return nil, errOutOfBounds
+	}
+	var start uint64
+	if item > 0 {
+		var err error
+		if start, err = t.offset(item - 1); err != nil {
+			return nil, err
+		}
+	}
+	end, err := t.offset(item)
+	if err != nil {
+		return nil, err
+	}
+	blob := make([]byte, end-start)
+	if _, err := t.data.ReadAt(blob, int64(start)); err != nil {
+		return nil, err
+	}
+	if t.compressed {
+		return snappy.Decode(nil, blob)
+	}
+	return blob, nil
+}
+
+// truncate discards all items from the given number on.
+func (t *freezerTable) truncate(items uint64) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if t.items <= items {
+		return nil
+	}
+	var size uint64
+	if items > 0 {
+		var err error
+		if size, err = t.offset(items - 1); err != nil {
+			return err
+		}
+	}
+	if err := t.index.Truncate(int64(items * indexEntrySize)); err != nil {
+		return err
+	}
+	if err := t.data.Truncate(int64(size)); err != nil {
+		return err
+	}
+	t.items, t.size = items, size
+	return nil
+}
+
+// Sync flushes the table to disk, data first so that the index never points
+// to items that were lost.
+func (t *freezerTable) Sync() error {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if t.index == nil {
+		return errClosed
+	}
+	if err := t.data.Sync(); err != nil {
+		return err
+	}
+	return t.index.Sync()
+}
+
+// Close closes the files of the table.
+func (t *freezerTable) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var errs []error
+	for _, f := range []*os.File{t.data, t.index} {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	t.index, t.data = nil, nil
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFreezerItem generates the content of a test item.
+func testFreezerItem(i uint64) []byte {
+	return bytes.Repeat([]byte{byte(i)}, int(i%17)+1)
+}
+
+// Tests that items appended to a freezer table can be retrieved, also after
+// reopening it.
+func TestFreezerTableAppendRetrieve(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		dir, err := ioutil.TempDir("", "freezer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		table, err := newFreezerTable(dir, "test", compressed)
+		if err != nil {
+			t.Fatalf("compressed %v: failed to create table: %v", compressed, err)
+		}
+		for i := uint64(0); i < 100; i++ {
+			if err := table.Append(i, testFreezerItem(i)); err != nil {
+				t.Fatalf("compressed %v: failed to append item %d: %v", compressed, i, err)
+			}
+		}
+		if err := table.Append(101, nil); err == nil {
+			t.Fatalf("compressed %v: out of order append succeeded", compressed)
+		}
+		table.Close()
+
+		if table, err = newFreezerTable(dir, "test", compressed); err != nil {
+			t.Fatalf("compressed %v: failed to reopen table: %v", compressed, err)
+		}
+		if items := table.Items(); items != 100 {
+			t.Fatalf("compressed %v: item count mismatch: have %d, want %d", compressed, items, 100)
+		}
+		for i := uint64(0); i < 100; i++ {
+			blob, err := table.Retrieve(i)
+			if err != nil {
+				t.Fatalf("compressed %v: failed to retrieve item %d: %v", compressed, i, err)
+			}
+			if !bytes.Equal(blob, testFreezerItem(i)) {
+				t.Fatalf("compressed %v: item %d mismatch: have %x, want %x", compressed, i, blob, testFreezerItem(i))
+			}
+		}
+		if _, err := table.Retrieve(100); err != errOutOfBounds {
+			t.Fatalf("compressed %v: out of bounds retrieval error mismatch: have %v, want %v", compressed, err, errOutOfBounds)
+		}
+		table.Close()
+	}
+}
+
+// Tests that partially written items are discarded when a table is reopened.
+func TestFreezerTableRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newFreezerTable(dir, "test", false)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for i := uint64(0); i < 10; i++ {
+		table.Append(i, testFreezerItem(i))
+	}
+	table.Close()
+
+	// Cut the last item short and leave half an index entry behind
+	data := filepath.Join(dir, "test.rdat")
+	stat, _ := os.Stat(data)
+	os.Truncate(data, stat.Size()-1)
+
+	index := filepath.Join(dir, "test.ridx")
+	f, _ := os.OpenFile(index, os.O_WRONLY|os.O_APPEND, 0644)
+	f.Write([]byte{0, 0, 0})
+	f.Close()
+
+	if table, err = newFreezerTable(dir, "test", false); err != nil {
+		t.Fatalf("failed to reopen table: %v", err)
+	}
+	defer table.Close()
+
+	if items := table.Items(); items != 9 {
+		t.Fatalf("item count mismatch: have %d, want %d", items, 9)
+	}
+	if blob, err := table.Retrieve(8); err != nil || !bytes.Equal(blob, testFreezerItem(8)) {
+		t.Fatalf("last item mismatch: have %x/%v, want %x", blob, err, testFreezerItem(8))
+	}
+	if err := table.Append(9, testFreezerItem(9)); err != nil {
+		t.Fatalf("failed to append after repair: %v", err)
+	}
+	if blob, err := table.Retrieve(9); err != nil || !bytes.Equal(blob, testFreezerItem(9)) {
+		t.Fatalf("appended item mismatch: have %x/%v, want %x", blob, err, testFreezerItem(9))
+	}
+}
+
+// Tests that truncating a table drops the items after the given number.
+func TestFreezerTableTruncate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	table, err := newFreezerTable(dir, "test", true)
+	if err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	defer table.Close()
+
+	for i := uint64(0); i < 10; i++ {
+		table.Append(i, testFreezerItem(i))
+	}
+	if err := table.truncate(4); err != nil {
+		t.Fatalf("failed to truncate table: %v", err)
+	}
+	if items := table.Items(); items != 4 {
+		t.Fatalf("item count mismatch: have %d, want %d", items, 4)
+	}
+	if _, err := table.Retrieve(4); err != errOutOfBounds {
+		t.Fatalf("truncated item retrieval error mismatch: have %v, want %v", err, errOutOfBounds)
+	}
+	if err := table.Append(4, []byte("new")); err != nil {
+		t.Fatalf("failed to append after truncation: %v", err)
+	}
+	if blob, _ := table.Retrieve(4); !bytes.Equal(blob, []byte("new")) {
+		t.Fatalf("appended item mismatch: have %x, want %x", blob, []byte("new"))
+	}
+	if blob, _ := table.Retrieve(3); !bytes.Equal(blob, testFreezerItem(3)) {
+		t.Fatalf("kept item mismatch: have %x, want %x", blob, testFreezerItem(3))
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the immutable blocks are moved into the freezer, from where the
+// accessors keep reading them.
+func TestFreezeChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Write a canonical chain of ten blocks into the key-value store
+	kvdb := ethdb.NewMemDatabase()
+
+	var blocks []*types.Block
+	parent := common.Hash{}
+	for i := int64(0); i < 10; i++ {
+		block := types.NewBlockWithHeader(&types.Header{ParentHash: parent, Number: big.NewInt(i), Extra: []byte("test block")})
+		receipts := types.Receipts{{CumulativeGasUsed: uint64(i), Logs: []*types.Log{}}}
+
+		WriteBlock(kvdb, block)
+		WriteReceipts(kvdb, block.Hash(), block.NumberU64(), receipts)
+		WriteTd(kvdb, block.Hash(), block.NumberU64(), big.NewInt(i+1))
+		WriteCanonicalHash(kvdb, block.Hash(), block.NumberU64())
+
+		blocks = append(blocks, block)
+		parent = block.Hash()
+	}
+	WriteHeadBlockHash(kvdb, parent)
+
+	f, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	f.threshold = 3
+
+	if frozen, err := f.freezeBatch(kvdb); err != nil || frozen != 7 {
+		t.Fatalf("frozen blocks mismatch: have %d/%v, want %d", frozen, err, 7)
+	}
+	if frozen, err := f.freezeBatch(kvdb); err != nil || frozen != 0 {
+		t.Fatalf("refrozen blocks mismatch: have %d/%v, want %d", frozen, err, 0)
+	}
+	// Frozen blocks except the genesis are gone from the key-value store
+	for _, block := range blocks[1:7] {
+		if HasHeader(kvdb, block.Hash(), block.NumberU64()) {
+			t.Fatalf("block #%d not deleted from key-value store", block.NumberU64())
+		}
+	}
+	if !HasHeader(kvdb, blocks[0].Hash(), 0) {
+		t.Fatalf("genesis deleted from key-value store")
+	}
+	// All blocks are readable through the freezer
+	db := &freezerdb{Database: kvdb, freezer: f}
+	checkBlocks := func(blocks []*types.Block) {
+		for _, block := range blocks {
+			hash, number := block.Hash(), block.NumberU64()
+			if have := ReadCanonicalHash(db, number); have != hash {
+				t.Fatalf("block #%d: canonical hash mismatch: have %x, want %x", number, have, hash)
+			}
+			if !HasHeader(db, hash, number) || !HasBody(db, hash, number) {
+				t.Fatalf("block #%d: not found", number)
+			}
+			if have := ReadBlock(db, hash, number); have == nil || have.Hash() != hash {
+				t.Fatalf("block #%d: block mismatch: have %v", number, have)
+			}
+			if receipts := ReadReceipts(db, hash, number); len(receipts) != 1 || receipts[0].CumulativeGasUsed != number {
+				t.Fatalf("block #%d: receipts mismatch: have %v", number, receipts)
+			}
+			if td := ReadTd(db, hash, number); td == nil || td.Uint64() != number+1 {
+				t.Fatalf("block #%d: total difficulty mismatch: have %v", number, td)
+			}
+		}
+	}
+	checkBlocks(blocks)
+
+	// Frozen data is only returned for the canonical hash
+	if HasHeader(db, common.Hash{0x01}, 3) || ReadBodyRLP(db, common.Hash{0x01}, 3) != nil {
+		t.Fatalf("frozen block returned for non-canonical hash")
+	}
+	// Truncated blocks are gone, the freezer resumes after them
+	if err := db.TruncateAncients(5); err != nil {
+		t.Fatalf("failed to truncate freezer: %v", err)
+	}
+	if HasHeader(db, blocks[5].Hash(), 5) {
+		t.Fatalf("truncated block still found")
+	}
+	checkBlocks(blocks[:5])
+
+	// Reopening the freezer keeps the frozen blocks
+	f.Close()
+	if f, err = newFreezer(dir); err != nil {
+		t.Fatalf("failed to reopen freezer: %v", err)
+	}
+	defer f.Close()
+
+	db = &freezerdb{Database: kvdb, freezer: f}
+	if frozen, _ := db.Ancients(); frozen != 5 {
+		t.Fatalf("frozen block count mismatch: have %d, want %d", frozen, 5)
+	}
+	checkBlocks(blocks[:5])
+}
+
+// hookedDatabase is a key-value store calling a hook once a given key was read.
+type hookedDatabase struct {
+	ethdb.Database
+	key  []byte
+	hook func()
+}
+
+// Get implements ethdb.Database, calling the hook after reading its key.
+func (db *hookedDatabase) Get(key []byte) ([]byte, error) {
+	data, err := db.Database.Get(key)
+	if db.hook != nil && bytes.Equal(key, db.key) {
+		hook := db.hook
+		db.hook = nil
+		hook()
+	}
+	return data, err
+}
+
+// Tests that a chain rewound and extended by a fork while a batch is frozen keeps
+// all its blocks, the truncation of the freezer waiting for the batch to finish.
+func TestFreezeRewind(t *testing.T) {
+	dir, err := ioutil.TempDir("", "freezer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Write a canonical chain of ten blocks and a fork of it from block #3 on
+	kvdb := ethdb.NewMemDatabase()
+
+	makeChain := func(parent *types.Block, n int, extra string) []*types.Block {
+		var blocks []*types.Block
+		for i := 0; i < n; i++ {
+			header := &types.Header{Number: big.NewInt(0), Extra: []byte(extra)}
+			if parent != nil {
+				header.ParentHash, header.Number = parent.Hash(), new(big.Int).Add(parent.Number(), common.Big1)
+			}
+			block := types.NewBlockWithHeader(header)
+			receipts := types.Receipts{{CumulativeGasUsed: block.NumberU64(), Logs: []*types.Log{}}}
+
+			WriteBlock(kvdb, block)
+			WriteReceipts(kvdb, block.Hash(), block.NumberU64(), receipts)
+			WriteTd(kvdb, block.Hash(), block.NumberU64(), new(big.Int).SetUint64(block.NumberU64()+1))
+			WriteCanonicalHash(kvdb, block.Hash(), block.NumberU64())
+			WriteHeadBlockHash(kvdb, block.Hash())
+
+			blocks = append(blocks, block)
+			parent = block
+		}
+		return blocks
+	}
+	blocks := makeChain(nil, 10, "test block")
+
+	f, err := newFreezer(dir)
+	if err != nil {
+		t.Fatalf("failed to create freezer: %v", err)
+	}
+	defer f.Close()
+	f.threshold = 3
+
+	// Switch to the fork like SetHead and a reimport once the batch is read
+	var (
+		fork      []*types.Block
+		truncated = make(chan error, 1)
+	)
+	db := &hookedDatabase{Database: kvdb, key: headerTDKey(6, blocks[6].Hash())}
+	db.hook = func() {
+		for _, block := range blocks[3:] {
+			DeleteCanonicalHash(kvdb, block.NumberU64())
+		}
+		WriteHeadBlockHash(kvdb, blocks[2].Hash())
+		fork = makeChain(blocks[2], 7, "fork block")
+
+		go func() { truncated <- f.TruncateAncients(3) }()
+		select {
+		case err := <-truncated:
+			truncated <- err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if _, err := f.freezeBatch(db); err != nil {
+		t.Fatalf("failed to freeze: %v", err)
+	}
+	if err := <-truncated; err != nil {
+		t.Fatalf("failed to truncate freezer: %v", err)
+	}
+	if frozen, _ := f.Ancients(); frozen > 3 {
+		t.Fatalf("blocks frozen past the fork: have %d, want at most %d", frozen, 3)
+	}
+	fdb := &freezerdb{Database: kvdb, freezer: f}
+	for _, block := range append(append([]*types.Block{}, blocks[:3]...), fork...) {
+		hash, number := block.Hash(), block.NumberU64()
+		if have := ReadCanonicalHash(fdb, number); have != hash {
+			t.Fatalf("block #%d: canonical hash mismatch: have %x, want %x", number, have, hash)
+		}
+		if have := ReadBlock(fdb, hash, number); have == nil || have.Hash() != hash {
+			t.Fatalf("block #%d: block lost", number)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// DatabaseStat is the number of entries of a category of database content, and
+// the space they take.
+type DatabaseStat struct {
+	Category string
+	Count    uint64
+	Size     common.StorageSize
+}
+
+// metadataKeys are the keys of the singleton entries of the database.
+var metadataKeys = [][]byte{
+	databaseVerisionKey, headHeaderKey, headBlockKey, headFastBlockKey,
+	fastTrieProgressKey, pruningRootsKey, txIndexTailKey, snapshotRootKey,
+}
+
+// keyCategories are the categories of the key-value store content, in the order
+// they are reported in.
+var keyCategories = []string{
+	"Headers", "Total difficulties", "Canonical hashes", "Header numbers",
+	"Bodies", "Receipts", "Transaction lookups", "Bloombit indexes",
+	"Trie nodes", "Contract codes", "Preimages", "Snapshot accounts",
+	"Snapshot storage", "Fork state", "Chain configs", "Chain indexers",
+	"Metadata", "Unaccounted",
+}
+
+// classifyKey returns the category of an entry of the key-value store, by the
+// schema of its key. Trie nodes and contract codes are both keyed by the hash of
+// their value, they are told apart by trie nodes being RLP lists.
+func classifyKey(key, value []byte) string {
+	size := len(key)
+	switch {
+	case bytes.HasPrefix(key, headerPrefix) && size == len(headerPrefix)+8+common.HashLength:
+		return "Headers"
+	case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerTDSuffix) && size == len(headerPrefix)+8+common.HashLength+len(headerTDSuffix):
+		return "Total difficulties"
+	case bytes.HasPrefix(key, headerPrefix) && bytes.HasSuffix(key, headerHashSuffix) && size == len(headerPrefix)+8+len(headerHashSuffix):
+		return "Canonical hashes"
+	case bytes.HasPrefix(key, headerNumberPrefix) && size == len(headerNumberPrefix)+common.HashLength:
+		return "Header numbers"
+	case bytes.HasPrefix(key, blockBodyPrefix) && size == len(blockBodyPrefix)+8+common.HashLength:
+		return "Bodies"
+	case bytes.HasPrefix(key, blockReceiptsPrefix) && size == len(blockReceiptsPrefix)+8+common.HashLength:
+		return "Receipts"
+	case bytes.HasPrefix(key, txLookupPrefix) && size == len(txLookupPrefix)+common.HashLength:
+		return "Transaction lookups"
+	case bytes.HasPrefix(key, bloomBitsPrefix) && size == len(bloomBitsPrefix)+10+common.HashLength:
+		return "Bloombit indexes"
+	case bytes.HasPrefix(key, preimagePrefix) && size == len(preimagePrefix)+common.HashLength:
+		return "Preimages"
+	case bytes.HasPrefix(key, SnapshotAccountPrefix) && size == len(SnapshotAccountPrefix)+common.HashLength:
+		return "Snapshot accounts"
+	case bytes.HasPrefix(key, SnapshotStoragePrefix) && size == len(SnapshotStoragePrefix)+2*common.HashLength:
+		return "Snapshot storage"
+	case bytes.HasPrefix(key, forkAccountPrefix) && size == len(forkAccountPrefix)+common.AddressLength:
+		return "Fork state"
+	case bytes.HasPrefix(key, forkStoragePrefix) && size == len(forkStoragePrefix)+2*common.HashLength:
+		return "Fork state"
+	case bytes.HasPrefix(key, forkDeletedPrefix) && size == len(forkDeletedPrefix)+common.HashLength:
+		return "Fork state"
+	case bytes.HasPrefix(key, configPrefix) && size == len(configPrefix)+common.HashLength:
+		return "Chain configs"
+	case size == common.HashLength:
+		if kind, _, rest, err := rlp.Split(value); err == nil && kind == rlp.List && len(rest) == 0 {
+			return "Trie nodes"
+		}
+		return "Contract codes"
+	case bytes.HasPrefix(key, []byte("i")):
+		return "Chain indexers"
+	}
+	for _, meta := range metadataKeys {
+		if bytes.Equal(key, meta) {
+			return "Metadata"
+		}
+	}
+	return "Unaccounted"
+}
+
+// InspectDatabase walks the entire key-value store and reports the number and
+// size of the entries of every category of content, including the entries not
+// matching any known key format. The tables of the ancient store are reported
+// too if the database has one.
+func InspectDatabase(db ethdb.Database) ([]DatabaseStat, error) {
+	var (
+		stats  = make(map[string]*DatabaseStat)
+		count  uint64
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for _, category := range keyCategories {
+		stats[category] = &DatabaseStat{Category: category}
+	}
+	it := KeyValueStore(db).NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+
+		stat := stats[classifyKey(key, value)]
+		stat.Count++
+		stat.Size += common.StorageSize(len(key) + len(value))
+
+		if count++; time.Since(logged) > 8*time.Second {
+			log.Info("Inspecting database", "entries", count, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	result := make([]DatabaseStat, 0, len(keyCategories))
+	for _, category := range keyCategories {
+		result = append(result, *stats[category])
+	}
+	// Append the tables of the ancient store, if any
+	if frdb, ok := db.(*freezerdb); ok {
+		names := make([]string, 0, len(frdb.tables))
+		for name := range frdb.tables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			table := frdb.tables[name]
+			result = append(result, DatabaseStat{
+				Category: "Ancient " + name,
+				Count:    table.Items(),
+				Size:     common.StorageSize(table.Size()),
+			})
+		}
+	}
+	log.Info("Inspected database", "entries", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return result, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the database inspection classifies the entries by their keys.
+func TestInspectDatabase(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+
+	tx := types.NewTransaction(1, common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	block := types.NewBlock(&types.Header{Number: big.NewInt(1)}, []*types.Transaction{tx}, nil, nil)
+
+	WriteBlock(db, block)
+	WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+	WriteTd(db, block.Hash(), block.NumberU64(), big.NewInt(1))
+	WriteReceipts(db, block.Hash(), block.NumberU64(), nil)
+	WriteTxLookupEntries(db, block)
+	WriteHeadBlockHash(db, block.Hash())
+	WritePreimages(db, 0, map[common.Hash][]byte{crypto.Keccak256Hash([]byte{0x01}): {0x01}})
+
+	node := []byte{0xc2, 0x80, 0x80} // RLP list of two empty strings
+	db.Put(crypto.Keccak256(node), node)
+	code := []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+	db.Put(crypto.Keccak256(code), code)
+	db.Put([]byte("junk"), []byte("value"))
+
+	stats, err := InspectDatabase(db)
+	if err != nil {
+		t.Fatalf("inspection failed: %v", err)
+	}
+	want := map[string]uint64{
+		"Headers":             1,
+		"Total difficulties":  1,
+		"Canonical hashes":    1,
+		"Header numbers":      1,
+		"Bodies":              1,
+		"Receipts":            1,
+		"Transaction lookups": 1,
+		"Trie nodes":          1,
+		"Contract codes":      1,
+		"Preimages":           1,
+		"Metadata":            1,
+		"Unaccounted":         1,
+	}
+	var total uint64
+	for _, stat := range stats {
+		if stat.Count != want[stat.Category] {
+			t.Errorf("%s: count mismatch: have %d, want %d", stat.Category, stat.Count, want[stat.Category])
+		}
+		if stat.Count > 0 && stat.Size == 0 {
+			t.Errorf("%s: size not counted", stat.Category)
+		}
+		total += stat.Count
+	}
+	if total != uint64(db.Len()) {
+		t.Errorf("total count mismatch: have %d, want %d", total, db.Len())
+	}
+}
@@ -41,6 +41,15 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// pruningRootsKey tracks the state roots kept by an unfinished state pruning.
+	pruningRootsKey = []byte("PruningRoots")
+
+	// txIndexTailKey tracks the oldest block whose transactions are indexed.
+	txIndexTailKey = []byte("TransactionIndexTail")
+
+	// snapshotRootKey tracks the state root the flat state snapshot on disk belongs to.
+	snapshotRootKey = []byte("SnapshotRoot")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -56,6 +65,13 @@ var (
 	preimagePrefix = []byte("secure-key-")      // preimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
+	forkAccountPrefix = []byte("fork-a") // forkAccountPrefix + address -> account of the remote fork state
+	forkStoragePrefix = []byte("fork-s") // forkStoragePrefix + address hash + slot -> storage of the remote fork state
+	forkDeletedPrefix = []byte("fork-d") // forkDeletedPrefix + address hash -> marker of accounts deleted since the fork
+
+	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -63,6 +79,33 @@ var (
 	preimageHitCounter = metrics.NewRegisteredCounter("db/preimage/hits", nil)
 )
 
+const (
+	// freezerHashTable indicates the name of the freezer canonical hash table.
+	freezerHashTable = "hashes"
+
+	// freezerHeaderTable indicates the name of the freezer header table.
+	freezerHeaderTable = "headers"
+
+	// freezerBodiesTable indicates the name of the freezer block body table.
+	freezerBodiesTable = "bodies"
+
+	// freezerReceiptTable indicates the name of the freezer receipts table.
+	freezerReceiptTable = "receipts"
+
+	// freezerDifficultyTable indicates the name of the freezer total difficulty table.
+	freezerDifficultyTable = "diffs"
+)
+
+// freezerNoSnappy configures whether compression is disabled for the ancient-tables.
+// Hashes and difficulties don't compress well.
+var freezerNoSnappy = map[string]bool{
+	freezerHashTable:       true,
+	freezerHeaderTable:     false,
+	freezerBodiesTable:     false,
+	freezerReceiptTable:    false,
+	freezerDifficultyTable: true,
+}
+
 // TxLookupEntry is a positional metadata to help looking up the data content of
 // a transaction or receipt given only its hash.
 type TxLookupEntry struct {
@@ -132,3 +175,28 @@ func preimageKey(hash common.Hash) []byte {
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
 }
+
+// forkAccountKey = forkAccountPrefix + address
+func forkAccountKey(addr common.Address) []byte {
+	return append(append([]byte{}, forkAccountPrefix...), addr.Bytes()...)
+}
+
+// forkStorageKey = forkStoragePrefix + address hash + slot
+func forkStorageKey(addrHash common.Hash, slot common.Hash) []byte {
+	return append(append(append([]byte{}, forkStoragePrefix...), addrHash.Bytes()...), slot.Bytes()...)
+}
+
+// forkDeletedKey = forkDeletedPrefix + address hash
+func forkDeletedKey(addrHash common.Hash) []byte {
+	return append(append([]byte{}, forkDeletedPrefix...), addrHash.Bytes()...)
+}
+
+// snapshotAccountKey = SnapshotAccountPrefix + account hash
+func snapshotAccountKey(hash common.Hash) []byte {
+	return append(append([]byte{}, SnapshotAccountPrefix...), hash.Bytes()...)
+}
+
+// snapshotStorageKey = SnapshotStoragePrefix + account hash + storage hash
+func snapshotStorageKey(accountHash, storageHash common.Hash) []byte {
+	return append(append(append([]byte{}, SnapshotStoragePrefix...), accountHash.Bytes()...), storageHash.Bytes()...)
+}
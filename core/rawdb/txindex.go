@@ -0,0 +1,121 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// IndexTransactions adds the transaction lookups of the canonical blocks in the
+// range [from, to), newest first, moving the index tail down along with every
+// written batch. Blocks missing from the database are skipped. It returns early
+// without error if interrupt is closed.
+func IndexTransactions(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}) error {
+	var (
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+		txs    int
+	)
+	for number := to; number > from; {
+		number--
+
+		if block := readCanonicalBlock(db, number); block != nil {
+			WriteTxLookupEntries(batch, block)
+			txs += len(block.Transactions())
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize || number == from {
+			WriteTxIndexTail(batch, number)
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+
+			select {
+			case <-interrupt:
+				log.Info("Transaction indexing interrupted", "tail", number, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+				return nil
+			default:
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing transactions", "block", number, "left", number-from, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if to > from {
+		log.Info("Indexed transactions", "from", from, "to", to-1, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+// UnindexTransactions removes the transaction lookups of the canonical blocks
+// in the range [from, to), oldest first, moving the index tail up along with
+// every written batch. It returns early without error if interrupt is closed.
+func UnindexTransactions(db ethdb.Database, from uint64, to uint64, interrupt chan struct{}) error {
+	var (
+		batch  = db.NewBatch()
+		start  = time.Now()
+		logged = time.Now()
+		txs    int
+	)
+	for number := from; number < to; number++ {
+		if block := readCanonicalBlock(db, number); block != nil {
+			for _, tx := range block.Transactions() {
+				DeleteTxLookupEntry(batch, tx.Hash())
+			}
+			txs += len(block.Transactions())
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize || number+1 == to {
+			WriteTxIndexTail(batch, number+1)
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+
+			select {
+			case <-interrupt:
+				log.Info("Transaction unindexing interrupted", "tail", number+1, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+				return nil
+			default:
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Unindexing transactions", "block", number, "left", to-number-1, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if to > from {
+		log.Info("Unindexed transactions", "from", from, "to", to-1, "txs", txs, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+// readCanonicalBlock retrieves the canonical block of a number, or nil if it is
+// not in the database.
+func readCanonicalBlock(db ethdb.Database, number uint64) *types.Block {
+	hash := ReadCanonicalHash(db, number)
+	if hash == (common.Hash{}) {
+		return nil
+	}
+	return ReadBlock(db, hash, number)
+}
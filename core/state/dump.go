@@ -49,6 +49,10 @@ func (self *StateDB) RawDump() Dump {
 
 	it := trie.NewIterator(self.trie.NodeIterator(nil))
 	for it.Next() {
+		// Skip the accounts deleted from forked states
+		if bytes.Equal(it.Value, forkTombstone) {
+			continue
+		}
 		addr := self.trie.GetKey(it.Key)
 		var data Account
 		if err := rlp.DecodeBytes(it.Value, &data); err != nil {
@@ -66,6 +70,9 @@ func (self *StateDB) RawDump() Dump {
 		}
 		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
 		for storageIt.Next() {
+			if bytes.Equal(storageIt.Value, forkTombstone) {
+				continue
+			}
 			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
 		}
 		dump.Accounts[common.Bytes2Hex(addr)] = account
@@ -166,6 +173,9 @@ func (self *StateDB) RawDumpNonContracts() Dump {
 		}
 		storageIt := trie.NewIterator(obj.getTrie(self.db).NodeIterator(nil))
 		for storageIt.Next() {
+			if bytes.Equal(storageIt.Value, forkTombstone) {
+				continue
+			}
 			account.Storage[common.Bytes2Hex(self.trie.GetKey(storageIt.Key))] = common.Bytes2Hex(storageIt.Value)
 		}
 		dump.Accounts[common.Bytes2Hex(addr)] = account
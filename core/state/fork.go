@@ -0,0 +1,195 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// forkTombstone marks the keys deleted from a forked trie, so that they are not
+// filled from the remote state again. It is the encoding of an empty string,
+// which is neither a valid account nor a stored storage value.
+var forkTombstone = []byte{0x80}
+
+// RemoteState is the state of a remote chain at the block a local chain was
+// forked at.
+type RemoteState interface {
+	// Account retrieves the nonce, balance and code of an account.
+	Account(addr common.Address) (nonce uint64, balance *big.Int, code []byte, err error)
+
+	// Storage retrieves a storage slot of an account.
+	Storage(addr common.Address, slot common.Hash) (common.Hash, error)
+}
+
+// NewForkDatabase creates a backing store for the state of a chain forked from
+// a remote one. The local tries hold the state changed since the fork, while
+// the accounts, code and storage they lack are retrieved from the remote state
+// on first access and cached in the database.
+//
+// The state roots of a forked chain only commit to the local changes, so blocks
+// of the remote chain cannot be imported on top of it.
+func NewForkDatabase(db ethdb.Database, remote RemoteState) Database {
+	return &forkDB{
+		Database: NewDatabase(db),
+		diskdb:   db,
+		remote:   remote,
+	}
+}
+
+// forkDB is a state database falling back to a remote state.
+type forkDB struct {
+	Database
+	diskdb ethdb.Database
+	remote RemoteState
+}
+
+// OpenTrie opens the main account trie.
+func (db *forkDB) OpenTrie(root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return &forkTrie{Trie: tr, fetch: db.account, deleted: db.deleted}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account.
+func (db *forkDB) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenStorageTrie(addrHash, root)
+	if err != nil {
+		return nil, err
+	}
+	fetch := func(key []byte) ([]byte, error) {
+		return db.storage(addrHash, common.BytesToHash(key))
+	}
+	return &forkTrie{Trie: tr, fetch: fetch}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *forkDB) CopyTrie(t Trie) Trie {
+	if t, ok := t.(*forkTrie); ok {
+		return &forkTrie{Trie: db.Database.CopyTrie(t.Trie), fetch: t.fetch, deleted: t.deleted}
+	}
+	return db.Database.CopyTrie(t)
+}
+
+// account returns the RLP encoding of a remote account, nil if it does not exist.
+func (db *forkDB) account(key []byte) ([]byte, error) {
+	addr := common.BytesToAddress(key)
+	if enc, ok := rawdb.ReadForkAccount(db.diskdb, addr); ok {
+		if len(enc) == 0 {
+			return nil, nil
+		}
+		return enc, nil
+	}
+	nonce, balance, code, err := db.remote.Account(addr)
+	if err != nil {
+		return nil, err
+	}
+	// Remember the address to look up the storage of the account with
+	rawdb.WritePreimages(db.diskdb, 0, map[common.Hash][]byte{crypto.Keccak256Hash(addr[:]): common.CopyBytes(addr[:])})
+
+	var enc []byte
+	if nonce != 0 || balance.Sign() != 0 || len(code) != 0 {
+		codeHash := crypto.Keccak256Hash(code)
+		if len(code) != 0 {
+			if err := db.diskdb.Put(codeHash[:], code); err != nil {
+				return nil, err
+			}
+		}
+		enc, err = rlp.EncodeToBytes(&Account{Nonce: nonce, Balance: balance, Root: emptyRoot, CodeHash: codeHash[:]})
+		if err != nil {
+			return nil, err
+		}
+	}
+	rawdb.WriteForkAccount(db.diskdb, addr, enc)
+	return enc, nil
+}
+
+// deleted records the deletion of an account, so that its remote storage is not
+// read again if it's recreated. The record outlives rewinds of the chain, which
+// leave the remote storage of accounts deleted later on empty.
+func (db *forkDB) deleted(key []byte) {
+	rawdb.WriteForkDeleted(db.diskdb, crypto.Keccak256Hash(key))
+}
+
+// storage returns the RLP encoding of a remote storage slot, nil if it is empty
+// or the account was deleted since the fork.
+func (db *forkDB) storage(addrHash common.Hash, slot common.Hash) ([]byte, error) {
+	if rawdb.HasForkDeleted(db.diskdb, addrHash) {
+		return nil, nil
+	}
+	value, ok := rawdb.ReadForkStorage(db.diskdb, addrHash, slot)
+	if !ok {
+		// The address is unknown for accounts never retrieved remotely, such as
+		// those allocated by the local genesis, whose storage is all local
+		addr := rawdb.ReadPreimage(db.diskdb, addrHash)
+		if len(addr) != common.AddressLength {
+			return nil, nil
+		}
+		var err error
+		if value, err = db.remote.Storage(common.BytesToAddress(addr), slot); err != nil {
+			return nil, err
+		}
+		rawdb.WriteForkStorage(db.diskdb, addrHash, slot, value)
+	}
+	if value == (common.Hash{}) {
+		return nil, nil
+	}
+	return rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
+}
+
+// forkTrie is a trie of a forked state, falling back to the remote state for the
+// keys it never held. Deletions are recorded as tombstones so that the remote
+// values do not show through again.
+type forkTrie struct {
+	Trie
+	fetch   func(key []byte) ([]byte, error)
+	deleted func(key []byte) // Deletion callback of the account trie, nil for storage tries
+}
+
+// TryGet returns the value of a key, retrieving it remotely if the trie lacks it.
+func (t *forkTrie) TryGet(key []byte) ([]byte, error) {
+	enc, err := t.Trie.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(enc) == 0 {
+		return t.fetch(key)
+	}
+	if bytes.Equal(enc, forkTombstone) {
+		return nil, nil
+	}
+	return enc, nil
+}
+
+// TryDelete marks a key as deleted.
+func (t *forkTrie) TryDelete(key []byte) error {
+	if t.deleted != nil {
+		t.deleted(key)
+	}
+	return t.Trie.TryUpdate(key, forkTombstone)
+}
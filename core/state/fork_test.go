@@ -0,0 +1,190 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// testRemoteState is a remote state served from a local state, counting the
+// requests made to it.
+type testRemoteState struct {
+	state    *StateDB
+	requests int
+	err      error // Error to fail all requests with, if set
+}
+
+func (r *testRemoteState) Account(addr common.Address) (uint64, *big.Int, []byte, error) {
+	r.requests++
+	if r.err != nil {
+		return 0, nil, nil, r.err
+	}
+	return r.state.GetNonce(addr), r.state.GetBalance(addr), r.state.GetCode(addr), nil
+}
+
+func (r *testRemoteState) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	r.requests++
+	if r.err != nil {
+		return common.Hash{}, r.err
+	}
+	return r.state.GetState(addr, slot), nil
+}
+
+// Tests that forked states fall back to the remote state for the accounts and
+// storage they lack, and that local changes, deletions included, take
+// precedence over it across reopens.
+func TestForkDatabase(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		account  = common.HexToAddress("0xacc0")
+		missing  = common.HexToAddress("0xdead")
+		code     = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+		slots    = []common.Hash{common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")}
+	)
+	remote, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	remote.SetNonce(contract, 3)
+	remote.SetBalance(contract, big.NewInt(100))
+	remote.SetCode(contract, code)
+	remote.SetState(contract, slots[0], common.HexToHash("0x05"))
+	remote.SetState(contract, slots[1], common.HexToHash("0x07"))
+	remote.SetBalance(account, big.NewInt(50))
+
+	db := ethdb.NewMemDatabase()
+	fork := NewForkDatabase(db, &testRemoteState{state: remote})
+	state, _ := New(common.Hash{}, fork)
+
+	if nonce := state.GetNonce(contract); nonce != 3 {
+		t.Errorf("contract nonce mismatch: have %d, want 3", nonce)
+	}
+	if balance := state.GetBalance(contract); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("contract balance mismatch: have %v, want 100", balance)
+	}
+	if have := state.GetCode(contract); !bytes.Equal(have, code) {
+		t.Errorf("contract code mismatch: have %x, want %x", have, code)
+	}
+	if value := state.GetState(contract, slots[1]); value != common.HexToHash("0x07") {
+		t.Errorf("contract storage mismatch: have %x, want 0x07", value)
+	}
+	if balance := state.GetBalance(account); balance.Cmp(big.NewInt(50)) != 0 {
+		t.Errorf("account balance mismatch: have %v, want 50", balance)
+	}
+	if state.Exist(missing) {
+		t.Errorf("missing account exists")
+	}
+	// Change the state, deleting remote storage and accounts too
+	state.SetState(contract, slots[0], common.Hash{})
+	state.SetState(contract, slots[2], common.HexToHash("0x09"))
+	state.Suicide(account)
+
+	root, err := state.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := fork.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+	// Reopen the state and ensure all changes stick without remote requests
+	reopened := &testRemoteState{state: remote}
+	state, err = New(root, NewForkDatabase(db, reopened))
+	if err != nil {
+		t.Fatalf("failed to reopen state: %v", err)
+	}
+	want := []common.Hash{{}, common.HexToHash("0x07"), common.HexToHash("0x09")}
+	for i, slot := range slots {
+		if value := state.GetState(contract, slot); value != want[i] {
+			t.Errorf("slot %d mismatch: have %x, want %x", i, value, want[i])
+		}
+	}
+	if have := state.GetCode(contract); !bytes.Equal(have, code) {
+		t.Errorf("reopened contract code mismatch: have %x, want %x", have, code)
+	}
+	if state.Exist(account) {
+		t.Errorf("deleted account resurrected from the remote state")
+	}
+	if state.Exist(missing) {
+		t.Errorf("missing account exists")
+	}
+	if reopened.requests != 0 {
+		t.Errorf("remote requests after reopen: have %d, want 0", reopened.requests)
+	}
+}
+
+// Tests that accounts deleted and recreated since the fork start with an empty
+// storage instead of the remote one.
+func TestForkRecreatedAccount(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		slot     = common.HexToHash("0x01")
+	)
+	remote, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	remote.SetCode(contract, []byte{0x00})
+	remote.SetState(contract, slot, common.HexToHash("0x05"))
+
+	db := ethdb.NewMemDatabase()
+	fork := NewForkDatabase(db, &testRemoteState{state: remote})
+	state, _ := New(common.Hash{}, fork)
+
+	state.Suicide(contract)
+	root, _ := state.Commit(true)
+	if err := fork.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+
+	// Recreate the account in a later state, and in one reopened from disk
+	state.CreateAccount(contract)
+	state.SetCode(contract, []byte{0x01})
+	if value := state.GetState(contract, slot); value != (common.Hash{}) {
+		t.Errorf("recreated account storage mismatch: have %x, want empty", value)
+	}
+	state, _ = New(root, NewForkDatabase(db, &testRemoteState{state: remote}))
+	state.CreateAccount(contract)
+	if value := state.GetState(contract, slot); value != (common.Hash{}) {
+		t.Errorf("reopened recreated account storage mismatch: have %x, want empty", value)
+	}
+}
+
+// Tests that failures to retrieve the remote state are reported as such, and
+// not as missing accounts and storage.
+func TestForkRemoteFailure(t *testing.T) {
+	remote, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+	remote.SetCode(common.HexToAddress("0xc0de"), []byte{0x00})
+	failure := errors.New("remote down")
+
+	state, _ := New(common.Hash{}, NewForkDatabase(ethdb.NewMemDatabase(), &testRemoteState{state: remote, err: failure}))
+	state.AddBalance(common.HexToAddress("0xacc0"), big.NewInt(1))
+	if err := state.Error(); err != failure {
+		t.Errorf("account failure mismatch: have %v, want %v", err, failure)
+	}
+	if _, err := state.Commit(true); err == nil {
+		t.Errorf("state committed on top of a failed retrieval")
+	}
+	// Storage failures count too
+	flaky := &testRemoteState{state: remote}
+	state, _ = New(common.Hash{}, NewForkDatabase(ethdb.NewMemDatabase(), flaky))
+	state.GetBalance(common.HexToAddress("0xc0de"))
+	flaky.err = failure
+	state.GetState(common.HexToAddress("0xc0de"), common.HexToHash("0x01"))
+	if err := state.Error(); err != failure {
+		t.Errorf("storage failure mismatch: have %v, want %v", err, failure)
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements the offline pruning of the state data of a chain
+// database.
+package pruner
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+
+	// forkTombstone is the value of the accounts deleted from the state of a
+	// forked chain, which has no code or storage to keep.
+	forkTombstone = []byte{0x80}
+)
+
+var (
+	// errNoHead is returned if the database has no chain to prune the state of.
+	errNoHead = errors.New("no chain head")
+
+	// errNoState is returned if none of the states to keep is complete, so that
+	// pruning would leave the chain without a usable state.
+	errNoState = errors.New("no complete state to keep")
+)
+
+// Prune deletes from a chain database all the state trie nodes and contract
+// code not reachable from the states of the recent canonical blocks. If none of
+// their states is on disk, the most recent older one is kept instead.
+//
+// The database must not be in use while pruning. The kept roots are recorded
+// before anything is deleted, so an interrupted pruning can be finished with
+// RecoverPruning.
+func Prune(db ethdb.Database, retain uint64) error {
+	roots, err := recentRoots(db, retain)
+	if err != nil {
+		return err
+	}
+	return prune(db, roots)
+}
+
+// RecoverPruning finishes the state pruning that was interrupted, if any.
+func RecoverPruning(db ethdb.Database) error {
+	roots := rawdb.ReadPruningRoots(db)
+	if len(roots) == 0 {
+		return nil
+	}
+	log.Warn("Resuming interrupted state pruning", "roots", len(roots))
+	return prune(db, roots)
+}
+
+// recentRoots collects the state roots of the given number of recent canonical
+// blocks which are on disk, or that of the most recent older block if none is.
+func recentRoots(db ethdb.Database, retain uint64) ([]common.Hash, error) {
+	hash := rawdb.ReadHeadBlockHash(db)
+	if hash == (common.Hash{}) {
+		return nil, errNoHead
+	}
+	number := rawdb.ReadHeaderNumber(db, hash)
+	if number == nil {
+		return nil, errNoHead
+	}
+	var (
+		roots []common.Hash
+		seen  = make(map[common.Hash]bool)
+	)
+	for n := int64(*number); n >= 0; n-- {
+		if len(roots) > 0 && uint64(int64(*number)-n) >= retain {
+			break
+		}
+		header := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, uint64(n)), uint64(n))
+		if header == nil {
+			return nil, fmt.Errorf("canonical header #%d missing", n)
+		}
+		if seen[header.Root] {
+			continue
+		}
+		if has, _ := db.Has(header.Root[:]); has || header.Root == emptyRoot {
+			roots = append(roots, header.Root)
+			seen[header.Root] = true
+		}
+	}
+	if len(roots) == 0 {
+		return nil, errNoState
+	}
+	return roots, nil
+}
+
+// prune deletes the state data not reachable from the given roots.
+func prune(db ethdb.Database, roots []common.Hash) error {
+	rawdb.WritePruningRoots(db, roots)
+
+	marked, err := mark(db, roots)
+	if err != nil {
+		return err
+	}
+	if err := sweep(rawdb.KeyValueStore(db), marked); err != nil {
+		return err
+	}
+	rawdb.DeletePruningRoots(db)
+
+	start := time.Now()
+	log.Info("Compacting database")
+	if err := db.Compact(nil, nil); err != nil {
+		return err
+	}
+	log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// mark collects the hashes of all trie nodes and contract code reachable from
+// the given state roots, along with the code cached for forked chains. Subtries
+// shared between the states are only iterated once. States with missing nodes
+// are tolerated as long as one is complete.
+func mark(db ethdb.Database, roots []common.Hash) (map[common.Hash]struct{}, error) {
+	var (
+		triedb   = trie.NewDatabase(db)
+		marked   = make(map[common.Hash]struct{})
+		complete int
+
+		start  = time.Now()
+		logged = time.Now()
+	)
+	var markTrie func(root common.Hash, accounts bool) error
+	markTrie = func(root common.Hash, accounts bool) error {
+		t, err := trie.New(root, triedb)
+		if err != nil {
+			return err
+		}
+		it := t.NodeIterator(nil)
+		for descend := true; it.Next(descend); {
+			descend = true
+			if hash := it.Hash(); hash != (common.Hash{}) {
+				if _, ok := marked[hash]; ok {
+					descend = false
+					continue
+				}
+				marked[hash] = struct{}{}
+			}
+			if it.Leaf() && accounts && !bytes.Equal(it.LeafBlob(), forkTombstone) {
+				var account state.Account
+				if err := rlp.Decode(bytes.NewReader(it.LeafBlob()), &account); err != nil {
+					return err
+				}
+				if account.Root != emptyRoot {
+					if err := markTrie(account.Root, false); err != nil {
+						return err
+					}
+				}
+				if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+					marked[codeHash] = struct{}{}
+				}
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Marking state data", "nodes", len(marked), "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
+		}
+		return it.Error()
+	}
+	for _, root := range roots {
+		if err := markTrie(root, true); err != nil {
+			if _, ok := err.(*trie.MissingNodeError); !ok {
+				return nil, err
+			}
+			log.Warn("Incomplete state kept", "root", root, "err", err)
+			continue
+		}
+		complete++
+	}
+	if complete == 0 {
+		return nil, errNoState
+	}
+	// The code of the accounts cached from the remote state of a forked chain is
+	// only referenced by the cache until the accounts are changed locally
+	err := rawdb.IterateForkAccounts(db, func(addr common.Address, enc []byte) error {
+		if len(enc) == 0 {
+			return nil
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			return err
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			marked[codeHash] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Marked state data", "roots", len(roots), "nodes", len(marked), "elapsed", common.PrettyDuration(time.Since(start)))
+	return marked, nil
+}
+
+// sweep deletes all the trie nodes and contract code that are not marked. Both
+// are stored under the hash of their content, which tells them apart from any
+// other data.
+func sweep(db ethdb.Database, marked map[common.Hash]struct{}) error {
+	var (
+		batch   = db.NewBatch()
+		deleted int
+		size    common.StorageSize
+
+		start  = time.Now()
+		logged = time.Now()
+	)
+	err := forEachEntry(db, func(key, value []byte) error {
+		if len(key) != common.HashLength {
+			return nil
+		}
+		hash := common.BytesToHash(key)
+		if _, ok := marked[hash]; ok || crypto.Keccak256Hash(value) != hash {
+			return nil
+		}
+		batch.Delete(key)
+		deleted++
+		size += common.StorageSize(len(key) + len(value))
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state data", "nodes", deleted, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Pruned state data", "nodes", deleted, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// forEachEntry calls fn with every entry of a key-value store.
+func forEachEntry(db ethdb.Database, fn func(key, value []byte) error) error {
+	it := db.NewIteratorWithPrefix(nil)
+	defer it.Release()
+
+	for it.Next() {
+		if err := fn(it.Key(), it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
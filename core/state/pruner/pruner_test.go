@@ -0,0 +1,237 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+var (
+	testAccount  = common.Address{0x01}
+	testContract = common.Address{0x02}
+	testCode     = []byte{0x60, 0x00}
+)
+
+// makeTestChain writes a chain of three blocks into the database, whose states
+// evolve as follows:
+//   - block 0: test account with a storage slot, test contract with code
+//   - block 1: test account storage modified, test contract destructed
+//   - block 2: test account balance modified
+func makeTestChain(t *testing.T, db ethdb.Database) []common.Hash {
+	sdb := state.NewDatabase(db)
+	statedb, _ := state.New(common.Hash{}, sdb)
+
+	changes := []func(){
+		func() {
+			statedb.SetBalance(testAccount, big.NewInt(1))
+			statedb.SetState(testAccount, common.Hash{0x01}, common.Hash{0x01})
+			statedb.SetCode(testContract, testCode)
+		},
+		func() {
+			statedb.SetState(testAccount, common.Hash{0x01}, common.Hash{0x02})
+			statedb.Suicide(testContract)
+		},
+		func() {
+			statedb.SetBalance(testAccount, big.NewInt(2))
+		},
+	}
+	var (
+		roots  []common.Hash
+		parent common.Hash
+	)
+	for i, change := range changes {
+		change()
+		root, err := statedb.Commit(true)
+		if err != nil {
+			t.Fatalf("block %d: failed to commit state: %v", i, err)
+		}
+		if err := sdb.TrieDB().Commit(root, false); err != nil {
+			t.Fatalf("block %d: failed to flush state: %v", i, err)
+		}
+		header := &types.Header{ParentHash: parent, Number: big.NewInt(int64(i)), Root: root}
+		rawdb.WriteHeader(db, header)
+		rawdb.WriteCanonicalHash(db, header.Hash(), uint64(i))
+		rawdb.WriteHeadBlockHash(db, header.Hash())
+
+		roots = append(roots, root)
+		parent = header.Hash()
+	}
+	return roots
+}
+
+// checkState verifies whether the full state of a root is in the database.
+func checkState(db ethdb.Database, root common.Hash) bool {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return false
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	return it.Error == nil
+}
+
+func TestPruneMemDatabase(t *testing.T) { testPrune(t, ethdb.NewMemDatabase()) }
+
+func TestPruneLDBDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pruner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.NewLDBDatabase(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	testPrune(t, db)
+}
+
+// Tests that pruning keeps the recent states whole and deletes the data only
+// reachable from older ones.
+func testPrune(t *testing.T, db ethdb.Database) {
+	roots := makeTestChain(t, db)
+	for i, root := range roots {
+		if !checkState(db, root) {
+			t.Fatalf("state %d incomplete before pruning", i)
+		}
+	}
+	if err := Prune(db, 2); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if checkState(db, roots[0]) {
+		t.Errorf("pruned state still complete")
+	}
+	for i, root := range roots[1:] {
+		if !checkState(db, root) {
+			t.Errorf("kept state %d incomplete", i+1)
+		}
+	}
+	if has, _ := db.Has(crypto.Keccak256(testCode)); has {
+		t.Errorf("unreachable code not pruned")
+	}
+	if rawdb.ReadHeadBlockHash(db) == (common.Hash{}) || rawdb.ReadPruningRoots(db) != nil {
+		t.Errorf("pruning metadata mismatch")
+	}
+}
+
+// Tests that an interrupted pruning is finished with the recorded roots.
+func TestRecoverPruning(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	roots := makeTestChain(t, db)
+
+	if err := RecoverPruning(db); err != nil {
+		t.Fatalf("failed to recover without pruning: %v", err)
+	}
+	if !checkState(db, roots[0]) {
+		t.Fatalf("state pruned without interrupted pruning")
+	}
+	rawdb.WritePruningRoots(db, roots[2:])
+	if err := RecoverPruning(db); err != nil {
+		t.Fatalf("failed to recover pruning: %v", err)
+	}
+	if checkState(db, roots[1]) {
+		t.Errorf("pruned state still complete")
+	}
+	if !checkState(db, roots[2]) {
+		t.Errorf("kept state incomplete")
+	}
+	if rawdb.ReadPruningRoots(db) != nil {
+		t.Errorf("finished pruning still recorded")
+	}
+}
+
+// Tests that the only complete state is kept even if it is older than the
+// states to retain.
+func TestPruneKeepsLastState(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	roots := makeTestChain(t, db)
+
+	// Drop the root node of the head state, as if it was never flushed
+	db.Delete(roots[2][:])
+
+	if err := Prune(db, 1); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if !checkState(db, roots[1]) {
+		t.Errorf("last complete state pruned")
+	}
+	if checkState(db, roots[0]) {
+		t.Errorf("older state still complete")
+	}
+}
+
+// testRemote is a remote state holding the test contract only.
+type testRemote struct{}
+
+func (testRemote) Account(addr common.Address) (uint64, *big.Int, []byte, error) {
+	if addr == testContract {
+		return 1, new(big.Int), testCode, nil
+	}
+	return 0, new(big.Int), nil, nil
+}
+
+func (testRemote) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	return common.Hash{}, nil
+}
+
+// Tests that pruning the database of a forked chain keeps the code of the remote
+// accounts, which is only referenced by the fork cache.
+func TestPruneForkedState(t *testing.T) {
+	db := ethdb.NewMemDatabase()
+	sdb := state.NewForkDatabase(db, testRemote{})
+	statedb, _ := state.New(common.Hash{}, sdb)
+
+	// Read the remote contract without changing it, and delete an account
+	if code := statedb.GetCode(testContract); len(code) == 0 {
+		t.Fatalf("remote code not retrieved")
+	}
+	statedb.SetBalance(testAccount, big.NewInt(1))
+	root, _ := statedb.Commit(true)
+	statedb.Suicide(testAccount)
+	root, _ = statedb.Commit(true)
+	if err := sdb.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	header := &types.Header{Number: big.NewInt(0), Root: root}
+	rawdb.WriteHeader(db, header)
+	rawdb.WriteCanonicalHash(db, header.Hash(), 0)
+	rawdb.WriteHeadBlockHash(db, header.Hash())
+
+	if err := Prune(db, 1); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	if has, _ := db.Has(crypto.Keccak256(testCode)); !has {
+		t.Errorf("remote code pruned")
+	}
+	statedb, _ = state.New(root, state.NewForkDatabase(db, testRemote{}))
+	if code := statedb.GetCode(testContract); len(code) == 0 {
+		t.Errorf("remote code missing after pruning")
+	}
+}
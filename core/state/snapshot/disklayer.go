@@ -0,0 +1,184 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+// While the snapshot is being generated in the background, only the accounts up
+// to the generation marker are readable from it.
+type diskLayer struct {
+	diskdb ethdb.Database // Key-value store containing the base snapshot
+	triedb *trie.Database // Trie node database the snapshot is generated from
+	root   common.Hash    // Root hash of the base snapshot
+	stale  bool           // Signals that the layer became stale (state progressed)
+
+	genMarker  []byte             // Hash of the last account generated on disk, nil if generation is done
+	genStart   time.Time          // Time the generation started at, carried over to later disk layers
+	genPending chan struct{}      // Closed when the generation is done
+	genAbort   chan chan struct{} // Aborts the running generator, nil if none runs
+
+	lock sync.RWMutex // Held for reading during database accesses, so that invalidation waits for them
+}
+
+// Root returns root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale return whether this layer has become stale (was flattened across) or if
+// it's still live.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// markStale invalidates the layer, waiting for the running reads to finish.
+func (dl *diskLayer) markStale() {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.stale = true
+}
+
+// Account directly retrieves the account trie value associated with a particular
+// hash in the snapshot slim data format.
+func (dl *diskLayer) Account(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covers(hash) {
+		return nil, errNotCoveredYet
+	}
+	return rawdb.ReadAccountSnapshot(dl.diskdb, hash), nil
+}
+
+// Storage directly retrieves the storage trie value associated with a particular
+// hash within a particular account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	if !dl.covers(accountHash) {
+		return nil, errNotCoveredYet
+	}
+	return rawdb.ReadStorageSnapshot(dl.diskdb, accountHash, storageHash), nil
+}
+
+// covers returns whether the account with the given hash was generated on disk
+// already. The lock must be held while the generator is running.
+func (dl *diskLayer) covers(hash common.Hash) bool {
+	return dl.genMarker == nil || bytes.Compare(hash[:], dl.genMarker) <= 0
+}
+
+// flatten writes the changes of a chain of diff layers, given from the top down,
+// into the database, along with the root of the topmost one. The changes are
+// merged in memory first, so that the storage wipes of destructed accounts can
+// be applied to the database before anything else. While the snapshot is being
+// generated, the changes of accounts past the marker are left to the generator,
+// which must not be running, and the root is written by the generator when done.
+func (dl *diskLayer) flatten(diffs []*diffLayer) error {
+	var (
+		destructs = make(map[common.Hash]struct{})
+		accounts  = make(map[common.Hash][]byte)
+		storage   = make(map[common.Hash]map[common.Hash][]byte)
+	)
+	for i := len(diffs) - 1; i >= 0; i-- {
+		diff := diffs[i]
+		for hash := range diff.destructs {
+			destructs[hash] = struct{}{}
+			delete(storage, hash)
+		}
+		for hash, data := range diff.accounts {
+			accounts[hash] = data
+		}
+		for hash, slots := range diff.storage {
+			merged := storage[hash]
+			if merged == nil {
+				merged = make(map[common.Hash][]byte)
+				storage[hash] = merged
+			}
+			for slot, data := range slots {
+				merged[slot] = data
+			}
+		}
+	}
+	batch := dl.diskdb.NewBatch()
+	for hash := range destructs {
+		if !dl.covers(hash) {
+			continue
+		}
+		prefix := append(append([]byte{}, rawdb.SnapshotStoragePrefix...), hash.Bytes()...)
+		err := iterateKeys(dl.diskdb, prefix, func(key, value []byte) error {
+			if len(key) == len(prefix)+common.HashLength {
+				return batch.Delete(common.CopyBytes(key))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for hash, data := range accounts {
+		if !dl.covers(hash) {
+			continue
+		}
+		if len(data) == 0 {
+			rawdb.DeleteAccountSnapshot(batch, hash)
+		} else {
+			rawdb.WriteAccountSnapshot(batch, hash, data)
+		}
+	}
+	for hash, slots := range storage {
+		if !dl.covers(hash) {
+			continue
+		}
+		for slot, data := range slots {
+			if len(data) == 0 {
+				rawdb.DeleteStorageSnapshot(batch, hash, slot)
+			} else {
+				rawdb.WriteStorageSnapshot(batch, hash, slot, data)
+			}
+		}
+	}
+	if dl.genMarker == nil {
+		rawdb.WriteSnapshotRoot(batch, diffs[0].root)
+	}
+	return batch.Write()
+}
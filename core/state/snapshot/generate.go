@@ -0,0 +1,354 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// emptyRoot is the known root hash of an empty trie.
+var emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+// account is the trie encoding of an Ethereum account, of which the snapshot
+// only needs the storage root.
+type account struct {
+	Nonce    uint64
+	Balance  *big.Int
+	Root     common.Hash
+	CodeHash []byte
+}
+
+// errGeneratorAborted is returned internally when the generator is aborted while
+// wiping the old snapshot.
+var errGeneratorAborted = errors.New("generator aborted")
+
+// generateLayer returns a disk layer for the given state root, wiping the flat
+// snapshot on disk and regenerating it from the trie in the background.
+func generateLayer(diskdb ethdb.Database, triedb *trie.Database, root common.Hash) *diskLayer {
+	log.Info("Generating state snapshot", "root", root)
+
+	// Invalidate the old snapshot first, so that an interrupted generation is
+	// started over on the next run
+	rawdb.DeleteSnapshotRoot(diskdb)
+
+	dl := &diskLayer{
+		diskdb:     diskdb,
+		triedb:     triedb,
+		root:       root,
+		genMarker:  []byte{},
+		genStart:   time.Now(),
+		genPending: make(chan struct{}),
+	}
+	dl.startGeneration()
+	return dl
+}
+
+// startGeneration runs the generator of the disk layer in the background.
+func (dl *diskLayer) startGeneration() {
+	dl.genAbort = make(chan chan struct{})
+	go dl.generate(dl.genAbort)
+}
+
+// stopGeneration aborts the generator of the disk layer, if running, and waits
+// for it to write out its progress.
+func (dl *diskLayer) stopGeneration() {
+	if dl.genAbort == nil {
+		return
+	}
+	done := make(chan struct{})
+	dl.genAbort <- done
+	<-done
+	dl.genAbort = nil
+}
+
+// generate fills the flat snapshot of the disk layer from the trie of its root,
+// going on from the marker. The generated accounts are published to the marker
+// in batches, readers falling back to the trie for the others. An abort is only
+// given way to between accounts, and leaves nothing past the marker on disk. If
+// the trie is incomplete, as its nodes may be pruned from memory while the chain
+// progresses, the generator waits to be aborted and resumed on a newer root.
+func (dl *diskLayer) generate(abort chan chan struct{}) {
+	var (
+		marker = dl.genMarker
+		batch  = dl.diskdb.NewBatch()
+		logged = time.Now()
+	)
+	// publish writes out the batch and moves the marker past the accounts in it
+	publish := func(next []byte) error {
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+
+		dl.lock.Lock()
+		dl.genMarker = next
+		dl.lock.Unlock()
+		return nil
+	}
+	// pause waits for the generator to be aborted
+	pause := func(err error) {
+		log.Debug("Paused state snapshot generation", "root", dl.root, "at", common.BytesToHash(marker), "err", err)
+		done := <-abort
+		close(done)
+	}
+	// Wipe the old snapshot before generating anything, over again if aborted
+	if len(marker) == 0 {
+		wipes := map[string]int{
+			string(rawdb.SnapshotAccountPrefix): len(rawdb.SnapshotAccountPrefix) + common.HashLength,
+			string(rawdb.SnapshotStoragePrefix): len(rawdb.SnapshotStoragePrefix) + 2*common.HashLength,
+		}
+		for prefix, length := range wipes {
+			err := iterateKeys(dl.diskdb, []byte(prefix), func(key, value []byte) error {
+				if len(key) != length {
+					return nil
+				}
+				batch.Delete(common.CopyBytes(key))
+				if batch.ValueSize() < ethdb.IdealBatchSize {
+					return nil
+				}
+				if err := publish(marker); err != nil {
+					return err
+				}
+				select {
+				case done := <-abort:
+					close(done)
+					return errGeneratorAborted
+				default:
+					return nil
+				}
+			})
+			if err == errGeneratorAborted {
+				return
+			}
+			if err != nil {
+				log.Error("Failed to wipe state snapshot", "err", err)
+				pause(err)
+				return
+			}
+		}
+	}
+	accTrie, err := trie.New(dl.root, dl.triedb)
+	if err != nil {
+		pause(err)
+		return
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(marker))
+	for accIt.Next() {
+		if bytes.Equal(accIt.Key, marker) {
+			continue
+		}
+		accountHash := common.BytesToHash(accIt.Key)
+
+		var acc account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			log.Error("Invalid account encountered during snapshot generation", "hash", accountHash, "err", err)
+			pause(err)
+			return
+		}
+		// Write the storage first, so that an account aborted halfway only leaves
+		// storage slots behind, which are dropped right away
+		if acc.Root != emptyRoot {
+			if err := dl.generateStorage(batch, accountHash, acc.Root, func() error { return publish(marker) }); err != nil {
+				batch.Reset()
+				if err := dl.wipeStorage(accountHash); err != nil {
+					log.Error("Failed to wipe partial snapshot storage", "hash", accountHash, "err", err)
+				}
+				pause(err)
+				return
+			}
+		}
+		rawdb.WriteAccountSnapshot(batch, accountHash, accIt.Value)
+		marker = common.CopyBytes(accIt.Key)
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := publish(marker); err != nil {
+				log.Error("Failed to write state snapshot", "err", err)
+				pause(err)
+				return
+			}
+		}
+		select {
+		case done := <-abort:
+			if err := publish(marker); err != nil {
+				log.Error("Failed to write state snapshot", "err", err)
+			}
+			close(done)
+			return
+		default:
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Generating state snapshot", "root", dl.root, "at", accountHash, "elapsed", common.PrettyDuration(time.Since(dl.genStart)))
+			logged = time.Now()
+		}
+	}
+	if accIt.Err != nil {
+		if err := publish(marker); err != nil {
+			log.Error("Failed to write state snapshot", "err", err)
+		}
+		pause(accIt.Err)
+		return
+	}
+	rawdb.WriteSnapshotRoot(batch, dl.root)
+	if err := publish(nil); err != nil {
+		log.Error("Failed to write state snapshot", "err", err)
+		pause(err)
+		return
+	}
+	close(dl.genPending)
+	log.Info("Generated state snapshot", "root", dl.root, "elapsed", common.PrettyDuration(time.Since(dl.genStart)))
+
+	// Wait for the tree to notice the generation is done
+	done := <-abort
+	close(done)
+}
+
+// generateStorage writes the storage slots of an account from its storage trie
+// into the batch, calling flush whenever the batch grows large.
+func (dl *diskLayer) generateStorage(batch ethdb.Batch, accountHash, root common.Hash, flush func() error) error {
+	storeTrie, err := trie.New(root, dl.triedb)
+	if err != nil {
+		return err
+	}
+	storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+	for storeIt.Next() {
+		rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(storeIt.Key), storeIt.Value)
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return storeIt.Err
+}
+
+// wipeStorage deletes the storage slots of an account from the flat snapshot.
+func (dl *diskLayer) wipeStorage(accountHash common.Hash) error {
+	batch := dl.diskdb.NewBatch()
+	prefix := append(append([]byte{}, rawdb.SnapshotStoragePrefix...), accountHash.Bytes()...)
+	err := iterateKeys(dl.diskdb, prefix, func(key, value []byte) error {
+		if len(key) == len(prefix)+common.HashLength {
+			return batch.Delete(common.CopyBytes(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+// VerifyState checks that the flat snapshot on disk holds exactly the accounts
+// and storage slots of the trie of the given state root.
+func VerifyState(diskdb ethdb.Database, triedb *trie.Database, root common.Hash) error {
+	if have := rawdb.ReadSnapshotRoot(diskdb); have != root {
+		return fmt.Errorf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+	var (
+		accounts, slots int
+		start           = time.Now()
+		logged          = time.Now()
+	)
+	accTrie, err := trie.New(root, triedb)
+	if err != nil {
+		return err
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		accountHash := common.BytesToHash(accIt.Key)
+		if data := rawdb.ReadAccountSnapshot(diskdb, accountHash); !bytes.Equal(data, accIt.Value) {
+			return fmt.Errorf("account %x mismatch: have %x, want %x", accountHash, data, accIt.Value)
+		}
+		accounts++
+
+		var acc account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			return err
+		}
+		storeTrie, err := trie.New(acc.Root, triedb)
+		if err != nil {
+			return err
+		}
+		storeIt := trie.NewIterator(storeTrie.NodeIterator(nil))
+		for storeIt.Next() {
+			storageHash := common.BytesToHash(storeIt.Key)
+			if data := rawdb.ReadStorageSnapshot(diskdb, accountHash, storageHash); !bytes.Equal(data, storeIt.Value) {
+				return fmt.Errorf("storage %x of account %x mismatch: have %x, want %x", storageHash, accountHash, data, storeIt.Value)
+			}
+			slots++
+		}
+		if storeIt.Err != nil {
+			return storeIt.Err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying state snapshot", "at", accountHash, "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if accIt.Err != nil {
+		return accIt.Err
+	}
+	// All trie entries are in the snapshot, make sure there are no others
+	var flatAccounts, flatSlots int
+	err = iterateKeys(diskdb, rawdb.SnapshotAccountPrefix, func(key, value []byte) error {
+		if len(key) == len(rawdb.SnapshotAccountPrefix)+common.HashLength {
+			flatAccounts++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = iterateKeys(diskdb, rawdb.SnapshotStoragePrefix, func(key, value []byte) error {
+		if len(key) == len(rawdb.SnapshotStoragePrefix)+2*common.HashLength {
+			flatSlots++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if flatAccounts != accounts || flatSlots != slots {
+		return fmt.Errorf("dangling snapshot entries: have %d accounts and %d slots, want %d and %d", flatAccounts, flatSlots, accounts, slots)
+	}
+	log.Info("Verified state snapshot", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// iterateKeys calls fn with every entry of a database whose key starts with the
+// given prefix.
+func iterateKeys(db ethdb.Database, prefix []byte, fn func(key, value []byte) error) error {
+	it := rawdb.KeyValueStore(db).NewIteratorWithPrefix(prefix)
+	defer it.Release()
+
+	for it.Next() {
+		if err := fn(it.Key(), it.Value()); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
@@ -0,0 +1,301 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a flat key-value view of the state, readable in a
+// single database lookup instead of a trie walk.
+//
+// The snapshot consists of a disk layer, holding the accounts and storage slots
+// of one state in the database, and of in-memory diff layers on top of it, each
+// holding the changes of one block. Diff layers form a tree, so that the states
+// of competing recent blocks can all be read. As the chain progresses, the
+// oldest diff layers are flattened into the disk layer.
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// errSnapshotMissing is returned if a snapshot layer is requested for a
+	// state the tree does not know.
+	errSnapshotMissing = errors.New("snapshot missing")
+
+	// errNotCoveredYet is returned from data accessors if the requested entry is
+	// not generated on disk yet, so it has to be read from the trie.
+	errNotCoveredYet = errors.New("not covered yet")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+// Entries are returned in their trie encoding, nil if they do not exist.
+type Snapshot interface {
+	// Root returns the root hash of the state the snapshot belongs to.
+	Root() common.Hash
+
+	// Account retrieves the trie value of an account by its hash.
+	Account(hash common.Hash) ([]byte, error)
+
+	// Storage retrieves the trie value of a storage slot by the hashes of its
+	// account and key.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports
+// some additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Stale returns whether the layer was invalidated by flattening.
+	Stale() bool
+
+	// markStale invalidates the layer.
+	markStale()
+}
+
+// Tree is an Ethereum state snapshot tree. It consists of one persistent base
+// layer backed by a key-value store, on top of which arbitrarily many in-memory
+// diff layers are stacked. The memory diffs can form a tree with branching, but
+// the disk layer is singleton and common to all. Beyond the in-memory layers,
+// the tree does not hold any state.
+type Tree struct {
+	diskdb ethdb.Database           // Persistent database to store the snapshot
+	triedb *trie.Database           // Trie node database to generate the snapshot from
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store, ensuring that it belongs to the expected state root. Diff layers are not
+// persisted, the tree is meant to be flattened into the disk layer on shutdown.
+//
+// If the snapshot is missing or inconsistent, it is regenerated from the trie
+// of the given root in the background. Until done, the entries not generated
+// yet are reported as such, to be read from the trie instead.
+func New(diskdb ethdb.Database, triedb *trie.Database, root common.Hash) *Tree {
+	var base *diskLayer
+	if rawdb.ReadSnapshotRoot(diskdb) == root {
+		base = &diskLayer{diskdb: diskdb, triedb: triedb, root: root}
+	} else {
+		base = generateLayer(diskdb, triedb, root)
+	}
+	return &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		layers: map[common.Hash]snapshot{root: base},
+	}
+}
+
+// disklayer returns the disk layer of the tree, or nil if the tree is disabled.
+// The lock must be held.
+func (t *Tree) disklayer() *diskLayer {
+	for _, layer := range t.layers {
+		for ; layer != nil; layer = layer.Parent() {
+			if disk, ok := layer.(*diskLayer); ok {
+				return disk
+			}
+		}
+	}
+	return nil
+}
+
+// Snapshot retrieves a snapshot belonging to the given state root, or nil if no
+// snapshot is maintained for that state.
+func (t *Tree) Snapshot(root common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[root]; ok {
+		return layer
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+//
+// The destructed accounts had all their storage wiped before the accounts and
+// storage slots were changed. Deleted entries are mapped to nil.
+func (t *Tree) Update(root, parent common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	if root == parent {
+		return fmt.Errorf("snapshot cycle %x", root)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.layers[root]; ok {
+		return nil
+	}
+	base, ok := t.layers[parent]
+	if !ok {
+		return fmt.Errorf("parent %v: %v", parent, errSnapshotMissing)
+	}
+	t.layers[root] = newDiffLayer(base, root, destructs, accounts, storage)
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the disk layer, and all the layers not built on
+// top of the new disk layer are discarded.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	snap, ok := t.layers[root]
+	if !ok {
+		return fmt.Errorf("head %v: %v", root, errSnapshotMissing)
+	}
+	// Collect the diff layers from the head downwards
+	var diffs []*diffLayer
+	for layer := snap; ; layer = layer.Parent() {
+		diff, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, diff)
+	}
+	if len(diffs) <= layers {
+		return nil
+	}
+	var (
+		flatten = diffs[layers:]
+		base    = flatten[len(flatten)-1].Parent().(*diskLayer)
+	)
+	// Invalidate the layers being flattened before touching the disk, so that no
+	// reader sees the disk layer change under its feet. A running generator is
+	// paused meanwhile and resumed on the new disk layer.
+	base.stopGeneration()
+	base.markStale()
+	for _, diff := range flatten {
+		diff.markStale()
+	}
+	if err := base.flatten(flatten); err != nil {
+		return err
+	}
+	disk := &diskLayer{
+		diskdb:     base.diskdb,
+		triedb:     base.triedb,
+		root:       flatten[0].root,
+		genMarker:  base.genMarker,
+		genStart:   base.genStart,
+		genPending: base.genPending,
+	}
+	if disk.genMarker != nil {
+		disk.startGeneration()
+	}
+	if layers > 0 {
+		diffs[layers-1].setParent(disk)
+	}
+	// Keep the layers building on the new disk layer, drop the rest
+	children := make(map[common.Hash]snapshot)
+	for root, layer := range t.layers {
+		for ancestor := layer; ancestor != nil; ancestor = ancestor.Parent() {
+			if ancestor == snapshot(disk) {
+				children[root] = layer
+				break
+			}
+			if ancestor.Stale() {
+				layer.markStale()
+				break
+			}
+		}
+	}
+	children[disk.root] = disk
+	t.layers = children
+
+	log.Debug("Flattened snapshot layers", "layers", len(flatten), "root", disk.root, "kept", len(children))
+	return nil
+}
+
+// Disable invalidates all the snapshot layers and the snapshot on disk, so that
+// the state is only read from the trie until the snapshot is regenerated on the
+// next start.
+func (t *Tree) Disable() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if disk := t.disklayer(); disk != nil {
+		disk.stopGeneration()
+	}
+	for _, layer := range t.layers {
+		layer.markStale()
+	}
+	t.layers = make(map[common.Hash]snapshot)
+	rawdb.DeleteSnapshotRoot(t.diskdb)
+}
+
+// Rebuild invalidates all the snapshot layers and regenerates the snapshot on
+// disk from the trie of the given root in the background. It is meant for when
+// the chain jumped to a state not covered by the tree anymore. A snapshot on disk
+// already belonging to the root, such as one retrieved by a state sync, is kept
+// as is.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if disk := t.disklayer(); disk != nil {
+		disk.stopGeneration()
+	}
+	for _, layer := range t.layers {
+		layer.markStale()
+	}
+	if rawdb.ReadSnapshotRoot(t.diskdb) == root {
+		t.layers = map[common.Hash]snapshot{root: &diskLayer{diskdb: t.diskdb, triedb: t.triedb, root: root}}
+	} else {
+		t.layers = map[common.Hash]snapshot{root: generateLayer(t.diskdb, t.triedb, root)}
+	}
+}
+
+// Stop aborts the background generation of the snapshot, if running, as the
+// database is about to be closed. An incomplete snapshot is regenerated on the
+// next start.
+func (t *Tree) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if disk := t.disklayer(); disk != nil {
+		disk.stopGeneration()
+	}
+}
+
+// WaitGeneration blocks until the background generation of the snapshot is done,
+// returning right away if none is running. It is meant for tests, as the
+// generator might wait for a newer root if the trie got pruned.
+func (t *Tree) WaitGeneration() {
+	t.lock.RLock()
+	disk := t.disklayer()
+	t.lock.RUnlock()
+
+	if disk != nil && disk.genPending != nil {
+		<-disk.genPending
+	}
+}
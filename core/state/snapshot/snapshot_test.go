@@ -0,0 +1,278 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeTestState writes a state with two accounts into the database, the second
+// one with two storage slots, and returns its root.
+func makeTestState(t *testing.T, triedb *trie.Database) common.Hash {
+	storeTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+	storeTrie.Update([]byte("slot-1"), []byte{0x01})
+	storeTrie.Update([]byte("slot-2"), []byte{0x02})
+	storeRoot, err := storeTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit storage trie: %v", err)
+	}
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb, 0)
+	for i, root := range []common.Hash{emptyRoot, storeRoot} {
+		blob, _ := rlp.EncodeToBytes(&account{Nonce: uint64(i), Balance: big.NewInt(1), Root: root, CodeHash: []byte{0x01}})
+		accTrie.Update([]byte{byte(i)}, blob)
+	}
+	root, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	return root
+}
+
+// Tests that a snapshot is generated from the trie, and that verification catches
+// entries that drifted from it.
+func TestGenerateAndVerify(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		triedb = trie.NewDatabase(db)
+		root   = makeTestState(t, triedb)
+	)
+	// Leave a dangling account from an older snapshot, generation must wipe it
+	rawdb.WriteAccountSnapshot(db, common.Hash{0xff}, []byte{0x01})
+
+	snaps := New(db, triedb, root)
+	snaps.WaitGeneration()
+
+	if err := VerifyState(db, triedb, root); err != nil {
+		t.Fatalf("generated snapshot mismatch: %v", err)
+	}
+	if snaps.Snapshot(root) == nil {
+		t.Fatalf("generated snapshot missing from tree")
+	}
+	// Corrupt the snapshot in various ways and make sure all are caught
+	var (
+		plainHash   = crypto.Keccak256Hash([]byte{0x00})
+		storageHash = crypto.Keccak256Hash([]byte{0x01})
+		slotHash    = crypto.Keccak256Hash([]byte("slot-1"))
+	)
+	corruptions := []struct {
+		name    string
+		corrupt func(db *ethdb.MemDatabase)
+	}{
+		{
+			name:    "modified account",
+			corrupt: func(db *ethdb.MemDatabase) { rawdb.WriteAccountSnapshot(db, plainHash, []byte{0x01}) },
+		},
+		{
+			name:    "missing slot",
+			corrupt: func(db *ethdb.MemDatabase) { rawdb.DeleteStorageSnapshot(db, storageHash, slotHash) },
+		},
+		{
+			name:    "dangling slot",
+			corrupt: func(db *ethdb.MemDatabase) { rawdb.WriteStorageSnapshot(db, plainHash, slotHash, []byte{0x01}) },
+		},
+	}
+	for _, tt := range corruptions {
+		corrupted := ethdb.NewMemDatabase()
+		for _, key := range db.Keys() {
+			value, _ := db.Get(key)
+			corrupted.Put(key, value)
+		}
+		tt.corrupt(corrupted)
+		if err := VerifyState(corrupted, triedb, root); err == nil {
+			t.Errorf("%s: corruption not detected", tt.name)
+		}
+	}
+}
+
+// Tests that a snapshot generated halfway only serves the generated accounts,
+// and that generation resumes on the newer root when flattening layers into it.
+func TestGenerateResume(t *testing.T) {
+	var (
+		db     = ethdb.NewMemDatabase()
+		triedb = trie.NewDatabase(db)
+		root   = makeTestState(t, triedb)
+	)
+	New(db, triedb, root).WaitGeneration()
+
+	// Drop the higher account from the snapshot and pause generation before it
+	hashes := []common.Hash{crypto.Keccak256Hash([]byte{0x00}), crypto.Keccak256Hash([]byte{0x01})}
+	lo, hi := hashes[0], hashes[1]
+	if bytes.Compare(lo[:], hi[:]) > 0 {
+		lo, hi = hi, lo
+	}
+	rawdb.DeleteSnapshotRoot(db)
+	rawdb.DeleteAccountSnapshot(db, hi)
+	disk := &diskLayer{diskdb: db, triedb: triedb, root: root}
+	if err := disk.wipeStorage(hi); err != nil {
+		t.Fatalf("failed to wipe storage: %v", err)
+	}
+	disk.genMarker, disk.genStart, disk.genPending = lo[:], time.Now(), make(chan struct{})
+
+	snaps := &Tree{diskdb: db, triedb: triedb, layers: map[common.Hash]snapshot{root: disk}}
+	if blob, err := disk.Account(lo); err != nil || blob == nil {
+		t.Fatalf("generated account unavailable: %x, %v", blob, err)
+	}
+	if _, err := disk.Account(hi); err != errNotCoveredYet {
+		t.Fatalf("pending account error mismatch: have %v, want %v", err, errNotCoveredYet)
+	}
+	// Change both accounts in a new state and flatten it into the disk layer
+	accTrie, _ := trie.NewSecure(root, triedb, 0)
+	accounts := make(map[common.Hash][]byte)
+	for i, hash := range hashes {
+		var acc account
+		if err := rlp.DecodeBytes(accTrie.Get([]byte{byte(i)}), &acc); err != nil {
+			t.Fatalf("failed to decode account %d: %v", i, err)
+		}
+		acc.Balance = big.NewInt(2)
+		blob, _ := rlp.EncodeToBytes(&acc)
+		accTrie.Update([]byte{byte(i)}, blob)
+		accounts[hash] = blob
+	}
+	next, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	if err := snaps.Update(next, root, nil, accounts, nil); err != nil {
+		t.Fatalf("failed to update snapshot: %v", err)
+	}
+	if err := snaps.Cap(next, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	snaps.WaitGeneration()
+
+	if err := VerifyState(db, triedb, next); err != nil {
+		t.Fatalf("resumed snapshot mismatch: %v", err)
+	}
+	snaps.Stop()
+}
+
+// Tests that diff layers read through to their parents unless changed or wiped,
+// and that capping flattens them into the disk layer and drops abandoned chains.
+func TestUpdateAndCap(t *testing.T) {
+	var (
+		db = ethdb.NewMemDatabase()
+
+		acc1, acc2          = common.Hash{0xa1}, common.Hash{0xa2}
+		slot1, slot2, slot3 = common.Hash{0x01}, common.Hash{0x02}, common.Hash{0x03}
+
+		base            = common.Hash{0xb0}
+		r1, r2, r2b, r3 = common.Hash{0x01}, common.Hash{0x02}, common.Hash{0x2b}, common.Hash{0x03}
+	)
+	rawdb.WriteAccountSnapshot(db, acc1, []byte("acc1-0"))
+	rawdb.WriteStorageSnapshot(db, acc1, slot1, []byte{0x01})
+	rawdb.WriteStorageSnapshot(db, acc1, slot2, []byte{0x02})
+	rawdb.WriteSnapshotRoot(db, base)
+
+	snaps := New(db, nil, base)
+	updates := []struct {
+		root, parent common.Hash
+		destructs    map[common.Hash]struct{}
+		accounts     map[common.Hash][]byte
+		storage      map[common.Hash]map[common.Hash][]byte
+	}{
+		{r1, base, nil, map[common.Hash][]byte{acc1: []byte("acc1-1")}, map[common.Hash]map[common.Hash][]byte{acc1: {slot1: nil}}},
+		{r2, r1, map[common.Hash]struct{}{acc1: {}}, map[common.Hash][]byte{acc1: []byte("acc1-2")}, map[common.Hash]map[common.Hash][]byte{acc1: {slot3: {0x03}}}},
+		{r2b, r1, nil, map[common.Hash][]byte{acc2: []byte("acc2-2")}, nil},
+		{r3, r2, nil, map[common.Hash][]byte{acc2: []byte("acc2-3")}, nil},
+	}
+	for _, u := range updates {
+		if err := snaps.Update(u.root, u.parent, u.destructs, u.accounts, u.storage); err != nil {
+			t.Fatalf("failed to add layer %x: %v", u.root, err)
+		}
+	}
+	if err := snaps.Update(common.Hash{0xff}, common.Hash{0xfe}, nil, nil, nil); err == nil {
+		t.Fatalf("layer added without parent")
+	}
+	check := func(snap Snapshot, accountHash, storageHash common.Hash, want []byte) {
+		t.Helper()
+		var (
+			have []byte
+			err  error
+		)
+		if storageHash == (common.Hash{}) {
+			have, err = snap.Account(accountHash)
+		} else {
+			have, err = snap.Storage(accountHash, storageHash)
+		}
+		if err != nil || !bytes.Equal(have, want) {
+			t.Errorf("layer %x, entry %x/%x: have %x (%v), want %x", snap.Root(), accountHash, storageHash, have, err, want)
+		}
+	}
+	old := snaps.Snapshot(r1)
+	check(old, acc1, common.Hash{}, []byte("acc1-1"))
+	check(old, acc1, slot1, nil)
+	check(old, acc1, slot2, []byte{0x02})
+	check(snaps.Snapshot(r2), acc1, slot2, nil)
+	check(snaps.Snapshot(r2), acc1, slot3, []byte{0x03})
+	check(snaps.Snapshot(r2b), acc2, common.Hash{}, []byte("acc2-2"))
+	check(snaps.Snapshot(r3), acc1, common.Hash{}, []byte("acc1-2"))
+
+	// Flatten all but the head layer, the side chain must be dropped
+	if err := snaps.Cap(r3, 1); err != nil {
+		t.Fatalf("failed to cap snapshot: %v", err)
+	}
+	if snaps.Snapshot(r2b) != nil || snaps.Snapshot(r1) != nil {
+		t.Errorf("abandoned layers kept")
+	}
+	if _, err := old.Account(acc1); err != ErrSnapshotStale {
+		t.Errorf("flattened layer error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	if root := rawdb.ReadSnapshotRoot(db); root != r2 {
+		t.Errorf("disk root mismatch: have %x, want %x", root, r2)
+	}
+	if blob := rawdb.ReadStorageSnapshot(db, acc1, slot2); blob != nil {
+		t.Errorf("destructed slot still on disk: %x", blob)
+	}
+	check(snaps.Snapshot(r2), acc1, slot3, []byte{0x03})
+	check(snaps.Snapshot(r3), acc2, common.Hash{}, []byte("acc2-3"))
+
+	// Flatten everything, as done on shutdown
+	if err := snaps.Cap(r3, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	if root := rawdb.ReadSnapshotRoot(db); root != r3 {
+		t.Errorf("disk root mismatch: have %x, want %x", root, r3)
+	}
+	if blob := rawdb.ReadAccountSnapshot(db, acc2); !bytes.Equal(blob, []byte("acc2-3")) {
+		t.Errorf("flattened account mismatch: have %x, want %x", blob, []byte("acc2-3"))
+	}
+	// Disabling must invalidate the snapshot on disk as well
+	head := snaps.Snapshot(r3)
+	snaps.Disable()
+	if snaps.Snapshot(r3) != nil {
+		t.Errorf("layer kept after disabling")
+	}
+	if _, err := head.Account(acc2); err != ErrSnapshotStale {
+		t.Errorf("disabled layer error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	if root := rawdb.ReadSnapshotRoot(db); root != (common.Hash{}) {
+		t.Errorf("disk root kept after disabling: %x", root)
+	}
+}
@@ -86,6 +86,10 @@ type stateObject struct {
 	dirtyCode bool // true if the code was updated
 	suicided  bool
 	deleted   bool
+
+	// reset is set if the object replaced an existing account, whose storage is
+	// no longer readable from the snapshot of the state.
+	reset bool
 }
 
 // empty returns whether the account is considered empty.
@@ -125,11 +129,13 @@ func (c *stateObject) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, c.data)
 }
 
-// setError remembers the first non-nil error it is called with.
+// setError remembers the first non-nil error it is called with, reporting it to
+// the state database too.
 func (self *stateObject) setError(err error) {
 	if self.dbErr == nil {
 		self.dbErr = err
 	}
+	self.db.setError(err)
 }
 
 func (self *stateObject) markSuicided() {
@@ -165,8 +171,8 @@ func (self *stateObject) GetState(db Database, key common.Hash) common.Hash {
 	if exists {
 		return value
 	}
-	// Load from DB in case it is missing.
-	enc, err := self.getTrie(db).TryGet(key[:])
+	// Load from the snapshot or the database in case it is missing.
+	enc, err := self.readStorage(db, key)
 	if err != nil {
 		self.setError(err)
 		return common.Hash{}
@@ -182,6 +188,19 @@ func (self *stateObject) GetState(db Database, key common.Hash) common.Hash {
 	return value
 }
 
+// readStorage retrieves the trie value of a storage slot, through the snapshot
+// of the state if the storage of the account was not wiped since.
+func (self *stateObject) readStorage(db Database, key common.Hash) ([]byte, error) {
+	if snap := self.db.snap; snap != nil && !self.reset {
+		if _, wiped := self.db.snapDestructs[self.addrHash]; !wiped {
+			if enc, err := snap.Storage(self.addrHash, crypto.Keccak256Hash(key[:])); err == nil {
+				return enc, nil
+			}
+		}
+	}
+	return self.getTrie(db).TryGet(key[:])
+}
+
 // SetState updates a value in account storage.
 func (self *stateObject) SetState(db Database, key, value common.Hash) {
 	self.db.journal.append(storageChange{
@@ -204,11 +223,17 @@ func (self *stateObject) updateTrie(db Database) Trie {
 		delete(self.dirtyStorage, key)
 		if (value == common.Hash{}) {
 			self.setError(tr.TryDelete(key[:]))
+			if self.db.snap != nil {
+				self.db.updateSnapshotStorage(self, key, nil)
+			}
 			continue
 		}
 		// Encoding []byte cannot fail, ok to ignore the error.
 		v, _ := rlp.EncodeToBytes(bytes.TrimLeft(value[:], "\x00"))
 		self.setError(tr.TryUpdate(key[:], v))
+		if self.db.snap != nil {
+			self.db.updateSnapshotStorage(self, key, v)
+		}
 	}
 	return tr
 }
@@ -283,6 +308,7 @@ func (self *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject.suicided = self.suicided
 	stateObject.dirtyCode = self.dirtyCode
 	stateObject.deleted = self.deleted
+	stateObject.reset = self.reset
 	return stateObject
 }
 
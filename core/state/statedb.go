@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
@@ -53,6 +54,14 @@ type StateDB struct {
 	db   Database
 	trie Trie
 
+	// The flat snapshot the state is read through, if any, and the changes to
+	// add to it on commit, keyed by account and storage slot hashes.
+	snaps         *snapshot.Tree
+	snap          snapshot.Snapshot
+	snapDestructs map[common.Hash]struct{}
+	snapAccounts  map[common.Hash][]byte
+	snapStorage   map[common.Hash]map[common.Hash][]byte
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects      map[common.Address]*stateObject
 	stateObjectsDirty map[common.Address]struct{}
@@ -85,19 +94,43 @@ type StateDB struct {
 
 // Create a new state from a given trie.
 func New(root common.Hash, db Database) (*StateDB, error) {
+	return NewWithSnapshot(root, db, nil)
+}
+
+// NewWithSnapshot creates a new state from a given trie, reading it through the
+// flat snapshot of the given tree if that covers the state, and adding the
+// changes to the tree on commit.
+func NewWithSnapshot(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error) {
 	tr, err := db.OpenTrie(root)
 	if err != nil {
 		return nil, err
 	}
-	return &StateDB{
+	sdb := &StateDB{
 		db:                db,
 		trie:              tr,
+		snaps:             snaps,
 		stateObjects:      make(map[common.Address]*stateObject),
 		stateObjectsDirty: make(map[common.Address]struct{}),
 		logs:              make(map[common.Hash][]*types.Log),
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
-	}, nil
+	}
+	sdb.openSnapshot(root)
+	return sdb, nil
+}
+
+// openSnapshot starts reading the state through the snapshot of the given root,
+// if the state has a snapshot tree and that covers the root.
+func (self *StateDB) openSnapshot(root common.Hash) {
+	self.snap, self.snapDestructs, self.snapAccounts, self.snapStorage = nil, nil, nil, nil
+	if self.snaps == nil {
+		return
+	}
+	if self.snap = self.snaps.Snapshot(root); self.snap != nil {
+		self.snapDestructs = make(map[common.Hash]struct{})
+		self.snapAccounts = make(map[common.Hash][]byte)
+		self.snapStorage = make(map[common.Hash]map[common.Hash][]byte)
+	}
 }
 
 // setError remembers the first non-nil error it is called with.
@@ -127,6 +160,7 @@ func (self *StateDB) Reset(root common.Hash) error {
 	self.logs = make(map[common.Hash][]*types.Log)
 	self.logSize = 0
 	self.preimages = make(map[common.Hash][]byte)
+	self.openSnapshot(root)
 	self.clearJournalAndRefund()
 	return nil
 }
@@ -349,6 +383,10 @@ func (self *StateDB) updateStateObject(stateObject *stateObject) {
 		panic(fmt.Errorf("can't encode object at %x: %v", addr[:], err))
 	}
 	self.setError(self.trie.TryUpdate(addr[:], data))
+
+	if self.snap != nil {
+		self.snapAccounts[stateObject.addrHash] = data
+	}
 }
 
 // deleteStateObject removes the given object from the state trie.
@@ -356,6 +394,28 @@ func (self *StateDB) deleteStateObject(stateObject *stateObject) {
 	stateObject.deleted = true
 	addr := stateObject.Address()
 	self.setError(self.trie.TryDelete(addr[:]))
+
+	if self.snap != nil {
+		self.destructSnapshot(stateObject)
+		self.snapAccounts[stateObject.addrHash] = nil
+	}
+}
+
+// destructSnapshot records that the storage of an account was wiped, discarding
+// its earlier storage changes.
+func (self *StateDB) destructSnapshot(stateObject *stateObject) {
+	self.snapDestructs[stateObject.addrHash] = struct{}{}
+	delete(self.snapStorage, stateObject.addrHash)
+}
+
+// updateSnapshotStorage records the change of a storage slot of an account.
+func (self *StateDB) updateSnapshotStorage(stateObject *stateObject, key common.Hash, enc []byte) {
+	slots := self.snapStorage[stateObject.addrHash]
+	if slots == nil {
+		slots = make(map[common.Hash][]byte)
+		self.snapStorage[stateObject.addrHash] = slots
+	}
+	slots[crypto.Keccak256Hash(key[:])] = enc
 }
 
 // Retrieve a state object given by the address. Returns nil if not found.
@@ -368,8 +428,17 @@ func (self *StateDB) getStateObject(addr common.Address) (stateObject *stateObje
 		return obj
 	}
 
-	// Load the object from the database.
-	enc, err := self.trie.TryGet(addr[:])
+	// Load the object from the snapshot or the database.
+	var (
+		enc []byte
+		err error
+	)
+	if self.snap != nil {
+		enc, err = self.snap.Account(crypto.Keccak256Hash(addr[:]))
+	}
+	if self.snap == nil || err != nil {
+		enc, err = self.trie.TryGet(addr[:])
+	}
 	if len(enc) == 0 {
 		self.setError(err)
 		return nil
@@ -408,6 +477,7 @@ func (self *StateDB) createObject(addr common.Address) (newobj, prev *stateObjec
 		self.journal.append(createObjectChange{account: &addr})
 	} else {
 		self.journal.append(resetObjectChange{prev: prev})
+		newobj.reset = true
 	}
 	self.setStateObject(newobj)
 	return newobj, prev
@@ -468,6 +538,25 @@ func (self *StateDB) Copy() *StateDB {
 		logSize:           self.logSize,
 		preimages:         make(map[common.Hash][]byte),
 		journal:           newJournal(),
+		snaps:             self.snaps,
+		snap:              self.snap,
+	}
+	if self.snap != nil {
+		state.snapDestructs = make(map[common.Hash]struct{}, len(self.snapDestructs))
+		for hash := range self.snapDestructs {
+			state.snapDestructs[hash] = struct{}{}
+		}
+		state.snapAccounts = make(map[common.Hash][]byte, len(self.snapAccounts))
+		for hash, data := range self.snapAccounts {
+			state.snapAccounts[hash] = data
+		}
+		state.snapStorage = make(map[common.Hash]map[common.Hash][]byte, len(self.snapStorage))
+		for hash, slots := range self.snapStorage {
+			state.snapStorage[hash] = make(map[common.Hash][]byte, len(slots))
+			for slot, data := range slots {
+				state.snapStorage[hash][slot] = data
+			}
+		}
 	}
 	// Copy the dirty states, logs, and preimages
 	for addr := range self.journal.dirties {
@@ -550,6 +639,7 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 		if stateObject.suicided || (deleteEmptyObjects && stateObject.empty()) {
 			s.deleteStateObject(stateObject)
 		} else {
+			s.resetSnapshot(stateObject)
 			stateObject.updateRoot(s.db)
 			s.updateStateObject(stateObject)
 		}
@@ -583,6 +673,10 @@ func (s *StateDB) clearJournalAndRefund() {
 
 // Commit writes the state to the underlying in-memory trie database.
 func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error) {
+	// Don't commit a state built on top of data that failed to load
+	if s.dbErr != nil {
+		return common.Hash{}, fmt.Errorf("commit aborted due to earlier error: %v", s.dbErr)
+	}
 	defer s.clearJournalAndRefund()
 
 	for addr := range s.journal.dirties {
@@ -603,6 +697,7 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error)
 				stateObject.dirtyCode = false
 			}
 			// Write any storage changes in the state object to its storage trie.
+			s.resetSnapshot(stateObject)
 			if err := stateObject.CommitTrie(s.db); err != nil {
 				return common.Hash{}, err
 			}
@@ -627,5 +722,29 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (root common.Hash, err error)
 		return nil
 	})
 	log.Debug("Trie cache stats after commit", "misses", trie.CacheMisses(), "unloads", trie.CacheUnloads())
-	return root, err
+	if err != nil {
+		return root, err
+	}
+	// Add the changes to the snapshot tree, and continue reading through them
+	if s.snap != nil {
+		if parent := s.snap.Root(); parent != root {
+			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
+				log.Warn("Failed to update snapshot tree", "from", parent, "to", root, "err", err)
+			}
+		}
+		s.openSnapshot(root)
+	}
+	return root, nil
+}
+
+// resetSnapshot records the storage wipe of an object that replaced an existing
+// account, before any of its storage changes are recorded.
+func (s *StateDB) resetSnapshot(stateObject *stateObject) {
+	if !stateObject.reset {
+		return
+	}
+	if s.snap != nil {
+		s.destructSnapshot(stateObject)
+	}
+	stateObject.reset = false
 }
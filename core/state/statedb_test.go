@@ -31,6 +31,7 @@ import (
 	check "gopkg.in/check.v1"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 )
@@ -435,3 +436,56 @@ func TestCopyOfCopy(t *testing.T) {
 		t.Fatalf("2nd copy fail, expected 42, got %v", got)
 	}
 }
+
+// Tests that a state read through a flat snapshot sees the same values as the
+// trie, and that the changes committed to it keep the snapshot consistent, even
+// when accounts are destructed or their storage is reset.
+func TestFlatSnapshotCommit(t *testing.T) {
+	var (
+		db    = ethdb.NewMemDatabase()
+		sdb   = NewDatabase(db)
+		addr1 = common.HexToAddress("aaaa")
+		addr2 = common.HexToAddress("bbbb")
+	)
+	state, _ := New(common.Hash{}, sdb)
+	state.SetState(addr1, common.Hash{0x01}, common.Hash{0x01})
+	state.SetState(addr1, common.Hash{0x02}, common.Hash{0x02})
+	state.SetBalance(addr2, big.NewInt(42))
+	root, _ := state.Commit(false)
+	sdb.TrieDB().Commit(root, false)
+
+	snaps := snapshot.New(db, sdb.TrieDB(), root)
+	snaps.WaitGeneration()
+
+	state, _ = NewWithSnapshot(root, sdb, snaps)
+	if value := state.GetState(addr1, common.Hash{0x02}); value != (common.Hash{0x02}) {
+		t.Fatalf("snapshot storage mismatch: have %x, want %x", value, common.Hash{0x02})
+	}
+	if balance := state.GetBalance(addr2); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Fatalf("snapshot balance mismatch: have %v, want %v", balance, 42)
+	}
+	// Reset the storage of one account in a first transaction and extend it in a
+	// second, while destructing the other account
+	state.CreateAccount(addr1)
+	state.SetState(addr1, common.Hash{0x03}, common.Hash{0x03})
+	state.Suicide(addr2)
+	state.Finalise(true)
+
+	if value := state.GetState(addr1, common.Hash{0x02}); value != (common.Hash{}) {
+		t.Fatalf("reset storage still readable: %x", value)
+	}
+	state.SetState(addr1, common.Hash{0x04}, common.Hash{0x04})
+	root, err := state.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if snaps.Snapshot(root) == nil {
+		t.Fatalf("committed state missing from the snapshot tree")
+	}
+	if err := snaps.Cap(root, 0); err != nil {
+		t.Fatalf("failed to flatten snapshot: %v", err)
+	}
+	if err := snapshot.VerifyState(db, sdb.TrieDB(), root); err != nil {
+		t.Fatalf("flattened snapshot mismatch: %v", err)
+	}
+}
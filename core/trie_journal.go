@@ -0,0 +1,274 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// trieJournalVersion is the version of the trie journal format, journals of other
+// versions are ignored.
+const trieJournalVersion = 1
+
+// trieJournalHeader precedes the trie nodes in the trie journal, describing the
+// chain they belong to.
+type trieJournalHeader struct {
+	Version uint64
+	Genesis common.Hash       // Genesis hash of the chain the tries belong to
+	Head    common.Hash       // Hash of the head block when journaled
+	Roots   []trieJournalRoot // Roots of the recent states kept in memory
+}
+
+// trieJournalRoot is a recent state root awaiting garbage collection.
+type trieJournalRoot struct {
+	Root   common.Hash
+	Number uint64
+}
+
+// trieJournaled reports whether the tries cached in memory are journaled.
+func (bc *BlockChain) trieJournaled() bool {
+	return bc.cacheConfig.TrieJournal != "" && !bc.cacheConfig.Disabled
+}
+
+// journalTries writes the tries cached in memory into the trie journal, along
+// with the roots of the recent states, replacing the previous journal.
+func (bc *BlockChain) journalTries() error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	start := time.Now()
+	header := &trieJournalHeader{
+		Version: trieJournalVersion,
+		Genesis: bc.genesisBlock.Hash(),
+		Head:    bc.CurrentBlock().Hash(),
+	}
+	for !bc.triegc.Empty() {
+		root, number := bc.triegc.Pop()
+		header.Roots = append(header.Roots, trieJournalRoot{Root: root.(common.Hash), Number: uint64(-number)})
+	}
+	for _, root := range header.Roots {
+		bc.triegc.Push(root.Root, -int64(root.Number))
+	}
+	path := bc.cacheConfig.TrieJournal
+	file, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := rlp.Encode(w, header); err != nil {
+		file.Close()
+		return err
+	}
+	nodes, err := bc.stateCache.TrieDB().Journal(w)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	// Make sure the journal hit the disk before replacing the old one, so a crash
+	// leaves either of them intact
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".new", path); err != nil {
+		return err
+	}
+	log.Info("Journaled trie cache", "head", bc.CurrentBlock().Number(), "states", len(header.Roots), "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// rejournalTries regenerates the trie journal periodically, bounding the number
+// of blocks to reprocess after a crash.
+func (bc *BlockChain) rejournalTries() {
+	defer bc.wg.Done()
+
+	ticker := time.NewTicker(bc.cacheConfig.TrieRejournal)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := bc.journalTries(); err != nil {
+				log.Warn("Failed to journal trie cache", "err", err)
+			}
+		case <-bc.quit:
+			return
+		}
+	}
+}
+
+// loadTrieJournal loads the tries of the trie journal into the memory cache,
+// tracking the recent states for garbage collection. It runs before the head
+// state is loaded, the recent states being available to repair the chain with.
+func (bc *BlockChain) loadTrieJournal() error {
+	roots, nodes, err := loadTrieJournal(bc.cacheConfig.TrieJournal, bc.genesisBlock.Hash(), bc.stateCache.TrieDB())
+	if err != nil || roots == nil {
+		return err
+	}
+	for _, root := range roots {
+		bc.triegc.Push(root.Root, -int64(root.Number))
+	}
+	log.Info("Loaded trie cache journal", "states", len(roots), "nodes", nodes)
+	return nil
+}
+
+// loadTrieJournal reads the trie journal at the given path into the trie
+// database, returning the roots of the recent states in it. A missing journal
+// or one of another chain is ignored.
+func loadTrieJournal(path string, genesis common.Hash, triedb *trie.Database) ([]trieJournalRoot, int, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	header := new(trieJournalHeader)
+	if err := rlp.Decode(r, header); err != nil {
+		return nil, 0, err
+	}
+	if header.Version != trieJournalVersion {
+		log.Warn("Ignoring trie cache journal of unknown version", "version", header.Version)
+		return nil, 0, nil
+	}
+	if header.Genesis != genesis {
+		log.Warn("Ignoring trie cache journal of another chain", "genesis", header.Genesis)
+		return nil, 0, nil
+	}
+	nodes, err := triedb.LoadJournal(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if header.Roots == nil {
+		header.Roots = []trieJournalRoot{}
+	}
+	return header.Roots, nodes, nil
+}
+
+// HeadRepair is the outcome of a chain head repair.
+type HeadRepair struct {
+	Head     *types.Header // Head block before the repair
+	Repaired *types.Header // Newest block with complete state, the head after the repair
+	Restored bool          // Whether the state of the new head was restored from the trie journal
+}
+
+// RepairHead rewinds the head block of the chain in the database to the newest
+// block with complete state, on disk or in the trie journal at the given path.
+// The state of the new head is committed to disk if it came from the journal.
+// Headers and blocks above the new head are kept to be reprocessed.
+func RepairHead(db ethdb.Database, journal string) (*HeadRepair, error) {
+	hash := rawdb.ReadHeadBlockHash(db)
+	if hash == (common.Hash{}) {
+		return nil, errors.New("empty database")
+	}
+	number := rawdb.ReadHeaderNumber(db, hash)
+	if number == nil {
+		return nil, fmt.Errorf("head block %x missing", hash)
+	}
+	head := rawdb.ReadHeader(db, hash, *number)
+	if head == nil {
+		return nil, fmt.Errorf("head block %x missing", hash)
+	}
+	// Make the recent states of the trie journal available
+	sdb := state.NewDatabase(db)
+	if journal != "" {
+		roots, nodes, err := loadTrieJournal(journal, rawdb.ReadCanonicalHash(db, 0), sdb.TrieDB())
+		if err != nil {
+			log.Warn("Failed to load trie cache journal", "err", err)
+		} else if roots != nil {
+			log.Info("Loaded trie cache journal", "states", len(roots), "nodes", nodes)
+		}
+	}
+	// Find the newest block with complete state
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	header := head
+	for {
+		if _, err := sdb.OpenTrie(header.Root); err == nil {
+			err := verifyState(sdb, header.Root)
+			if err == nil {
+				break
+			}
+			log.Warn("Incomplete block state", "number", header.Number, "hash", header.Hash(), "err", err)
+		}
+		if header.Number.Sign() == 0 {
+			return nil, errors.New("no block with complete state")
+		}
+		parent := rawdb.ReadHeader(db, header.ParentHash, header.Number.Uint64()-1)
+		if parent == nil {
+			return nil, fmt.Errorf("block #%d [%x] missing", header.Number.Uint64()-1, header.ParentHash)
+		}
+		header = parent
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Searching block with complete state", "number", header.Number, "rewound", head.Number.Uint64()-header.Number.Uint64(), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	repair := &HeadRepair{Head: head, Repaired: header}
+	if ok, _ := db.Has(header.Root[:]); !ok && header.Root != types.EmptyRootHash {
+		if err := sdb.TrieDB().Commit(header.Root, true); err != nil {
+			return nil, err
+		}
+		repair.Restored = true
+	}
+	if header.Hash() != head.Hash() {
+		rawdb.WriteHeadBlockHash(db, header.Hash())
+		if hash := rawdb.ReadHeadFastBlockHash(db); hash != (common.Hash{}) {
+			if number := rawdb.ReadHeaderNumber(db, hash); number != nil && *number > header.Number.Uint64() {
+				rawdb.WriteHeadFastBlockHash(db, header.Hash())
+			}
+		}
+	}
+	return repair, nil
+}
+
+// verifyState checks that all the nodes and codes of a state are available.
+func verifyState(sdb state.Database, root common.Hash) error {
+	statedb, err := state.New(root, sdb)
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	return it.Error
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// crashTrieJournal imports the blocks into a fresh chain, journaling its trie
+// cache before the last few blocks, and abandons the chain without stopping it
+// as if the node crashed.
+func crashTrieJournal(t *testing.T, blocks []*types.Block, journal string) ethdb.Database {
+	db := ethdb.NewMemDatabase()
+	new(Genesis).MustCommit(db)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, TrieJournal: journal}
+	chain, err := NewBlockChain(db, cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks[:len(blocks)-10]); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	if err := chain.journalTries(); err != nil {
+		t.Fatalf("failed to journal trie cache: %v", err)
+	}
+	if n, err := chain.InsertChain(blocks[len(blocks)-10:]); err != nil {
+		t.Fatalf("failed to insert block %d: %v", n, err)
+	}
+	return db
+}
+
+// Tests that the recent states cached in memory survive a crash through the trie
+// journal, both when restarting the chain and when repairing its head offline.
+func TestTrieJournalCrash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trie-journal")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	gendb := ethdb.NewMemDatabase()
+	genesis := new(Genesis).MustCommit(gendb)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), gendb, 2*triesInMemory, func(i int, b *BlockGen) { b.SetCoinbase(common.Address{1}) })
+	journaled := blocks[len(blocks)-11]
+
+	// Restarting the chain must resume from the last journaled head state
+	journal := filepath.Join(dir, "restart.rlp")
+	db := crashTrieJournal(t, blocks, journal)
+
+	cacheConfig := &CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, TrieJournal: journal}
+	chain, err := NewBlockChain(db, cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != journaled.Hash() {
+		t.Fatalf("head block mismatch: have #%d, want #%d", head.NumberU64(), journaled.NumberU64())
+	}
+	if n, err := chain.InsertChain(blocks[len(blocks)-10:]); err != nil {
+		t.Fatalf("failed to reinsert block %d: %v", n, err)
+	}
+	chain.Stop()
+
+	// Without the journal, the offline repair falls back to the genesis state
+	db = crashTrieJournal(t, blocks, filepath.Join(dir, "lost.rlp"))
+
+	repair, err := RepairHead(db, "")
+	if err != nil {
+		t.Fatalf("failed to repair head: %v", err)
+	}
+	if repair.Head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("original head mismatch: have #%d, want #%d", repair.Head.Number, len(blocks))
+	}
+	if repair.Repaired.Hash() != genesis.Hash() || repair.Restored {
+		t.Fatalf("repaired head mismatch: have #%d (restored %v), want genesis", repair.Repaired.Number, repair.Restored)
+	}
+	// With the journal, the offline repair restores the last journaled head state
+	journal = filepath.Join(dir, "repair.rlp")
+	db = crashTrieJournal(t, blocks, journal)
+
+	if repair, err = RepairHead(db, journal); err != nil {
+		t.Fatalf("failed to repair head: %v", err)
+	}
+	if repair.Repaired.Hash() != journaled.Hash() || !repair.Restored {
+		t.Fatalf("repaired head mismatch: have #%d (restored %v), want #%d restored", repair.Repaired.Number, repair.Restored, journaled.NumberU64())
+	}
+	if head := rawdb.ReadHeadBlockHash(db); head != journaled.Hash() {
+		t.Fatalf("head block hash mismatch: have %x, want %x", head, journaled.Hash())
+	}
+	if err := verifyState(state.NewDatabase(db), journaled.Root()); err != nil {
+		t.Fatalf("repaired head state incomplete: %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Reasons of the transaction rejections of the built-in policies.
+const (
+	RejectSenderNotAllowed = "sender-not-allowed"
+	RejectSenderDenied     = "sender-denied"
+	RejectRecipientDenied  = "recipient-denied"
+	RejectMethodDenied     = "method-denied"
+	RejectClassUnderpriced = "class-underpriced"
+)
+
+// maxTxRejections is the number of recent rejections remembered by the pool.
+const maxTxRejections = 256
+
+var rejectedTxCounter = metrics.NewRegisteredCounter("txpool/rejected", nil)
+
+// TxPolicy is an admission rule of the transaction pool, enforced on top of the
+// validity checks every transaction has to pass. Policies apply to local and
+// remote transactions alike.
+type TxPolicy interface {
+	// Admit returns nil if the transaction of the given sender may enter the
+	// pool. Rejections should be reported with a *TxRejectedError so that the
+	// reason is tracked in the metrics.
+	Admit(tx *types.Transaction, from common.Address, local bool) error
+}
+
+// TxPolicyFunc is an adapter to allow the use of ordinary functions as policies.
+type TxPolicyFunc func(tx *types.Transaction, from common.Address, local bool) error
+
+// Admit implements TxPolicy, calling f(tx, from, local).
+func (f TxPolicyFunc) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	return f(tx, from, local)
+}
+
+// TxRejectedError is returned when a transaction is refused by a pool policy.
+type TxRejectedError struct {
+	Reason string // Short identifier of the rule, also used as metric name
+	Detail string // Human readable explanation, may be empty
+}
+
+// Error implements error.
+func (err *TxRejectedError) Error() string {
+	if err.Detail == "" {
+		return "transaction rejected: " + err.Reason
+	}
+	return fmt.Sprintf("transaction rejected: %s (%s)", err.Reason, err.Detail)
+}
+
+// TxRejection is a transaction refused by the pool policies.
+type TxRejection struct {
+	Hash   common.Hash    `json:"hash"`
+	From   common.Address `json:"from"`
+	Reason string         `json:"reason"`
+	Error  string         `json:"error"`
+	Time   time.Time      `json:"time"`
+}
+
+// txRejectionLog is a bounded log of the most recent policy rejections.
+type txRejectionLog struct {
+	entries []TxRejection
+	next    int
+	lock    sync.Mutex
+}
+
+// add records a rejection, overwriting the oldest one if the log is full.
+func (l *txRejectionLog) add(rejection TxRejection) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if len(l.entries) < maxTxRejections {
+		l.entries = append(l.entries, rejection)
+		return
+	}
+	l.entries[l.next] = rejection
+	l.next = (l.next + 1) % maxTxRejections
+}
+
+// list returns the recorded rejections, oldest first.
+func (l *txRejectionLog) list() []TxRejection {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	list := make([]TxRejection, 0, len(l.entries))
+	list = append(list, l.entries[l.next:]...)
+	return append(list, l.entries[:l.next]...)
+}
+
+// rejectionReason returns the reason of a policy error, wrapping errors that do
+// not carry one as custom rejections.
+func rejectionReason(err error) string {
+	if rejected, ok := err.(*TxRejectedError); ok {
+		return rejected.Reason
+	}
+	return "custom"
+}
+
+// markRejected updates the rejection metrics of a policy error.
+func markRejected(reason string) {
+	rejectedTxCounter.Inc(1)
+	metrics.GetOrRegisterCounter("txpool/rejected/"+reason, nil).Inc(1)
+}
+
+// AccountPolicy filters transactions by the accounts they involve.
+type AccountPolicy struct {
+	allow map[common.Address]struct{}
+	deny  map[common.Address]struct{}
+}
+
+// NewAccountPolicy creates a policy admitting only transactions sent by the
+// allowed accounts, or by anyone if there are none, and rejecting transactions
+// sent by or to any of the denied accounts.
+func NewAccountPolicy(allow, deny []common.Address) *AccountPolicy {
+	policy := &AccountPolicy{
+		allow: make(map[common.Address]struct{}),
+		deny:  make(map[common.Address]struct{}),
+	}
+	for _, addr := range allow {
+		policy.allow[addr] = struct{}{}
+	}
+	for _, addr := range deny {
+		policy.deny[addr] = struct{}{}
+	}
+	return policy
+}
+
+// Admit implements TxPolicy.
+func (p *AccountPolicy) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	if _, ok := p.deny[from]; ok {
+		return &TxRejectedError{Reason: RejectSenderDenied, Detail: from.Hex()}
+	}
+	if _, ok := p.allow[from]; len(p.allow) > 0 && !ok {
+		return &TxRejectedError{Reason: RejectSenderNotAllowed, Detail: from.Hex()}
+	}
+	if to := tx.To(); to != nil {
+		if _, ok := p.deny[*to]; ok {
+			return &TxRejectedError{Reason: RejectRecipientDenied, Detail: to.Hex()}
+		}
+	}
+	return nil
+}
+
+// MethodFilter restricts the methods that may be called on a contract, by their
+// four byte selectors. Transactions without a selector are treated as calls of
+// the zero selector.
+type MethodFilter struct {
+	Allow [][4]byte // Only these methods may be called, any if empty
+	Deny  [][4]byte // These methods may not be called
+}
+
+// MethodPolicy filters the calls of contracts by method.
+type MethodPolicy struct {
+	Contracts map[common.Address]MethodFilter
+}
+
+// Admit implements TxPolicy.
+func (p *MethodPolicy) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	if tx.To() == nil {
+		return nil
+	}
+	filter, ok := p.Contracts[*tx.To()]
+	if !ok {
+		return nil
+	}
+	var selector [4]byte
+	copy(selector[:], tx.Data())
+
+	for _, denied := range filter.Deny {
+		if selector == denied {
+			return &TxRejectedError{Reason: RejectMethodDenied, Detail: fmt.Sprintf("%x on %s", selector, tx.To().Hex())}
+		}
+	}
+	if len(filter.Allow) == 0 {
+		return nil
+	}
+	for _, allowed := range filter.Allow {
+		if selector == allowed {
+			return nil
+		}
+	}
+	return &TxRejectedError{Reason: RejectMethodDenied, Detail: fmt.Sprintf("%x on %s", selector, tx.To().Hex())}
+}
+
+// GasPriceClass is a set of senders sharing a minimum gas price.
+type GasPriceClass struct {
+	Name     string
+	Senders  []common.Address
+	MinPrice *big.Int
+}
+
+// GasPricePolicy enforces a minimum gas price depending on the class of the
+// sender. Senders in several classes are held to the first one listed, senders
+// in none only to the pool wide price limit.
+type GasPricePolicy struct {
+	classes []GasPriceClass
+	members map[common.Address]int
+}
+
+// NewGasPricePolicy creates a policy enforcing the minimum prices of the given
+// sender classes.
+func NewGasPricePolicy(classes ...GasPriceClass) *GasPricePolicy {
+	policy := &GasPricePolicy{
+		classes: classes,
+		members: make(map[common.Address]int),
+	}
+	for i := len(classes) - 1; i >= 0; i-- {
+		for _, addr := range classes[i].Senders {
+			policy.members[addr] = i
+		}
+	}
+	return policy
+}
+
+// Admit implements TxPolicy.
+func (p *GasPricePolicy) Admit(tx *types.Transaction, from common.Address, local bool) error {
+	i, ok := p.members[from]
+	if !ok {
+		return nil
+	}
+	class := p.classes[i]
+	if class.MinPrice != nil && tx.GasPrice().Cmp(class.MinPrice) < 0 {
+		return &TxRejectedError{
+			Reason: RejectClassUnderpriced,
+			Detail: fmt.Sprintf("%s requires %v, have %v", class.Name, class.MinPrice, tx.GasPrice()),
+		}
+	}
+	return nil
+}
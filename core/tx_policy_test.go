@@ -0,0 +1,221 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// setupPolicyTxPool creates a pool enforcing the given policies and funds the
+// accounts of the given keys.
+func setupPolicyTxPool(policies []TxPolicy, keys ...*ecdsa.PrivateKey) *TxPool {
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	for _, key := range keys {
+		statedb.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	}
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Policies = policies
+	return NewTxPool(config, params.TestChainConfig, blockchain)
+}
+
+// callTransaction creates a signed call of a contract.
+func callTransaction(nonce uint64, to common.Address, data []byte, gasprice *big.Int, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(0), 100000, gasprice, data), types.HomesteadSigner{}, key)
+	return tx
+}
+
+// checkRejected verifies that err is a policy rejection for the given reason.
+func checkRejected(t *testing.T, err error, reason string) {
+	t.Helper()
+
+	rejected, ok := err.(*TxRejectedError)
+	if !ok {
+		t.Fatalf("error mismatch: have %v, want rejection %q", err, reason)
+	}
+	if rejected.Reason != reason {
+		t.Fatalf("rejection reason mismatch: have %q, want %q", rejected.Reason, reason)
+	}
+}
+
+// Tests that the account policy enforces its allow and deny lists.
+func TestAccountPolicy(t *testing.T) {
+	t.Parallel()
+
+	allowed, _ := crypto.GenerateKey()
+	denied, _ := crypto.GenerateKey()
+	other, _ := crypto.GenerateKey()
+	contract := common.HexToAddress("0xdeadbeef")
+
+	policy := NewAccountPolicy(
+		[]common.Address{crypto.PubkeyToAddress(allowed.PublicKey), crypto.PubkeyToAddress(denied.PublicKey)},
+		[]common.Address{crypto.PubkeyToAddress(denied.PublicKey), contract},
+	)
+	pool := setupPolicyTxPool([]TxPolicy{policy}, allowed, denied, other)
+	defer pool.Stop()
+
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), allowed)); err != nil {
+		t.Fatalf("failed to add allowed transaction: %v", err)
+	}
+	checkRejected(t, pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), denied)), RejectSenderDenied)
+	checkRejected(t, pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), other)), RejectSenderNotAllowed)
+	checkRejected(t, pool.AddRemote(callTransaction(1, contract, nil, big.NewInt(1), allowed)), RejectRecipientDenied)
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 1 pending", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the method policy filters contract calls by selector.
+func TestMethodPolicy(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	var (
+		token    = common.HexToAddress("0x70ce")
+		registry = common.HexToAddress("0x4e9")
+		transfer = [4]byte{0xa9, 0x05, 0x9c, 0xbb}
+		approve  = [4]byte{0x09, 0x5e, 0xa7, 0xb3}
+		register = [4]byte{0xf2, 0xc2, 0x98, 0xbe}
+	)
+	policy := &MethodPolicy{Contracts: map[common.Address]MethodFilter{
+		token:    {Deny: [][4]byte{approve}},
+		registry: {Allow: [][4]byte{register}},
+	}}
+	pool := setupPolicyTxPool([]TxPolicy{policy}, key)
+	defer pool.Stop()
+
+	tests := []struct {
+		to     common.Address
+		data   []byte
+		reason string
+	}{
+		{token, append(transfer[:], make([]byte, 64)...), ""},
+		{token, append(approve[:], make([]byte, 64)...), RejectMethodDenied},
+		{registry, append(register[:], make([]byte, 32)...), ""},
+		{registry, transfer[:], RejectMethodDenied},
+		{registry, nil, RejectMethodDenied},
+		{common.HexToAddress("0x1"), approve[:], ""},
+	}
+	nonce := uint64(0)
+	for i, tt := range tests {
+		err := pool.AddRemote(callTransaction(nonce, tt.to, tt.data, big.NewInt(1), key))
+		if tt.reason == "" {
+			if err != nil {
+				t.Fatalf("test %d: failed to add transaction: %v", i, err)
+			}
+			nonce++
+			continue
+		}
+		checkRejected(t, err, tt.reason)
+	}
+}
+
+// Tests that sender classes are held to their minimum gas prices, and that
+// custom validators are consulted.
+func TestGasPriceAndCustomPolicies(t *testing.T) {
+	t.Parallel()
+
+	premium, _ := crypto.GenerateKey()
+	regular, _ := crypto.GenerateKey()
+
+	errCustom := errors.New("no contract creations")
+	policies := []TxPolicy{
+		NewGasPricePolicy(GasPriceClass{
+			Name:     "premium",
+			Senders:  []common.Address{crypto.PubkeyToAddress(premium.PublicKey)},
+			MinPrice: big.NewInt(10),
+		}),
+		TxPolicyFunc(func(tx *types.Transaction, from common.Address, local bool) error {
+			if tx.To() == nil {
+				return errCustom
+			}
+			return nil
+		}),
+	}
+	pool := setupPolicyTxPool(policies, premium, regular)
+	defer pool.Stop()
+
+	checkRejected(t, pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(9), premium)), RejectClassUnderpriced)
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(10), premium)); err != nil {
+		t.Fatalf("failed to add premium transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(1), regular)); err != nil {
+		t.Fatalf("failed to add regular transaction: %v", err)
+	}
+	creation, _ := types.SignTx(types.NewContractCreation(1, big.NewInt(0), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, regular)
+	if err := pool.AddRemote(creation); err != errCustom {
+		t.Fatalf("custom policy error mismatch: have %v, want %v", err, errCustom)
+	}
+	// Both rejections must be recorded with their reasons
+	rejections := pool.Rejections()
+	if len(rejections) != 2 {
+		t.Fatalf("rejection count mismatch: have %d, want 2", len(rejections))
+	}
+	if rejections[0].Reason != RejectClassUnderpriced || rejections[0].From != crypto.PubkeyToAddress(premium.PublicKey) {
+		t.Errorf("first rejection mismatch: have %+v", rejections[0])
+	}
+	if rejections[1].Reason != "custom" || rejections[1].Hash != creation.Hash() {
+		t.Errorf("second rejection mismatch: have %+v", rejections[1])
+	}
+}
+
+// Tests that the rejection log keeps only the most recent rejections in order.
+func TestTxRejectionLog(t *testing.T) {
+	var log txRejectionLog
+	for i := 0; i < maxTxRejections+10; i++ {
+		log.add(TxRejection{Hash: common.BigToHash(big.NewInt(int64(i)))})
+	}
+	list := log.list()
+	if len(list) != maxTxRejections {
+		t.Fatalf("log length mismatch: have %d, want %d", len(list), maxTxRejections)
+	}
+	for i, rejection := range list {
+		if want := common.BigToHash(big.NewInt(int64(i + 10))); rejection.Hash != want {
+			t.Fatalf("rejection %d mismatch: have %x, want %x", i, rejection.Hash, want)
+		}
+	}
+}
+
+// Tests that rejections are counted per reason.
+func TestRejectionMetrics(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	markRejected("metrics-test")
+	counter := metrics.GetOrRegisterCounter("txpool/rejected/metrics-test", nil)
+	if have := counter.Count(); have != 1 {
+		t.Fatalf("rejection count mismatch: have %d, want 1", have)
+	}
+}
@@ -128,6 +128,8 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	PoolJournal string // Journal of the full pool content, remotes included (empty = disabled)
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
@@ -137,6 +139,8 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	Policies []TxPolicy `toml:"-"` // Admission rules enforced on top of the validity checks
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -188,9 +192,12 @@ type TxPool struct {
 	chain        blockChain
 	gasPrice     *big.Int
 	txFeed       event.Feed
+	evictFeed    event.Feed
+	stageFeed    event.Feed
 	scope        event.SubscriptionScope
 	chainHeadCh  chan ChainHeadEvent
 	chainHeadSub event.Subscription
+	rewindCh     chan *txPoolRewind
 	signer       types.Signer
 	mu           sync.RWMutex
 
@@ -201,12 +208,23 @@ type TxPool struct {
 	locals  *accountSet // Set of local transaction to exempt from eviction rules
 	journal *txJournal  // Journal of local transaction to back up to disk
 
+	impersonated map[common.Address]struct{} // Accounts placeholder signatures are accepted from on dev chains
+
 	pending map[common.Address]*txList   // All currently processable transactions
 	queue   map[common.Address]*txList   // Queued but non-processable transactions
 	beats   map[common.Address]time.Time // Last heartbeat from each known account
 	all     *txLookup                    // All transactions to allow lookups
 	priced  *txPricedList                // All transactions sorted by price
 
+	rejections txRejectionLog // Recent transactions refused by the admission policies
+
+	included map[common.Hash]struct{} // Transactions of the new chain segment during a reset
+
+	stageQueue []interface{} // Lifecycle and eviction events awaiting delivery, in the order they happened
+	stageLock  sync.Mutex    // Lock protecting the lifecycle and eviction event queue
+	stageWake  chan struct{} // Notification channel for queued lifecycle and eviction events
+	stageQuit  chan struct{} // Quit channel of the lifecycle and eviction event dispatcher
+
 	wg sync.WaitGroup // for shutdown sync
 
 	homestead bool
@@ -223,12 +241,15 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		config:      config,
 		chainconfig: chainconfig,
 		chain:       chain,
-		signer:      types.NewEIP155Signer(chainconfig.ChainID),
+		signer:      WithImpersonation(chainconfig, types.NewEIP155Signer(chainconfig.ChainID)),
 		pending:     make(map[common.Address]*txList),
 		queue:       make(map[common.Address]*txList),
 		beats:       make(map[common.Address]time.Time),
 		all:         newTxLookup(),
 		chainHeadCh: make(chan ChainHeadEvent, chainHeadChanSize),
+		rewindCh:    make(chan *txPoolRewind),
+		stageWake:   make(chan struct{}, 1),
+		stageQuit:   make(chan struct{}),
 		gasPrice:    new(big.Int).SetUint64(config.PriceLimit),
 	}
 	pool.locals = newAccountSet(pool.signer)
@@ -250,12 +271,19 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// If full pool journaling is enabled, load the remaining transactions too
+	if config.PoolJournal != "" {
+		if err := pool.loadPoolJournal(); err != nil {
+			log.Warn("Failed to load transaction pool journal", "err", err)
+		}
+	}
 	// Subscribe events from blockchain
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
 
-	// Start the event loop and return
-	pool.wg.Add(1)
+	// Start the event loops and return
+	pool.wg.Add(2)
 	go pool.loop()
+	go pool.dispatchStages()
 
 	return pool
 }
@@ -296,6 +324,16 @@ func (pool *TxPool) loop() {
 
 				pool.mu.Unlock()
 			}
+		// Handle rewinds to an earlier chain head
+		case req := <-pool.rewindCh:
+			pool.mu.Lock()
+			pool.clear()
+			pool.reset(nil, req.head.Header())
+			head = req.head
+			pool.mu.Unlock()
+
+			req.done <- pool.Restore(req.snap)
+
 		// Be unsubscribed due to system stopped
 		case <-pool.chainHeadSub.Err():
 			return
@@ -322,22 +360,37 @@ func (pool *TxPool) loop() {
 				}
 				// Any non-locals old enough should be removed
 				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
-					for _, tx := range pool.queue[addr].Flatten() {
+					txs := pool.queue[addr].Flatten()
+					for _, tx := range txs {
 						pool.removeTx(tx.Hash(), true)
 					}
+					pool.evicted(TxEvictionLifetime, txs...)
 				}
 			}
 			pool.mu.Unlock()
 
 		// Handle local transaction journal rotation
 		case <-journal.C:
-			if pool.journal != nil {
-				pool.mu.Lock()
-				if err := pool.journal.rotate(pool.local()); err != nil {
-					log.Warn("Failed to rotate local tx journal", "err", err)
-				}
-				pool.mu.Unlock()
-			}
+			pool.mu.Lock()
+			pool.rejournal()
+			pool.mu.Unlock()
+		}
+	}
+}
+
+// rejournal regenerates the local transaction journal and the full pool journal
+// from the current content of the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) rejournal() {
+	if pool.journal != nil {
+		if err := pool.journal.rotate(pool.local()); err != nil {
+			log.Warn("Failed to rotate local tx journal", "err", err)
+		}
+	}
+	if pool.config.PoolJournal != "" {
+		if err := pool.savePoolJournal(); err != nil {
+			log.Warn("Failed to save tx pool journal", "err", err)
 		}
 	}
 }
@@ -357,6 +410,18 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// If we're reorging an old state, reinject all dropped transactions
 	var reinject types.Transactions
 
+	// Track the transactions of the new chain segment to tell inclusions apart
+	// from nonce invalidations when dropping stale transactions
+	pool.included = make(map[common.Hash]struct{})
+	defer func() { pool.included = nil }()
+
+	if oldHead != nil && oldHead.Hash() == newHead.ParentHash {
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			for _, tx := range block.Transactions() {
+				pool.included[tx.Hash()] = struct{}{}
+			}
+		}
+	}
 	if oldHead != nil && oldHead.Hash() != newHead.ParentHash {
 		// If the reorg is too deep, avoid doing it (will happen during fast sync)
 		oldNum := oldHead.Number.Uint64()
@@ -399,6 +464,9 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 				}
 			}
 			reinject = types.TxDifference(discarded, included)
+			for _, tx := range included {
+				pool.included[tx.Hash()] = struct{}{}
+			}
 		}
 	}
 	// Initialize the internal state to the current head
@@ -439,6 +507,11 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 func (pool *TxPool) Stop() {
 	// Unsubscribe all subscriptions registered from txpool
 	pool.scope.Close()
+	select {
+	case <-pool.stageQuit:
+	default:
+		close(pool.stageQuit)
+	}
 
 	// Unsubscribe subscriptions registered from blockchain
 	pool.chainHeadSub.Unsubscribe()
@@ -447,6 +520,13 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.config.PoolJournal != "" {
+		pool.mu.Lock()
+		if err := pool.savePoolJournal(); err != nil {
+			log.Warn("Failed to save tx pool journal", "err", err)
+		}
+		pool.mu.Unlock()
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -456,6 +536,112 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeEvictedTxsEvent registers a subscription of EvictedTxsEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeEvictedTxsEvent(ch chan<- EvictedTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.evictFeed.Subscribe(ch))
+}
+
+// SubscribeTxLifecycleEvent registers a subscription of TxLifecycleEvent and
+// starts sending event to the given channel.
+func (pool *TxPool) SubscribeTxLifecycleEvent(ch chan<- TxLifecycleEvent) event.Subscription {
+	return pool.scope.Track(pool.stageFeed.Subscribe(ch))
+}
+
+// evicted notifies the subscribers of transactions leaving the pool without
+// being included in a block.
+func (pool *TxPool) evicted(reason TxEvictionReason, txs ...*types.Transaction) {
+	if len(txs) > 0 {
+		pool.queueEvent(EvictedTxsEvent{Txs: txs, Reason: reason})
+		pool.staged(reason.stage(), txs...)
+	}
+}
+
+// stage returns the lifecycle stage of transactions evicted for the reason.
+func (reason TxEvictionReason) stage() TxLifecycleStage {
+	if reason == TxEvictionReplaced {
+		return TxLifecycleReplaced
+	}
+	return TxLifecycleStage("dropped-" + reason)
+}
+
+// markStage updates the lifecycle metrics of transactions reaching a stage.
+func markStage(stage TxLifecycleStage, count int) {
+	metrics.GetOrRegisterCounter("txpool/lifecycle/"+string(stage), nil).Inc(int64(count))
+}
+
+// staged notifies the subscribers of transactions reaching a new stage of their
+// life in the pool.
+func (pool *TxPool) staged(stage TxLifecycleStage, txs ...*types.Transaction) {
+	if len(txs) > 0 {
+		markStage(stage, len(txs))
+		pool.queueEvent(TxLifecycleEvent{Txs: txs, Stage: stage})
+	}
+}
+
+// queueEvent queues a lifecycle or eviction event for the dispatcher, so that
+// the events reach the subscribers in the order they happened, without blocking
+// the pool.
+func (pool *TxPool) queueEvent(ev interface{}) {
+	pool.stageLock.Lock()
+	pool.stageQueue = append(pool.stageQueue, ev)
+	pool.stageLock.Unlock()
+
+	select {
+	case pool.stageWake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchStages delivers the queued lifecycle and eviction events to the
+// subscribers one by one, in the order they were queued.
+func (pool *TxPool) dispatchStages() {
+	defer pool.wg.Done()
+
+	for {
+		select {
+		case <-pool.stageWake:
+			pool.stageLock.Lock()
+			events := pool.stageQueue
+			pool.stageQueue = nil
+			pool.stageLock.Unlock()
+
+			for _, ev := range events {
+				switch ev := ev.(type) {
+				case TxLifecycleEvent:
+					pool.stageFeed.Send(ev)
+				case EvictedTxsEvent:
+					pool.evictFeed.Send(ev)
+				}
+			}
+		case <-pool.stageQuit:
+			return
+		}
+	}
+}
+
+// dropStale removes transactions whose nonce is below that of their sender's
+// account, reporting them as included if they are part of the new chain head.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) dropStale(txs types.Transactions) {
+	var included, invalidated types.Transactions
+	for _, tx := range txs {
+		hash := tx.Hash()
+		pool.all.Remove(hash)
+		pool.priced.Removed()
+
+		if _, ok := pool.included[hash]; ok {
+			included = append(included, tx)
+		} else {
+			log.Trace("Removed old transaction", "hash", hash)
+			invalidated = append(invalidated, tx)
+		}
+	}
+	pool.staged(TxLifecycleIncluded, included...)
+	pool.evicted(TxEvictionNonceTooLow, invalidated...)
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -471,9 +657,11 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 	defer pool.mu.Unlock()
 
 	pool.gasPrice = price
-	for _, tx := range pool.priced.Cap(price, pool.locals) {
+	drop := pool.priced.Cap(price, pool.locals)
+	for _, tx := range drop {
 		pool.removeTx(tx.Hash(), false)
 	}
+	pool.evicted(TxEvictionUnderpriced, drop...)
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
@@ -547,6 +735,38 @@ func (pool *TxPool) Locals() []common.Address {
 	return pool.locals.flatten()
 }
 
+// Impersonate makes the pool accept transactions of the given account carrying
+// a placeholder signature instead of a real one. Only dev chains resolve the
+// senders of such transactions.
+func (pool *TxPool) Impersonate(addr common.Address) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	if pool.impersonated == nil {
+		pool.impersonated = make(map[common.Address]struct{})
+	}
+	pool.impersonated[addr] = struct{}{}
+}
+
+// StopImpersonating makes the pool reject further transactions of the given
+// account carrying a placeholder signature.
+func (pool *TxPool) StopImpersonating(addr common.Address) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	delete(pool.impersonated, addr)
+}
+
+// Impersonating returns whether the pool accepts transactions of the given
+// account carrying a placeholder signature.
+func (pool *TxPool) Impersonating(addr common.Address) bool {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	_, ok := pool.impersonated[addr]
+	return ok
+}
+
 // local retrieves all currently known local transactions, groupped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -584,6 +804,12 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if err != nil {
 		return ErrInvalidSender
 	}
+	// Placeholder signatures are only accepted from impersonated accounts
+	if impersonated, ok := impersonatedSender(tx); ok && impersonated == from {
+		if _, ok := pool.impersonated[from]; !ok {
+			return ErrInvalidSender
+		}
+	}
 	// Drop non-local transactions under our own minimal accepted gas price
 	local = local || pool.locals.contains(from) // account may be local even if the transaction arrived from the network
 	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
@@ -605,9 +831,24 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
+	// Enforce the admission policies of the local node
+	for _, policy := range pool.config.Policies {
+		if err := policy.Admit(tx, from, local); err != nil {
+			reason := rejectionReason(err)
+			markRejected(reason)
+			pool.rejections.add(TxRejection{Hash: tx.Hash(), From: from, Reason: reason, Error: err.Error(), Time: time.Now()})
+			return err
+		}
+	}
 	return nil
 }
 
+// Rejections returns the most recent transactions refused by the admission
+// policies of the pool, oldest first.
+func (pool *TxPool) Rejections() []TxRejection {
+	return pool.rejections.list()
+}
+
 // add validates a transaction and inserts it into the non-executable queue for
 // later pending promotion and execution. If the transaction is a replacement for
 // an already pending or queued one, it overwrites the previous and returns this
@@ -644,6 +885,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			underpricedTxCounter.Inc(1)
 			pool.removeTx(tx.Hash(), false)
 		}
+		pool.evicted(TxEvictionUnderpriced, drop...)
 	}
 	// If the transaction is replacing an already pending one, do directly
 	from, _ := types.Sender(pool.signer, tx) // already validated
@@ -659,6 +901,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed()
 			pendingReplaceCounter.Inc(1)
+			pool.evicted(TxEvictionReplaced, old)
 		}
 		pool.all.Add(tx)
 		pool.priced.Put(tx)
@@ -668,6 +911,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 
 		// We've directly injected a replacement transaction, notify subsystems
 		go pool.txFeed.Send(NewTxsEvent{types.Transactions{tx}})
+		pool.staged(TxLifecyclePromoted, tx)
 
 		return old != nil, nil
 	}
@@ -676,6 +920,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	pool.staged(TxLifecycleQueued, tx)
 	// Mark local addresses and journal local transactions
 	if local {
 		if !pool.locals.contains(from) {
@@ -709,6 +954,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction) (bool, er
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed()
 		queuedReplaceCounter.Inc(1)
+		pool.evicted(TxEvictionReplaced, old)
 	}
 	if pool.all.Get(hash) == nil {
 		pool.all.Add(tx)
@@ -747,6 +993,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingDiscardCounter.Inc(1)
+		pool.evicted(TxEvictionReplaced, tx)
 		return false
 	}
 	// Otherwise discard any previous transaction and mark this
@@ -755,6 +1002,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 		pool.priced.Removed()
 
 		pendingReplaceCounter.Inc(1)
+		pool.evicted(TxEvictionReplaced, old)
 	}
 	// Failsafe to work around direct pending inserts (tests)
 	if pool.all.Get(hash) == nil {
@@ -873,6 +1121,103 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all.Get(hash)
 }
 
+// ContentFrom retrieves the pending and queued transactions of a single account,
+// sorted by nonce.
+func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var pending, queued types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		pending = list.Flatten()
+	}
+	if list := pool.queue[addr]; list != nil {
+		queued = list.Flatten()
+	}
+	return pending, queued
+}
+
+// Remove evicts a single transaction from the pool, moving the subsequent ones
+// of its sender back to the future queue. It returns whether the transaction
+// was found.
+func (pool *TxPool) Remove(hash common.Hash) bool {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	tx := pool.all.Get(hash)
+	if tx == nil {
+		return false
+	}
+	pool.removeTx(hash, true)
+	pool.evicted(TxEvictionRemoved, tx)
+
+	// Drop the transaction from the journals right away, lest a restart bring it back
+	pool.rejournal()
+	return true
+}
+
+// RemoveSender evicts all the transactions of an account from the pool and
+// returns their number.
+func (pool *TxPool) RemoveSender(addr common.Address) int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	removed := pool.removeSender(addr)
+	if removed > 0 {
+		pool.rejournal()
+	}
+	return removed
+}
+
+// Clear evicts all the transactions from the pool and returns their number.
+func (pool *TxPool) Clear() int {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	removed := pool.clear()
+	if removed > 0 {
+		pool.rejournal()
+	}
+	return removed
+}
+
+// clear evicts all the transactions from the pool and returns their number.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) clear() int {
+	accounts := make(map[common.Address]struct{})
+	for addr := range pool.pending {
+		accounts[addr] = struct{}{}
+	}
+	for addr := range pool.queue {
+		accounts[addr] = struct{}{}
+	}
+	removed := 0
+	for addr := range accounts {
+		removed += pool.removeSender(addr)
+	}
+	return removed
+}
+
+// removeSender evicts all the transactions of an account from the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) removeSender(addr common.Address) int {
+	var txs types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		txs = append(txs, list.Flatten()...)
+	}
+	if list := pool.queue[addr]; list != nil {
+		txs = append(txs, list.Flatten()...)
+	}
+	// Remove the highest nonces first to avoid needlessly requeueing the rest
+	for i := len(txs) - 1; i >= 0; i-- {
+		pool.removeTx(txs[i].Hash(), true)
+	}
+	pool.evicted(TxEvictionRemoved, txs...)
+	return len(txs)
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
@@ -900,6 +1245,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 			for _, tx := range invalids {
 				pool.enqueueTx(tx.Hash(), tx)
 			}
+			pool.staged(TxLifecycleQueued, invalids...)
 			// Update the account nonce if needed
 			if nonce := tx.Nonce(); pool.pendingState.GetNonce(addr) > nonce {
 				pool.pendingState.SetNonce(addr, nonce)
@@ -937,12 +1283,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			continue // Just in case someone calls with a non existing account
 		}
 		// Drop all transactions that are deemed too old (low nonce)
-		for _, tx := range list.Forward(pool.currentState.GetNonce(addr)) {
-			hash := tx.Hash()
-			log.Trace("Removed old queued transaction", "hash", hash)
-			pool.all.Remove(hash)
-			pool.priced.Removed()
-		}
+		pool.dropStale(list.Forward(pool.currentState.GetNonce(addr)))
+
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
@@ -952,6 +1294,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			pool.priced.Removed()
 			queuedNofundsCounter.Inc(1)
 		}
+		pool.evicted(TxEvictionNoFunds, drops...)
+
 		// Gather all executable transactions and promote them
 		for _, tx := range list.Ready(pool.pendingState.GetNonce(addr)) {
 			hash := tx.Hash()
@@ -962,13 +1306,15 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 		}
 		// Drop all transactions over the allowed limit
 		if !pool.locals.contains(addr) {
-			for _, tx := range list.Cap(int(pool.config.AccountQueue)) {
+			caps := list.Cap(int(pool.config.AccountQueue))
+			for _, tx := range caps {
 				hash := tx.Hash()
 				pool.all.Remove(hash)
 				pool.priced.Removed()
 				queuedRateLimitCounter.Inc(1)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
+			pool.evicted(TxEvictionRateLimit, caps...)
 		}
 		// Delete the entire queue entry if it became empty.
 		if list.Empty() {
@@ -978,6 +1324,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 	// Notify subsystem for new promoted transactions.
 	if len(promoted) > 0 {
 		go pool.txFeed.Send(NewTxsEvent{promoted})
+		pool.staged(TxLifecyclePromoted, promoted...)
 	}
 	// If the pending limit is overflown, start equalizing allowances
 	pending := uint64(0)
@@ -1010,7 +1357,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 				for pending > pool.config.GlobalSlots && pool.pending[offenders[len(offenders)-2]].Len() > threshold {
 					for i := 0; i < len(offenders)-1; i++ {
 						list := pool.pending[offenders[i]]
-						for _, tx := range list.Cap(list.Len() - 1) {
+						caps := list.Cap(list.Len() - 1)
+						for _, tx := range caps {
 							// Drop the transaction from the global pools too
 							hash := tx.Hash()
 							pool.all.Remove(hash)
@@ -1022,6 +1370,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 							}
 							log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 						}
+						pool.evicted(TxEvictionRateLimit, caps...)
 						pending--
 					}
 				}
@@ -1032,7 +1381,8 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			for pending > pool.config.GlobalSlots && uint64(pool.pending[offenders[len(offenders)-1]].Len()) > pool.config.AccountSlots {
 				for _, addr := range offenders {
 					list := pool.pending[addr]
-					for _, tx := range list.Cap(list.Len() - 1) {
+					caps := list.Cap(list.Len() - 1)
+					for _, tx := range caps {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
 						pool.all.Remove(hash)
@@ -1044,6 +1394,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 						}
 						log.Trace("Removed fairness-exceeding pending transaction", "hash", hash)
 					}
+					pool.evicted(TxEvictionRateLimit, caps...)
 					pending--
 				}
 			}
@@ -1074,9 +1425,11 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 
 			// Drop all transactions if they are less than the overflow
 			if size := uint64(list.Len()); size <= drop {
-				for _, tx := range list.Flatten() {
+				txs := list.Flatten()
+				for _, tx := range txs {
 					pool.removeTx(tx.Hash(), true)
 				}
+				pool.evicted(TxEvictionRateLimit, txs...)
 				drop -= size
 				queuedRateLimitCounter.Inc(int64(size))
 				continue
@@ -1085,6 +1438,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) {
 			txs := list.Flatten()
 			for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
 				pool.removeTx(txs[i].Hash(), true)
+				pool.evicted(TxEvictionRateLimit, txs[i])
 				drop--
 				queuedRateLimitCounter.Inc(1)
 			}
@@ -1101,12 +1455,8 @@ func (pool *TxPool) demoteUnexecutables() {
 		nonce := pool.currentState.GetNonce(addr)
 
 		// Drop all transactions that are deemed too old (low nonce)
-		for _, tx := range list.Forward(nonce) {
-			hash := tx.Hash()
-			log.Trace("Removed old pending transaction", "hash", hash)
-			pool.all.Remove(hash)
-			pool.priced.Removed()
-		}
+		pool.dropStale(list.Forward(nonce))
+
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
 		drops, invalids := list.Filter(pool.currentState.GetBalance(addr), pool.currentMaxGas)
 		for _, tx := range drops {
@@ -1116,18 +1466,24 @@ func (pool *TxPool) demoteUnexecutables() {
 			pool.priced.Removed()
 			pendingNofundsCounter.Inc(1)
 		}
+		pool.evicted(TxEvictionNoFunds, drops...)
+
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
 			pool.enqueueTx(hash, tx)
 		}
+		pool.staged(TxLifecycleQueued, invalids...)
+
 		// If there's a gap in front, alert (should never happen) and postpone all transactions
 		if list.Len() > 0 && list.txs.Get(nonce) == nil {
-			for _, tx := range list.Cap(0) {
+			gapped := list.Cap(0)
+			for _, tx := range gapped {
 				hash := tx.Hash()
 				log.Error("Demoting invalidated transaction", "hash", hash)
 				pool.enqueueTx(hash, tx)
 			}
+			pool.staged(TxLifecycleQueued, gapped...)
 		}
 		// Delete the entire queue entry if it became empty.
 		if list.Empty() {
@@ -23,6 +23,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -1832,3 +1833,418 @@ func benchmarkPoolBatchInsert(b *testing.B, size int) {
 		pool.AddRemotes(batch)
 	}
 }
+
+// expectEviction waits for an eviction event and checks its reason and contents.
+func expectEviction(t *testing.T, events chan EvictedTxsEvent, reason TxEvictionReason, txs ...*types.Transaction) {
+	t.Helper()
+
+	select {
+	case ev := <-events:
+		if ev.Reason != reason {
+			t.Fatalf("eviction reason mismatch: have %s, want %s", ev.Reason, reason)
+		}
+		have := make(map[common.Hash]bool)
+		for _, tx := range ev.Txs {
+			have[tx.Hash()] = true
+		}
+		if len(have) != len(txs) {
+			t.Fatalf("evicted transaction count mismatch: have %d, want %d", len(have), len(txs))
+		}
+		for _, tx := range txs {
+			if !have[tx.Hash()] {
+				t.Fatalf("transaction %x not evicted", tx.Hash())
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no %s eviction event fired", reason)
+	}
+}
+
+// Tests that transactions can be inspected and evicted per account and in bulk,
+// and that the evictions are announced.
+func TestTransactionRemoval(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	other, _ := crypto.GenerateKey()
+	account, _ := deriveSender(transaction(0, 0, key))
+	pool.currentState.AddBalance(account, big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+
+	events := make(chan EvictedTxsEvent, 16)
+	sub := pool.SubscribeEvictedTxsEvent(events)
+	defer sub.Unsubscribe()
+
+	txs := []*types.Transaction{
+		transaction(0, 100000, key),
+		transaction(1, 100000, key),
+		transaction(2, 100000, key),
+		transaction(4, 100000, key),
+	}
+	for _, tx := range txs {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	if err := pool.AddRemote(transaction(0, 100000, other)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pending, queued := pool.ContentFrom(account)
+	if len(pending) != 3 || len(queued) != 1 {
+		t.Fatalf("account content mismatch: have %d pending, %d queued, want 3 and 1", len(pending), len(queued))
+	}
+	// Removing a pending transaction postpones the subsequent ones
+	if !pool.Remove(txs[1].Hash()) {
+		t.Fatalf("failed to remove pending transaction")
+	}
+	if pool.Remove(txs[1].Hash()) {
+		t.Fatalf("removed transaction twice")
+	}
+	expectEviction(t, events, TxEvictionRemoved, txs[1])
+
+	pending, queued = pool.ContentFrom(account)
+	if len(pending) != 1 || len(queued) != 2 {
+		t.Fatalf("account content mismatch: have %d pending, %d queued, want 1 and 2", len(pending), len(queued))
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Removing the sender drops all its remaining transactions
+	if removed := pool.RemoveSender(account); removed != 3 {
+		t.Fatalf("removed transaction count mismatch: have %d, want 3", removed)
+	}
+	expectEviction(t, events, TxEvictionRemoved, txs[0], txs[2], txs[3])
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 1 and 0", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// The sender can resubmit from its current nonce
+	if err := pool.AddRemote(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to resubmit transaction: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatched: have %d, want 2", pending)
+	}
+	// Clearing empties the pool
+	if removed := pool.Clear(); removed != 2 {
+		t.Fatalf("cleared transaction count mismatch: have %d, want 2", removed)
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Fatalf("pool not empty: have %d pending, %d queued", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that transactions removed by the operator are dropped from the journal
+// right away, not coming back on a restart.
+func TestTransactionRemovalJournaling(t *testing.T) {
+	t.Parallel()
+
+	// Create a temporary file for the journal
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temporary journal: %v", err)
+	}
+	journal := file.Name()
+	defer os.Remove(journal)
+
+	file.Close()
+	os.Remove(journal)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = journal
+	config.Rejournal = time.Hour
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	kept, _ := crypto.GenerateKey()
+	stuck, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(kept.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(stuck.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.AddLocal(pricedTransaction(0, 100000, big.NewInt(1), kept)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	tx := pricedTransaction(0, 100000, big.NewInt(1), stuck)
+	if err := pool.AddLocal(tx); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if !pool.Remove(tx.Hash()) {
+		t.Fatalf("failed to remove local transaction")
+	}
+	pool.Stop()
+
+	// Restart the pool long before the next rejournal and check the removal stuck
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 1 and 0", pending, queued)
+	}
+	if pool.Get(tx.Hash()) != nil {
+		t.Fatalf("removed transaction restored from journal")
+	}
+}
+
+// Tests that replacements, underpriced drops and expirations are announced.
+func TestTransactionEvictionEvents(t *testing.T) {
+	// Reduce the eviction interval to a testable amount
+	defer func(old time.Duration) { evictionInterval = old }(evictionInterval)
+	evictionInterval = 100 * time.Millisecond
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+	config.Lifetime = 200 * time.Millisecond
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	events := make(chan EvictedTxsEvent, 16)
+	sub := pool.SubscribeEvictedTxsEvent(events)
+	defer sub.Unsubscribe()
+
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Replace a pending transaction
+	cheap := pricedTransaction(0, 100000, big.NewInt(1), keys[0])
+	if err := pool.AddRemote(cheap); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(2), keys[0])); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	expectEviction(t, events, TxEvictionReplaced, cheap)
+
+	// Let a queued transaction expire
+	gapped := pricedTransaction(5, 100000, big.NewInt(5), keys[1])
+	if err := pool.AddRemote(gapped); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectEviction(t, events, TxEvictionLifetime, gapped)
+
+	// Fill the pool and push out its cheapest transaction
+	cheapest := pricedTransaction(0, 100000, big.NewInt(1), keys[1])
+	if err := pool.AddRemote(cheapest); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	for i := uint64(0); i < 2; i++ {
+		if err := pool.AddRemote(pricedTransaction(i, 100000, big.NewInt(3), keys[2])); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	if err := pool.AddRemote(pricedTransaction(2, 100000, big.NewInt(4), keys[2])); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectEviction(t, events, TxEvictionUnderpriced, cheapest)
+}
+
+// expectStages waits until each of the given transactions reached the wanted
+// lifecycle stage, or fails after a timeout.
+func expectStages(t *testing.T, events chan TxLifecycleEvent, want map[common.Hash]TxLifecycleStage) {
+	t.Helper()
+
+	timeout := time.After(time.Second)
+	for len(want) > 0 {
+		select {
+		case ev := <-events:
+			for _, tx := range ev.Txs {
+				if stage, ok := want[tx.Hash()]; ok && stage == ev.Stage {
+					delete(want, tx.Hash())
+				}
+			}
+		case <-timeout:
+			for hash, stage := range want {
+				t.Errorf("transaction %x did not reach stage %s", hash, stage)
+			}
+			t.FailNow()
+		}
+	}
+}
+
+// Tests that the lifecycle events of a transaction are delivered in the order
+// its stages were reached.
+func TestTransactionLifecycleOrdering(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxLifecycleEvent, 256)
+	sub := pool.SubscribeTxLifecycleEvent(events)
+	defer sub.Unsubscribe()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Queue a batch of gapped transactions, fill the gap and include them all
+	txs := make([]*types.Transaction, 32)
+	for i := range txs {
+		txs[i] = transaction(uint64(i), 100000, key)
+	}
+	for _, tx := range txs[1:] {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	if err := pool.AddRemote(txs[0]); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pool.mu.Lock()
+	pool.currentState.SetNonce(crypto.PubkeyToAddress(key.PublicKey), uint64(len(txs)))
+	pool.included = make(map[common.Hash]struct{})
+	for _, tx := range txs {
+		pool.included[tx.Hash()] = struct{}{}
+	}
+	pool.demoteUnexecutables()
+	pool.included = nil
+	pool.mu.Unlock()
+
+	// Collect the stages of every transaction until all are included
+	stages := make(map[common.Hash][]TxLifecycleStage)
+	timeout := time.After(time.Second)
+	for included := 0; included < len(txs); {
+		select {
+		case ev := <-events:
+			for _, tx := range ev.Txs {
+				stages[tx.Hash()] = append(stages[tx.Hash()], ev.Stage)
+				if ev.Stage == TxLifecycleIncluded {
+					included++
+				}
+			}
+		case <-timeout:
+			t.Fatalf("only %d of %d transactions included", included, len(txs))
+		}
+	}
+	want := []TxLifecycleStage{TxLifecycleQueued, TxLifecyclePromoted, TxLifecycleIncluded}
+	for i, tx := range txs {
+		if have := stages[tx.Hash()]; !reflect.DeepEqual(have, want) {
+			t.Errorf("tx %d: stage order mismatch: have %v, want %v", i, have, want)
+		}
+	}
+}
+
+// Tests that eviction events are delivered in order with the lifecycle events of
+// the same transactions.
+func TestTransactionEvictionOrdering(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	// Unbuffered channels, so the events are received in the order they're sent
+	stages := make(chan TxLifecycleEvent)
+	stageSub := pool.SubscribeTxLifecycleEvent(stages)
+	defer stageSub.Unsubscribe()
+
+	evictions := make(chan EvictedTxsEvent)
+	evictSub := pool.SubscribeEvictedTxsEvent(evictions)
+	defer evictSub.Unsubscribe()
+
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// Add a transaction and replace it right away
+	tx := pricedTransaction(0, 100000, big.NewInt(1), key)
+	if err := pool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(2), key)); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	var have []string
+	for timeout := time.After(time.Second); len(have) < 4; {
+		select {
+		case ev := <-stages:
+			if ev.Txs[0].Hash() == tx.Hash() {
+				have = append(have, string(ev.Stage))
+			}
+		case ev := <-evictions:
+			if ev.Txs[0].Hash() == tx.Hash() {
+				have = append(have, "evicted-"+string(ev.Reason))
+			}
+		case <-timeout:
+			t.Fatalf("events missing, have %v", have)
+		}
+	}
+	want := []string{string(TxLifecycleQueued), string(TxLifecyclePromoted), "evicted-" + string(TxEvictionReplaced), string(TxLifecycleReplaced)}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("event order mismatch: have %v, want %v", have, want)
+	}
+}
+
+// Tests that transactions announce the stages of their life in the pool, and
+// that stale transactions are told apart by whether they were included.
+func TestTransactionLifecycleEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	events := make(chan TxLifecycleEvent, 64)
+	sub := pool.SubscribeTxLifecycleEvent(events)
+	defer sub.Unsubscribe()
+
+	other, _ := crypto.GenerateKey()
+	poor, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(poor.PublicKey), big.NewInt(1000000))
+
+	// Queue a gapped transaction and promote it by filling the gap
+	gapped := transaction(1, 100000, key)
+	if err := pool.AddRemote(gapped); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectStages(t, events, map[common.Hash]TxLifecycleStage{gapped.Hash(): TxLifecycleQueued})
+
+	filler := transaction(0, 100000, key)
+	if err := pool.AddRemote(filler); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	expectStages(t, events, map[common.Hash]TxLifecycleStage{
+		filler.Hash(): TxLifecyclePromoted,
+		gapped.Hash(): TxLifecyclePromoted,
+	})
+	// Include the first transaction and invalidate another by nonce, while the
+	// sender with too little balance can no longer pay
+	stale := transaction(0, 100000, other)
+	unpayable := transaction(0, 100000, poor)
+	if err := pool.AddRemotes([]*types.Transaction{stale, unpayable}); err[0] != nil || err[1] != nil {
+		t.Fatalf("failed to add transactions: %v", err)
+	}
+	pool.mu.Lock()
+	pool.currentState.SetNonce(crypto.PubkeyToAddress(key.PublicKey), 1)
+	pool.currentState.SetNonce(crypto.PubkeyToAddress(other.PublicKey), 1)
+	pool.currentState.SubBalance(crypto.PubkeyToAddress(poor.PublicKey), big.NewInt(1000000))
+	pool.included = map[common.Hash]struct{}{filler.Hash(): {}}
+	pool.demoteUnexecutables()
+	pool.included = nil
+	pool.mu.Unlock()
+
+	expectStages(t, events, map[common.Hash]TxLifecycleStage{
+		filler.Hash():    TxLifecycleIncluded,
+		stale.Hash():     TxLifecycleDroppedNonceTooLow,
+		unpayable.Hash(): TxLifecycleDroppedNoFunds,
+	})
+	if pending, queued := pool.Stats(); pending != 1 || queued != 0 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 1 pending", pending, queued)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// errTxPoolStopped is returned when rewinding a pool that is already stopped.
+var errTxPoolStopped = errors.New("transaction pool stopped")
+
+// Statuses of the transactions of a pool snapshot.
+const (
+	TxPoolEntryPending = "pending"
+	TxPoolEntryQueued  = "queued"
+)
+
+// TxPoolEntry is a transaction of a pool snapshot along with its status in the
+// pool it was taken from.
+type TxPoolEntry struct {
+	Tx     *types.Transaction `json:"tx"`
+	Local  bool               `json:"local"`
+	Status string             `json:"status"`
+}
+
+// TxPoolSnapshot is a dump of the full content of a transaction pool, remote
+// transactions included. The transactions of every account are kept in nonce
+// order so that restoring them reproduces the statuses of the original pool,
+// as long as the chain state matches.
+type TxPoolSnapshot struct {
+	Transactions []*TxPoolEntry `json:"transactions"`
+}
+
+// Snapshot returns a dump of the current content of the pool.
+func (pool *TxPool) Snapshot() *TxPoolSnapshot {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	return pool.snapshot()
+}
+
+// snapshot returns a dump of the current content of the pool.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) snapshot() *TxPoolSnapshot {
+	snap := new(TxPoolSnapshot)
+	add := func(addr common.Address, list *txList, status string) {
+		if list == nil {
+			return
+		}
+		local := pool.locals.contains(addr)
+		for _, tx := range list.Flatten() {
+			snap.Transactions = append(snap.Transactions, &TxPoolEntry{Tx: tx, Local: local, Status: status})
+		}
+	}
+	for addr, list := range pool.pending {
+		add(addr, list, TxPoolEntryPending)
+		add(addr, pool.queue[addr], TxPoolEntryQueued)
+	}
+	for addr, list := range pool.queue {
+		if _, ok := pool.pending[addr]; !ok {
+			add(addr, list, TxPoolEntryQueued)
+		}
+	}
+	return snap
+}
+
+// Restore injects the transactions of a snapshot into the pool, returning the
+// errors of the individual transactions in snapshot order. Local transactions
+// are only treated as such if the pool tracks locals.
+func (pool *TxPool) Restore(snap *TxPoolSnapshot) []error {
+	var (
+		locals, remotes     []*types.Transaction
+		localIdx, remoteIdx []int
+	)
+	for i, entry := range snap.Transactions {
+		if entry.Local {
+			locals, localIdx = append(locals, entry.Tx), append(localIdx, i)
+		} else {
+			remotes, remoteIdx = append(remotes, entry.Tx), append(remoteIdx, i)
+		}
+	}
+	errs := make([]error, len(snap.Transactions))
+	for i, err := range pool.AddLocals(locals) {
+		errs[localIdx[i]] = err
+	}
+	for i, err := range pool.AddRemotes(remotes) {
+		errs[remoteIdx[i]] = err
+	}
+	return errs
+}
+
+// txPoolRewind is a request to rewind the pool to an earlier chain head.
+type txPoolRewind struct {
+	head *types.Block
+	snap *TxPoolSnapshot
+	done chan []error
+}
+
+// Rewind replaces the content of the pool with a snapshot taken at an earlier
+// chain head, which must have been made the current head of the chain already.
+// Unlike a reorg, the transactions of the rewound blocks are not reinjected.
+// The errors of the individual transactions are returned in snapshot order.
+func (pool *TxPool) Rewind(head *types.Block, snap *TxPoolSnapshot) ([]error, error) {
+	req := &txPoolRewind{head: head, snap: snap, done: make(chan []error, 1)}
+	select {
+	case pool.rewindCh <- req:
+		return <-req.done, nil
+	case <-pool.chainHeadSub.Err():
+		return nil, errTxPoolStopped
+	}
+}
+
+// saveTxPoolSnapshot writes a pool snapshot to disk, replacing any previous one
+// only once the new one is complete.
+func saveTxPoolSnapshot(path string, snap *TxPoolSnapshot) error {
+	output, err := os.OpenFile(path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := rlp.Encode(output, snap); err != nil {
+		output.Close()
+		return err
+	}
+	if err := output.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".new", path)
+}
+
+// loadTxPoolSnapshot reads a pool snapshot from disk. A missing snapshot is
+// reported as an empty one.
+func loadTxPoolSnapshot(path string) (*TxPoolSnapshot, error) {
+	input, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return new(TxPoolSnapshot), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	snap := new(TxPoolSnapshot)
+	if err := rlp.Decode(input, snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// loadPoolJournal restores the full pool journal from disk.
+func (pool *TxPool) loadPoolJournal() error {
+	snap, err := loadTxPoolSnapshot(pool.config.PoolJournal)
+	if err != nil {
+		return err
+	}
+	// Skip the local transactions already restored from the local journal
+	fresh := new(TxPoolSnapshot)
+	for _, entry := range snap.Transactions {
+		if pool.Get(entry.Tx.Hash()) == nil {
+			fresh.Transactions = append(fresh.Transactions, entry)
+		}
+	}
+	dropped := 0
+	for _, err := range pool.Restore(fresh) {
+		if err != nil {
+			log.Debug("Failed to add journaled pool transaction", "err", err)
+			dropped++
+		}
+	}
+	log.Info("Loaded transaction pool journal", "transactions", len(snap.Transactions), "dropped", dropped)
+	return nil
+}
+
+// savePoolJournal writes the full pool journal to disk.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) savePoolJournal() error {
+	snap := pool.snapshot()
+	if err := saveTxPoolSnapshot(pool.config.PoolJournal, snap); err != nil {
+		return err
+	}
+	log.Info("Regenerated transaction pool journal", "transactions", len(snap.Transactions))
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// snapshotStatuses maps the transactions of a snapshot to their statuses.
+func snapshotStatuses(snap *TxPoolSnapshot) map[common.Hash]string {
+	statuses := make(map[common.Hash]string)
+	for _, entry := range snap.Transactions {
+		statuses[entry.Tx.Hash()] = entry.Status
+	}
+	return statuses
+}
+
+// Tests that the full pool journal restores remote transactions along with the
+// local ones, keeping their statuses across restarts.
+func TestTransactionPoolJournaling(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "txpool-journal")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.Journal = filepath.Join(dir, "transactions.rlp")
+	config.PoolJournal = filepath.Join(dir, "txpool.rlp")
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	local, _ := crypto.GenerateKey()
+	remote, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(local.PublicKey), big.NewInt(1000000000))
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(remote.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.AddLocal(transaction(0, 100000, local)); err != nil {
+		t.Fatalf("failed to add local transaction: %v", err)
+	}
+	if err := pool.AddRemote(transaction(0, 100000, remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	if err := pool.AddRemote(transaction(2, 100000, remote)); err != nil {
+		t.Fatalf("failed to add remote transaction: %v", err)
+	}
+	want := snapshotStatuses(pool.Snapshot())
+	if len(want) != 3 {
+		t.Fatalf("snapshot size mismatch: have %d, want 3", len(want))
+	}
+	pool.Stop()
+
+	// Restart the pool and ensure all transactions survived with their statuses
+	pool = NewTxPool(config, params.TestChainConfig, &testBlockChain{statedb, 1000000, new(event.Feed)})
+	defer pool.Stop()
+
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 2 pending, 1 queued", pending, queued)
+	}
+	have := snapshotStatuses(pool.Snapshot())
+	for hash, status := range want {
+		if have[hash] != status {
+			t.Errorf("transaction %x status mismatch: have %q, want %q", hash, have[hash], status)
+		}
+	}
+	if !pool.locals.contains(crypto.PubkeyToAddress(local.PublicKey)) {
+		t.Errorf("local account not restored")
+	}
+	if pool.locals.contains(crypto.PubkeyToAddress(remote.PublicKey)) {
+		t.Errorf("remote account restored as local")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that pool snapshots survive a JSON round trip and can be imported into
+// another pool.
+func TestTransactionPoolSnapshotImport(t *testing.T) {
+	t.Parallel()
+
+	source, key := setupTxPool()
+	defer source.Stop()
+	source.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	for _, nonce := range []uint64{0, 1, 3} {
+		if err := source.AddRemote(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	blob, err := json.Marshal(source.Snapshot())
+	if err != nil {
+		t.Fatalf("failed to encode snapshot: %v", err)
+	}
+	snap := new(TxPoolSnapshot)
+	if err := json.Unmarshal(blob, snap); err != nil {
+		t.Fatalf("failed to decode snapshot: %v", err)
+	}
+	target, _ := setupTxPool()
+	defer target.Stop()
+	target.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	for i, err := range target.Restore(snap) {
+		if err != nil {
+			t.Fatalf("failed to import transaction %d: %v", i, err)
+		}
+	}
+	if pending, queued := target.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 2 pending, 1 queued", pending, queued)
+	}
+	if err := validateTxPoolInternals(target); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that rewinding the pool replaces its content with the snapshot, without
+// keeping any transaction added since.
+func TestTransactionPoolRewind(t *testing.T) {
+	t.Parallel()
+
+	key, _ := crypto.GenerateKey()
+	pool := setupPolicyTxPool(nil, key)
+	defer pool.Stop()
+
+	for _, nonce := range []uint64{0, 2} {
+		if err := pool.AddLocal(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	snap := pool.Snapshot()
+	want := snapshotStatuses(snap)
+
+	for _, nonce := range []uint64{1, 3} {
+		if err := pool.AddLocal(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	errs, err := pool.Rewind(pool.chain.CurrentBlock(), snap)
+	if err != nil {
+		t.Fatalf("failed to rewind pool: %v", err)
+	}
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("failed to restore transaction %d: %v", i, err)
+		}
+	}
+	have := snapshotStatuses(pool.Snapshot())
+	if len(have) != len(want) {
+		t.Fatalf("pool size mismatch: have %d, want %d", len(have), len(want))
+	}
+	for hash, status := range want {
+		if have[hash] != status {
+			t.Errorf("transaction %x status mismatch: have %q, want %q", hash, have[hash], status)
+		}
+	}
+	if !pool.locals.contains(crypto.PubkeyToAddress(key.PublicKey)) {
+		t.Errorf("local account not restored")
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// Rewinding a stopped pool must fail instead of blocking
+	pool.Stop()
+	if _, err := pool.Rewind(pool.chain.CurrentBlock(), snap); err != errTxPoolStopped {
+		t.Fatalf("stopped pool rewind error mismatch: have %v, want %v", err, errTxPoolStopped)
+	}
+}
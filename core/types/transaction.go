@@ -17,11 +17,13 @@
 package types
 
 import (
+	"bytes"
 	"container/heap"
 	"errors"
 	"io"
 	"math/big"
 	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -37,6 +39,7 @@ var (
 
 type Transaction struct {
 	data txdata
+	time time.Time // Time first seen locally, used to order by arrival
 	// caches
 	hash atomic.Value
 	size atomic.Value
@@ -101,7 +104,7 @@ func newTransaction(nonce uint64, to *common.Address, amount *big.Int, gasLimit
 		d.Price.Set(gasPrice)
 	}
 
-	return &Transaction{data: d}
+	return &Transaction{data: d, time: time.Now()}
 }
 
 // ChainId returns which chain id this transaction was signed for (if at all)
@@ -134,6 +137,7 @@ func (tx *Transaction) DecodeRLP(s *rlp.Stream) error {
 	err := s.Decode(&tx.data)
 	if err == nil {
 		tx.size.Store(common.StorageSize(rlp.ListSize(size)))
+		tx.time = time.Now()
 	}
 
 	return err
@@ -163,7 +167,7 @@ func (tx *Transaction) UnmarshalJSON(input []byte) error {
 	if !crypto.ValidateSignatureValues(V, dec.R, dec.S, false) {
 		return ErrInvalidSig
 	}
-	*tx = Transaction{data: dec}
+	*tx = Transaction{data: dec, time: time.Now()}
 	return nil
 }
 
@@ -235,7 +239,7 @@ func (tx *Transaction) WithSignature(signer Signer, sig []byte) (*Transaction, e
 	if err != nil {
 		return nil, err
 	}
-	cpy := &Transaction{data: tx.data}
+	cpy := &Transaction{data: tx.data, time: tx.time}
 	cpy.data.R, cpy.data.S, cpy.data.V = r, s, v
 	return cpy, nil
 }
@@ -313,12 +317,63 @@ func (s *TxByPrice) Pop() interface{} {
 	return x
 }
 
+// TxOrder reports whether transaction a should be picked before transaction b,
+// both being the next executable transactions of their senders.
+type TxOrder func(a, b *Transaction) bool
+
+// TxPriceOrder picks the transactions paying the higher gas price first.
+func TxPriceOrder(a, b *Transaction) bool {
+	return a.data.Price.Cmp(b.data.Price) > 0
+}
+
+// TxArrivalOrder picks the transactions seen first locally first. Transactions
+// seen at the same time are ordered by hash to stay deterministic.
+func TxArrivalOrder(a, b *Transaction) bool {
+	if !a.time.Equal(b.time) {
+		return a.time.Before(b.time)
+	}
+	ha, hb := a.Hash(), b.Hash()
+	return bytes.Compare(ha[:], hb[:]) < 0
+}
+
+// TxPriceArrivalOrder picks the transactions paying the higher gas price first,
+// and those seen first locally among equally priced ones.
+func TxPriceArrivalOrder(a, b *Transaction) bool {
+	if cmp := a.data.Price.Cmp(b.data.Price); cmp != 0 {
+		return cmp > 0
+	}
+	return TxArrivalOrder(a, b)
+}
+
+// txHeads is a heap of the next transactions of the accounts, kept in the order
+// of a TxOrder.
+type txHeads struct {
+	txs   Transactions
+	order TxOrder
+}
+
+func (h *txHeads) Len() int           { return len(h.txs) }
+func (h *txHeads) Less(i, j int) bool { return h.order(h.txs[i], h.txs[j]) }
+func (h *txHeads) Swap(i, j int)      { h.txs[i], h.txs[j] = h.txs[j], h.txs[i] }
+
+func (h *txHeads) Push(x interface{}) {
+	h.txs = append(h.txs, x.(*Transaction))
+}
+
+func (h *txHeads) Pop() interface{} {
+	old := h.txs
+	n := len(old)
+	x := old[n-1]
+	h.txs = old[0 : n-1]
+	return x
+}
+
 // TransactionsByPriceAndNonce represents a set of transactions that can return
 // transactions in a profit-maximizing sorted order, while supporting removing
 // entire batches of transactions for non-executable accounts.
 type TransactionsByPriceAndNonce struct {
 	txs    map[common.Address]Transactions // Per account nonce-sorted list of transactions
-	heads  TxByPrice                       // Next transaction for each unique account (price heap)
+	heads  *txHeads                        // Next transaction for each unique account (ordered heap)
 	signer Signer                          // Signer for the set of transactions
 }
 
@@ -328,10 +383,20 @@ type TransactionsByPriceAndNonce struct {
 // Note, the input map is reowned so the caller should not interact any more with
 // if after providing it to the constructor.
 func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transactions) *TransactionsByPriceAndNonce {
-	// Initialize a price based heap with the head transactions
-	heads := make(TxByPrice, 0, len(txs))
+	return NewTransactionsByOrderAndNonce(signer, txs, TxPriceOrder)
+}
+
+// NewTransactionsByOrderAndNonce creates a transaction set that retrieves the
+// transactions of different accounts in the given order, in a nonce-honouring
+// way.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// if after providing it to the constructor.
+func NewTransactionsByOrderAndNonce(signer Signer, txs map[common.Address]Transactions, order TxOrder) *TransactionsByPriceAndNonce {
+	// Initialize an ordered heap with the head transactions
+	heads := &txHeads{txs: make(Transactions, 0, len(txs)), order: order}
 	for from, accTxs := range txs {
-		heads = append(heads, accTxs[0])
+		heads.txs = append(heads.txs, accTxs[0])
 		// Ensure the sender address is from the signer
 		acc, _ := Sender(signer, accTxs[0])
 		txs[acc] = accTxs[1:]
@@ -339,7 +404,7 @@ func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transa
 			delete(txs, from)
 		}
 	}
-	heap.Init(&heads)
+	heap.Init(heads)
 
 	// Assemble and return the transaction set
 	return &TransactionsByPriceAndNonce{
@@ -351,20 +416,20 @@ func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transa
 
 // Peek returns the next transaction by price.
 func (t *TransactionsByPriceAndNonce) Peek() *Transaction {
-	if len(t.heads) == 0 {
+	if len(t.heads.txs) == 0 {
 		return nil
 	}
-	return t.heads[0]
+	return t.heads.txs[0]
 }
 
 // Shift replaces the current best head with the next one from the same account.
 func (t *TransactionsByPriceAndNonce) Shift() {
-	acc, _ := Sender(t.signer, t.heads[0])
+	acc, _ := Sender(t.signer, t.heads.txs[0])
 	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
-		t.heads[0], t.txs[acc] = txs[0], txs[1:]
-		heap.Fix(&t.heads, 0)
+		t.heads.txs[0], t.txs[acc] = txs[0], txs[1:]
+		heap.Fix(t.heads, 0)
 	} else {
-		heap.Pop(&t.heads)
+		heap.Pop(t.heads)
 	}
 }
 
@@ -372,7 +437,7 @@ func (t *TransactionsByPriceAndNonce) Shift() {
 // the same account. This should be used when a transaction cannot be executed
 // and hence all subsequent ones should be discarded from the same account.
 func (t *TransactionsByPriceAndNonce) Pop() {
-	heap.Pop(&t.heads)
+	heap.Pop(t.heads)
 }
 
 // Message is a fully derived transaction and implements core.Message
@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -177,6 +178,67 @@ func TestTransactionPriceNonceSort(t *testing.T) {
 	}
 }
 
+// Tests that transactions of different accounts can be ordered by arrival, and
+// by price with arrival breaking the ties, while keeping the nonce ordering.
+func TestTransactionArrivalSort(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := HomesteadSigner{}
+
+	// Account i sends its transactions at times i, i+5, ..., with two prices
+	start := time.Now()
+	all := Transactions{}
+	for i, key := range keys {
+		for nonce := 0; nonce < 3; nonce++ {
+			price := big.NewInt(1)
+			if i%2 == 1 {
+				price = big.NewInt(2)
+			}
+			tx, _ := SignTx(NewTransaction(uint64(nonce), common.Address{}, big.NewInt(100), 100, price, nil), signer, key)
+			tx.time = start.Add(time.Duration(i+nonce*len(keys)) * time.Second)
+			all = append(all, tx)
+		}
+	}
+	groups := func() map[common.Address]Transactions {
+		groups := make(map[common.Address]Transactions)
+		for _, tx := range all {
+			from, _ := Sender(signer, tx)
+			groups[from] = append(groups[from], tx)
+		}
+		return groups
+	}
+	collect := func(order TxOrder) Transactions {
+		txset := NewTransactionsByOrderAndNonce(signer, groups(), order)
+
+		txs := Transactions{}
+		for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+			txs = append(txs, tx)
+			txset.Shift()
+		}
+		return txs
+	}
+	// Arrival ordering must reproduce the sending schedule
+	for i, tx := range collect(TxArrivalOrder) {
+		if want := start.Add(time.Duration(i) * time.Second); !tx.time.Equal(want) {
+			t.Errorf("tx #%d: arrival mismatch: have %v, want %v", i, tx.time.Sub(start), want.Sub(start))
+		}
+	}
+	// Price and arrival ordering must pick the expensive heads first, breaking
+	// ties by arrival
+	txs := collect(TxPriceArrivalOrder)
+	for i := 1; i < len(txs); i++ {
+		prev, next := txs[i-1], txs[i]
+		if prev.GasPrice().Cmp(next.GasPrice()) < 0 {
+			t.Errorf("tx #%d: price ordering violated: %v before %v", i, prev.GasPrice(), next.GasPrice())
+		}
+		if prev.GasPrice().Cmp(next.GasPrice()) == 0 && prev.time.After(next.time) {
+			t.Errorf("tx #%d: arrival ordering violated among equal prices", i)
+		}
+	}
+}
+
 // TestTransactionJSON tests serializing/de-serializing to/from JSON.
 func TestTransactionJSON(t *testing.T) {
 	key, err := crypto.GenerateKey()
@@ -17,9 +17,8 @@
 package vm
 
 import (
-	"math/big"
-
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/holiman/uint256"
 )
 
 // destinations stores one map per contract (keyed by hash of code).
@@ -28,7 +27,7 @@ import (
 type destinations map[common.Hash]bitvec
 
 // has checks whether code has a JUMPDEST at dest.
-func (d destinations) has(codehash common.Hash, code []byte, dest *big.Int) bool {
+func (d destinations) has(codehash common.Hash, code []byte, dest *uint256.Int) bool {
 	// PC cannot go beyond len(code) and certainly can't be bigger than 63bits.
 	// Don't bother checking for JUMPDEST in that case.
 	udest := dest.Uint64()
@@ -0,0 +1,141 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CodeCoverage is the execution coverage of a single bytecode.
+type CodeCoverage struct {
+	Code     []byte               `json:"-"`
+	Hits     map[uint64]uint64    `json:"hits"`               // Execution count by program counter
+	Branches map[uint64][2]uint64 `json:"branches,omitempty"` // JUMPI outcomes by program counter: fallthrough, jump
+}
+
+// CoverageTracer is an EVM tracer recording the program counters executed for
+// every code hash. Unlike other tracers it is meant to be shared by many
+// executions, possibly running concurrently, and accumulates their coverage.
+type CoverageTracer struct {
+	coverage map[common.Hash]*CodeCoverage
+	lock     sync.Mutex
+}
+
+// NewCoverageTracer creates a new coverage tracer.
+func NewCoverageTracer() *CoverageTracer {
+	return &CoverageTracer{coverage: make(map[common.Hash]*CodeCoverage)}
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *CoverageTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+// CaptureState implements the Tracer interface, recording the executed instruction.
+func (t *CoverageTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	cov, ok := t.coverage[contract.CodeHash]
+	if !ok {
+		cov = &CodeCoverage{
+			Code:     contract.Code,
+			Hits:     make(map[uint64]uint64),
+			Branches: make(map[uint64][2]uint64),
+		}
+		t.coverage[contract.CodeHash] = cov
+	}
+	cov.Hits[pc]++
+
+	// Record the outcome of conditional jumps that are about to execute
+	if op == JUMPI && err == nil && stack.len() >= 2 {
+		outcomes := cov.Branches[pc]
+		if stack.Back(1).Sign() != 0 {
+			outcomes[1]++
+		} else {
+			outcomes[0]++
+		}
+		cov.Branches[pc] = outcomes
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *CoverageTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *CoverageTracer) CaptureEnd(output []byte, gasUsed uint64, tm time.Duration, err error) error {
+	return nil
+}
+
+// Merge adds coverage collected elsewhere, as returned by Coverage, to the one
+// collected so far.
+func (t *CoverageTracer) Merge(coverage map[common.Hash]*CodeCoverage) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for hash, cov := range coverage {
+		dst, ok := t.coverage[hash]
+		if !ok {
+			dst = &CodeCoverage{
+				Code:     cov.Code,
+				Hits:     make(map[uint64]uint64, len(cov.Hits)),
+				Branches: make(map[uint64][2]uint64, len(cov.Branches)),
+			}
+			t.coverage[hash] = dst
+		}
+		for pc, hits := range cov.Hits {
+			dst.Hits[pc] += hits
+		}
+		for pc, outcomes := range cov.Branches {
+			merged := dst.Branches[pc]
+			merged[0] += outcomes[0]
+			merged[1] += outcomes[1]
+			dst.Branches[pc] = merged
+		}
+	}
+}
+
+// Coverage returns a copy of the coverage collected so far, keyed by code hash.
+func (t *CoverageTracer) Coverage() map[common.Hash]*CodeCoverage {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	coverage := make(map[common.Hash]*CodeCoverage, len(t.coverage))
+	for hash, cov := range t.coverage {
+		cpy := &CodeCoverage{
+			Code:     cov.Code,
+			Hits:     make(map[uint64]uint64, len(cov.Hits)),
+			Branches: make(map[uint64][2]uint64, len(cov.Branches)),
+		}
+		for pc, hits := range cov.Hits {
+			cpy.Hits[pc] = hits
+		}
+		for pc, outcomes := range cov.Branches {
+			cpy.Branches[pc] = outcomes
+		}
+		coverage[hash] = cpy
+	}
+	return coverage
+}
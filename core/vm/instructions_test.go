@@ -18,10 +18,13 @@ package vm
 
 import (
 	"math/big"
+	"math/rand"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/holiman/uint256"
 )
 
 type twoOperandTest struct {
@@ -39,36 +42,18 @@ func testTwoOperandOp(t *testing.T, tests []twoOperandTest, opFn func(pc *uint64
 	)
 
 	env.interpreter = evmInterpreter
-	evmInterpreter.intPool = poolOfIntPools.get()
 	for i, test := range tests {
-		x := new(big.Int).SetBytes(common.Hex2Bytes(test.x))
-		shift := new(big.Int).SetBytes(common.Hex2Bytes(test.y))
-		expected := new(big.Int).SetBytes(common.Hex2Bytes(test.expected))
+		x := new(uint256.Int).SetBytes(common.Hex2Bytes(test.x))
+		y := new(uint256.Int).SetBytes(common.Hex2Bytes(test.y))
+		expected := new(uint256.Int).SetBytes(common.Hex2Bytes(test.expected))
 		stack.push(x)
-		stack.push(shift)
+		stack.push(y)
 		opFn(&pc, evmInterpreter, nil, nil, stack)
 		actual := stack.pop()
-		if actual.Cmp(expected) != 0 {
-			t.Errorf("Testcase %d, expected  %v, got %v", i, expected, actual)
-		}
-		// Check pool usage
-		// 1.pool is not allowed to contain anything on the stack
-		// 2.pool is not allowed to contain the same pointers twice
-		if evmInterpreter.intPool.pool.len() > 0 {
-
-			poolvals := make(map[*big.Int]struct{})
-			poolvals[actual] = struct{}{}
-
-			for evmInterpreter.intPool.pool.len() > 0 {
-				key := evmInterpreter.intPool.get()
-				if _, exist := poolvals[key]; exist {
-					t.Errorf("Testcase %d, pool contains double-entry", i)
-				}
-				poolvals[key] = struct{}{}
-			}
+		if !actual.Eq(expected) {
+			t.Errorf("Testcase %d, expected  %v, got %v", i, expected, &actual)
 		}
 	}
-	poolOfIntPools.put(evmInterpreter.intPool)
 }
 
 func TestByteOp(t *testing.T) {
@@ -79,34 +64,32 @@ func TestByteOp(t *testing.T) {
 	)
 
 	env.interpreter = evmInterpreter
-	evmInterpreter.intPool = poolOfIntPools.get()
 	tests := []struct {
 		v        string
 		th       uint64
-		expected *big.Int
+		expected uint64
 	}{
-		{"ABCDEF0908070605040302010000000000000000000000000000000000000000", 0, big.NewInt(0xAB)},
-		{"ABCDEF0908070605040302010000000000000000000000000000000000000000", 1, big.NewInt(0xCD)},
-		{"00CDEF090807060504030201ffffffffffffffffffffffffffffffffffffffff", 0, big.NewInt(0x00)},
-		{"00CDEF090807060504030201ffffffffffffffffffffffffffffffffffffffff", 1, big.NewInt(0xCD)},
-		{"0000000000000000000000000000000000000000000000000000000000102030", 31, big.NewInt(0x30)},
-		{"0000000000000000000000000000000000000000000000000000000000102030", 30, big.NewInt(0x20)},
-		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 32, big.NewInt(0x0)},
-		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 0xFFFFFFFFFFFFFFFF, big.NewInt(0x0)},
+		{"ABCDEF0908070605040302010000000000000000000000000000000000000000", 0, 0xAB},
+		{"ABCDEF0908070605040302010000000000000000000000000000000000000000", 1, 0xCD},
+		{"00CDEF090807060504030201ffffffffffffffffffffffffffffffffffffffff", 0, 0x00},
+		{"00CDEF090807060504030201ffffffffffffffffffffffffffffffffffffffff", 1, 0xCD},
+		{"0000000000000000000000000000000000000000000000000000000000102030", 31, 0x30},
+		{"0000000000000000000000000000000000000000000000000000000000102030", 30, 0x20},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 32, 0x0},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", 0xFFFFFFFFFFFFFFFF, 0x0},
 	}
 	pc := uint64(0)
 	for _, test := range tests {
-		val := new(big.Int).SetBytes(common.Hex2Bytes(test.v))
-		th := new(big.Int).SetUint64(test.th)
+		val := new(uint256.Int).SetBytes(common.Hex2Bytes(test.v))
+		th := new(uint256.Int).SetUint64(test.th)
 		stack.push(val)
 		stack.push(th)
 		opByte(&pc, evmInterpreter, nil, nil, stack)
 		actual := stack.pop()
-		if actual.Cmp(test.expected) != 0 {
-			t.Fatalf("Expected  [%v] %v:th byte to be %v, was %v.", test.v, test.th, test.expected, actual)
+		if !actual.IsUint64() || actual.Uint64() != test.expected {
+			t.Fatalf("Expected  [%v] %v:th byte to be %v, was %v.", test.v, test.th, test.expected, &actual)
 		}
 	}
-	poolOfIntPools.put(evmInterpreter.intPool)
 }
 
 func TestSHL(t *testing.T) {
@@ -214,7 +197,6 @@ func opBenchmark(bench *testing.B, op func(pc *uint64, interpreter *EVMInterpret
 	)
 
 	env.interpreter = evmInterpreter
-	evmInterpreter.intPool = poolOfIntPools.get()
 	// convert args
 	byteArgs := make([][]byte, len(args))
 	for i, arg := range args {
@@ -224,13 +206,12 @@ func opBenchmark(bench *testing.B, op func(pc *uint64, interpreter *EVMInterpret
 	bench.ResetTimer()
 	for i := 0; i < bench.N; i++ {
 		for _, arg := range byteArgs {
-			a := new(big.Int).SetBytes(arg)
+			a := new(uint256.Int).SetBytes(arg)
 			stack.push(a)
 		}
 		op(&pc, evmInterpreter, nil, nil, stack)
 		stack.pop()
 	}
-	poolOfIntPools.put(evmInterpreter.intPool)
 }
 
 func BenchmarkOpAdd64(b *testing.B) {
@@ -450,21 +431,19 @@ func TestOpMstore(t *testing.T) {
 	)
 
 	env.interpreter = evmInterpreter
-	evmInterpreter.intPool = poolOfIntPools.get()
 	mem.Resize(64)
 	pc := uint64(0)
 	v := "abcdef00000000000000abba000000000deaf000000c0de00100000000133700"
-	stack.pushN(new(big.Int).SetBytes(common.Hex2Bytes(v)), big.NewInt(0))
+	stack.pushN(*new(uint256.Int).SetBytes(common.Hex2Bytes(v)), *new(uint256.Int))
 	opMstore(&pc, evmInterpreter, nil, mem, stack)
 	if got := common.Bytes2Hex(mem.Get(0, 32)); got != v {
 		t.Fatalf("Mstore fail, got %v, expected %v", got, v)
 	}
-	stack.pushN(big.NewInt(0x1), big.NewInt(0))
+	stack.pushN(*new(uint256.Int).SetUint64(0x1), *new(uint256.Int))
 	opMstore(&pc, evmInterpreter, nil, mem, stack)
 	if common.Bytes2Hex(mem.Get(0, 32)) != "0000000000000000000000000000000000000000000000000000000000000001" {
 		t.Fatalf("Mstore failed to overwrite previous value")
 	}
-	poolOfIntPools.put(evmInterpreter.intPool)
 }
 
 func BenchmarkOpMstore(bench *testing.B) {
@@ -476,16 +455,193 @@ func BenchmarkOpMstore(bench *testing.B) {
 	)
 
 	env.interpreter = evmInterpreter
-	evmInterpreter.intPool = poolOfIntPools.get()
 	mem.Resize(64)
 	pc := uint64(0)
-	memStart := big.NewInt(0)
-	value := big.NewInt(0x1337)
+	memStart := new(uint256.Int)
+	value := new(uint256.Int).SetUint64(0x1337)
 
 	bench.ResetTimer()
 	for i := 0; i < bench.N; i++ {
-		stack.pushN(value, memStart)
+		stack.pushN(*value, *memStart)
 		opMstore(&pc, evmInterpreter, nil, mem, stack)
 	}
-	poolOfIntPools.put(evmInterpreter.intPool)
+}
+
+// bigOps are reference implementations of the arithmetic instructions on top
+// of big.Int, matching the semantics of the interpreter before the stack moved
+// to fixed-width integers. The first argument is the top of the stack.
+var bigOps = map[string]struct {
+	op  executionFunc
+	ref func(args ...*big.Int) *big.Int
+}{
+	"ADD": {opAdd, func(a ...*big.Int) *big.Int { return math.U256(new(big.Int).Add(a[0], a[1])) }},
+	"SUB": {opSub, func(a ...*big.Int) *big.Int { return math.U256(new(big.Int).Sub(a[0], a[1])) }},
+	"MUL": {opMul, func(a ...*big.Int) *big.Int { return math.U256(new(big.Int).Mul(a[0], a[1])) }},
+	"DIV": {opDiv, func(a ...*big.Int) *big.Int {
+		if a[1].Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Div(a[0], a[1])
+	}},
+	"SDIV": {opSdiv, func(a ...*big.Int) *big.Int {
+		x, y := math.S256(new(big.Int).Set(a[0])), math.S256(new(big.Int).Set(a[1]))
+		if x.Sign() == 0 || y.Sign() == 0 {
+			return new(big.Int)
+		}
+		res := new(big.Int).Div(new(big.Int).Abs(x), new(big.Int).Abs(y))
+		if x.Sign() != y.Sign() {
+			res.Neg(res)
+		}
+		return math.U256(res)
+	}},
+	"MOD": {opMod, func(a ...*big.Int) *big.Int {
+		if a[1].Sign() == 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Mod(a[0], a[1])
+	}},
+	"SMOD": {opSmod, func(a ...*big.Int) *big.Int {
+		x, y := math.S256(new(big.Int).Set(a[0])), math.S256(new(big.Int).Set(a[1]))
+		if y.Sign() == 0 {
+			return new(big.Int)
+		}
+		res := new(big.Int).Mod(new(big.Int).Abs(x), new(big.Int).Abs(y))
+		if x.Sign() < 0 {
+			res.Neg(res)
+		}
+		return math.U256(res)
+	}},
+	"EXP": {opExp, func(a ...*big.Int) *big.Int { return math.Exp(new(big.Int).Set(a[0]), new(big.Int).Set(a[1])) }},
+	"SIGNEXTEND": {opSignExtend, func(a ...*big.Int) *big.Int {
+		num := new(big.Int).Set(a[1])
+		if a[0].Cmp(big.NewInt(31)) >= 0 {
+			return num
+		}
+		bit := uint(a[0].Uint64()*8 + 7)
+		mask := new(big.Int).Lsh(common.Big1, bit)
+		mask.Sub(mask, common.Big1)
+		if num.Bit(int(bit)) > 0 {
+			num.Or(num, mask.Not(mask))
+		} else {
+			num.And(num, mask)
+		}
+		return math.U256(num)
+	}},
+	"LT":  {opLt, func(a ...*big.Int) *big.Int { return boolToBig(a[0].Cmp(a[1]) < 0) }},
+	"GT":  {opGt, func(a ...*big.Int) *big.Int { return boolToBig(a[0].Cmp(a[1]) > 0) }},
+	"EQ":  {opEq, func(a ...*big.Int) *big.Int { return boolToBig(a[0].Cmp(a[1]) == 0) }},
+	"AND": {opAnd, func(a ...*big.Int) *big.Int { return new(big.Int).And(a[0], a[1]) }},
+	"OR":  {opOr, func(a ...*big.Int) *big.Int { return new(big.Int).Or(a[0], a[1]) }},
+	"XOR": {opXor, func(a ...*big.Int) *big.Int { return new(big.Int).Xor(a[0], a[1]) }},
+	"SLT": {opSlt, func(a ...*big.Int) *big.Int {
+		return boolToBig(math.S256(new(big.Int).Set(a[0])).Cmp(math.S256(new(big.Int).Set(a[1]))) < 0)
+	}},
+	"SGT": {opSgt, func(a ...*big.Int) *big.Int {
+		return boolToBig(math.S256(new(big.Int).Set(a[0])).Cmp(math.S256(new(big.Int).Set(a[1]))) > 0)
+	}},
+	"BYTE": {opByte, func(a ...*big.Int) *big.Int {
+		if a[0].Cmp(common.Big32) >= 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).SetUint64(uint64(math.Byte(a[1], 32, int(a[0].Int64()))))
+	}},
+	"SHL": {opSHL, func(a ...*big.Int) *big.Int {
+		if a[0].Cmp(common.Big256) >= 0 {
+			return new(big.Int)
+		}
+		return math.U256(new(big.Int).Lsh(a[1], uint(a[0].Uint64())))
+	}},
+	"SHR": {opSHR, func(a ...*big.Int) *big.Int {
+		if a[0].Cmp(common.Big256) >= 0 {
+			return new(big.Int)
+		}
+		return new(big.Int).Rsh(a[1], uint(a[0].Uint64()))
+	}},
+	"SAR": {opSAR, func(a ...*big.Int) *big.Int {
+		value := math.S256(new(big.Int).Set(a[1]))
+		if a[0].Cmp(common.Big256) >= 0 {
+			if value.Sign() >= 0 {
+				return new(big.Int)
+			}
+			return math.U256(big.NewInt(-1))
+		}
+		return math.U256(value.Rsh(value, uint(a[0].Uint64())))
+	}},
+	"ADDMOD": {opAddmod, func(a ...*big.Int) *big.Int {
+		if a[2].Sign() == 0 {
+			return new(big.Int)
+		}
+		res := new(big.Int).Add(a[0], a[1])
+		return res.Mod(res, a[2])
+	}},
+	"MULMOD": {opMulmod, func(a ...*big.Int) *big.Int {
+		if a[2].Sign() == 0 {
+			return new(big.Int)
+		}
+		res := new(big.Int).Mul(a[0], a[1])
+		return res.Mod(res, a[2])
+	}},
+}
+
+func boolToBig(b bool) *big.Int {
+	if b {
+		return big.NewInt(1)
+	}
+	return new(big.Int)
+}
+
+// TestOpsAgainstBigInt cross checks the fixed-width instruction implementations
+// against their big.Int reference on edge cases and random operands.
+func TestOpsAgainstBigInt(t *testing.T) {
+	var (
+		env            = NewEVM(Context{}, nil, params.TestChainConfig, Config{})
+		stack          = newstack()
+		pc             = uint64(0)
+		evmInterpreter = NewEVMInterpreter(env, env.vmConfig)
+	)
+	env.interpreter = evmInterpreter
+
+	values := []*big.Int{
+		big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(7), big.NewInt(30), big.NewInt(31),
+		big.NewInt(32), big.NewInt(255), big.NewInt(256), big.NewInt(257),
+		math.BigPow(2, 63), math.BigPow(2, 64), new(big.Int).Sub(math.BigPow(2, 64), common.Big1),
+		math.BigPow(2, 128), math.BigPow(2, 192), math.BigPow(2, 255),
+		new(big.Int).Sub(math.BigPow(2, 255), common.Big1),
+		new(big.Int).Sub(math.BigPow(2, 256), common.Big1),
+		new(big.Int).Sub(math.BigPow(2, 256), common.Big2),
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 24; i++ {
+		buf := make([]byte, 1+rnd.Intn(32))
+		rnd.Read(buf)
+		values = append(values, new(big.Int).SetBytes(buf))
+	}
+	for name, op := range bigOps {
+		arity := 2
+		if name == "ADDMOD" || name == "MULMOD" {
+			arity = 3
+		}
+		check := func(args ...*big.Int) {
+			for i := len(args) - 1; i >= 0; i-- {
+				v, _ := uint256.FromBig(args[i])
+				stack.push(v)
+			}
+			op.op(&pc, evmInterpreter, nil, nil, stack)
+			have := stack.pop()
+			if want := op.ref(args...); have.ToBig().Cmp(want) != 0 {
+				t.Errorf("%s%x: have %x, want %x", name, args, have.ToBig(), want)
+			}
+		}
+		for _, a := range values {
+			for _, b := range values {
+				if arity == 2 {
+					check(a, b)
+					continue
+				}
+				for _, c := range values[:16] {
+					check(a, b, c)
+				}
+			}
+		}
+	}
 }
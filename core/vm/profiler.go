@@ -0,0 +1,268 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GasStat is an aggregated gas figure of a profiled entity.
+type GasStat struct {
+	Gas    uint64 `json:"gas"`              // Gas consumed, excluding nested calls
+	Refund uint64 `json:"refund,omitempty"` // Gas refunds accrued (before the refund cap)
+	Count  uint64 `json:"count"`            // Number of calls or executions
+}
+
+// ContractGasProfile is the gas breakdown of a single contract.
+type ContractGasProfile struct {
+	GasStat
+	Functions map[string]*GasStat `json:"functions"` // Breakdown by 4-byte selector
+}
+
+// GasProfile is the result of a gas profiling run. Gas is always attributed
+// exclusively: a CALL is charged only for its own overhead, the gas spent by
+// the callee is accounted to the callee.
+type GasProfile struct {
+	GasUsed   uint64                                 `json:"gasUsed"` // Execution gas used, excluding intrinsic gas and refunds
+	Refund    uint64                                 `json:"refund"`  // Total refund counter at the end of execution
+	Contracts map[common.Address]*ContractGasProfile `json:"contracts"`
+	Opcodes   map[string]*GasStat                    `json:"opcodes"`
+	Stacks    map[string]uint64                      `json:"stacks"` // Gas by folded call stack, flamegraph compatible
+}
+
+// WriteFolded writes the profile's call stacks in the folded format consumed
+// by flamegraph.pl and compatible tools, one "frame;frame;OPCODE gas" per line.
+func (p *GasProfile) WriteFolded(w io.Writer) error {
+	stacks := make([]string, 0, len(p.Stacks))
+	for stack := range p.Stacks {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, p.Stacks[stack]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// profileFrame is a call frame tracked by the gas profiler.
+type profileFrame struct {
+	contract *ContractGasProfile
+	function *GasStat
+	path     string // Folded call stack leading to this frame
+
+	op       OpCode // Last executed, not yet accounted operation
+	opGas    uint64 // Gas available before executing op
+	opCost   uint64 // Gas cost of op as reported by the interpreter
+	pending  bool   // Whether op is awaiting accounting
+	children uint64 // Gas used by calls made by op
+	used     uint64 // Total gas used by the frame so far, including children
+}
+
+// GasProfiler is an EVM tracer aggregating the gas consumption of an execution
+// by contract, by function selector and by opcode, including nested calls.
+type GasProfiler struct {
+	profile GasProfile
+	frames  []*profileFrame
+	create  bool     // Whether the outermost frame runs init code
+	env     *EVM     // Environment used to read the refund counter
+	refund  uint64   // Last seen value of the refund counter
+	owner   *GasStat // Contract whose last operation may have accrued refunds
+	ownerFn *GasStat // Function whose last operation may have accrued refunds
+	ownerOp *GasStat // Opcode whose last execution may have accrued refunds
+}
+
+// NewGasProfiler creates a new gas profiling tracer.
+func NewGasProfiler() *GasProfiler {
+	return &GasProfiler{
+		profile: GasProfile{
+			Contracts: make(map[common.Address]*ContractGasProfile),
+			Opcodes:   make(map[string]*GasStat),
+			Stacks:    make(map[string]uint64),
+		},
+	}
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (p *GasProfiler) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	p.create = create
+	return nil
+}
+
+// CaptureState implements the Tracer interface, accounting the gas consumed by
+// the previous operation of the executing frame.
+func (p *GasProfiler) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	p.env = env
+
+	// Leave any frames that returned and enter the new one if a call was made
+	for len(p.frames) > depth {
+		p.leave()
+	}
+	if depth > len(p.frames) {
+		p.enter(contract)
+	}
+	frame := p.frames[depth-1]
+	if frame.pending {
+		p.account(frame, frame.opGas-gas)
+	}
+	frame.op, frame.opGas, frame.opCost, frame.pending = op, gas, cost, true
+
+	// Refunds are accrued while calculating the gas cost of the operation
+	p.owner, p.ownerFn, p.ownerOp = &frame.contract.GasStat, frame.function, p.opcode(op)
+	p.trackRefund()
+
+	// An error here means the operation failed before executing, consuming all gas
+	if err != nil && err != errExecutionReverted {
+		frame.opCost = gas
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface. Failing operations other than
+// REVERT consume all the gas of their frame.
+func (p *GasProfiler) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if depth <= len(p.frames) && err != errExecutionReverted {
+		frame := p.frames[depth-1]
+		frame.opCost = frame.opGas
+	}
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (p *GasProfiler) CaptureEnd(output []byte, gasUsed uint64, t time.Duration, err error) error {
+	for len(p.frames) > 0 {
+		p.leave()
+	}
+	p.trackRefund()
+	p.profile.GasUsed = gasUsed
+	return nil
+}
+
+// Profile returns the aggregated gas profile.
+func (p *GasProfiler) Profile() *GasProfile {
+	return &p.profile
+}
+
+// enter pushes a new call frame for the given contract.
+func (p *GasProfiler) enter(contract *Contract) {
+	addr := contract.Address()
+	cp, ok := p.profile.Contracts[addr]
+	if !ok {
+		cp = &ContractGasProfile{Functions: make(map[string]*GasStat)}
+		p.profile.Contracts[addr] = cp
+	}
+	// Contract creations run init code without a selector
+	create := p.create
+	if n := len(p.frames); n > 0 {
+		caller := p.frames[n-1].op
+		create = caller == CREATE || caller == CREATE2
+	}
+	selector := "fallback"
+	switch {
+	case create:
+		selector = "constructor"
+	case len(contract.Input) >= 4:
+		selector = fmt.Sprintf("0x%x", contract.Input[:4])
+	}
+	fn, ok := cp.Functions[selector]
+	if !ok {
+		fn = new(GasStat)
+		cp.Functions[selector] = fn
+	}
+	cp.Count++
+	fn.Count++
+
+	path := fmt.Sprintf("%x:%s", addr, selector)
+	if n := len(p.frames); n > 0 {
+		path = p.frames[n-1].path + ";" + path
+	}
+	p.frames = append(p.frames, &profileFrame{contract: cp, function: fn, path: path})
+}
+
+// leave pops the innermost call frame, accounting its last operation and
+// attributing its total gas usage to the operation that called it.
+func (p *GasProfiler) leave() {
+	frame := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+
+	if frame.pending {
+		p.account(frame, frame.opCost)
+	}
+	if n := len(p.frames); n > 0 {
+		p.frames[n-1].children += frame.used
+	}
+}
+
+// account charges the gas consumed by the frame's pending operation, less the
+// gas used by any nested calls it made.
+func (p *GasProfiler) account(frame *profileFrame, used uint64) {
+	own := used
+	if frame.children < own {
+		own -= frame.children
+	} else {
+		own = 0
+	}
+	frame.used += own + frame.children
+	frame.children, frame.pending = 0, false
+
+	frame.contract.Gas += own
+	frame.function.Gas += own
+
+	stat := p.opcode(frame.op)
+	stat.Gas += own
+	stat.Count++
+
+	if own > 0 {
+		p.profile.Stacks[frame.path+";"+frame.op.String()] += own
+	}
+}
+
+// opcode retrieves the statistics of the given opcode.
+func (p *GasProfiler) opcode(op OpCode) *GasStat {
+	name := strings.TrimSpace(op.String())
+	stat, ok := p.profile.Opcodes[name]
+	if !ok {
+		stat = new(GasStat)
+		p.profile.Opcodes[name] = stat
+	}
+	return stat
+}
+
+// trackRefund attributes any change of the refund counter since the last check
+// to the most recently executed operation.
+func (p *GasProfiler) trackRefund() {
+	if p.env == nil {
+		return
+	}
+	refund := p.env.StateDB.GetRefund()
+	if refund > p.refund && p.owner != nil {
+		delta := refund - p.refund
+		p.owner.Refund += delta
+		p.ownerFn.Refund += delta
+		p.ownerOp.Refund += delta
+	}
+	p.refund = refund
+	p.profile.Refund = refund
+}
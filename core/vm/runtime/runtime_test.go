@@ -17,6 +17,7 @@
 package runtime
 
 import (
+	"fmt"
 	"math/big"
 	"strings"
 	"testing"
@@ -148,3 +149,101 @@ func BenchmarkCall(b *testing.B) {
 		}
 	}
 }
+
+func TestFailureTracer(t *testing.T) {
+	var (
+		inner      = common.HexToAddress("0xbb")
+		outer      = common.HexToAddress("0xaa")
+		tracer     = vm.NewFailureTracer()
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	)
+	// The inner contract reverts unconditionally
+	statedb.SetCode(inner, common.Hex2Bytes("60006000fd"))
+	// The outer contract calls the inner one and reverts afterwards
+	statedb.SetCode(outer, common.Hex2Bytes("60006000600060006000"+"73"+common.Bytes2Hex(inner[:])+"5af1"+"60006000fd"))
+
+	_, _, err := Call(outer, nil, &Config{State: statedb, EVMConfig: vm.Config{Debug: true, Tracer: tracer}})
+	if err == nil {
+		t.Fatal("expected call to revert")
+	}
+	frames := tracer.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("frame count mismatch: have %d, want 2", len(frames))
+	}
+	if frames[0].Address != inner || frames[0].PC != 4 || frames[0].Op != vm.REVERT || frames[0].Depth != 2 {
+		t.Errorf("inner frame mismatch: %+v", frames[0])
+	}
+	if frames[1].Address != outer || frames[1].PC != 37 || frames[1].Op != vm.REVERT || frames[1].Depth != 1 {
+		t.Errorf("outer frame mismatch: %+v", frames[1])
+	}
+}
+
+func TestGasProfiler(t *testing.T) {
+	var (
+		inner      = common.HexToAddress("0xbb")
+		outer      = common.HexToAddress("0xaa")
+		profiler   = vm.NewGasProfiler()
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	)
+	// The inner contract stores a value
+	statedb.SetCode(inner, common.Hex2Bytes("6001600055"))
+	// The outer contract calls the inner one with a selector
+	statedb.SetCode(outer, common.Hex2Bytes("63aabbccdd600052"+"600060006004601c6000"+"73"+common.Bytes2Hex(inner[:])+"5af150"))
+
+	if _, _, err := Call(outer, nil, &Config{State: statedb, EVMConfig: vm.Config{Debug: true, Tracer: profiler}}); err != nil {
+		t.Fatal(err)
+	}
+	profile := profiler.Profile()
+
+	var total uint64
+	for _, contract := range profile.Contracts {
+		total += contract.Gas
+	}
+	if total != profile.GasUsed {
+		t.Errorf("contract gas mismatch: have %d, want %d", total, profile.GasUsed)
+	}
+	if gas := profile.Contracts[inner].Gas; gas != 20006 {
+		t.Errorf("inner contract gas mismatch: have %d, want %d", gas, 20006)
+	}
+	if fn := profile.Contracts[inner].Functions["0xaabbccdd"]; fn == nil || fn.Gas != 20006 || fn.Count != 1 {
+		t.Errorf("inner function mismatch: %+v", fn)
+	}
+	if op := profile.Opcodes["SSTORE"]; op.Gas != 20000 || op.Count != 1 {
+		t.Errorf("SSTORE stats mismatch: %+v", op)
+	}
+	if op := profile.Opcodes["CALL"]; op.Gas >= 20000 {
+		t.Errorf("CALL charged for nested execution: %+v", op)
+	}
+	stack := fmt.Sprintf("%x:fallback;%x:0xaabbccdd;SSTORE", outer, inner)
+	if gas := profile.Stacks[stack]; gas != 20000 {
+		t.Errorf("folded stack %s mismatch: have %d, want %d", stack, gas, 20000)
+	}
+}
+
+func TestCoverageTracer(t *testing.T) {
+	var (
+		address    = common.HexToAddress("0xaa")
+		tracer     = vm.NewCoverageTracer()
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(ethdb.NewMemDatabase()))
+	)
+	// PUSH1 0 PUSH1 8 JUMPI STOP ... JUMPDEST STOP, never taking the jump
+	statedb.SetCode(address, common.Hex2Bytes("600060085700000000005b00"))
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := Call(address, nil, &Config{State: statedb, EVMConfig: vm.Config{Debug: true, Tracer: tracer}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	coverage := tracer.Coverage()[statedb.GetCodeHash(address)]
+	if coverage == nil {
+		t.Fatal("no coverage recorded for code")
+	}
+	for pc, want := range map[uint64]uint64{0: 2, 2: 2, 4: 2, 5: 2, 10: 0} {
+		if have := coverage.Hits[pc]; have != want {
+			t.Errorf("pc %d: hit count mismatch: have %d, want %d", pc, have, want)
+		}
+	}
+	if have := coverage.Branches[4]; have != [2]uint64{2, 0} {
+		t.Errorf("branch outcome mismatch: have %v, want %v", have, [2]uint64{2, 0})
+	}
+}
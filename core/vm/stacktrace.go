@@ -0,0 +1,137 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// CallFrame is a single level of the EVM call stack at the time of a failure.
+type CallFrame struct {
+	Address common.Address // Address of the executing contract
+	Code    []byte         // Code being executed (init code for contract creations)
+	Create  bool           // Whether the frame is running contract init code
+	PC      uint64         // Program counter of the last executed instruction
+	Op      OpCode         // Last executed instruction
+	Depth   int            // Call depth, 1 for the outermost frame
+}
+
+// FailureTracer is an EVM tracer that tracks the call stack and records it at
+// the point a REVERT, invalid opcode or other execution error occurs. Failures
+// propagating up the call stack are merged into the deepest recorded one, so
+// the final trace runs from the original failure to the outermost call.
+type FailureTracer struct {
+	frames  []CallFrame // Current call stack, outermost first
+	failure []CallFrame // Call stack at the last failure, outermost first
+	create  bool        // Whether the next entered frame runs init code
+	err     error
+}
+
+// NewFailureTracer creates a new failure tracer.
+func NewFailureTracer() *FailureTracer {
+	return &FailureTracer{}
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *FailureTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.create = create
+	return nil
+}
+
+// CaptureState implements the Tracer interface, tracking the executing frame.
+func (t *FailureTracer) CaptureState(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	switch {
+	case depth > len(t.frames):
+		// Entering a new frame, find out whether the caller asked for a creation
+		create := t.create
+		if len(t.frames) > 0 {
+			caller := t.frames[len(t.frames)-1].Op
+			create = caller == CREATE || caller == CREATE2
+		}
+		t.frames = append(t.frames, CallFrame{Address: contract.Address(), Code: contract.Code, Create: create, Depth: depth})
+	case depth < len(t.frames):
+		t.frames = t.frames[:depth]
+	}
+	frame := &t.frames[depth-1]
+	frame.PC, frame.Op = pc, op
+
+	// A recorded inner failure is stale if its caller already returned or
+	// went on to make another call, as it was evidently handled
+	if n := len(t.failure); n > depth {
+		switch {
+		case depth < n-1, op == CALL, op == CALLCODE, op == DELEGATECALL, op == STATICCALL, op == CREATE, op == CREATE2:
+			t.failure = nil
+		}
+	}
+
+	// Errors are reported here if they happen before the step was logged
+	if err != nil {
+		t.fail(depth)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to record an execution fault.
+func (t *FailureTracer) CaptureFault(env *EVM, pc uint64, op OpCode, gas, cost uint64, memory *Memory, stack *Stack, contract *Contract, depth int, err error) error {
+	if depth <= len(t.frames) {
+		t.fail(depth)
+	}
+	return nil
+}
+
+// fail records the current call stack up to the given depth as a failure.
+func (t *FailureTracer) fail(depth int) {
+	frames := make([]CallFrame, depth)
+	copy(frames, t.frames[:depth])
+
+	// If the failure is caused by an inner one, extend with its deeper frames
+	if len(t.failure) > depth {
+		for i := 0; i < depth; i++ {
+			if t.failure[i].Address != frames[i].Address {
+				t.failure = frames
+				return
+			}
+		}
+		frames = append(frames, t.failure[depth:]...)
+	}
+	t.failure = frames
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *FailureTracer) CaptureEnd(output []byte, gasUsed uint64, tm time.Duration, err error) error {
+	t.err = err
+	if err == nil {
+		t.failure = nil
+	}
+	return nil
+}
+
+// Error returns the VM error captured by the trace.
+func (t *FailureTracer) Error() error { return t.err }
+
+// Frames returns the call stack at the failure which aborted the execution,
+// innermost frame first. It is empty if the execution succeeded.
+func (t *FailureTracer) Frames() []CallFrame {
+	frames := make([]CallFrame, len(t.failure))
+	for i, frame := range t.failure {
+		frames[len(frames)-1-i] = frame
+	}
+	return frames
+}
@@ -26,14 +26,17 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/blockarchive"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -180,6 +183,24 @@ func (api *PrivateAdminAPI) ExportChain(file string) (bool, error) {
 	return true, nil
 }
 
+// ExportArchive exports the current blockchain into a chain archive directory,
+// reusing the intact chunks left over by a previous export.
+func (api *PrivateAdminAPI) ExportArchive(dir string) (bool, error) {
+	chain := api.eth.BlockChain()
+	if err := blockarchive.Export(chain, dir, 0, chain.CurrentBlock().NumberU64(), nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImportArchive imports the blocks of a chain archive directory.
+func (api *PrivateAdminAPI) ImportArchive(dir string) (bool, error) {
+	if err := blockarchive.Import(api.eth.BlockChain(), dir, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -281,6 +302,10 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 type PrivateDebugAPI struct {
 	config *params.ChainConfig
 	eth    *Ethereum
+
+	coverage       *vm.CoverageTracer // Coverage collected by the last coverage run
+	coverageConfig *vm.Config         // Chain EVM config to restore when coverage stops, nil if not running
+	coverageLock   sync.Mutex
 }
 
 // NewPrivateDebugAPI creates a new API definition for the full node-related
@@ -320,7 +345,7 @@ func (api *PrivateDebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs,
 		} else {
 			results[i].RLP = fmt.Sprintf("0x%x", rlpBytes)
 		}
-		if results[i].Block, err = ethapi.RPCMarshalBlock(block, true, true); err != nil {
+		if results[i].Block, err = ethapi.RPCMarshalBlock(block, true, true, api.eth.blockchain.Config()); err != nil {
 			results[i].Block = map[string]interface{}{"error": err.Error()}
 		}
 	}
@@ -193,10 +193,42 @@ func (b *EthAPIBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.eth.TxPool().Content()
 }
 
+func (b *EthAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.eth.TxPool().ContentFrom(addr)
+}
+
+func (b *EthAPIBackend) TxPoolRemove(hash common.Hash) bool {
+	return b.eth.TxPool().Remove(hash)
+}
+
+func (b *EthAPIBackend) TxPoolRemoveSender(addr common.Address) int {
+	return b.eth.TxPool().RemoveSender(addr)
+}
+
+func (b *EthAPIBackend) TxPoolClear() int {
+	return b.eth.TxPool().Clear()
+}
+
+func (b *EthAPIBackend) TxPoolSnapshot() *core.TxPoolSnapshot {
+	return b.eth.TxPool().Snapshot()
+}
+
+func (b *EthAPIBackend) TxPoolRestore(ctx context.Context, snap *core.TxPoolSnapshot) []error {
+	return b.eth.TxPool().Restore(snap)
+}
+
+func (b *EthAPIBackend) TxPoolRejections() []core.TxRejection {
+	return b.eth.TxPool().Rejections()
+}
+
 func (b *EthAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.eth.TxPool().SubscribeNewTxsEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return b.eth.TxPool().SubscribeTxLifecycleEvent(ch)
+}
+
 func (b *EthAPIBackend) Downloader() *downloader.Downloader {
 	return b.eth.Downloader()
 }
@@ -221,6 +253,10 @@ func (b *EthAPIBackend) AccountManager() *accounts.Manager {
 	return b.eth.AccountManager()
 }
 
+func (b *EthAPIBackend) Impersonating(addr common.Address) bool {
+	return b.eth.Impersonating(addr)
+}
+
 func (b *EthAPIBackend) BloomStatus() (uint64, uint64) {
 	sections, _, _ := b.eth.bloomIndexer.Sections()
 	return params.BloomBitsBlocks, sections
@@ -0,0 +1,108 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	errCoverageRunning    = errors.New("coverage collection already running")
+	errCoverageNotRunning = errors.New("coverage collection not running")
+	errNoCoverage         = errors.New("no coverage collected")
+)
+
+// StartCoverage starts recording the instructions executed by every transaction
+// processed by the chain or sealed by the miner, discarding previously collected
+// coverage.
+func (api *PrivateDebugAPI) StartCoverage() error {
+	api.coverageLock.Lock()
+	defer api.coverageLock.Unlock()
+
+	if api.coverageConfig != nil {
+		return errCoverageRunning
+	}
+	config := api.eth.blockchain.VMConfig()
+	api.coverageConfig = &config
+	api.coverage = vm.NewCoverageTracer()
+
+	traced := config
+	traced.Debug, traced.Tracer = true, api.coverage
+	api.eth.blockchain.SetVMConfig(traced)
+	log.Info("Started contract coverage collection")
+	return nil
+}
+
+// StopCoverage stops recording coverage. The collected data remains available
+// until the next call to StartCoverage.
+func (api *PrivateDebugAPI) StopCoverage() error {
+	api.coverageLock.Lock()
+	defer api.coverageLock.Unlock()
+
+	if api.coverageConfig == nil {
+		return errCoverageNotRunning
+	}
+	api.eth.blockchain.SetVMConfig(*api.coverageConfig)
+	api.coverageConfig = nil
+
+	log.Info("Stopped contract coverage collection")
+	return nil
+}
+
+// Coverage returns the raw coverage collected so far: the execution count of
+// every program counter, keyed by code hash.
+func (api *PrivateDebugAPI) Coverage() (map[common.Hash]*vm.CodeCoverage, error) {
+	api.coverageLock.Lock()
+	defer api.coverageLock.Unlock()
+
+	if api.coverage == nil {
+		return nil, errNoCoverage
+	}
+	return api.coverage.Coverage(), nil
+}
+
+// CoverageReport maps the coverage collected so far through the given compiler
+// artifacts and returns it as an lcov tracefile.
+func (api *PrivateDebugAPI) CoverageReport(config SourceMapConfig) (string, error) {
+	coverage, err := api.Coverage()
+	if err != nil {
+		return "", err
+	}
+	mapper, err := config.newSourceMapper()
+	if err != nil {
+		return "", err
+	}
+	executions := make([]*compiler.ExecutionCoverage, 0, len(coverage))
+	for _, cov := range coverage {
+		executions = append(executions, &compiler.ExecutionCoverage{
+			Code:     cov.Code,
+			Hits:     cov.Hits,
+			Branches: cov.Branches,
+		})
+	}
+	var buf bytes.Buffer
+	if err := mapper.WriteLCOV(&buf, executions); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
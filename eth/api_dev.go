@@ -0,0 +1,282 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/thunder"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// chainSnapshot is a point of a dev chain that can be reverted to.
+type chainSnapshot struct {
+	id     uint64
+	number uint64
+	hash   common.Hash
+	pool   *core.TxPoolSnapshot
+	clock  *thunder.ClockState // Block clock state, nil on chains without one
+}
+
+// PrivateDevAPI provides the test isolation and state manipulation methods of
+// dev chains.
+type PrivateDevAPI struct {
+	eth *Ethereum
+
+	snapshots []*chainSnapshot // Live snapshots, oldest first
+	nextID    uint64           // Identifier of the next snapshot
+	lock      sync.Mutex
+}
+
+// NewPrivateDevAPI creates a new API definition for the dev chain methods of
+// the Ethereum service.
+func NewPrivateDevAPI(eth *Ethereum) *PrivateDevAPI {
+	return &PrivateDevAPI{eth: eth, nextID: 1}
+}
+
+// Snapshot records the current chain head, transaction pool content and block
+// clock, and returns the identifier to revert to them with.
+func (api *PrivateDevAPI) Snapshot() hexutil.Uint64 {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	head := api.eth.blockchain.CurrentBlock()
+	snap := &chainSnapshot{
+		id:     api.nextID,
+		number: head.NumberU64(),
+		hash:   head.Hash(),
+		pool:   api.eth.txPool.Snapshot(),
+	}
+	if engine, ok := api.eth.engine.(*thunder.Thunder); ok {
+		snap.clock = engine.ClockState()
+	}
+	api.snapshots = append(api.snapshots, snap)
+	api.nextID++
+
+	return hexutil.Uint64(snap.id)
+}
+
+// Revert rewinds the chain, the state, the transaction pool and the block clock
+// to a snapshot. Filters see the rewound logs as removed and the snapshot block
+// as the new head. The snapshot and all later ones are discarded, so reverting
+// to the same point again requires a new snapshot. False is returned for unknown
+// snapshots.
+func (api *PrivateDevAPI) Revert(id hexutil.Uint64) (bool, error) {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	index := -1
+	for i, snap := range api.snapshots {
+		if snap.id == uint64(id) {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return false, nil
+	}
+	snap := api.snapshots[index]
+	if err := api.revert(snap); err != nil {
+		return false, err
+	}
+	api.snapshots = api.snapshots[:index]
+	return true, nil
+}
+
+// revert rewinds the node to a snapshot.
+func (api *PrivateDevAPI) revert(snap *chainSnapshot) error {
+	var (
+		chain = api.eth.blockchain
+		db    = api.eth.chainDb
+	)
+	if rawdb.ReadCanonicalHash(db, snap.number) != snap.hash {
+		return fmt.Errorf("snapshot block #%d [%x…] no longer canonical", snap.number, snap.hash[:4])
+	}
+	if _, err := chain.StateAt(chain.GetHeader(snap.hash, snap.number).Root); err != nil {
+		return fmt.Errorf("snapshot state unavailable: %v", err)
+	}
+	// Pause the miner so that no block is sealed on top of the rewound chain
+	resume, err := api.pauseMining()
+	if err != nil {
+		return err
+	}
+	defer resume()
+
+	// Collect the logs of the rewound blocks before dropping them
+	var removed []*types.Log
+	for number := chain.CurrentBlock().NumberU64(); number > snap.number; number-- {
+		hash := rawdb.ReadCanonicalHash(db, number)
+		for _, receipt := range rawdb.ReadReceipts(db, hash, number) {
+			for _, l := range receipt.Logs {
+				l := *l
+				l.Removed = true
+				removed = append(removed, &l)
+			}
+		}
+	}
+	if err := chain.SetHead(snap.number); err != nil {
+		return err
+	}
+	head := chain.CurrentBlock()
+	if head.Hash() != snap.hash {
+		return errors.New("chain rewound past the snapshot block")
+	}
+	errs, err := api.eth.txPool.Rewind(head, snap.pool)
+	if err != nil {
+		return err
+	}
+	for i, err := range errs {
+		if err != nil {
+			log.Debug("Failed to restore snapshot transaction", "hash", snap.pool.Transactions[i].Tx.Hash(), "err", err)
+		}
+	}
+	// Move the clock back too, so that later blocks don't keep the time changes
+	if engine, ok := api.eth.engine.(*thunder.Thunder); ok {
+		engine.RestoreClock(snap.clock)
+	}
+	// Let the filters and the miner pick up the new head
+	var events []interface{}
+	if len(removed) > 0 {
+		events = append(events, core.RemovedLogsEvent{Logs: removed})
+	}
+	chain.PostChainEvents(append(events, core.ChainHeadEvent{Block: head}), nil)
+
+	log.Info("Reverted to chain snapshot", "number", snap.number, "hash", snap.hash)
+	return nil
+}
+
+// pauseMining stops the miner if it is running and returns the function that
+// restarts it.
+func (api *PrivateDevAPI) pauseMining() (func(), error) {
+	if !api.eth.miner.Mining() {
+		return func() {}, nil
+	}
+	coinbase, err := api.eth.Etherbase()
+	if err != nil {
+		return nil, err
+	}
+	api.eth.miner.Stop()
+	return func() { api.eth.miner.Start(coinbase) }, nil
+}
+
+// SetBalance sets the balance of an account in the head state.
+func (api *PrivateDevAPI) SetBalance(addr common.Address, balance hexutil.Big) error {
+	return api.setState(func(statedb *state.StateDB) {
+		statedb.SetBalance(addr, (*big.Int)(&balance))
+	})
+}
+
+// SetCode sets the code of an account in the head state.
+func (api *PrivateDevAPI) SetCode(addr common.Address, code hexutil.Bytes) error {
+	return api.setState(func(statedb *state.StateDB) {
+		statedb.SetCode(addr, code)
+	})
+}
+
+// SetStorageAt sets a storage slot of an account in the head state.
+func (api *PrivateDevAPI) SetStorageAt(addr common.Address, key common.Hash, value common.Hash) error {
+	return api.setState(func(statedb *state.StateDB) {
+		statedb.SetState(addr, key, value)
+	})
+}
+
+// SetNonce sets the nonce of an account in the head state.
+func (api *PrivateDevAPI) SetNonce(addr common.Address, nonce hexutil.Uint64) error {
+	return api.setState(func(statedb *state.StateDB) {
+		statedb.SetNonce(addr, uint64(nonce))
+	})
+}
+
+// setState applies a change to the head state. As the head block commits to its
+// state, the change is sealed into an empty block on top of it, which becomes
+// the new head right away. The consensus engine prepares the block header, but
+// doesn't finalize it, so that no block reward is minted and the change is the
+// only one. As the change is part of no transaction, chains with such blocks can
+// neither be re-executed nor exported and imported elsewhere. The state is also
+// committed to disk for that reason, as it can't be regenerated.
+func (api *PrivateDevAPI) setState(change func(statedb *state.StateDB)) error {
+	api.lock.Lock()
+	defer api.lock.Unlock()
+
+	// Pause the miner so that no block is sealed next to the new one
+	resume, err := api.pauseMining()
+	if err != nil {
+		return err
+	}
+	defer resume()
+
+	var (
+		chain  = api.eth.blockchain
+		engine = api.eth.engine
+	)
+	parent := chain.CurrentBlock()
+	statedb, err := chain.StateAt(parent.Root())
+	if err != nil {
+		return err
+	}
+	change(statedb)
+
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+	}
+	if err := engine.Prepare(chain, header); err != nil {
+		return err
+	}
+	// Keep the parent's timestamp, leaving the clock to the next mined block
+	header.Time = new(big.Int).Set(parent.Time())
+
+	header.Root = statedb.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	block := types.NewBlock(header, nil, nil, nil)
+
+	status, err := chain.WriteBlockWithState(block, nil, statedb)
+	if err != nil {
+		return err
+	}
+	if status != core.CanonStatTy {
+		return fmt.Errorf("state block #%d [%x…] not canonical", block.NumberU64(), block.Hash().Bytes()[:4])
+	}
+	if err := chain.StateCache().TrieDB().Commit(block.Root(), false); err != nil {
+		return err
+	}
+	chain.PostChainEvents([]interface{}{
+		core.ChainEvent{Block: block, Hash: block.Hash()},
+		core.ChainHeadEvent{Block: block},
+	}, nil)
+	return nil
+}
+
+// ImpersonateAccount makes eth_sendTransaction accept transactions from the
+// given account without a key.
+func (api *PrivateDevAPI) ImpersonateAccount(addr common.Address) {
+	api.eth.txPool.Impersonate(addr)
+}
+
+// StopImpersonatingAccount requires a key for the transactions of the given
+// account again.
+func (api *PrivateDevAPI) StopImpersonatingAccount(addr common.Address) {
+	api.eth.txPool.StopImpersonating(addr)
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/thunder"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/miner"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newDevTestBackend creates an Ethereum service on a fresh dev chain, with just
+// the parts the dev API needs.
+func newDevTestBackend(t *testing.T, config *params.ThunderConfig, alloc core.GenesisAlloc) *Ethereum {
+	config.Dev = true
+	chainConfig := *params.TestThunderChainConfig
+	chainConfig.Thunder = config
+
+	db := ethdb.NewMemDatabase()
+	(&core.Genesis{Config: &chainConfig, Alloc: alloc, GasLimit: 10000000}).MustCommit(db)
+
+	eth := &Ethereum{
+		config:      &DefaultConfig,
+		chainConfig: &chainConfig,
+		chainDb:     db,
+		eventMux:    new(event.TypeMux),
+		engine:      thunder.New(config),
+		gasPrice:    big.NewInt(1),
+	}
+	var err error
+	if eth.blockchain, err = core.NewBlockChain(db, nil, &chainConfig, eth.engine, vm.Config{}); err != nil {
+		t.Fatalf("failed to create dev chain: %v", err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+	eth.txPool = core.NewTxPool(poolConfig, &chainConfig, eth.blockchain)
+	eth.miner = miner.New(eth, &chainConfig, eth.eventMux, eth.engine, time.Second, params.GenesisGasLimit, params.GenesisGasLimit)
+	return eth
+}
+
+// stopDevTestBackend tears down the parts of the service created by newDevTestBackend.
+func stopDevTestBackend(eth *Ethereum) {
+	eth.miner.Stop()
+	eth.txPool.Stop()
+	eth.blockchain.Stop()
+	eth.eventMux.Stop()
+}
+
+// Tests that dev_set* calls set the exact values, without any block reward
+// minted on top.
+func TestDevSetStateNoReward(t *testing.T) {
+	coinbase := common.HexToAddress("0xc01b")
+	eth := newDevTestBackend(t, &params.ThunderConfig{
+		FeeRecipients: []params.ThunderFeeRecipient{{Address: coinbase, Weight: 1}},
+		BlockReward:   big.NewInt(1000),
+	}, nil)
+	defer stopDevTestBackend(eth)
+
+	api := NewPrivateDevAPI(eth)
+	if err := api.SetBalance(coinbase, hexutil.Big(*big.NewInt(42))); err != nil {
+		t.Fatalf("failed to set balance: %v", err)
+	}
+	if err := api.SetNonce(coinbase, 3); err != nil {
+		t.Fatalf("failed to set nonce: %v", err)
+	}
+	if number := eth.blockchain.CurrentBlock().NumberU64(); number != 2 {
+		t.Fatalf("head number mismatch: have %d, want 2", number)
+	}
+	statedb, err := eth.blockchain.State()
+	if err != nil {
+		t.Fatalf("failed to open head state: %v", err)
+	}
+	if balance := statedb.GetBalance(coinbase); balance.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 42", balance)
+	}
+	if nonce := statedb.GetNonce(coinbase); nonce != 3 {
+		t.Errorf("nonce mismatch: have %d, want 3", nonce)
+	}
+}
+
+// Tests that reverting to a snapshot restores the chain, the transaction pool
+// and the block clock, and notifies the filters of the rewound logs.
+func TestDevRevert(t *testing.T) {
+	var (
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0x1095")
+	)
+	eth := newDevTestBackend(t, new(params.ThunderConfig), core.GenesisAlloc{
+		sender:   {Balance: big.NewInt(1000000000)},
+		contract: {Balance: new(big.Int), Code: []byte{0x60, 0x00, 0x60, 0x00, 0xa0, 0x00}}, // LOG0 of nothing
+	})
+	defer stopDevTestBackend(eth)
+
+	var clock *thunder.DevAPI
+	for _, api := range eth.engine.APIs(eth.blockchain) {
+		if service, ok := api.Service.(*thunder.DevAPI); ok {
+			clock = service
+		}
+	}
+	signer := types.NewEIP155Signer(eth.chainConfig.ChainID)
+	tx, _ := types.SignTx(types.NewTransaction(0, contract, new(big.Int), 100000, big.NewInt(1), nil), signer, key)
+	if err := eth.txPool.AddRemote(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	// Take a snapshot, then include the transaction and move the clock
+	api := NewPrivateDevAPI(eth)
+	id := api.Snapshot()
+
+	genesis := eth.blockchain.CurrentBlock()
+	blocks, _ := core.GenerateChain(eth.chainConfig, genesis, eth.engine, eth.chainDb, 1, func(i int, gen *core.BlockGen) {
+		gen.AddTx(tx)
+	})
+	if _, err := eth.blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	for pending, _ := eth.txPool.Stats(); pending != 0; pending, _ = eth.txPool.Stats() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	clock.IncreaseTime(hexutil.Uint64(time.Hour / time.Second))
+	clock.FreezeClock(true)
+	pinned := uint64(time.Now().Add(3 * time.Hour).Unix())
+	if err := clock.SetNextBlockTimestamp(hexutil.Uint64(pinned)); err != nil {
+		t.Fatalf("failed to pin next block timestamp: %v", err)
+	}
+	// Revert and check what the filters see
+	var (
+		removedCh = make(chan core.RemovedLogsEvent, 1)
+		headCh    = make(chan core.ChainHeadEvent, 1)
+	)
+	removedSub := eth.blockchain.SubscribeRemovedLogsEvent(removedCh)
+	defer removedSub.Unsubscribe()
+	headSub := eth.blockchain.SubscribeChainHeadEvent(headCh)
+	defer headSub.Unsubscribe()
+
+	if ok, err := api.Revert(id); !ok || err != nil {
+		t.Fatalf("failed to revert: %v %v", ok, err)
+	}
+	if head := eth.blockchain.CurrentBlock(); head.Hash() != genesis.Hash() {
+		t.Fatalf("head mismatch: have #%d, want genesis", head.NumberU64())
+	}
+	select {
+	case ev := <-removedCh:
+		if len(ev.Logs) != 1 || !ev.Logs[0].Removed || ev.Logs[0].TxHash != tx.Hash() {
+			t.Errorf("removed logs mismatch: %v", ev.Logs)
+		}
+	default:
+		t.Errorf("no removed logs event")
+	}
+	select {
+	case ev := <-headCh:
+		if ev.Block.Hash() != genesis.Hash() {
+			t.Errorf("head event mismatch: have #%d, want genesis", ev.Block.NumberU64())
+		}
+	default:
+		t.Errorf("no head event")
+	}
+	if pending, _ := eth.txPool.Stats(); pending != 1 || eth.txPool.Get(tx.Hash()) == nil {
+		t.Errorf("transaction not restored to the pool")
+	}
+	// The clock runs with its original offset again and forgets the pinned time
+	if now, wall := int64(clock.Now()), time.Now().Unix(); now < wall-5 || now > wall+5 {
+		t.Errorf("clock not restored: have %d, want about %d", now, wall)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if now, wall := int64(clock.Now()), time.Now().Unix(); now < wall-5 {
+		t.Errorf("clock still frozen: have %d, want about %d", now, wall)
+	}
+	header := &types.Header{ParentHash: genesis.Hash(), Number: big.NewInt(1)}
+	if err := eth.engine.Prepare(eth.blockchain, header); err != nil {
+		t.Fatalf("failed to prepare block: %v", err)
+	}
+	if header.Time.Uint64() >= pinned {
+		t.Errorf("next block still pinned: have %d, reverted %d", header.Time, pinned)
+	}
+}
@@ -19,6 +19,7 @@ package eth
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -27,6 +28,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -50,6 +52,10 @@ const (
 	// and reexecute to produce missing historical state necessary to run a specific
 	// trace.
 	defaultTraceReexec = uint64(128)
+
+	// gasProfilerName is the tracer name selecting the native gas profiler
+	// instead of a JavaScript tracer.
+	gasProfilerName = "gasProfiler"
 )
 
 // TraceConfig holds extra parameters to trace functions.
@@ -192,7 +198,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 
 			// Fetch and execute the next block trace tasks
 			for task := range tasks {
-				signer := types.MakeSigner(api.config, task.block.Number())
+				signer := core.MakeSigner(api.config, task.block.Number())
 
 				// Trace all the transactions contained within
 				for i, tx := range task.block.Transactions() {
@@ -413,7 +419,7 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 	}
 	// Execute all the transaction contained within the block concurrently
 	var (
-		signer = types.MakeSigner(api.config, block.Number())
+		signer = core.MakeSigner(api.config, block.Number())
 
 		txs     = block.Transactions()
 		results = make([]*txTraceResult, len(txs))
@@ -561,6 +567,63 @@ func (api *PrivateDebugAPI) TraceTransaction(ctx context.Context, hash common.Ha
 	return api.traceTx(ctx, msg, vmctx, statedb, config)
 }
 
+// SourceMapConfig holds the compiler artifacts used to map executed code back to
+// Solidity sources.
+type SourceMapConfig struct {
+	Artifacts []json.RawMessage `json:"artifacts"` // solc --combined-json outputs with bin, bin-runtime, srcmap and srcmap-runtime
+	Sources   map[string]string `json:"sources"`   // Source file contents keyed by their sourceList paths
+}
+
+// newSourceMapper creates a source mapper from the configured artifacts.
+func (config *SourceMapConfig) newSourceMapper() (*compiler.SourceMapper, error) {
+	artifacts := make([][]byte, len(config.Artifacts))
+	for i, artifact := range config.Artifacts {
+		artifacts[i] = artifact
+	}
+	return compiler.NewSourceMapper(artifacts, config.Sources)
+}
+
+// StackTraceConfig holds the compiler artifacts used to resolve a Solidity level
+// stack trace.
+type StackTraceConfig struct {
+	SourceMapConfig
+	Reexec *uint64 `json:"reexec"`
+}
+
+// StackTraceTransaction re-executes a transaction and returns the Solidity level
+// stack trace of the REVERT, invalid opcode or other error that aborted it. The
+// trace is empty if the transaction succeeded.
+func (api *PrivateDebugAPI) StackTraceTransaction(ctx context.Context, hash common.Hash, config *StackTraceConfig) (*tracers.StackTrace, error) {
+	tx, blockHash, _, index := rawdb.ReadTransaction(api.eth.ChainDb(), hash)
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	var (
+		reexec = defaultTraceReexec
+		mapper *compiler.SourceMapper
+	)
+	if config != nil {
+		if config.Reexec != nil {
+			reexec = *config.Reexec
+		}
+		var err error
+		if mapper, err = config.newSourceMapper(); err != nil {
+			return nil, err
+		}
+	}
+	msg, vmctx, statedb, err := api.computeTxEnv(blockHash, int(index), reexec)
+	if err != nil {
+		return nil, err
+	}
+	tracer := vm.NewFailureTracer()
+	vmenv := vm.NewEVM(vmctx, statedb, api.config, vm.Config{Debug: true, Tracer: tracer})
+
+	if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+		return nil, fmt.Errorf("tracing failed: %v", err)
+	}
+	return tracers.NewStackTrace(tracer, mapper), nil
+}
+
 // traceTx configures a new tracer according to the provided configuration, and
 // executes the given message in the provided environment. The return value will
 // be tracer dependent.
@@ -571,6 +634,9 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 		err    error
 	)
 	switch {
+	case config != nil && config.Tracer != nil && *config.Tracer == gasProfilerName:
+		tracer = vm.NewGasProfiler()
+
 	case config != nil && config.Tracer != nil:
 		// Define a meaningful timeout of a single transaction trace
 		timeout := defaultTraceTimeout
@@ -614,6 +680,9 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 			StructLogs:  ethapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
+	case *vm.GasProfiler:
+		return tracer.Profile(), nil
+
 	case *tracers.Tracer:
 		return tracer.GetResult()
 
@@ -638,7 +707,7 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 		return nil, vm.Context{}, nil, err
 	}
 	// Recompute transactions up to the target index.
-	signer := types.MakeSigner(api.config, block.Number())
+	signer := core.MakeSigner(api.config, block.Number())
 
 	for idx, tx := range block.Transactions() {
 		// Assemble the transaction call message and return if the requested offset
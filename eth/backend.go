@@ -65,6 +65,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -79,11 +80,13 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/snap"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
@@ -133,6 +136,8 @@ type Ethereum struct {
 	gasPrice  *big.Int
 	etherbase common.Address
 
+	fork *remoteState // State of the remote node dev chains are forked from, nil if not forked
+
 	networkID     uint64
 	netRPCService *ethapi.PublicNetAPI
 
@@ -158,11 +163,38 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		log.Warn("Sanitizing invalid miner gas price", "provided", config.MinerGasPrice, "updated", DefaultConfig.MinerGasPrice)
 		config.MinerGasPrice = new(big.Int).Set(DefaultConfig.MinerGasPrice)
 	}
+	// Connect to the node to fork the state of, if any
+	var fork *remoteState
+	if config.ForkURL != "" {
+		if config.Genesis == nil {
+			return nil, errors.New("forking is only supported on dev chains")
+		}
+		var (
+			header *types.Header
+			err    error
+		)
+		if fork, header, err = dialFork(config.ForkURL, config.ForkBlock); err != nil {
+			return nil, fmt.Errorf("failed to connect to fork node: %v", err)
+		}
+		log.Info("Forking remote chain state", "url", config.ForkURL, "number", header.Number, "hash", header.Hash())
+		config.Genesis = core.ForkGenesisBlock(config.Genesis, header)
+	}
 	// Assemble the Ethereum object
 	chainDb, err := CreateDB(ctx, config, "chaindata")
 	if err != nil {
 		return nil, err
 	}
+	if freezer := FreezerPath(ctx.ResolvePath, "chaindata", config.DatabaseFreezer); freezer != "" {
+		frdb, err := rawdb.NewDatabaseWithFreezer(chainDb, freezer)
+		if err != nil {
+			chainDb.Close()
+			return nil, fmt.Errorf("failed to open ancient database: %v", err)
+		}
+		chainDb = frdb
+	}
+	if err := pruner.RecoverPruning(chainDb); err != nil {
+		return nil, fmt.Errorf("failed to resume state pruning: %v", err)
+	}
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlock(chainDb, config.Genesis)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -193,10 +225,17 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		}
 		rawdb.WriteDatabaseVersion(chainDb, core.BlockChainVersion)
 	}
+	if config.TrieJournal != "" {
+		config.TrieJournal = ctx.ResolvePath(config.TrieJournal)
+	}
 	var (
 		vmConfig    = vm.Config{EnablePreimageRecording: config.EnablePreimageRecording}
-		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout}
+		cacheConfig = &core.CacheConfig{Disabled: config.NoPruning, TrieNodeLimit: config.TrieCache, TrieTimeLimit: config.TrieTimeout, Snapshot: !config.NoSnapshot, TxLookupLimit: config.TxLookupLimit, TrieJournal: config.TrieJournal, TrieRejournal: config.TrieRejournal}
 	)
+	if fork != nil {
+		cacheConfig.Fork = fork
+		eth.fork = fork
+	}
 	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, eth.chainConfig, eth.engine, vmConfig)
 	if err != nil {
 		return nil, err
@@ -212,14 +251,27 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.PoolJournal != "" {
+		config.TxPool.PoolJournal = ctx.ResolvePath(config.TxPool.PoolJournal)
+	}
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
 
 	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb); err != nil {
 		return nil, err
 	}
+	// Serve the state snapshot to the peers, retrieving it from them if requested
+	var syncer *snap.Syncer
+	if config.SnapSync {
+		syncer = snap.NewSyncer(chainDb, eth.blockchain.Snapshots())
+		eth.protocolManager.downloader.SetStateSyncer(syncer)
+	}
+	eth.protocolManager.SubProtocols = append(eth.protocolManager.SubProtocols, snap.MakeProtocols(eth.blockchain.StateCache().TrieDB(), syncer)...)
 
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, config.MinerRecommit, config.MinerGasFloor, config.MinerGasCeil)
 	eth.miner.SetExtra(makeExtraData(config.MinerExtraData))
+	if err := eth.miner.SetTxOrdering(miner.TxOrdering{Mode: config.MinerTxOrder, Priority: config.MinerPriority}); err != nil {
+		return nil, err
+	}
 
 	eth.APIBackend = &EthAPIBackend{eth, nil}
 	gpoParams := config.GPO
@@ -260,6 +312,21 @@ func CreateDB(ctx *node.ServiceContext, config *Config, name string) (ethdb.Data
 	return db, nil
 }
 
+// FreezerPath returns the location of the ancient chain data of a database,
+// resolving relative paths with the given function. Unless configured otherwise,
+// the freezer lives inside the database directory. The path is empty for
+// ephemeral databases.
+func FreezerPath(resolve func(string) string, name, freezer string) string {
+	dir := resolve(name)
+	if dir == "" {
+		return ""
+	}
+	if freezer == "" {
+		return filepath.Join(dir, "ancient")
+	}
+	return resolve(freezer)
+}
+
 // CreateConsensusEngine creates the required type of consensus engine instance for an Ethereum service
 func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainConfig, config *ethash.Config, notify []string, noverify bool, db ethdb.Database) consensus.Engine {
 	// If proof-of-authority is requested, set it up
@@ -302,6 +369,14 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Append the test isolation APIs of dev chains
+	if thunder := s.chainConfig.Thunder; thunder != nil && thunder.Dev {
+		apis = append(apis, rpc.API{
+			Namespace: "dev",
+			Version:   "1.0",
+			Service:   NewPrivateDevAPI(s),
+		})
+	}
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -447,6 +522,12 @@ func (s *Ethereum) StopMining() {
 	s.miner.Stop()
 }
 
+// Impersonating returns whether transactions from the given account are accepted
+// without a key.
+func (s *Ethereum) Impersonating(addr common.Address) bool {
+	return s.txPool.Impersonating(addr)
+}
+
 func (s *Ethereum) IsMining() bool      { return s.miner.Mining() }
 func (s *Ethereum) Miner() *miner.Miner { return s.miner }
 
@@ -508,7 +589,9 @@ func (s *Ethereum) Stop() error {
 	s.txPool.Stop()
 	s.miner.Stop()
 	s.eventMux.Stop()
-
+	if s.fork != nil {
+		s.fork.client.Close()
+	}
 	s.chainDb.Close()
 	close(s.shutdownChan)
 	return nil
@@ -48,6 +48,8 @@ var DefaultConfig = Config{
 	DatabaseCache: 768,
 	TrieCache:     256,
 	TrieTimeout:   60 * time.Minute,
+	TrieJournal:   "triecache.rlp",
+	TrieRejournal: 5 * time.Minute,
 	MinerGasFloor: 8000000,
 	MinerGasCeil:  8000000,
 	MinerGasPrice: big.NewInt(params.GWei),
@@ -86,6 +88,17 @@ type Config struct {
 	SyncMode  downloader.SyncMode
 	NoPruning bool
 
+	// NoSnapshot disables the flat state snapshot, reading all state from the trie
+	NoSnapshot bool
+
+	// TxLookupLimit is the number of recent blocks to keep the transaction lookups
+	// of, 0 for all blocks
+	TxLookupLimit uint64 `toml:",omitempty"`
+
+	// SnapSync retrieves the state during fast sync as ranges of the flat snapshot
+	// from the peers speaking the snap protocol, instead of trie node by node
+	SnapSync bool `toml:",omitempty"`
+
 	// Light client options
 	LightServ  int `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightPeers int `toml:",omitempty"` // Maximum number of LES client peers
@@ -94,8 +107,11 @@ type Config struct {
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
 	DatabaseCache      int
+	DatabaseFreezer    string
 	TrieCache          int
 	TrieTimeout        time.Duration
+	TrieJournal        string        // Journal of the in-memory tries to survive crashes with
+	TrieRejournal      time.Duration // Time interval to regenerate the trie journal
 
 	// Mining-related options
 	Etherbase      common.Address `toml:",omitempty"`
@@ -106,6 +122,8 @@ type Config struct {
 	MinerGasPrice  *big.Int
 	MinerRecommit  time.Duration
 	MinerNoverify  bool
+	MinerTxOrder   string           `toml:",omitempty"` // Ordering of the transactions of new blocks
+	MinerPriority  []common.Address `toml:",omitempty"` // Senders whose transactions are mined first
 
 	// Ethash options
 	Ethash ethash.Config
@@ -119,6 +137,10 @@ type Config struct {
 	// Enables tracking of SHA3 preimages in the VM
 	EnablePreimageRecording bool
 
+	// Forking options of dev chains
+	ForkURL   string `toml:",omitempty"` // RPC endpoint of the node to fork the state of
+	ForkBlock uint64 `toml:",omitempty"` // Block to fork the state at, the latest if zero
+
 	// Miscellaneous options
 	DocRoot string `toml:"-"`
 }
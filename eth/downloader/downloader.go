@@ -109,8 +109,9 @@ type Downloader struct {
 	syncStatsState       stateSyncStats
 	syncStatsLock        sync.RWMutex // Lock protecting the sync stats fields
 
-	lightchain LightChain
-	blockchain BlockChain
+	lightchain  LightChain
+	blockchain  BlockChain
+	stateSyncer StateSyncer // Optional retriever of the state ahead of the trie node sync
 
 	// Callbacks
 	dropPeer peerDropFn // Drops a peer for misbehaving
@@ -197,6 +198,14 @@ type BlockChain interface {
 	InsertReceiptChain(types.Blocks, []types.Receipts) (int, error)
 }
 
+// StateSyncer retrieves the state of a block by other means than the trie node
+// sync, which only fills in what it left out afterwards.
+type StateSyncer interface {
+	// Sync retrieves the state of the given root, returning early if cancel is
+	// closed.
+	Sync(root common.Hash, cancel <-chan struct{}) error
+}
+
 // New creates a new downloader to fetch hashes and blocks from remote peers.
 func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockChain, lightchain LightChain, dropPeer peerDropFn) *Downloader {
 	if lightchain == nil {
@@ -233,6 +242,12 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 	return dl
 }
 
+// SetStateSyncer sets the retriever of the state to run ahead of the trie node
+// sync during fast sync. It must be called before any sync is started.
+func (d *Downloader) SetStateSyncer(syncer StateSyncer) {
+	d.stateSyncer = syncer
+}
+
 // Progress retrieves the synchronisation boundaries, specifically the origin
 // block where synchronisation started at (may have failed/suspended); the block
 // or header sync is currently at; and the latest known block which the sync targets.
@@ -685,6 +685,51 @@ func testCanonicalSynchronisation(t *testing.T, protocol int, mode SyncMode) {
 	assertOwnChain(t, tester, targetBlocks+1)
 }
 
+// stubStateSyncer is a state syncer recording the roots it was asked to sync,
+// leaving the whole state to the trie node sync.
+type stubStateSyncer struct {
+	roots []common.Hash
+	lock  sync.Mutex
+}
+
+func (s *stubStateSyncer) Sync(root common.Hash, cancel <-chan struct{}) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.roots = append(s.roots, root)
+	return errors.New("no state available")
+}
+
+// Tests that a state syncer runs ahead of the trie node sync during fast sync,
+// the trie node sync retrieving whatever it left out.
+func TestStateSyncer63(t *testing.T) { testStateSyncer(t, 63) }
+func TestStateSyncer64(t *testing.T) { testStateSyncer(t, 64) }
+
+func testStateSyncer(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	syncer := new(stubStateSyncer)
+	tester.downloader.SetStateSyncer(syncer)
+
+	targetBlocks := blockCacheItems - 15
+	hashes, headers, blocks, receipts := tester.makeChain(targetBlocks, 0, tester.genesis, nil, false)
+
+	tester.newPeer("peer", protocol, hashes, headers, blocks, receipts)
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	assertOwnChain(t, tester, targetBlocks+1)
+
+	syncer.lock.Lock()
+	defer syncer.lock.Unlock()
+	if len(syncer.roots) == 0 {
+		t.Fatalf("state syncer not invoked")
+	}
+}
+
 // Tests that if a large batch of blocks are being downloaded, it is throttled
 // until the cached blocks are retrieved.
 func TestThrottling62(t *testing.T)     { testThrottling(t, 62, FullSync) }
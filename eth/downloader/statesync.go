@@ -214,6 +214,7 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 type stateSync struct {
 	d *Downloader // Downloader instance to access and manage current peerset
 
+	root   common.Hash                // State root currently being synced
 	sched  *trie.Sync                 // State trie sync scheduler defining the tasks
 	keccak hash.Hash                  // Keccak256 hasher to verify deliveries with
 	tasks  map[common.Hash]*stateTask // Set of tasks currently queued for retrieval
@@ -239,7 +240,7 @@ type stateTask struct {
 func newStateSync(d *Downloader, root common.Hash) *stateSync {
 	return &stateSync{
 		d:       d,
-		sched:   state.NewStateSync(root, d.stateDB),
+		root:    root,
 		keccak:  sha3.NewKeccak256(),
 		tasks:   make(map[common.Hash]*stateTask),
 		deliver: make(chan *stateReq),
@@ -250,12 +251,48 @@ func newStateSync(d *Downloader, root common.Hash) *stateSync {
 
 // run starts the task assignment and response processing loop, blocking until
 // it finishes, and finally notifying any goroutines waiting for the loop to
-// finish.
+// finish. If there is a state syncer, it runs first and the trie node sync only
+// retrieves what it left out.
 func (s *stateSync) run() {
-	s.err = s.loop()
+	if s.err = s.syncState(); s.err == nil {
+		s.sched = state.NewStateSync(s.root, s.d.stateDB)
+		s.err = s.loop()
+	}
 	close(s.done)
 }
 
+// syncState retrieves the state with the state syncer of the downloader, if
+// there is one. Failures are left to the trie node sync to recover from.
+func (s *stateSync) syncState() error {
+	if s.d.stateSyncer == nil {
+		return nil
+	}
+	s.d.cancelLock.RLock()
+	cancelCh := s.d.cancelCh
+	s.d.cancelLock.RUnlock()
+
+	cancel, done := make(chan struct{}), make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-s.cancel:
+		case <-cancelCh:
+		case <-done:
+			return
+		}
+		close(cancel)
+	}()
+	if err := s.d.stateSyncer.Sync(s.root, cancel); err != nil {
+		select {
+		case <-cancel:
+			return errCancelStateFetch
+		default:
+		}
+		log.Warn("State sync failed, falling back to trie sync", "err", err)
+	}
+	return nil
+}
+
 // Wait blocks until the sync is done or canceled.
 func (s *stateSync) Wait() error {
 	<-s.done
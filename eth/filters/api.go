@@ -28,6 +28,7 @@ import (
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
@@ -168,6 +169,82 @@ func (api *PublicFilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// TxLifecycleCriteria selects the transactions of a lifecycle subscription.
+// Transactions of any sender or hash match the empty criteria.
+type TxLifecycleCriteria struct {
+	Senders []common.Address `json:"senders"`
+	Hashes  []common.Hash    `json:"hashes"`
+}
+
+// txLifecycleNotification is the notification of a transaction reaching a new
+// stage of its life in the transaction pool.
+type txLifecycleNotification struct {
+	Hash   common.Hash           `json:"hash"`
+	From   common.Address        `json:"from"`
+	Nonce  hexutil.Uint64        `json:"nonce"`
+	Status core.TxLifecycleStage `json:"status"`
+}
+
+// TransactionLifecycle creates a subscription that is triggered each time a
+// transaction of the pool is queued, promoted, included, replaced or dropped,
+// along with the reason it was dropped for.
+func (api *PublicFilterAPI) TransactionLifecycle(ctx context.Context, crit *TxLifecycleCriteria) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	senders := make(map[common.Address]struct{})
+	hashes := make(map[common.Hash]struct{})
+	if crit != nil {
+		for _, addr := range crit.Senders {
+			senders[addr] = struct{}{}
+		}
+		for _, hash := range crit.Hashes {
+			hashes[hash] = struct{}{}
+		}
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		stages := make(chan core.TxLifecycleEvent, 128)
+		stagesSub := api.events.SubscribeTxLifecycle(stages)
+
+		for {
+			select {
+			case ev := <-stages:
+				for _, tx := range ev.Txs {
+					hash := tx.Hash()
+					if _, ok := hashes[hash]; len(hashes) > 0 && !ok {
+						continue
+					}
+					var signer types.Signer = types.HomesteadSigner{}
+					if tx.Protected() {
+						signer = types.NewEIP155Signer(tx.ChainId())
+					}
+					from, _ := types.Sender(signer, tx)
+					if _, ok := senders[from]; len(senders) > 0 && !ok {
+						continue
+					}
+					notifier.Notify(rpcSub.ID, &txLifecycleNotification{
+						Hash:   hash,
+						From:   from,
+						Nonce:  hexutil.Uint64(tx.Nonce()),
+						Status: ev.Stage,
+					})
+				}
+			case <-rpcSub.Err():
+				stagesSub.Unsubscribe()
+				return
+			case <-notifier.Closed():
+				stagesSub.Unsubscribe()
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
 //
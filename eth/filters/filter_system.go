@@ -53,6 +53,9 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// TxLifecycleSubscription queries the stages pooled transactions go
+	// through until they are included or dropped
+	TxLifecycleSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -82,6 +85,7 @@ type subscription struct {
 	logs      chan []*types.Log
 	hashes    chan []common.Hash
 	headers   chan *types.Header
+	stages    chan core.TxLifecycleEvent
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -96,6 +100,7 @@ type EventSystem struct {
 
 	// Subscriptions
 	txsSub        event.Subscription         // Subscription for new transaction event
+	stagesSub     event.Subscription         // Subscription for transaction lifecycle event
 	logsSub       event.Subscription         // Subscription for new log event
 	rmLogsSub     event.Subscription         // Subscription for removed log event
 	chainSub      event.Subscription         // Subscription for new chain event
@@ -105,6 +110,7 @@ type EventSystem struct {
 	install   chan *subscription         // install filter for event notification
 	uninstall chan *subscription         // remove filter for event notification
 	txsCh     chan core.NewTxsEvent      // Channel to receive new transactions event
+	stagesCh  chan core.TxLifecycleEvent // Channel to receive transaction lifecycle event
 	logsCh    chan []*types.Log          // Channel to receive new log event
 	rmLogsCh  chan core.RemovedLogsEvent // Channel to receive removed log event
 	chainCh   chan core.ChainEvent       // Channel to receive new chain event
@@ -124,6 +130,7 @@ func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventS
 		install:   make(chan *subscription),
 		uninstall: make(chan *subscription),
 		txsCh:     make(chan core.NewTxsEvent, txChanSize),
+		stagesCh:  make(chan core.TxLifecycleEvent, txChanSize),
 		logsCh:    make(chan []*types.Log, logsChanSize),
 		rmLogsCh:  make(chan core.RemovedLogsEvent, rmLogsChanSize),
 		chainCh:   make(chan core.ChainEvent, chainEvChanSize),
@@ -131,6 +138,7 @@ func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventS
 
 	// Subscribe events
 	m.txsSub = m.backend.SubscribeNewTxsEvent(m.txsCh)
+	m.stagesSub = m.backend.SubscribeTxLifecycleEvent(m.stagesCh)
 	m.logsSub = m.backend.SubscribeLogsEvent(m.logsCh)
 	m.rmLogsSub = m.backend.SubscribeRemovedLogsEvent(m.rmLogsCh)
 	m.chainSub = m.backend.SubscribeChainEvent(m.chainCh)
@@ -138,7 +146,7 @@ func NewEventSystem(mux *event.TypeMux, backend Backend, lightMode bool) *EventS
 	m.pendingLogSub = m.mux.Subscribe(core.PendingLogsEvent{})
 
 	// Make sure none of the subscriptions are empty
-	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil ||
+	if m.txsSub == nil || m.stagesSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil ||
 		m.pendingLogSub.Closed() {
 		log.Crit("Subscribe for event system failed")
 	}
@@ -175,6 +183,7 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.stages:
 			}
 		}
 
@@ -314,6 +323,23 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscript
 	return es.subscribe(sub)
 }
 
+// SubscribeTxLifecycle creates a subscription that writes the stages pooled
+// transactions go through.
+func (es *EventSystem) SubscribeTxLifecycle(stages chan core.TxLifecycleEvent) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       TxLifecycleSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		headers:   make(chan *types.Header),
+		stages:    stages,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 // broadcast event to filters that match criteria.
@@ -355,6 +381,10 @@ func (es *EventSystem) broadcast(filters filterIndex, ev interface{}) {
 		for _, f := range filters[PendingTransactionsSubscription] {
 			f.hashes <- hashes
 		}
+	case core.TxLifecycleEvent:
+		for _, f := range filters[TxLifecycleSubscription] {
+			f.stages <- e
+		}
 	case core.ChainEvent:
 		for _, f := range filters[BlocksSubscription] {
 			f.headers <- e.Block.Header()
@@ -450,6 +480,7 @@ func (es *EventSystem) eventLoop() {
 	defer func() {
 		es.pendingLogSub.Unsubscribe()
 		es.txsSub.Unsubscribe()
+		es.stagesSub.Unsubscribe()
 		es.logsSub.Unsubscribe()
 		es.rmLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
@@ -465,6 +496,8 @@ func (es *EventSystem) eventLoop() {
 		// Handle subscribed events
 		case ev := <-es.txsCh:
 			es.broadcast(index, ev)
+		case ev := <-es.stagesCh:
+			es.broadcast(index, ev)
 		case ev := <-es.logsCh:
 			es.broadcast(index, ev)
 		case ev := <-es.rmLogsCh:
@@ -500,6 +533,8 @@ func (es *EventSystem) eventLoop() {
 		// System stopped
 		case <-es.txsSub.Err():
 			return
+		case <-es.stagesSub.Err():
+			return
 		case <-es.logsSub.Err():
 			return
 		case <-es.rmLogsSub.Err():
@@ -0,0 +1,93 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// forkRequestTimeout is the time allowed for a single request to the node a
+// chain is forked from.
+const forkRequestTimeout = 30 * time.Second
+
+// remoteState is the state of a remote node at the block a local chain is forked
+// at, retrieved over its RPC API.
+type remoteState struct {
+	client *ethclient.Client
+	number *big.Int
+}
+
+// dialFork connects to the node to fork the state of, returning its state and
+// the header of the block to fork at, or of the latest one if number is zero.
+func dialFork(url string, number uint64) (*remoteState, *types.Header, error) {
+	client, err := ethclient.Dial(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), forkRequestTimeout)
+	defer cancel()
+
+	var block *big.Int
+	if number > 0 {
+		block = new(big.Int).SetUint64(number)
+	}
+	header, err := client.HeaderByNumber(ctx, block)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return &remoteState{client: client, number: header.Number}, header, nil
+}
+
+// Account implements state.RemoteState, retrieving the nonce, balance and code
+// of an account.
+func (s *remoteState) Account(addr common.Address) (uint64, *big.Int, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), forkRequestTimeout)
+	defer cancel()
+
+	nonce, err := s.client.NonceAt(ctx, addr, s.number)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	balance, err := s.client.BalanceAt(ctx, addr, s.number)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	code, err := s.client.CodeAt(ctx, addr, s.number)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	return nonce, balance, code, nil
+}
+
+// Storage implements state.RemoteState, retrieving a storage slot of an account.
+func (s *remoteState) Storage(addr common.Address, slot common.Hash) (common.Hash, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), forkRequestTimeout)
+	defer cancel()
+
+	value, err := s.client.StorageAt(ctx, addr, slot, s.number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(value), nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ForkTestService serves the state of a chain over the subset of the eth RPC
+// namespace needed to fork it.
+type ForkTestService struct {
+	chain *core.BlockChain
+}
+
+func (s *ForkTestService) header(number rpc.BlockNumber) *types.Header {
+	if number == rpc.LatestBlockNumber {
+		return s.chain.CurrentHeader()
+	}
+	return s.chain.GetHeaderByNumber(uint64(number))
+}
+
+func (s *ForkTestService) state(number rpc.BlockNumber) (*state.StateDB, error) {
+	return s.chain.StateAt(s.header(number).Root)
+}
+
+func (s *ForkTestService) GetBlockByNumber(number rpc.BlockNumber, full bool) *types.Header {
+	return s.header(number)
+}
+
+func (s *ForkTestService) GetBalance(addr common.Address, number rpc.BlockNumber) (*hexutil.Big, error) {
+	statedb, err := s.state(number)
+	if err != nil {
+		return nil, err
+	}
+	return (*hexutil.Big)(statedb.GetBalance(addr)), nil
+}
+
+func (s *ForkTestService) GetTransactionCount(addr common.Address, number rpc.BlockNumber) (hexutil.Uint64, error) {
+	statedb, err := s.state(number)
+	if err != nil {
+		return 0, err
+	}
+	return hexutil.Uint64(statedb.GetNonce(addr)), nil
+}
+
+func (s *ForkTestService) GetCode(addr common.Address, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	statedb, err := s.state(number)
+	if err != nil {
+		return nil, err
+	}
+	return statedb.GetCode(addr), nil
+}
+
+func (s *ForkTestService) GetStorageAt(addr common.Address, slot string, number rpc.BlockNumber) (hexutil.Bytes, error) {
+	statedb, err := s.state(number)
+	if err != nil {
+		return nil, err
+	}
+	value := statedb.GetState(addr, common.HexToHash(slot))
+	return value[:], nil
+}
+
+// Tests that a chain forked from a remote node serves the remote state at the
+// fork block, overridden by its own genesis allocations.
+func TestForkedChain(t *testing.T) {
+	var (
+		contract = common.HexToAddress("0xc0de")
+		sender   = common.HexToAddress("0x5e4d")
+		faucet   = common.HexToAddress("0xfa0c")
+		code     = []byte{0x60, 0x00, 0x60, 0x00, 0xf3}
+		slot     = common.HexToHash("0x01")
+	)
+	// Create the remote chain, changing the state after the fork block
+	remoteDb := ethdb.NewMemDatabase()
+	remoteGenesis := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			contract: {Balance: big.NewInt(1), Code: code, Storage: map[common.Hash]common.Hash{slot: common.HexToHash("0x2a")}},
+			sender:   {Balance: big.NewInt(1000), Nonce: 7},
+			faucet:   {Balance: big.NewInt(1)},
+		},
+	}
+	genesis := remoteGenesis.MustCommit(remoteDb)
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), remoteDb, 2, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(sender)
+	})
+	remoteChain, _ := core.NewBlockChain(remoteDb, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	defer remoteChain.Stop()
+	if _, err := remoteChain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert remote chain: %v", err)
+	}
+	// Serve the remote chain over IPC
+	dir, err := ioutil.TempDir("", "eth-fork")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	endpoint := filepath.Join(dir, "remote.ipc")
+	listener, server, err := rpc.StartIPCEndpoint(endpoint, []rpc.API{{
+		Namespace: "eth",
+		Version:   "1.0",
+		Service:   &ForkTestService{chain: remoteChain},
+		Public:    true,
+	}})
+	if err != nil {
+		t.Fatalf("failed to start remote endpoint: %v", err)
+	}
+	defer server.Stop()
+	defer listener.Close()
+
+	// Fork the remote chain at its first block, and check the state the local
+	// chain sees
+	fork, header, err := dialFork(endpoint, 1)
+	if err != nil {
+		t.Fatalf("failed to connect to remote: %v", err)
+	}
+	defer fork.client.Close()
+
+	if header.Hash() != blocks[0].Hash() {
+		t.Fatalf("fork block mismatch: have %x, want %x", header.Hash(), blocks[0].Hash())
+	}
+	localGenesis := core.ForkGenesisBlock(&core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{faucet: {Balance: big.NewInt(1000000)}},
+	}, header)
+
+	db := ethdb.NewMemDatabase()
+	localGenesis.MustCommit(db)
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{TrieNodeLimit: 256, TrieTimeLimit: 5 * time.Minute, Fork: fork}, params.TestChainConfig, ethash.NewFaker(), vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to create forked chain: %v", err)
+	}
+	defer chain.Stop()
+
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("failed to open forked state: %v", err)
+	}
+	if value := statedb.GetState(contract, slot); value != common.HexToHash("0x2a") {
+		t.Errorf("contract storage mismatch: have %x, want 0x2a", value)
+	}
+	if have := statedb.GetCode(contract); string(have) != string(code) {
+		t.Errorf("contract code mismatch: have %x, want %x", have, code)
+	}
+	if nonce := statedb.GetNonce(sender); nonce != 7 {
+		t.Errorf("sender nonce mismatch: have %d, want 7", nonce)
+	}
+	// The sender mined the first remote block, but not the second one
+	want := new(big.Int).Add(big.NewInt(1000), ethash.ByzantiumBlockReward)
+	if balance := statedb.GetBalance(sender); balance.Cmp(want) != 0 {
+		t.Errorf("sender balance mismatch: have %v, want %v", balance, want)
+	}
+	if balance := statedb.GetBalance(faucet); balance.Cmp(big.NewInt(1000000)) != 0 {
+		t.Errorf("faucet balance mismatch: have %v, want 1000000", balance)
+	}
+}
@@ -23,13 +23,19 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
 		NoPruning               bool
-		LightServ               int  `toml:",omitempty"`
-		LightPeers              int  `toml:",omitempty"`
-		SkipBcVersionCheck      bool `toml:"-"`
-		DatabaseHandles         int  `toml:"-"`
+		NoSnapshot              bool
+		TxLookupLimit           uint64 `toml:",omitempty"`
+		SnapSync                bool   `toml:",omitempty"`
+		LightServ               int    `toml:",omitempty"`
+		LightPeers              int    `toml:",omitempty"`
+		SkipBcVersionCheck      bool   `toml:"-"`
+		DatabaseHandles         int    `toml:"-"`
 		DatabaseCache           int
+		DatabaseFreezer         string
 		TrieCache               int
 		TrieTimeout             time.Duration
+		TrieJournal             string
+		TrieRejournal           time.Duration
 		Etherbase               common.Address `toml:",omitempty"`
 		MinerNotify             []string       `toml:",omitempty"`
 		MinerExtraData          hexutil.Bytes  `toml:",omitempty"`
@@ -38,10 +44,14 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		MinerGasPrice           *big.Int
 		MinerRecommit           time.Duration
 		MinerNoverify           bool
+		MinerTxOrder            string           `toml:",omitempty"`
+		MinerPriority           []common.Address `toml:",omitempty"`
 		Ethash                  ethash.Config
 		TxPool                  core.TxPoolConfig
 		GPO                     gasprice.Config
 		EnablePreimageRecording bool
+		ForkURL                 string `toml:",omitempty"`
+		ForkBlock               uint64 `toml:",omitempty"`
 		DocRoot                 string `toml:"-"`
 	}
 	var enc Config
@@ -49,13 +59,19 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
 	enc.NoPruning = c.NoPruning
+	enc.NoSnapshot = c.NoSnapshot
+	enc.TxLookupLimit = c.TxLookupLimit
+	enc.SnapSync = c.SnapSync
 	enc.LightServ = c.LightServ
 	enc.LightPeers = c.LightPeers
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
+	enc.DatabaseFreezer = c.DatabaseFreezer
 	enc.TrieCache = c.TrieCache
 	enc.TrieTimeout = c.TrieTimeout
+	enc.TrieJournal = c.TrieJournal
+	enc.TrieRejournal = c.TrieRejournal
 	enc.Etherbase = c.Etherbase
 	enc.MinerNotify = c.MinerNotify
 	enc.MinerExtraData = c.MinerExtraData
@@ -64,10 +80,14 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.MinerGasPrice = c.MinerGasPrice
 	enc.MinerRecommit = c.MinerRecommit
 	enc.MinerNoverify = c.MinerNoverify
+	enc.MinerTxOrder = c.MinerTxOrder
+	enc.MinerPriority = c.MinerPriority
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
+	enc.ForkURL = c.ForkURL
+	enc.ForkBlock = c.ForkBlock
 	enc.DocRoot = c.DocRoot
 	return &enc, nil
 }
@@ -79,13 +99,19 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
 		NoPruning               *bool
-		LightServ               *int  `toml:",omitempty"`
-		LightPeers              *int  `toml:",omitempty"`
-		SkipBcVersionCheck      *bool `toml:"-"`
-		DatabaseHandles         *int  `toml:"-"`
+		NoSnapshot              *bool
+		TxLookupLimit           *uint64 `toml:",omitempty"`
+		SnapSync                *bool   `toml:",omitempty"`
+		LightServ               *int    `toml:",omitempty"`
+		LightPeers              *int    `toml:",omitempty"`
+		SkipBcVersionCheck      *bool   `toml:"-"`
+		DatabaseHandles         *int    `toml:"-"`
 		DatabaseCache           *int
+		DatabaseFreezer         *string
 		TrieCache               *int
 		TrieTimeout             *time.Duration
+		TrieJournal             *string
+		TrieRejournal           *time.Duration
 		Etherbase               *common.Address `toml:",omitempty"`
 		MinerNotify             []string        `toml:",omitempty"`
 		MinerExtraData          *hexutil.Bytes  `toml:",omitempty"`
@@ -94,10 +120,14 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		MinerGasPrice           *big.Int
 		MinerRecommit           *time.Duration
 		MinerNoverify           *bool
+		MinerTxOrder            *string          `toml:",omitempty"`
+		MinerPriority           []common.Address `toml:",omitempty"`
 		Ethash                  *ethash.Config
 		TxPool                  *core.TxPoolConfig
 		GPO                     *gasprice.Config
 		EnablePreimageRecording *bool
+		ForkURL                 *string `toml:",omitempty"`
+		ForkBlock               *uint64 `toml:",omitempty"`
 		DocRoot                 *string `toml:"-"`
 	}
 	var dec Config
@@ -116,6 +146,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPruning != nil {
 		c.NoPruning = *dec.NoPruning
 	}
+	if dec.NoSnapshot != nil {
+		c.NoSnapshot = *dec.NoSnapshot
+	}
+	if dec.TxLookupLimit != nil {
+		c.TxLookupLimit = *dec.TxLookupLimit
+	}
+	if dec.SnapSync != nil {
+		c.SnapSync = *dec.SnapSync
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -131,12 +170,21 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseCache != nil {
 		c.DatabaseCache = *dec.DatabaseCache
 	}
+	if dec.DatabaseFreezer != nil {
+		c.DatabaseFreezer = *dec.DatabaseFreezer
+	}
 	if dec.TrieCache != nil {
 		c.TrieCache = *dec.TrieCache
 	}
 	if dec.TrieTimeout != nil {
 		c.TrieTimeout = *dec.TrieTimeout
 	}
+	if dec.TrieJournal != nil {
+		c.TrieJournal = *dec.TrieJournal
+	}
+	if dec.TrieRejournal != nil {
+		c.TrieRejournal = *dec.TrieRejournal
+	}
 	if dec.Etherbase != nil {
 		c.Etherbase = *dec.Etherbase
 	}
@@ -161,6 +209,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.MinerNoverify != nil {
 		c.MinerNoverify = *dec.MinerNoverify
 	}
+	if dec.MinerTxOrder != nil {
+		c.MinerTxOrder = *dec.MinerTxOrder
+	}
+	if dec.MinerPriority != nil {
+		c.MinerPriority = dec.MinerPriority
+	}
 	if dec.Ethash != nil {
 		c.Ethash = *dec.Ethash
 	}
@@ -173,6 +227,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.EnablePreimageRecording != nil {
 		c.EnablePreimageRecording = *dec.EnablePreimageRecording
 	}
+	if dec.ForkURL != nil {
+		c.ForkURL = *dec.ForkURL
+	}
+	if dec.ForkBlock != nil {
+		c.ForkBlock = *dec.ForkBlock
+	}
 	if dec.DocRoot != nil {
 		c.DocRoot = *dec.DocRoot
 	}
@@ -0,0 +1,313 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// softResponseLimit is the target maximum size of replies to data retrievals.
+	softResponseLimit = 2 * 1024 * 1024
+
+	// maxCodeLookups is the maximum number of bytecodes to serve. This number is
+	// there to limit the number of disk lookups.
+	maxCodeLookups = 1024
+
+	// maxStorageLookups is the maximum number of accounts to serve the storage
+	// of. This number is there to limit the number of trie lookups.
+	maxStorageLookups = 1024
+
+	// storageAccountSize is the size every account served the storage of counts
+	// with against the response limit, even if its storage is empty.
+	storageAccountSize = common.HashLength
+)
+
+// MakeProtocols constructs the snap protocols, serving the state tries of the
+// given database and delivering the responses of the remote peers to the syncer,
+// if there is one.
+func MakeProtocols(triedb *trie.Database, syncer *Syncer) []p2p.Protocol {
+	protocols := make([]p2p.Protocol, len(ProtocolVersions))
+	for i, version := range ProtocolVersions {
+		version := version // Closure for the run
+		protocols[i] = p2p.Protocol{
+			Name:    ProtocolName,
+			Version: version,
+			Length:  ProtocolLengths[i],
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				return Handle(triedb, syncer, NewPeer(version, p, rw))
+			},
+		}
+	}
+	return protocols
+}
+
+// Handle is the callback invoked to manage the life cycle of a snap peer. When
+// this function terminates, the peer is disconnected.
+func Handle(triedb *trie.Database, syncer *Syncer, peer *Peer) error {
+	if syncer != nil {
+		if err := syncer.Register(peer); err != nil {
+			return err
+		}
+		defer syncer.Unregister(peer.id)
+	}
+	for {
+		if err := handleMessage(triedb, syncer, peer); err != nil {
+			peer.logger.Debug("Snap message handling failed", "err", err)
+			return err
+		}
+	}
+}
+
+// handleMessage is invoked whenever an inbound message is received from a remote
+// peer. The remote connection is torn down upon returning any error.
+func handleMessage(triedb *trie.Database, syncer *Syncer, peer *Peer) error {
+	// Read the next message from the remote peer, and ensure it's fully consumed
+	msg, err := peer.rw.ReadMsg()
+	if err != nil {
+		return err
+	}
+	if msg.Size > ProtocolMaxMsgSize {
+		return fmt.Errorf("%v: %v > %v", errMsgTooLarge, msg.Size, ProtocolMaxMsgSize)
+	}
+	defer msg.Discard()
+
+	// Handle the message depending on its contents
+	switch msg.Code {
+	case GetAccountRangeMsg:
+		var req getAccountRangeData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		return p2p.Send(peer.rw, AccountRangeMsg, serviceGetAccountRange(triedb, &req))
+
+	case AccountRangeMsg:
+		res := new(accountRangeData)
+		if err := msg.Decode(res); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		if syncer != nil {
+			syncer.deliver(peer.id, res.ID, res)
+		}
+		return nil
+
+	case GetStorageRangesMsg:
+		var req getStorageRangesData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		return p2p.Send(peer.rw, StorageRangesMsg, serviceGetStorageRanges(triedb, &req))
+
+	case StorageRangesMsg:
+		res := new(storageRangesData)
+		if err := msg.Decode(res); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		if syncer != nil {
+			syncer.deliver(peer.id, res.ID, res)
+		}
+		return nil
+
+	case GetByteCodesMsg:
+		var req getByteCodesData
+		if err := msg.Decode(&req); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		return p2p.Send(peer.rw, ByteCodesMsg, serviceGetByteCodes(triedb, &req))
+
+	case ByteCodesMsg:
+		res := new(byteCodesData)
+		if err := msg.Decode(res); err != nil {
+			return fmt.Errorf("%v: message %v: %v", errDecode, msg, err)
+		}
+		if syncer != nil {
+			syncer.deliver(peer.id, res.ID, res)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%v: %v", errInvalidMsgCode, msg.Code)
+	}
+}
+
+// serviceGetAccountRange assembles the response to an account range query. The
+// first account at or after the limit is included as well, proving that there
+// are no more accounts in the range. An empty response without proofs signals
+// that the state is not available.
+func serviceGetAccountRange(triedb *trie.Database, req *getAccountRangeData) *accountRangeData {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	res := &accountRangeData{ID: req.ID}
+
+	tr, err := trie.New(req.Root, triedb)
+	if err != nil {
+		return res
+	}
+	var (
+		accounts []*accountData
+		size     uint64
+	)
+	it := trie.NewIterator(tr.NodeIterator(req.Origin[:]))
+	for it.Next() {
+		hash := common.BytesToHash(it.Key)
+		accounts = append(accounts, &accountData{Hash: hash, Body: common.CopyBytes(it.Value)})
+
+		size += uint64(common.HashLength + len(it.Value))
+		if bytes.Compare(hash[:], req.Limit[:]) >= 0 || size >= req.Bytes {
+			break
+		}
+	}
+	if it.Err != nil {
+		return res
+	}
+	proof := newProofList()
+	if err := tr.Prove(req.Origin[:], 0, proof); err != nil {
+		return res
+	}
+	if len(accounts) > 0 {
+		if err := tr.Prove(accounts[len(accounts)-1].Hash[:], 0, proof); err != nil {
+			return res
+		}
+	}
+	res.Accounts, res.Proof = accounts, proof.nodes
+	return res
+}
+
+// serviceGetStorageRanges assembles the response to a storage ranges query. The
+// storage of the accounts is served in full until the size or account limit is
+// reached, the last range being proven if it's partial.
+func serviceGetStorageRanges(triedb *trie.Database, req *getStorageRangesData) *storageRangesData {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	res := &storageRangesData{ID: req.ID}
+
+	accTrie, err := trie.New(req.Root, triedb)
+	if err != nil {
+		return res
+	}
+	var size uint64
+	for i, account := range req.Accounts {
+		// Only the first account may be served from a non-zero origin
+		var origin common.Hash
+		if i == 0 {
+			origin = req.Origin
+		} else if req.Origin != (common.Hash{}) || i >= maxStorageLookups || size >= req.Bytes {
+			break
+		}
+		size += storageAccountSize
+
+		blob, err := accTrie.TryGet(account[:])
+		if err != nil || blob == nil {
+			break
+		}
+		var acc state.Account
+		if err := rlp.DecodeBytes(blob, &acc); err != nil {
+			break
+		}
+		stTrie, err := trie.New(acc.Root, triedb)
+		if err != nil {
+			break
+		}
+		var (
+			slots   []*storageData
+			partial bool
+		)
+		it := trie.NewIterator(stTrie.NodeIterator(origin[:]))
+		for it.Next() {
+			slots = append(slots, &storageData{Hash: common.BytesToHash(it.Key), Body: common.CopyBytes(it.Value)})
+
+			size += uint64(common.HashLength + len(it.Value))
+			if size >= req.Bytes {
+				partial = true
+				break
+			}
+		}
+		if it.Err != nil {
+			break
+		}
+		res.Slots = append(res.Slots, slots)
+
+		// Prove the range if it doesn't cover the whole storage, no more accounts
+		// are served after it
+		if origin != (common.Hash{}) || partial {
+			proof := newProofList()
+			if err := stTrie.Prove(origin[:], 0, proof); err != nil {
+				res.Slots = res.Slots[:len(res.Slots)-1]
+				break
+			}
+			if len(slots) > 0 {
+				if err := stTrie.Prove(slots[len(slots)-1].Hash[:], 0, proof); err != nil {
+					res.Slots = res.Slots[:len(res.Slots)-1]
+					break
+				}
+			}
+			res.Proof = proof.nodes
+			break
+		}
+	}
+	return res
+}
+
+// serviceGetByteCodes assembles the response to a byte codes query. Unknown codes
+// are left out.
+func serviceGetByteCodes(triedb *trie.Database, req *getByteCodesData) *byteCodesData {
+	if req.Bytes > softResponseLimit {
+		req.Bytes = softResponseLimit
+	}
+	res := &byteCodesData{ID: req.ID}
+
+	var size uint64
+	for i, hash := range req.Hashes {
+		if i >= maxCodeLookups || size >= req.Bytes {
+			break
+		}
+		if blob, err := triedb.Node(hash); err == nil && len(blob) > 0 {
+			res.Codes = append(res.Codes, blob)
+			size += uint64(len(blob))
+		}
+	}
+	return res
+}
+
+// proofList collects the distinct trie nodes of merkle proofs. It implements
+// ethdb.Putter.
+type proofList struct {
+	keys  map[string]struct{}
+	nodes [][]byte
+}
+
+func newProofList() *proofList {
+	return &proofList{keys: make(map[string]struct{})}
+}
+
+// Put adds a trie node to the proof, unless it's already contained.
+func (l *proofList) Put(key []byte, value []byte) error {
+	if _, ok := l.keys[string(key)]; !ok {
+		l.keys[string(key)] = struct{}{}
+		l.nodes = append(l.nodes, common.CopyBytes(value))
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snap implements the snapshot sync protocol, retrieving the state of a
+// block as contiguous ranges of accounts and storage slots instead of trie nodes.
+//
+// Every range comes with the merkle proofs of its first and last keys, allowing
+// it to be verified against the state root on its own. The syncing node stores
+// the verified ranges in the flat snapshot and rebuilds the tries from it.
+package snap
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Constants to match up protocol versions and messages
+const (
+	snap1 = 1
+)
+
+// ProtocolName is the official short name of the protocol used during capability
+// negotiation.
+var ProtocolName = "snap"
+
+// ProtocolVersions are the supported versions of the snap protocol (first is
+// primary).
+var ProtocolVersions = []uint{snap1}
+
+// ProtocolLengths are the number of implemented messages corresponding to the
+// different protocol versions.
+var ProtocolLengths = []uint64{6}
+
+// ProtocolMaxMsgSize is the maximum cap on the size of a protocol message.
+const ProtocolMaxMsgSize = 10 * 1024 * 1024
+
+// snap protocol message codes
+const (
+	GetAccountRangeMsg  = 0x00
+	AccountRangeMsg     = 0x01
+	GetStorageRangesMsg = 0x02
+	StorageRangesMsg    = 0x03
+	GetByteCodesMsg     = 0x04
+	ByteCodesMsg        = 0x05
+)
+
+var (
+	errMsgTooLarge    = errors.New("message too long")
+	errDecode         = errors.New("invalid message")
+	errInvalidMsgCode = errors.New("invalid message code")
+)
+
+// getAccountRangeData is the network packet requesting the accounts of a state
+// with hashes from Origin up to Limit.
+type getAccountRangeData struct {
+	ID     uint64      // Request ID to match up responses with
+	Root   common.Hash // Root of the state to serve the accounts of
+	Origin common.Hash // Hash of the first account to retrieve
+	Limit  common.Hash // Hash of the last account to retrieve
+	Bytes  uint64      // Soft limit on the size of the response
+}
+
+// accountRangeData is the network packet of the accounts of a range, along with
+// the merkle proofs of the origin and the last account.
+type accountRangeData struct {
+	ID       uint64         // Request ID of the query being answered
+	Accounts []*accountData // Accounts of the range in ascending hash order
+	Proof    [][]byte       // Trie nodes proving the edges of the range
+}
+
+// accountData is an account of a range, in its trie encoding.
+type accountData struct {
+	Hash common.Hash  // Hash of the account address
+	Body rlp.RawValue // Trie encoding of the account
+}
+
+// getStorageRangesData is the network packet requesting the storage slots of a
+// number of accounts. A non-zero Origin only applies to the first account, in
+// which case the others are not served.
+type getStorageRangesData struct {
+	ID       uint64        // Request ID to match up responses with
+	Root     common.Hash   // Root of the state to serve the storage of
+	Accounts []common.Hash // Hashes of the accounts to serve the storage of
+	Origin   common.Hash   // Hash of the first slot to retrieve
+	Bytes    uint64        // Soft limit on the size of the response
+}
+
+// storageRangesData is the network packet of the storage slots of a number of
+// accounts. The storage of every account but the last one is complete, the last
+// one comes with the merkle proofs of its edges if it's not complete, or if it
+// does not start at the beginning of the storage.
+type storageRangesData struct {
+	ID    uint64           // Request ID of the query being answered
+	Slots [][]*storageData // Storage slots of the accounts in ascending hash order
+	Proof [][]byte         // Trie nodes proving the edges of the last range
+}
+
+// storageData is a storage slot of a range, in its trie encoding.
+type storageData struct {
+	Hash common.Hash // Hash of the storage slot key
+	Body []byte      // Trie encoding of the slot value
+}
+
+// getByteCodesData is the network packet requesting contract codes.
+type getByteCodesData struct {
+	ID     uint64        // Request ID to match up responses with
+	Hashes []common.Hash // Code hashes to retrieve
+	Bytes  uint64        // Soft limit on the size of the response
+}
+
+// byteCodesData is the network packet of the requested contract codes, in the
+// order of the request. Codes not available are left out.
+type byteCodesData struct {
+	ID    uint64   // Request ID of the query being answered
+	Codes [][]byte // Requested contract codes
+}
@@ -0,0 +1,846 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+const (
+	// accountConcurrency is the number of chunks the account range is split into,
+	// allowing as many peers to retrieve accounts concurrently.
+	accountConcurrency = 16
+
+	// maxStorageSetRequest is the maximum number of accounts to request the storage
+	// of at once.
+	maxStorageSetRequest = 64
+
+	// maxCodeRequest is the maximum number of bytecodes to request at once.
+	maxCodeRequest = 128
+
+	// requestTimeout is the maximum time a peer may take to answer a request.
+	requestTimeout = 10 * time.Second
+)
+
+var (
+	// maxRequestSize is the response size requested from the peers.
+	maxRequestSize uint64 = softResponseLimit
+
+	// stallTimeout is the time after which the sync gives up if no data arrived
+	// anymore, leaving the missing state to the trie node sync.
+	stallTimeout = 30 * time.Second
+)
+
+// ErrCancelled is returned if a sync is cancelled.
+var ErrCancelled = errors.New("sync cancelled")
+
+var errDuplicatePeer = errors.New("duplicate peer")
+
+// accountTask is a chunk of the account range still to be retrieved.
+type accountTask struct {
+	next    common.Hash // Hash of the next account to retrieve
+	last    common.Hash // Hash of the last account of the chunk
+	done    bool        // Whether all accounts of the chunk were retrieved
+	pending bool        // Whether the chunk is being requested from a peer
+}
+
+// storageTask is the storage of an account still to be retrieved.
+type storageTask struct {
+	account common.Hash // Hash of the account owning the storage
+	root    common.Hash // Storage root of the account
+	state   common.Hash // State root the account was retrieved from
+	next    common.Hash // Hash of the next storage slot to retrieve
+
+	attempts map[string]struct{} // Peers which failed to deliver the storage
+	pending  bool                // Whether the storage is being requested from a peer
+}
+
+// codeTask is a contract code still to be retrieved.
+type codeTask struct {
+	attempts map[string]struct{} // Peers which failed to deliver the code
+	pending  bool                // Whether the code is being requested from a peer
+}
+
+// request is a data retrieval in flight to a peer.
+type request struct {
+	id    uint64
+	peer  string
+	timer *time.Timer
+
+	account  *accountTask   // Account chunk of an account range request
+	storages []*storageTask // Storage tasks of a storage ranges request
+	codes    []common.Hash  // Code hashes of a bytecode request
+}
+
+// response is a reply of a peer to a request, or a timeout.
+type response struct {
+	peer   string
+	id     uint64
+	packet interface{} // Network packet delivered, nil for a timeout
+}
+
+// Syncer retrieves the state of a block from the peers speaking the snap
+// protocol. The accounts and storage slots are stored in the flat snapshot and
+// the tries rebuilt from it once all ranges are retrieved.
+//
+// Parts of the state which couldn't be retrieved are left out of the rebuilt
+// tries, to be filled in by the trie node sync run afterwards. The same goes for
+// state changed by a new sync target, retrieving the accounts not yet known from
+// the new state. The progress of the sync is kept in memory only.
+type Syncer struct {
+	db    ethdb.Database // Database to store the state in
+	snaps *snapshot.Tree // Snapshot tree to invalidate while syncing, may be nil
+
+	root     common.Hash                             // Current state root to sync
+	accounts []*accountTask                          // Chunks of the account range to retrieve
+	storages []*storageTask                          // Storage tries to retrieve
+	codes    map[common.Hash]*codeTask               // Contract codes to retrieve
+	partial  map[common.Hash]struct{}                // Accounts with storage that couldn't be retrieved
+	missing  int                                     // Number of codes that couldn't be retrieved
+	pending  map[string]*request                     // Requests in flight, one per peer
+	nextID   uint64                                  // Identifier of the next request
+	useless  map[string]struct{}                     // Peers not serving the current state root
+	finished bool                                    // Whether the tries were rebuilt
+	synced   struct{ accounts, slots, codes uint64 } // Statistics of the retrieved state
+
+	peers     map[string]*Peer // Peers speaking the snap protocol
+	update    chan struct{}    // Notification channel of peer set changes
+	responses chan *response   // Delivery channel of the active sync, nil if none
+	quit      chan struct{}    // Termination channel of the active sync, nil if none
+	lock      sync.RWMutex     // Lock protecting the peers and channels
+}
+
+// NewSyncer creates a snap syncer storing the state into the given database.
+func NewSyncer(db ethdb.Database, snaps *snapshot.Tree) *Syncer {
+	return &Syncer{
+		db:      db,
+		snaps:   snaps,
+		codes:   make(map[common.Hash]*codeTask),
+		partial: make(map[common.Hash]struct{}),
+		pending: make(map[string]*request),
+		useless: make(map[string]struct{}),
+		peers:   make(map[string]*Peer),
+		update:  make(chan struct{}, 1),
+	}
+}
+
+// Register injects a new peer into the set of peers to retrieve state from.
+func (s *Syncer) Register(peer *Peer) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.peers[peer.id]; ok {
+		return errDuplicatePeer
+	}
+	s.peers[peer.id] = peer
+	s.notify()
+	return nil
+}
+
+// Unregister removes a peer from the set of peers to retrieve state from.
+func (s *Syncer) Unregister(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.peers, id)
+	s.notify()
+}
+
+// notify signals the sync loop that the peer set changed.
+func (s *Syncer) notify() {
+	select {
+	case s.update <- struct{}{}:
+	default:
+	}
+}
+
+// deliver passes a response of a peer to the active sync, dropping it if there
+// is none.
+func (s *Syncer) deliver(peer string, id uint64, packet interface{}) {
+	s.lock.RLock()
+	responses, quit := s.responses, s.quit
+	s.lock.RUnlock()
+
+	if responses == nil {
+		return
+	}
+	select {
+	case responses <- &response{peer: peer, id: id, packet: packet}:
+	case <-quit:
+	}
+}
+
+// Sync retrieves the state of the given root, continuing where a previous sync
+// left off. It returns once all state was retrieved or the sync stalled, after
+// which the tries are rebuilt and the sync is not run anymore.
+func (s *Syncer) Sync(root common.Hash, cancel <-chan struct{}) error {
+	if s.finished {
+		return nil
+	}
+	if s.accounts == nil {
+		if err := s.init(); err != nil {
+			return err
+		}
+	}
+	if s.root != root {
+		log.Info("Starting snapshot sync", "root", root)
+		s.root = root
+		s.useless = make(map[string]struct{})
+	}
+	if err := s.loop(cancel); err != nil {
+		return err
+	}
+	s.finished = true
+	return s.rebuild()
+}
+
+// init invalidates the snapshot and wipes the flat state on disk, splitting the
+// account range into chunks to retrieve.
+func (s *Syncer) init() error {
+	if s.snaps != nil {
+		s.snaps.Disable()
+	}
+	rawdb.DeleteSnapshotRoot(s.db)
+
+	batch := s.db.NewBatch()
+	for _, prefix := range [][]byte{rawdb.SnapshotAccountPrefix, rawdb.SnapshotStoragePrefix} {
+		it := rawdb.KeyValueStore(s.db).NewIteratorWithPrefix(prefix)
+		for it.Next() {
+			if key := it.Key(); len(key) == len(prefix)+common.HashLength || len(key) == len(prefix)+2*common.HashLength {
+				batch.Delete(common.CopyBytes(key))
+			}
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	for i := 0; i < accountConcurrency; i++ {
+		task := new(accountTask)
+		task.next[0] = byte(i * 256 / accountConcurrency)
+		for j := range task.last {
+			task.last[j] = 0xff
+		}
+		task.last[0] = byte((i+1)*256/accountConcurrency - 1)
+		s.accounts = append(s.accounts, task)
+	}
+	return nil
+}
+
+// loop assigns the tasks to the peers and processes their responses until there
+// is nothing left to retrieve or the sync stalls.
+func (s *Syncer) loop(cancel <-chan struct{}) error {
+	responses, quit := make(chan *response), make(chan struct{})
+
+	s.lock.Lock()
+	s.responses, s.quit = responses, quit
+	s.lock.Unlock()
+
+	defer func() {
+		close(quit)
+
+		s.lock.Lock()
+		s.responses, s.quit = nil, nil
+		s.lock.Unlock()
+
+		for _, req := range s.pending {
+			req.timer.Stop()
+			s.revert(req, false)
+		}
+	}()
+	stall := time.NewTimer(stallTimeout)
+	defer stall.Stop()
+
+	logged := time.Now()
+	for !s.complete() {
+		s.assign(responses, quit)
+
+		select {
+		case <-cancel:
+			return ErrCancelled
+
+		case <-s.update:
+			// Revert the requests of the peers gone
+			s.lock.RLock()
+			for id, req := range s.pending {
+				if _, ok := s.peers[id]; !ok {
+					req.timer.Stop()
+					s.revert(req, false)
+				}
+			}
+			s.lock.RUnlock()
+
+		case res := <-responses:
+			if s.process(res) {
+				if !stall.Stop() {
+					<-stall.C
+				}
+				stall.Reset(stallTimeout)
+			}
+
+		case <-stall.C:
+			log.Warn("Snapshot sync stalled", "accounts", s.synced.accounts, "slots", s.synced.slots, "codes", s.synced.codes)
+			return nil
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Syncing state snapshot", "accounts", s.synced.accounts, "slots", s.synced.slots, "codes", s.synced.codes, "storages", len(s.storages), "pending codes", len(s.codes))
+			logged = time.Now()
+		}
+	}
+	log.Info("Retrieved state snapshot", "accounts", s.synced.accounts, "slots", s.synced.slots, "codes", s.synced.codes)
+	return nil
+}
+
+// complete returns whether all state was retrieved.
+func (s *Syncer) complete() bool {
+	for _, task := range s.accounts {
+		if !task.done {
+			return false
+		}
+	}
+	return len(s.storages) == 0 && len(s.codes) == 0
+}
+
+// exhausted returns whether all peers failed to deliver a task.
+func (s *Syncer) exhausted(attempts map[string]struct{}) bool {
+	if len(s.peers) == 0 {
+		return false
+	}
+	for id := range s.peers {
+		if _, ok := attempts[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// assign sends requests for the pending tasks to the idle peers, the storage and
+// code requests having priority over new accounts.
+func (s *Syncer) assign(responses chan *response, quit chan struct{}) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	// Drop the tasks all peers failed to deliver
+	for hash, task := range s.codes {
+		if !task.pending && s.exhausted(task.attempts) {
+			log.Debug("Dropping undeliverable code", "hash", hash)
+			delete(s.codes, hash)
+			s.missing++
+		}
+	}
+	storages := s.storages[:0]
+	for _, task := range s.storages {
+		if !task.pending && s.exhausted(task.attempts) {
+			log.Debug("Dropping undeliverable storage", "account", task.account, "root", task.root)
+			s.partial[task.account] = struct{}{}
+			continue
+		}
+		storages = append(storages, task)
+	}
+	for i := len(storages); i < len(s.storages); i++ {
+		s.storages[i] = nil
+	}
+	s.storages = storages
+
+	for id, peer := range s.peers {
+		if _, ok := s.pending[id]; ok {
+			continue
+		}
+		if _, ok := s.useless[id]; ok {
+			continue
+		}
+		req := &request{id: s.nextID, peer: id}
+		var err error
+		switch {
+		case s.assignCodes(req):
+			err = peer.RequestByteCodes(req.id, req.codes, maxRequestSize)
+		case s.assignStorages(req):
+			accounts := make([]common.Hash, len(req.storages))
+			for i, task := range req.storages {
+				accounts[i] = task.account
+			}
+			err = peer.RequestStorageRanges(req.id, req.storages[0].state, accounts, req.storages[0].next, maxRequestSize)
+		case s.assignAccounts(req):
+			err = peer.RequestAccountRange(req.id, s.root, req.account.next, req.account.last, maxRequestSize)
+		default:
+			continue
+		}
+		s.nextID++
+		if err != nil {
+			peer.logger.Debug("Failed to request state ranges", "err", err)
+			s.revert(req, false)
+			continue
+		}
+		req.timer = time.AfterFunc(requestTimeout, func() {
+			select {
+			case responses <- &response{peer: req.peer, id: req.id}:
+			case <-quit:
+			}
+		})
+		s.pending[id] = req
+	}
+}
+
+// assignCodes fills a request with the codes the peer didn't fail to deliver.
+func (s *Syncer) assignCodes(req *request) bool {
+	for hash, task := range s.codes {
+		if _, ok := task.attempts[req.peer]; ok || task.pending {
+			continue
+		}
+		task.pending = true
+		req.codes = append(req.codes, hash)
+		if len(req.codes) == maxCodeRequest {
+			break
+		}
+	}
+	return len(req.codes) > 0
+}
+
+// assignStorages fills a request with the storage tasks the peer didn't fail to
+// deliver. A partially retrieved storage is requested on its own, the others are
+// batched if they belong to the same state.
+func (s *Syncer) assignStorages(req *request) bool {
+	for _, task := range s.storages {
+		if _, ok := task.attempts[req.peer]; ok || task.pending {
+			continue
+		}
+		if len(req.storages) > 0 && (task.next != (common.Hash{}) || task.state != req.storages[0].state) {
+			continue
+		}
+		task.pending = true
+		req.storages = append(req.storages, task)
+		if task.next != (common.Hash{}) || len(req.storages) == maxStorageSetRequest {
+			break
+		}
+	}
+	return len(req.storages) > 0
+}
+
+// assignAccounts fills a request with the next idle account chunk.
+func (s *Syncer) assignAccounts(req *request) bool {
+	for _, task := range s.accounts {
+		if !task.done && !task.pending {
+			task.pending = true
+			req.account = task
+			return true
+		}
+	}
+	return false
+}
+
+// revert returns the tasks of a request to the queue, marking them as failed by
+// the peer if requested.
+func (s *Syncer) revert(req *request, failed bool) {
+	if req.account != nil {
+		req.account.pending = false
+	}
+	for _, task := range req.storages {
+		task.pending = false
+		if failed {
+			if task.attempts == nil {
+				task.attempts = make(map[string]struct{})
+			}
+			task.attempts[req.peer] = struct{}{}
+		}
+	}
+	for _, hash := range req.codes {
+		if task := s.codes[hash]; task != nil {
+			task.pending = false
+			if failed {
+				if task.attempts == nil {
+					task.attempts = make(map[string]struct{})
+				}
+				task.attempts[req.peer] = struct{}{}
+			}
+		}
+	}
+	delete(s.pending, req.peer)
+}
+
+// process handles a response of a peer, returning whether it delivered any data.
+func (s *Syncer) process(res *response) bool {
+	req := s.pending[res.peer]
+	if req == nil || req.id != res.id {
+		return false
+	}
+	req.timer.Stop()
+	delete(s.pending, res.peer)
+
+	var err error
+	switch packet := res.packet.(type) {
+	case nil:
+		log.Debug("Snapshot sync request timed out", "peer", res.peer, "reqid", res.id)
+		s.revert(req, true)
+		return false
+
+	case *accountRangeData:
+		if req.account == nil {
+			err = errors.New("unexpected account range")
+		} else {
+			err = s.processAccounts(req, packet)
+		}
+	case *storageRangesData:
+		if req.storages == nil {
+			err = errors.New("unexpected storage ranges")
+		} else {
+			err = s.processStorages(req, packet)
+		}
+	case *byteCodesData:
+		if req.codes == nil {
+			err = errors.New("unexpected byte codes")
+		} else {
+			err = s.processCodes(req, packet)
+		}
+	}
+	if err != nil {
+		// The peer doesn't have the state or served it wrong, don't ask it anymore
+		log.Debug("Snapshot sync request failed", "peer", res.peer, "reqid", res.id, "err", err)
+		s.useless[res.peer] = struct{}{}
+		s.revert(req, true)
+		return false
+	}
+	return true
+}
+
+// processAccounts verifies and stores an account range, queueing the storage and
+// code of the accounts for retrieval.
+func (s *Syncer) processAccounts(req *request, res *accountRangeData) error {
+	task := req.account
+	task.pending = false
+
+	if len(res.Accounts) == 0 && len(res.Proof) == 0 {
+		return errors.New("state unavailable")
+	}
+	keys := make([][]byte, len(res.Accounts))
+	values := make([][]byte, len(res.Accounts))
+	for i, acc := range res.Accounts {
+		keys[i], values[i] = acc.Hash[:], acc.Body
+	}
+	more, err := trie.VerifyRangeProof(s.root, task.next[:], keys, values, newProofDb(res.Proof))
+	if err != nil {
+		return err
+	}
+	// Store the accounts of the chunk, the one past its end is another chunk's
+	batch := s.db.NewBatch()
+	for _, acc := range res.Accounts {
+		if bytes.Compare(acc.Hash[:], task.last[:]) > 0 {
+			break
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(acc.Body, &account); err != nil {
+			return err
+		}
+		rawdb.WriteAccountSnapshot(batch, acc.Hash, acc.Body)
+		s.synced.accounts++
+
+		if account.Root != emptyRoot {
+			s.storages = append(s.storages, &storageTask{account: acc.Hash, root: account.Root, state: s.root})
+		}
+		if hash := common.BytesToHash(account.CodeHash); hash != emptyCode && s.codes[hash] == nil {
+			if ok, _ := s.db.Has(hash[:]); !ok {
+				s.codes[hash] = new(codeTask)
+			}
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to store account snapshot", "err", err)
+	}
+	// Advance the chunk past the last account retrieved
+	if !more || len(keys) == 0 || bytes.Compare(keys[len(keys)-1], task.last[:]) >= 0 {
+		task.done = true
+	} else if next, ok := incHash(common.BytesToHash(keys[len(keys)-1])); ok {
+		task.next = next
+	} else {
+		task.done = true
+	}
+	return nil
+}
+
+// processStorages verifies and stores the storage ranges of a batch of accounts.
+// Every range but the last one must be complete, the last one is proven if it's
+// only partial.
+func (s *Syncer) processStorages(req *request, res *storageRangesData) error {
+	if len(res.Slots) == 0 || len(res.Slots) > len(req.storages) {
+		return errors.New("state unavailable")
+	}
+	// Verify all ranges before storing any of them
+	more := false
+	for i, slots := range res.Slots {
+		task := req.storages[i]
+
+		keys := make([][]byte, len(slots))
+		values := make([][]byte, len(slots))
+		for j, slot := range slots {
+			keys[j], values[j] = slot.Hash[:], slot.Body
+		}
+		var err error
+		if i == len(res.Slots)-1 && len(res.Proof) > 0 {
+			more, err = trie.VerifyRangeProof(task.root, task.next[:], keys, values, newProofDb(res.Proof))
+		} else {
+			_, err = trie.VerifyRangeProof(task.root, nil, keys, values, nil)
+		}
+		if err != nil {
+			return fmt.Errorf("account %x: %v", task.account, err)
+		}
+	}
+	batch := s.db.NewBatch()
+	for i, slots := range res.Slots {
+		for _, slot := range slots {
+			rawdb.WriteStorageSnapshot(batch, req.storages[i].account, slot.Hash, slot.Body)
+			s.synced.slots++
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to store storage snapshot", "err", err)
+	}
+	// Drop the completed tasks, advancing a partial last one
+	done := make(map[*storageTask]struct{})
+	for i, task := range req.storages {
+		task.pending = false
+		if i >= len(res.Slots) {
+			continue
+		}
+		if i == len(res.Slots)-1 && more {
+			last := res.Slots[i][len(res.Slots[i])-1].Hash
+			if next, ok := incHash(last); ok {
+				task.next = next
+				continue
+			}
+		}
+		done[task] = struct{}{}
+	}
+	storages := s.storages[:0]
+	for _, task := range s.storages {
+		if _, ok := done[task]; !ok {
+			storages = append(storages, task)
+		}
+	}
+	for i := len(storages); i < len(s.storages); i++ {
+		s.storages[i] = nil
+	}
+	s.storages = storages
+	return nil
+}
+
+// processCodes stores the delivered contract codes, returning the missing ones
+// to the queue.
+func (s *Syncer) processCodes(req *request, res *byteCodesData) error {
+	if len(res.Codes) == 0 {
+		return errors.New("state unavailable")
+	}
+	requested := make(map[common.Hash]struct{}, len(req.codes))
+	for _, hash := range req.codes {
+		requested[hash] = struct{}{}
+	}
+	batch := s.db.NewBatch()
+	for _, code := range res.Codes {
+		hash := crypto.Keccak256Hash(code)
+		if _, ok := requested[hash]; !ok {
+			return fmt.Errorf("unrequested code %x", hash)
+		}
+		batch.Put(hash[:], code)
+		delete(requested, hash)
+		delete(s.codes, hash)
+		s.synced.codes++
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to store contract codes", "err", err)
+	}
+	for hash := range requested {
+		if task := s.codes[hash]; task != nil {
+			task.pending = false
+			if task.attempts == nil {
+				task.attempts = make(map[string]struct{})
+			}
+			task.attempts[req.peer] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// rebuild regenerates the tries from the flat state. Storage tries are only
+// stored if they match the storage root of their account. As the trie node sync
+// considers everything below a stored node to be complete, the account trie is
+// only stored if all storage and code were retrieved.
+func (s *Syncer) rebuild() error {
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		triedb   = trie.NewDatabase(s.db)
+		complete = len(s.partial) == 0 && s.missing == 0
+		accounts int
+	)
+	// Drop the tasks left over by a stalled sync
+	for _, task := range s.storages {
+		s.partial[task.account] = struct{}{}
+	}
+	if len(s.storages) > 0 || len(s.codes) > 0 {
+		complete = false
+	}
+	for _, task := range s.accounts {
+		if !task.done {
+			complete = false
+		}
+	}
+	s.storages, s.codes = nil, make(map[common.Hash]*codeTask)
+
+	log.Info("Rebuilding state tries", "root", s.root, "complete", complete)
+
+	accTrie, _ := trie.New(common.Hash{}, triedb)
+	it := rawdb.KeyValueStore(s.db).NewIteratorWithPrefix(rawdb.SnapshotAccountPrefix)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(rawdb.SnapshotAccountPrefix)+common.HashLength {
+			continue
+		}
+		hash := common.BytesToHash(key[len(rawdb.SnapshotAccountPrefix):])
+
+		var account state.Account
+		if err := rlp.DecodeBytes(it.Value(), &account); err != nil {
+			return err
+		}
+		if _, partial := s.partial[hash]; !partial && account.Root != emptyRoot {
+			if err := s.rebuildStorage(triedb, hash, account.Root); err != nil {
+				return err
+			}
+		}
+		if complete {
+			accTrie.Update(hash[:], common.CopyBytes(it.Value()))
+
+			// Flush the account trie every now and then to bound the memory use,
+			// the nodes stored are all complete subtries
+			if accounts++; accounts%100000 == 0 {
+				root, err := accTrie.Commit(nil)
+				if err != nil {
+					return err
+				}
+				if err := triedb.Commit(root, false); err != nil {
+					return err
+				}
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Rebuilding state tries", "at", hash, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if complete {
+		root, err := accTrie.Commit(nil)
+		if err != nil {
+			return err
+		}
+		if err := triedb.Commit(root, false); err != nil {
+			return err
+		}
+		if root == s.root {
+			rawdb.WriteSnapshotRoot(s.db, root)
+		} else {
+			log.Info("Rebuilt state trie outdated", "have", root, "want", s.root)
+		}
+	}
+	log.Info("Rebuilt state tries", "root", s.root, "complete", complete, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// rebuildStorage regenerates the storage trie of an account from the flat state,
+// storing it if it matches the storage root.
+func (s *Syncer) rebuildStorage(triedb *trie.Database, account common.Hash, root common.Hash) error {
+	if ok, _ := s.db.Has(root[:]); ok {
+		return nil
+	}
+	tr, _ := trie.New(common.Hash{}, triedb)
+
+	prefix := append(append([]byte{}, rawdb.SnapshotStoragePrefix...), account[:]...)
+	it := rawdb.KeyValueStore(s.db).NewIteratorWithPrefix(prefix)
+	for it.Next() {
+		if key := it.Key(); len(key) == len(prefix)+common.HashLength {
+			tr.Update(key[len(prefix):], common.CopyBytes(it.Value()))
+		}
+	}
+	it.Release()
+	if err := it.Error(); err != nil {
+		return err
+	}
+	have, err := tr.Commit(nil)
+	if err != nil {
+		return err
+	}
+	if have != root {
+		log.Debug("Rebuilt storage trie mismatch", "account", account, "have", have, "want", root)
+		triedb.Dereference(have)
+		return nil
+	}
+	return triedb.Commit(have, false)
+}
+
+// newProofDb collects the nodes of a merkle proof into a database to verify it
+// with.
+func newProofDb(proof [][]byte) trie.DatabaseReader {
+	db := ethdb.NewMemDatabase()
+	for _, node := range proof {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// incHash returns the hash following the given one, or false if there is none.
+func incHash(h common.Hash) (common.Hash, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		h[i]++
+		if h[i] != 0 {
+			return h, true
+		}
+	}
+	return h, false
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snap
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// makeTestState creates a state with plain accounts, contracts and accounts with
+// storage, returning its database and root.
+func makeTestState(t *testing.T) (ethdb.Database, common.Hash) {
+	db := ethdb.NewMemDatabase()
+	sdb := state.NewDatabase(db)
+	statedb, _ := state.New(common.Hash{}, sdb)
+
+	for i := 0; i < 1000; i++ {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		statedb.AddBalance(addr, big.NewInt(int64(i+1)))
+		if i%10 == 0 {
+			statedb.SetCode(addr, []byte{byte(i), byte(i >> 8), 0x01})
+		}
+		if i%50 == 0 {
+			for j := 0; j < 10*i; j++ {
+				statedb.SetState(addr, common.BigToHash(big.NewInt(int64(j))), common.BigToHash(big.NewInt(int64(j+1))))
+			}
+		}
+	}
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := sdb.TrieDB().Commit(root, false); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	return db, root
+}
+
+// connect links the syncer with a peer serving the state of the given database.
+func connect(syncer *Syncer, id byte, db ethdb.Database) {
+	local, remote := p2p.MsgPipe()
+
+	go Handle(trie.NewDatabase(db), nil, NewPeer(snap1, p2p.NewPeer(discover.NodeID{id}, "", nil), remote))
+	go Handle(trie.NewDatabase(syncer.db), syncer, NewPeer(snap1, p2p.NewPeer(discover.NodeID{id}, "", nil), local))
+}
+
+// Tests that a state is retrieved fully from peers, even if some of them don't
+// have it, and its tries and snapshot rebuilt.
+func TestSync(t *testing.T) {
+	defer func(size uint64) { maxRequestSize = size }(maxRequestSize)
+	maxRequestSize = 2048
+
+	srcdb, root := makeTestState(t)
+
+	db := ethdb.NewMemDatabase()
+	syncer := NewSyncer(db, nil)
+	connect(syncer, 1, ethdb.NewMemDatabase())
+	connect(syncer, 2, srcdb)
+	connect(syncer, 3, srcdb)
+
+	if err := syncer.Sync(root, nil); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != root {
+		t.Fatalf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+	if err := snapshot.VerifyState(db, trie.NewDatabase(db), root); err != nil {
+		t.Fatalf("synced state invalid: %v", err)
+	}
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open synced state: %v", err)
+	}
+	for i := 0; i < 1000; i += 10 {
+		addr := common.BigToAddress(big.NewInt(int64(i)))
+		if code := statedb.GetCode(addr); len(code) != 3 || code[0] != byte(i) {
+			t.Errorf("account %d: code mismatch: %x", i, code)
+		}
+	}
+}
+
+// Tests that a sync without any peer serving the state stalls, leaving all of
+// it to the trie node sync.
+func TestSyncStall(t *testing.T) {
+	defer func(timeout time.Duration) { stallTimeout = timeout }(stallTimeout)
+	stallTimeout = 200 * time.Millisecond
+
+	_, root := makeTestState(t)
+
+	db := ethdb.NewMemDatabase()
+	syncer := NewSyncer(db, nil)
+	connect(syncer, 1, ethdb.NewMemDatabase())
+
+	if err := syncer.Sync(root, nil); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != (common.Hash{}) {
+		t.Fatalf("snapshot root written for missing state: %x", have)
+	}
+	if ok, _ := db.Has(root[:]); ok {
+		t.Fatalf("state root stored for missing state")
+	}
+}
+
+// Tests that storage range requests are served for a limited number of accounts,
+// even if they have no storage to count against the size limit.
+func TestStorageRangesLimit(t *testing.T) {
+	db, root := makeTestState(t)
+
+	var accounts []common.Hash
+	for len(accounts) <= 2*maxStorageLookups {
+		for i := 1; i < 1000; i++ {
+			if i%50 != 0 {
+				accounts = append(accounts, crypto.Keccak256Hash(common.BigToAddress(big.NewInt(int64(i))).Bytes()))
+			}
+		}
+	}
+	req := &getStorageRangesData{Root: root, Accounts: accounts, Bytes: softResponseLimit}
+	if res := serviceGetStorageRanges(trie.NewDatabase(db), req); len(res.Slots) != maxStorageLookups {
+		t.Errorf("served account count mismatch: have %d, want %d", len(res.Slots), maxStorageLookups)
+	}
+	req = &getStorageRangesData{Root: root, Accounts: accounts, Bytes: 100 * storageAccountSize}
+	if res := serviceGetStorageRanges(trie.NewDatabase(db), req); len(res.Slots) != 100 {
+		t.Errorf("served account count mismatch: have %d, want %d", len(res.Slots), 100)
+	}
+}
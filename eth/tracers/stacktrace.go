@@ -0,0 +1,85 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/compiler"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// StackFrame is a single entry of a Solidity level stack trace.
+type StackFrame struct {
+	Address common.Address           `json:"address"`
+	PC      uint64                   `json:"pc"`
+	Op      string                   `json:"op"`
+	Depth   int                      `json:"depth"`
+	Create  bool                     `json:"create,omitempty"`
+	Source  *compiler.SourceLocation `json:"source,omitempty"` // Nil if the code is not covered by any artifact
+}
+
+// StackTrace is the Solidity level stack trace of a failed execution.
+type StackTrace struct {
+	Error  string       `json:"error,omitempty"`
+	Frames []StackFrame `json:"frames"` // Innermost frame first
+}
+
+// NewStackTrace resolves the call frames recorded by a failure tracer into
+// source locations using the given mapper, which may be nil.
+func NewStackTrace(tracer *vm.FailureTracer, mapper *compiler.SourceMapper) *StackTrace {
+	trace := &StackTrace{Frames: []StackFrame{}}
+	if err := tracer.Error(); err != nil {
+		trace.Error = err.Error()
+	}
+	for _, frame := range tracer.Frames() {
+		sf := StackFrame{
+			Address: frame.Address,
+			PC:      frame.PC,
+			Op:      frame.Op.String(),
+			Depth:   frame.Depth,
+			Create:  frame.Create,
+		}
+		if mapper != nil {
+			sf.Source = mapper.Lookup(frame.Code, frame.PC, frame.Create)
+		}
+		trace.Frames = append(trace.Frames, sf)
+	}
+	return trace
+}
+
+// String implements fmt.Stringer, formatting the trace the way stack traces of
+// most programming languages look.
+func (trace *StackTrace) String() string {
+	var buf bytes.Buffer
+	if trace.Error != "" {
+		fmt.Fprintf(&buf, "Error: %s\n", trace.Error)
+	}
+	for _, frame := range trace.Frames {
+		if frame.Source != nil {
+			fmt.Fprintf(&buf, "    at %v [%x pc=%d %s]\n", frame.Source, frame.Address, frame.PC, frame.Op)
+			if frame.Source.Snippet != "" {
+				fmt.Fprintf(&buf, "        %s\n", frame.Source.Snippet)
+			}
+		} else {
+			fmt.Fprintf(&buf, "    at <unknown> [%x pc=%d %s]\n", frame.Address, frame.PC, frame.Op)
+		}
+	}
+	return buf.String()
+}
@@ -17,6 +17,7 @@
 package ethdb
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"strings"
@@ -28,7 +29,6 @@ import (
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/filter"
-	"github.com/syndtr/goleveldb/leveldb/iterator"
 	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
@@ -119,15 +119,37 @@ func (db *LDBDatabase) Delete(key []byte) error {
 	return db.db.Delete(key, nil)
 }
 
-func (db *LDBDatabase) NewIterator() iterator.Iterator {
+// NewIterator returns an iterator over the entire database content.
+func (db *LDBDatabase) NewIterator() Iterator {
 	return db.db.NewIterator(nil, nil)
 }
 
+// NewIteratorWithStart returns an iterator over the database content from the
+// given start key on.
+func (db *LDBDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return db.db.NewIterator(&util.Range{Start: start}, nil)
+}
+
 // NewIteratorWithPrefix returns a iterator to iterate over subset of database content with a particular prefix.
-func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) iterator.Iterator {
+func (db *LDBDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
 	return db.db.NewIterator(util.BytesPrefix(prefix), nil)
 }
 
+// Stat returns a LevelDB property of the database, adding the "leveldb." prefix
+// if missing.
+func (db *LDBDatabase) Stat(property string) (string, error) {
+	if !strings.HasPrefix(property, "leveldb.") {
+		property = "leveldb." + property
+	}
+	return db.db.GetProperty(property)
+}
+
+// Compact flattens the underlying data store for the given key range. A nil start
+// is treated as a key before all keys, and a nil limit as a key after all keys.
+func (db *LDBDatabase) Compact(start []byte, limit []byte) error {
+	return db.db.CompactRange(util.Range{Start: start, Limit: limit})
+}
+
 func (db *LDBDatabase) Close() {
 	// Stop the metrics collection to avoid internal database races
 	db.quitLock.Lock()
@@ -414,10 +436,73 @@ func (dt *table) Delete(key []byte) error {
 	return dt.db.Delete(append([]byte(dt.prefix), key...))
 }
 
+// NewIterator returns an iterator over the entries of the table, with the table
+// prefix stripped off the keys.
+func (dt *table) NewIterator() Iterator {
+	return dt.NewIteratorWithPrefix(nil)
+}
+
+// NewIteratorWithStart returns an iterator over the entries of the table from the
+// given start key on, with the table prefix stripped off the keys.
+func (dt *table) NewIteratorWithStart(start []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithStart(append([]byte(dt.prefix), start...)),
+		prefix: []byte(dt.prefix),
+	}
+}
+
+// NewIteratorWithPrefix returns an iterator over the entries of the table whose
+// keys start with the given prefix, with the table prefix stripped off the keys.
+func (dt *table) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return &tableIterator{
+		it:     dt.db.NewIteratorWithPrefix(append([]byte(dt.prefix), prefix...)),
+		prefix: []byte(dt.prefix),
+	}
+}
+
+// Stat returns a statistic of the underlying database.
+func (dt *table) Stat(property string) (string, error) {
+	return dt.db.Stat(property)
+}
+
+// Compact flattens the storage of the given key range of the table, the whole
+// table for nil bounds.
+func (dt *table) Compact(start []byte, limit []byte) error {
+	start = append([]byte(dt.prefix), start...)
+	if limit != nil {
+		limit = append([]byte(dt.prefix), limit...)
+	} else {
+		limit = util.BytesPrefix([]byte(dt.prefix)).Limit
+	}
+	return dt.db.Compact(start, limit)
+}
+
 func (dt *table) Close() {
 	// Do nothing; don't close the underlying DB.
 }
 
+// tableIterator strips the table prefix off the keys of an iterator over the
+// underlying database, stopping at the first key outside of the table.
+type tableIterator struct {
+	it     Iterator
+	prefix []byte
+}
+
+func (it *tableIterator) Next() bool {
+	return it.it.Next() && bytes.HasPrefix(it.it.Key(), it.prefix)
+}
+
+func (it *tableIterator) Error() error  { return it.it.Error() }
+func (it *tableIterator) Value() []byte { return it.it.Value() }
+func (it *tableIterator) Release()      { it.it.Release() }
+
+func (it *tableIterator) Key() []byte {
+	if key := it.it.Key(); key != nil {
+		return key[len(it.prefix):]
+	}
+	return nil
+}
+
 type tableBatch struct {
 	batch  Batch
 	prefix string
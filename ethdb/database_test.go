@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/dbtest"
 )
 
 func newTestLDB() (*ethdb.LDBDatabase, func()) {
@@ -56,6 +57,26 @@ func TestMemoryDB_PutGet(t *testing.T) {
 	testPutGet(ethdb.NewMemDatabase(), t)
 }
 
+func TestLDB_Conformance(t *testing.T) {
+	var removes []func()
+	defer func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}()
+	dbtest.TestDatabaseSuite(t, func() ethdb.Database {
+		db, remove := newTestLDB()
+		removes = append(removes, remove)
+		return db
+	})
+}
+
+func TestMemoryDB_Conformance(t *testing.T) {
+	dbtest.TestDatabaseSuite(t, func() ethdb.Database {
+		return ethdb.NewMemDatabase()
+	})
+}
+
 func testPutGet(db ethdb.Database, t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,441 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dbtest contains the conformance test suite every ethdb.Database
+// implementation must pass.
+package dbtest
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// TestDatabaseSuite runs the conformance tests against databases created by the
+// given constructor, each test getting a fresh empty database.
+func TestDatabaseSuite(t *testing.T, New func() ethdb.Database) {
+	t.Run("PutGet", func(t *testing.T) { testPutGet(t, New) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, New) })
+	t.Run("Batch", func(t *testing.T) { testBatch(t, New) })
+	t.Run("Iterator", func(t *testing.T) { testIterator(t, New) })
+	t.Run("IteratorStart", func(t *testing.T) { testIteratorStart(t, New) })
+	t.Run("IteratorSnapshot", func(t *testing.T) { testIteratorSnapshot(t, New) })
+	t.Run("Table", func(t *testing.T) { testTable(t, New) })
+	t.Run("Compact", func(t *testing.T) { testCompact(t, New) })
+	t.Run("Stat", func(t *testing.T) { testStat(t, New) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, New) })
+}
+
+var testValues = []string{"", "a", "1251", "\x00123\x00", "aaaa", "bbbb", "cccc"}
+
+func testPutGet(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	for _, k := range testValues {
+		if err := db.Put([]byte(k), nil); err != nil {
+			t.Fatalf("put of empty value failed: %v", err)
+		}
+		if data, err := db.Get([]byte(k)); err != nil || len(data) != 0 {
+			t.Fatalf("get of empty value mismatch: have %x (%v), want empty", data, err)
+		}
+	}
+	for _, v := range testValues {
+		if err := db.Put([]byte(v), []byte(v)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	for _, v := range testValues {
+		data, err := db.Get([]byte(v))
+		if err != nil {
+			t.Fatalf("get failed: %v", err)
+		}
+		if !bytes.Equal(data, []byte(v)) {
+			t.Fatalf("get returned wrong result, got %q expected %q", string(data), v)
+		}
+		if has, err := db.Has([]byte(v)); err != nil || !has {
+			t.Fatalf("has mismatch: have %v (%v), want true", has, err)
+		}
+	}
+	// Returned values must be owned by the caller
+	data, _ := db.Get([]byte("aaaa"))
+	data[0] = 'x'
+	if data, _ := db.Get([]byte("aaaa")); !bytes.Equal(data, []byte("aaaa")) {
+		t.Fatalf("database modified through returned value: %q", data)
+	}
+	// Overwrites must replace the old values
+	for _, v := range testValues {
+		if err := db.Put([]byte(v), []byte("?")); err != nil {
+			t.Fatalf("put override failed: %v", err)
+		}
+		if data, err := db.Get([]byte(v)); err != nil || !bytes.Equal(data, []byte("?")) {
+			t.Fatalf("get of override mismatch: have %q (%v), want %q", data, err, "?")
+		}
+	}
+	if _, err := db.Get([]byte("missing")); err == nil {
+		t.Fatalf("get of missing key succeeded")
+	}
+	if has, err := db.Has([]byte("missing")); err != nil || has {
+		t.Fatalf("has of missing key mismatch: have %v (%v), want false", has, err)
+	}
+}
+
+func testDelete(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	for _, v := range testValues {
+		if err := db.Put([]byte(v), []byte(v)); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+	}
+	for _, v := range testValues {
+		if err := db.Delete([]byte(v)); err != nil {
+			t.Fatalf("delete %q failed: %v", v, err)
+		}
+	}
+	for _, v := range testValues {
+		if _, err := db.Get([]byte(v)); err == nil {
+			t.Fatalf("got deleted value %q", v)
+		}
+		if has, _ := db.Has([]byte(v)); has {
+			t.Fatalf("has deleted value %q", v)
+		}
+	}
+	if err := db.Delete([]byte("missing")); err != nil {
+		t.Fatalf("delete of missing key failed: %v", err)
+	}
+}
+
+func testBatch(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	db.Put([]byte("deleted"), []byte("value"))
+
+	b := db.NewBatch()
+	b.Put([]byte("1"), []byte("1"))
+	b.Put([]byte("2"), []byte("2"))
+	b.Delete([]byte("deleted"))
+	b.Put([]byte("3"), []byte("3"))
+	b.Put([]byte("2"), []byte("two"))
+
+	if _, err := db.Get([]byte("1")); err == nil {
+		t.Fatalf("batch applied before write")
+	}
+	if b.ValueSize() == 0 {
+		t.Fatalf("batch size not tracked")
+	}
+	if err := b.Write(); err != nil {
+		t.Fatalf("batch write failed: %v", err)
+	}
+	want := map[string]string{"1": "1", "2": "two", "3": "3"}
+	if have := dump(t, db); !reflect.DeepEqual(have, want) {
+		t.Fatalf("batch contents mismatch: have %v, want %v", have, want)
+	}
+	// Reset batches must be reusable
+	b.Reset()
+	if b.ValueSize() != 0 {
+		t.Fatalf("reset batch size mismatch: have %d, want 0", b.ValueSize())
+	}
+	b.Delete([]byte("1"))
+	if err := b.Write(); err != nil {
+		t.Fatalf("reused batch write failed: %v", err)
+	}
+	delete(want, "1")
+	if have := dump(t, db); !reflect.DeepEqual(have, want) {
+		t.Fatalf("reused batch contents mismatch: have %v, want %v", have, want)
+	}
+}
+
+func testIterator(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	content := map[string]string{
+		"k1":   "v1",
+		"k2":   "v2",
+		"k3":   "v3",
+		"k10":  "v10",
+		"l1":   "w1",
+		"":     "empty",
+		"\xff": "max",
+	}
+	for k, v := range content {
+		db.Put([]byte(k), []byte(v))
+	}
+	db.Put([]byte("k4"), []byte("deleted"))
+	db.Delete([]byte("k4"))
+
+	tests := []struct {
+		prefix string
+		keys   []string
+	}{
+		{"", []string{"", "k1", "k10", "k2", "k3", "l1", "\xff"}},
+		{"k", []string{"k1", "k10", "k2", "k3"}},
+		{"k1", []string{"k1", "k10"}},
+		{"l", []string{"l1"}},
+		{"\xff", []string{"\xff"}},
+		{"m", nil},
+	}
+	for _, tt := range tests {
+		it := db.NewIteratorWithPrefix([]byte(tt.prefix))
+
+		var keys []string
+		for it.Next() {
+			if want := content[string(it.Key())]; string(it.Value()) != want {
+				t.Errorf("prefix %q: value of %q mismatch: have %q, want %q", tt.prefix, it.Key(), it.Value(), want)
+			}
+			keys = append(keys, string(it.Key()))
+		}
+		if err := it.Error(); err != nil {
+			t.Errorf("prefix %q: iteration failed: %v", tt.prefix, err)
+		}
+		it.Release()
+
+		if !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("prefix %q: keys mismatch: have %q, want %q", tt.prefix, keys, tt.keys)
+		}
+	}
+}
+
+func testIteratorStart(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	for _, k := range []string{"a", "b1", "b2", "c"} {
+		db.Put([]byte(k), []byte(k))
+	}
+	tests := []struct {
+		start string
+		keys  []string
+	}{
+		{"", []string{"a", "b1", "b2", "c"}},
+		{"b", []string{"b1", "b2", "c"}},
+		{"b2", []string{"b2", "c"}},
+		{"b3", []string{"c"}},
+		{"d", nil},
+	}
+	for _, tt := range tests {
+		if keys := iterateKeys(t, db.NewIteratorWithStart([]byte(tt.start))); !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("start %q: keys mismatch: have %q, want %q", tt.start, keys, tt.keys)
+		}
+	}
+	if keys, want := iterateKeys(t, db.NewIterator()), tests[0].keys; !reflect.DeepEqual(keys, want) {
+		t.Errorf("full iteration keys mismatch: have %q, want %q", keys, want)
+	}
+}
+
+func testIteratorSnapshot(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	for i := 0; i < 10; i++ {
+		db.Put([]byte(fmt.Sprintf("key-%d", i)), []byte{byte(i)})
+	}
+	it := db.NewIteratorWithPrefix([]byte("key-"))
+	defer it.Release()
+
+	// Changes after the creation of the iterator must not be visible to it
+	db.Put([]byte("key-a"), []byte{0xa})
+	db.Delete([]byte("key-0"))
+
+	var count int
+	for it.Next() {
+		if want := fmt.Sprintf("key-%d", count); string(it.Key()) != want {
+			t.Fatalf("key %d mismatch: have %q, want %q", count, it.Key(), want)
+		}
+		count++
+	}
+	if count != 10 {
+		t.Fatalf("iterated entry count mismatch: have %d, want %d", count, 10)
+	}
+}
+
+func testTable(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	db.Put([]byte("a-outside"), []byte{0x00})
+	table := ethdb.NewTable(db, "t-")
+	table.Put([]byte("1"), []byte{0x01})
+	table.Put([]byte("2"), []byte{0x02})
+
+	b := table.NewBatch()
+	b.Put([]byte("3"), []byte{0x03})
+	b.Delete([]byte("1"))
+	if err := b.Write(); err != nil {
+		t.Fatalf("table batch write failed: %v", err)
+	}
+	if data, err := db.Get([]byte("t-2")); err != nil || !bytes.Equal(data, []byte{0x02}) {
+		t.Fatalf("table entry mismatch in database: have %x (%v), want %x", data, err, []byte{0x02})
+	}
+	want := map[string]string{"2": "\x02", "3": "\x03"}
+	if have := dump(t, table); !reflect.DeepEqual(have, want) {
+		t.Fatalf("table contents mismatch: have %q, want %q", have, want)
+	}
+	// Iterations must not run past the end of the table
+	db.Put([]byte("u-outside"), []byte{0x00})
+	if keys, want := iterateKeys(t, table.NewIteratorWithStart([]byte("3"))), []string{"3"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("table keys mismatch: have %q, want %q", keys, want)
+	}
+	if keys, want := iterateKeys(t, table.NewIterator()), []string{"2", "3"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("table keys mismatch: have %q, want %q", keys, want)
+	}
+	if err := table.Compact(nil, nil); err != nil {
+		t.Fatalf("table compaction failed: %v", err)
+	}
+	if have := dump(t, table); !reflect.DeepEqual(have, want) {
+		t.Fatalf("table contents mismatch after compaction: have %q, want %q", have, want)
+	}
+}
+
+func testCompact(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	want := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key, value := fmt.Sprintf("key-%04d", i), fmt.Sprintf("value-%d", i)
+		db.Put([]byte(key), []byte(value))
+		want[key] = value
+	}
+	for i := 0; i < 1000; i += 3 {
+		key := fmt.Sprintf("key-%04d", i)
+		db.Delete([]byte(key))
+		delete(want, key)
+	}
+	if err := db.Compact([]byte("key-0100"), []byte("key-0200")); err != nil {
+		t.Fatalf("range compaction failed: %v", err)
+	}
+	if have := dump(t, db); !reflect.DeepEqual(have, want) {
+		t.Fatalf("contents mismatch after range compaction: have %d entries, want %d", len(have), len(want))
+	}
+	if err := db.Compact(nil, nil); err != nil {
+		t.Fatalf("full compaction failed: %v", err)
+	}
+	if have := dump(t, db); !reflect.DeepEqual(have, want) {
+		t.Fatalf("contents mismatch after full compaction: have %d entries, want %d", len(have), len(want))
+	}
+	for key, value := range want {
+		if data, err := db.Get([]byte(key)); err != nil || string(data) != value {
+			t.Fatalf("get of %q mismatch after compaction: have %q (%v), want %q", key, data, err, value)
+		}
+	}
+}
+
+func testStat(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	db.Put([]byte("key"), []byte("value"))
+	if stats, err := db.Stat("stats"); err != nil || stats == "" {
+		t.Fatalf("stats mismatch: have %q (%v), want overview", stats, err)
+	}
+	if _, err := db.Stat("no-such-property"); err == nil {
+		t.Fatalf("unknown property succeeded")
+	}
+}
+
+func testConcurrency(t *testing.T, New func() ethdb.Database) {
+	db := New()
+	defer db.Close()
+
+	const n = 8
+	var pending sync.WaitGroup
+
+	pending.Add(n)
+	for i := 0; i < n; i++ {
+		go func(key string) {
+			defer pending.Done()
+			if err := db.Put([]byte(key), []byte("v"+key)); err != nil {
+				t.Errorf("put failed: %v", err)
+			}
+		}(fmt.Sprint(i))
+	}
+	pending.Wait()
+
+	pending.Add(n)
+	for i := 0; i < n; i++ {
+		go func(key string) {
+			defer pending.Done()
+			data, err := db.Get([]byte(key))
+			if err != nil || !bytes.Equal(data, []byte("v"+key)) {
+				t.Errorf("get mismatch: have %q (%v), want %q", data, err, "v"+key)
+			}
+		}(fmt.Sprint(i))
+	}
+	pending.Wait()
+
+	pending.Add(n)
+	for i := 0; i < n; i++ {
+		go func(key string) {
+			defer pending.Done()
+			b := db.NewBatch()
+			b.Delete([]byte(key))
+			if err := b.Write(); err != nil {
+				t.Errorf("batch delete failed: %v", err)
+			}
+		}(fmt.Sprint(i))
+	}
+	pending.Wait()
+
+	if have := dump(t, db); len(have) != 0 {
+		t.Fatalf("entries left after deletion: %q", have)
+	}
+}
+
+// iterateKeys collects the keys returned by an iterator, releasing it.
+func iterateKeys(t *testing.T, it ethdb.Iterator) []string {
+	defer it.Release()
+
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	return keys
+}
+
+// dump collects all the entries of a database by iterating over them, checking
+// that they are returned in ascending key order.
+func dump(t *testing.T, db ethdb.Database) map[string]string {
+	it := db.NewIteratorWithPrefix(nil)
+	defer it.Release()
+
+	var (
+		entries = make(map[string]string)
+		keys    []string
+	)
+	for it.Next() {
+		entries[string(it.Key())] = string(it.Value())
+		keys = append(keys, string(it.Key()))
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("keys not in ascending order: %q", keys)
+	}
+	return entries
+}
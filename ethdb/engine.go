@@ -0,0 +1,101 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DefaultEngine is the storage engine used for persistent databases if none is
+// configured.
+const DefaultEngine = "leveldb"
+
+// Opener opens the persistent database of a storage engine in a directory. The
+// cache allowance is given in megabytes, the handles limit the open files.
+type Opener func(file string, cache, handles int) (Database, error)
+
+// engine is a registered storage engine.
+type engine struct {
+	open   Opener
+	exists func(file string) bool // Whether a directory holds a database of the engine
+}
+
+var (
+	engines     = make(map[string]engine)
+	enginesLock sync.RWMutex
+)
+
+func init() {
+	RegisterEngine(DefaultEngine, func(file string, cache, handles int) (Database, error) {
+		return NewLDBDatabase(file, cache, handles)
+	}, func(file string) bool {
+		_, err := os.Stat(filepath.Join(file, "CURRENT"))
+		return err == nil
+	})
+}
+
+// RegisterEngine makes a storage engine available under the given name. The
+// exists function reports whether a directory already holds a database of the
+// engine, so that an existing database is not opened with a different one.
+func RegisterEngine(name string, open Opener, exists func(file string) bool) {
+	enginesLock.Lock()
+	defer enginesLock.Unlock()
+
+	if _, ok := engines[name]; ok {
+		panic(fmt.Sprintf("database engine %q registered twice", name))
+	}
+	engines[name] = engine{open: open, exists: exists}
+}
+
+// Engines returns the names of the registered storage engines.
+func Engines() []string {
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OpenEngine opens the persistent database in a directory with the named storage
+// engine, creating it if needed. It refuses to open a database created by another
+// engine.
+func OpenEngine(name string, file string, cache, handles int) (Database, error) {
+	if name == "" {
+		name = DefaultEngine
+	}
+	enginesLock.RLock()
+	defer enginesLock.RUnlock()
+
+	eng, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown database engine %q", name)
+	}
+	for other, e := range engines {
+		if other != name && e.exists(file) {
+			return nil, fmt.Errorf("database %s was created by engine %q, not %q", file, other, name)
+		}
+	}
+	return eng.open(file, cache, handles)
+}
@@ -34,12 +34,68 @@ type Deleter interface {
 type Database interface {
 	Putter
 	Deleter
+	Iteratee
+	Stater
+	Compacter
 	Get(key []byte) ([]byte, error)
 	Has(key []byte) (bool, error)
 	Close()
 	NewBatch() Batch
 }
 
+// Iteratee wraps the creation of iterators over the content of a database. The
+// iterators see the database as of their creation.
+type Iteratee interface {
+	// NewIterator creates an iterator over the entire database, in ascending key
+	// order.
+	NewIterator() Iterator
+
+	// NewIteratorWithStart creates an iterator over the entries whose keys are not
+	// less than the given start, in ascending key order.
+	NewIteratorWithStart(start []byte) Iterator
+
+	// NewIteratorWithPrefix creates an iterator over the entries whose keys start
+	// with the given prefix, in ascending key order.
+	NewIteratorWithPrefix(prefix []byte) Iterator
+}
+
+// Stater wraps the retrieval of the internal statistics of a database.
+type Stater interface {
+	// Stat returns a statistic of the database. The properties are specific to the
+	// storage engine, but all engines understand "stats" as a general overview.
+	Stat(property string) (string, error)
+}
+
+// Compacter wraps the compaction of the storage of a database.
+type Compacter interface {
+	// Compact flattens the storage of the given key range, discarding deleted and
+	// overwritten data. A nil start is treated as a key before all keys, and a nil
+	// limit as a key after all keys, so Compact(nil, nil) compacts everything.
+	Compact(start []byte, limit []byte) error
+}
+
+// Iterator iterates over the key-value pairs of a database in ascending key order.
+// An iterator must be released after use, but it is not safe for concurrent use.
+type Iterator interface {
+	// Next moves the iterator to the next key-value pair, returning whether there
+	// is one. It must be called before accessing the first pair.
+	Next() bool
+
+	// Error returns any failure that stopped the iteration.
+	Error() error
+
+	// Key returns the key of the current pair. The caller must not modify it, and
+	// it is only valid until the iterator is moved.
+	Key() []byte
+
+	// Value returns the value of the current pair. The caller must not modify it,
+	// and it is only valid until the iterator is moved.
+	Value() []byte
+
+	// Release frees up the resources held by the iterator.
+	Release()
+}
+
 // Batch is a write-only database that commits changes to its host database
 // when Write is called. Batch cannot be used concurrently.
 type Batch interface {
@@ -50,3 +106,29 @@ type Batch interface {
 	// Reset resets the batch for reuse
 	Reset()
 }
+
+// AncientReader wraps the read operations of an append-only store of immutable
+// chain data, whose items of every kind are numbered by block from zero.
+type AncientReader interface {
+	// HasAncient returns whether an item of the given kind is frozen.
+	HasAncient(kind string, number uint64) (bool, error)
+
+	// Ancient retrieves a frozen item of the given kind.
+	Ancient(kind string, number uint64) ([]byte, error)
+
+	// Ancients returns the number of frozen blocks.
+	Ancients() (uint64, error)
+}
+
+// AncientWriter wraps the write operations of an append-only store of immutable
+// chain data.
+type AncientWriter interface {
+	// AppendAncient freezes the next block, all of whose items are given.
+	AppendAncient(number uint64, hash, header, body, receipts, td []byte) error
+
+	// TruncateAncients discards all frozen blocks from the given number on.
+	TruncateAncients(items uint64) error
+
+	// Sync flushes the frozen blocks to disk.
+	Sync() error
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+const (
+	kindDelete byte = 0 // Entry shadowing the key in older data
+	kindPut    byte = 1 // Entry holding a value for the key
+)
+
+var (
+	// errCorruptRecord is returned if an encoded entry cannot be decoded.
+	errCorruptRecord = errors.New("corrupt record")
+
+	// crcTable is the checksum table used by log records and table blocks.
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// appendEntry appends the encoding of an entry to a buffer: the kind, followed by
+// the length prefixed key and, for insertions, the length prefixed value.
+func appendEntry(buf []byte, kind byte, key, value []byte) []byte {
+	var size [binary.MaxVarintLen64]byte
+
+	buf = append(buf, kind)
+	buf = append(buf, size[:binary.PutUvarint(size[:], uint64(len(key)))]...)
+	buf = append(buf, key...)
+	if kind == kindPut {
+		buf = append(buf, size[:binary.PutUvarint(size[:], uint64(len(value)))]...)
+		buf = append(buf, value...)
+	}
+	return buf
+}
+
+// decodeEntry decodes the entry at the start of a buffer, returning the rest of
+// the buffer. The key and value point into the buffer.
+func decodeEntry(buf []byte) (kind byte, key, value, rest []byte, err error) {
+	if len(buf) == 0 {
+		return 0, nil, nil, nil, errCorruptRecord
+	}
+	kind, buf = buf[0], buf[1:]
+	if kind != kindPut && kind != kindDelete {
+		return 0, nil, nil, nil, errCorruptRecord
+	}
+	if key, buf, err = decodeBytes(buf); err != nil {
+		return 0, nil, nil, nil, err
+	}
+	if kind == kindPut {
+		if value, buf, err = decodeBytes(buf); err != nil {
+			return 0, nil, nil, nil, err
+		}
+	}
+	return kind, key, value, buf, nil
+}
+
+// decodeBytes decodes a length prefixed byte slice.
+func decodeBytes(buf []byte) ([]byte, []byte, error) {
+	size, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < size {
+		return nil, nil, errCorruptRecord
+	}
+	return buf[n : n+int(size)], buf[n+int(size):], nil
+}
+
+// batch is a write-only batch that commits its changes atomically to the
+// database when Write is called. The changes are kept in their log encoding.
+type batch struct {
+	db   *Database
+	data []byte
+	size int
+}
+
+// Put inserts the given value into the batch for later committing.
+func (b *batch) Put(key, value []byte) error {
+	b.data = appendEntry(b.data, kindPut, key, value)
+	b.size += len(value)
+	return nil
+}
+
+// Delete inserts the a key removal into the batch for later committing.
+func (b *batch) Delete(key []byte) error {
+	b.data = appendEntry(b.data, kindDelete, key, nil)
+	b.size += 1
+	return nil
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *batch) ValueSize() int {
+	return b.size
+}
+
+// Write flushes any accumulated data to disk.
+func (b *batch) Write() error {
+	if len(b.data) == 0 {
+		return nil
+	}
+	return b.db.write(b.data)
+}
+
+// Reset resets the batch for reuse.
+func (b *batch) Reset() {
+	b.data = b.data[:0]
+	b.size = 0
+}
+
+// writeRecord appends a checksummed record holding encoded entries to a log.
+func writeRecord(w io.Writer, data []byte) error {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[:4], crc32.Checksum(data, crcTable))
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+
+	_, err := w.Write(append(header[:], data...))
+	return err
+}
+
+// replayLog calls fn with the contents of every record in a log file. A torn
+// record at the end of the log, left by a crash during a write, ends the replay
+// silently, as that write was never acknowledged.
+func replayLog(path string, fn func(data []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(f, header[:]); err != nil {
+			return nil
+		}
+		data := make([]byte, binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(f, data); err != nil {
+			return nil
+		}
+		if crc32.Checksum(data, crcTable) != binary.BigEndian.Uint32(header[:4]) {
+			return nil
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+}
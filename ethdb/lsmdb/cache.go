@@ -0,0 +1,188 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"container/list"
+	"sync"
+)
+
+// blockKey identifies a data block across all the tables.
+type blockKey struct {
+	file   uint64
+	offset uint64
+}
+
+// blockCache is a size limited LRU cache of decoded data blocks. Blocks of deleted
+// tables are not dropped explicitly, as table numbers are never reused they just
+// age out of the cache.
+type blockCache struct {
+	capacity int // Maximum total size of the cached blocks
+	size     int // Total size of the cached blocks
+
+	items map[blockKey]*list.Element
+	lru   *list.List // Cached blocks, most recently used first
+	lock  sync.Mutex
+}
+
+// blockCacheItem is an element of the LRU list of the block cache.
+type blockCacheItem struct {
+	key blockKey
+	blk *block
+}
+
+// newBlockCache creates a block cache holding at most the given number of bytes.
+func newBlockCache(capacity int) *blockCache {
+	return &blockCache{
+		capacity: capacity,
+		items:    make(map[blockKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// get retrieves a cached block, or nil if it is not cached.
+func (c *blockCache) get(key blockKey) *block {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*blockCacheItem).blk
+	}
+	return nil
+}
+
+// add inserts a block into the cache, evicting the least recently used ones if
+// the cache grew too large.
+func (c *blockCache) add(key blockKey, blk *block) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.items[key]; ok {
+		return
+	}
+	c.items[key] = c.lru.PushFront(&blockCacheItem{key: key, blk: blk})
+	c.size += blk.size
+
+	for c.size > c.capacity && c.lru.Len() > 0 {
+		item := c.lru.Remove(c.lru.Back()).(*blockCacheItem)
+		delete(c.items, item.key)
+		c.size -= item.blk.size
+	}
+}
+
+// tableCache is an LRU cache of opened table readers, limiting the number of open
+// files. Readers in use are never closed, so the cache may temporarily hold more
+// of them than its capacity.
+type tableCache struct {
+	db       *Database
+	capacity int
+
+	readers map[uint64]*list.Element
+	lru     *list.List // Cached readers, most recently used first
+	lock    sync.Mutex
+}
+
+// newTableCache creates a table cache keeping at most the given number of files
+// open.
+func newTableCache(db *Database, capacity int) *tableCache {
+	return &tableCache{
+		db:       db,
+		capacity: capacity,
+		readers:  make(map[uint64]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// get retrieves the reader of a table, opening the file if needed. The reader
+// must be released after use.
+func (c *tableCache) get(num uint64) (*tableReader, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if elem, ok := c.readers[num]; ok {
+		c.lru.MoveToFront(elem)
+		r := elem.Value.(*tableReader)
+		r.refs++
+		return r, nil
+	}
+	r, err := openTable(c.db.tablePath(num), num)
+	if err != nil {
+		return nil, err
+	}
+	r.refs++
+	c.readers[num] = c.lru.PushFront(r)
+
+	// Close the least recently used readers not in use anymore
+	for elem := c.lru.Back(); elem != nil && len(c.readers) > c.capacity; {
+		prev := elem.Prev()
+		if old := elem.Value.(*tableReader); old.refs == 0 {
+			c.lru.Remove(elem)
+			delete(c.readers, old.num)
+			old.file.Close()
+		}
+		elem = prev
+	}
+	return r, nil
+}
+
+// release returns a reader obtained from the cache, closing it if it was evicted
+// in the meantime.
+func (c *tableCache) release(r *tableReader) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	r.refs--
+	if r.refs == 0 && r.evicted {
+		r.file.Close()
+	}
+}
+
+// evict drops the reader of a table about to be deleted from the cache.
+func (c *tableCache) evict(num uint64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.readers[num]
+	if !ok {
+		return
+	}
+	c.lru.Remove(elem)
+	delete(c.readers, num)
+
+	r := elem.Value.(*tableReader)
+	r.evicted = true
+	if r.refs == 0 {
+		r.file.Close()
+	}
+}
+
+// close closes all the cached readers.
+func (c *tableCache) close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for num, elem := range c.readers {
+		r := elem.Value.(*tableReader)
+		r.evicted = true
+		if r.refs == 0 {
+			r.file.Close()
+		}
+		delete(c.readers, num)
+	}
+	c.lru.Init()
+}
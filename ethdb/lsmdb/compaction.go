@@ -0,0 +1,323 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// compaction merges tables of a level with the overlapping ones of the next level
+// into new tables of the next level.
+type compaction struct {
+	level  int
+	inputs [2][]*tableMeta // Tables of the level and of the next one
+
+	smallest, largest []byte // Key range of all the inputs
+	dropDeletes       bool   // Whether no deeper level holds keys in the range
+}
+
+// maxLevelSize returns the size of a level starting its compaction.
+func (db *Database) maxLevelSize(level int) uint64 {
+	size := db.opts.level1MaxSize
+	for ; level > 1; level-- {
+		size *= 10
+	}
+	return size
+}
+
+// pickCompaction selects the level furthest over its size limit and the tables
+// to compact from it, or returns nil if all the levels are within limits. The
+// lock must be held.
+func (db *Database) pickCompaction() *compaction {
+	var (
+		v         = db.current
+		level     = -1
+		bestScore = 1.0
+	)
+	for i := 0; i < numLevels-1; i++ {
+		var score float64
+		if i == 0 {
+			score = float64(len(v.levels[0])) / l0CompactionTrigger
+		} else {
+			score = float64(v.size(i)) / float64(db.maxLevelSize(i))
+		}
+		if score >= bestScore {
+			level, bestScore = i, score
+		}
+	}
+	if level < 0 {
+		return nil
+	}
+	if level == 0 {
+		// Level 0 tables overlap each other, all of them are compacted together
+		return db.newCompaction(0, v.levels[0])
+	}
+	// Pick the tables of deeper levels round-robin, to spread the compactions over
+	// the whole key space
+	tables := v.levels[level]
+	i := sort.Search(len(tables), func(i int) bool {
+		return bytes.Compare(tables[i].Smallest, db.compactPtr[level]) > 0
+	})
+	if i == len(tables) {
+		i = 0
+	}
+	return db.newCompaction(level, []*tableMeta{tables[i]})
+}
+
+// rangeCompaction selects the tables of a level intersecting the given key range,
+// or returns nil if there are none. A nil limit is treated as a key after all
+// keys. The lock must be held.
+func (db *Database) rangeCompaction(level int, start, limit []byte) *compaction {
+	var inputs []*tableMeta
+	for _, t := range db.current.levels[level] {
+		if bytes.Compare(t.Largest, start) >= 0 && (limit == nil || bytes.Compare(t.Smallest, limit) < 0) {
+			inputs = append(inputs, t)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil
+	}
+	if level == 0 {
+		// Level 0 tables overlap each other, all of them are compacted together
+		// so that no older entry of a key stays behind
+		inputs = db.current.levels[0]
+	}
+	return db.newCompaction(level, inputs)
+}
+
+// newCompaction creates a compaction of the given tables of a level, collecting
+// the overlapping tables of the next level. The lock must be held.
+func (db *Database) newCompaction(level int, inputs []*tableMeta) *compaction {
+	v := db.current
+
+	c := &compaction{level: level}
+	c.inputs[0] = append([]*tableMeta{}, inputs...)
+	c.smallest, c.largest = keyRange(c.inputs[0])
+	c.inputs[1] = v.overlapping(level+1, c.smallest, c.largest)
+	c.smallest, c.largest = keyRange(append(append([]*tableMeta{}, c.inputs[0]...), c.inputs[1]...))
+
+	c.dropDeletes = true
+	for i := level + 2; i < numLevels; i++ {
+		if len(v.overlapping(i, c.smallest, c.largest)) > 0 {
+			c.dropDeletes = false
+		}
+	}
+	return c
+}
+
+// keyRange returns the smallest and largest keys of a set of tables.
+func keyRange(tables []*tableMeta) (smallest, largest []byte) {
+	for _, t := range tables {
+		if smallest == nil || bytes.Compare(t.Smallest, smallest) < 0 {
+			smallest = t.Smallest
+		}
+		if largest == nil || bytes.Compare(t.Largest, largest) > 0 {
+			largest = t.Largest
+		}
+	}
+	return smallest, largest
+}
+
+// compact runs one compaction if any level is over its size limit, returning
+// false if there was nothing to compact.
+func (db *Database) compact() (bool, error) {
+	db.compactLock.Lock()
+	defer db.compactLock.Unlock()
+
+	db.mu.Lock()
+	c := db.pickCompaction()
+	db.mu.Unlock()
+
+	if c == nil {
+		return false, nil
+	}
+	return true, db.runCompaction(c)
+}
+
+// Compact flattens the storage of the given key range, by flushing the memory
+// table and compacting the intersecting tables of every level into the next one.
+// A nil start is treated as a key before all keys, and a nil limit as a key after
+// all keys.
+func (db *Database) Compact(start []byte, limit []byte) error {
+	if err := db.flushMemory(); err != nil {
+		return err
+	}
+	db.compactLock.Lock()
+	defer db.compactLock.Unlock()
+
+	for level := 0; level < numLevels-1; level++ {
+		db.mu.Lock()
+		if db.closed {
+			db.mu.Unlock()
+			return errClosed
+		}
+		c := db.rangeCompaction(level, start, limit)
+		db.mu.Unlock()
+
+		if c == nil {
+			continue
+		}
+		if err := db.runCompaction(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCompaction writes the outputs of a compaction and replaces its inputs with
+// them. The compaction lock must be held.
+func (db *Database) runCompaction(c *compaction) error {
+	db.mu.Lock()
+	v := db.current
+	v.retain()
+	db.mu.Unlock()
+
+	defer v.release()
+
+	// A single table not overlapping the next level can simply be moved down
+	var outputs []*tableMeta
+	if c.level > 0 && len(c.inputs[1]) == 0 {
+		outputs = c.inputs[0]
+	} else {
+		var err error
+		if outputs, err = db.merge(c); err != nil {
+			return err
+		}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Replace the inputs with the outputs. Only flushes ran since the compaction was
+	// picked, adding level 0 tables that are newer than all the inputs.
+	levels := db.current.levels
+	levels[c.level] = removeTables(levels[c.level], c.inputs[0])
+	next := append(removeTables(levels[c.level+1], c.inputs[1]), outputs...)
+	sort.Slice(next, func(i, j int) bool {
+		return bytes.Compare(next[i].Smallest, next[j].Smallest) < 0
+	})
+	levels[c.level+1] = next
+
+	if err := db.commit(levels); err != nil {
+		for _, t := range outputs {
+			if t.refs == 0 {
+				db.deleteTable(t.Num)
+			}
+		}
+		return err
+	}
+	if c.level > 0 {
+		db.compactPtr[c.level] = c.inputs[0][len(c.inputs[0])-1].Largest
+	}
+	db.log.Debug("Compacted database tables", "level", c.level, "inputs", len(c.inputs[0])+len(c.inputs[1]), "outputs", len(outputs))
+	return nil
+}
+
+// removeTables returns the tables of a level without the given ones.
+func removeTables(tables []*tableMeta, remove []*tableMeta) []*tableMeta {
+	removed := make(map[uint64]bool)
+	for _, t := range remove {
+		removed[t.Num] = true
+	}
+	var kept []*tableMeta
+	for _, t := range tables {
+		if !removed[t.Num] {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// merge writes the entries of the compaction inputs into new tables, keeping the
+// newest entry of every key.
+func (db *Database) merge(c *compaction) ([]*tableMeta, error) {
+	var sources []internalIterator
+	if c.level == 0 {
+		for _, t := range c.inputs[0] {
+			sources = append(sources, &levelIterator{db: db, tables: []*tableMeta{t}})
+		}
+	} else {
+		sources = append(sources, &levelIterator{db: db, tables: c.inputs[0]})
+	}
+	sources = append(sources, &levelIterator{db: db, tables: c.inputs[1]})
+
+	it := &mergingIterator{sources: sources}
+	defer it.release()
+
+	var (
+		outputs []*tableMeta
+		w       *tableWriter
+		num     uint64
+	)
+	abort := func() {
+		if w != nil {
+			w.abort()
+		}
+		for _, t := range outputs {
+			db.deleteTable(t.Num)
+		}
+	}
+	finish := func() error {
+		size, err := w.finish()
+		if err != nil {
+			return err
+		}
+		outputs = append(outputs, &tableMeta{Num: num, Size: size, Smallest: w.smallest, Largest: common.CopyBytes(w.largest)})
+		w = nil
+		return nil
+	}
+	for it.seek(nil); it.valid(); it.next() {
+		e := it.entry()
+		if e.kind == kindDelete && c.dropDeletes {
+			continue
+		}
+		if w == nil {
+			db.mu.Lock()
+			num = db.allocFile()
+			db.mu.Unlock()
+
+			var err error
+			if w, err = newTableWriter(db.tablePath(num)); err != nil {
+				abort()
+				return nil, err
+			}
+		}
+		if err := w.add(e.kind, e.key, e.value); err != nil {
+			abort()
+			return nil, err
+		}
+		if w.size() >= db.opts.tableSize {
+			if err := finish(); err != nil {
+				abort()
+				return nil, err
+			}
+		}
+	}
+	if err := it.error(); err != nil {
+		abort()
+		return nil, err
+	}
+	if w != nil {
+		if err := finish(); err != nil {
+			abort()
+			return nil, err
+		}
+	}
+	return outputs, nil
+}
@@ -0,0 +1,667 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package lsmdb implements a pure Go log-structured merge tree key-value store,
+// an alternative storage engine for the chain database.
+//
+// Writes are appended to a log and inserted into a sorted memory table. Full
+// memory tables are flushed into immutable table files in the background, and
+// the table files are merged into levels of growing size, each with disjoint
+// key ranges, so that a read checks at most one table per level.
+package lsmdb
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/prometheus/prometheus/util/flock"
+)
+
+// EngineName is the name the engine is registered under in ethdb.
+const EngineName = "lsmdb"
+
+const (
+	l0CompactionTrigger = 4  // Number of level 0 tables starting a compaction
+	l0StopTrigger       = 12 // Number of level 0 tables stalling the writes
+
+	minWriteBuffer  = 4 * 1024 * 1024  // Minimum size of the memory table before flushing it
+	tableTargetSize = 8 * 1024 * 1024  // Size of the tables written by compactions
+	level1MaxSize   = 64 * 1024 * 1024 // Size of level 1 starting a compaction, ten fold for every deeper level
+)
+
+var (
+	// errNotFound is returned if a key is not in the database.
+	errNotFound = errors.New("not found")
+
+	// errClosed is returned if the database is used after being closed.
+	errClosed = errors.New("database closed")
+)
+
+func init() {
+	ethdb.RegisterEngine(EngineName, func(file string, cache, handles int) (ethdb.Database, error) {
+		return New(file, cache, handles)
+	}, Exists)
+}
+
+// options are the tunables of the database, exposed for tests to exercise the
+// flushing and compaction with small amounts of data.
+type options struct {
+	writeBuffer   int    // Size of the memory table before flushing it
+	blockCache    int    // Size of the data block cache
+	openTables    int    // Maximum number of table files kept open
+	tableSize     uint64 // Size of the tables written by compactions
+	level1MaxSize uint64 // Size of level 1 starting a compaction
+}
+
+// Database is a persistent key-value store implementing ethdb.Database.
+type Database struct {
+	dir  string
+	opts options
+	lock flock.Releaser // Prevents concurrent use of the database directory
+	log  log.Logger
+
+	writeLock   sync.Mutex // Serializes the writers
+	compactLock sync.Mutex // Serializes the compactions
+
+	mu       sync.Mutex // Protects the fields below
+	cond     *sync.Cond // Signalled when the background work progressed
+	mem      *memTable  // Memory table receiving the writes
+	imm      *memTable  // Full memory table being flushed, if any
+	seq      uint64     // Sequence number of the last write visible to readers
+	current  *version   // Current set of tables
+	nextFile uint64     // Number of the next log or table file
+	logNum   uint64     // Number of the log backing the memory table
+	logFile  *os.File   // Log backing the memory table
+	immLog   uint64     // Number of the log backing the flushed memory table
+	bgErr    error      // Failure of the background work, stopping the writes
+	closed   bool
+
+	compactPtr [numLevels][]byte // Key after which the next compaction of each level starts
+
+	tables *tableCache
+	blocks *blockCache
+
+	wake chan struct{} // Notifies the background goroutine of new work
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// Exists returns whether a directory holds a database of this engine.
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, manifestName))
+	return err == nil
+}
+
+// New opens the database in the given directory, creating it if needed. The
+// cache allowance is given in megabytes, the handles limit the open table files.
+func New(file string, cache int, handles int) (*Database, error) {
+	// Ensure we have some minimal caching and file guarantees, like LevelDB
+	if cache < 16 {
+		cache = 16
+	}
+	if handles < 16 {
+		handles = 16
+	}
+	log.New("database", file).Info("Allocated cache and file handles", "cache", cache, "handles", handles)
+
+	writeBuffer := cache / 4 * 1024 * 1024
+	if writeBuffer < minWriteBuffer {
+		writeBuffer = minWriteBuffer
+	}
+	return open(file, options{
+		writeBuffer:   writeBuffer,
+		blockCache:    cache / 2 * 1024 * 1024,
+		openTables:    handles,
+		tableSize:     tableTargetSize,
+		level1MaxSize: level1MaxSize,
+	})
+}
+
+// open opens the database in the given directory with explicit options.
+func open(dir string, opts options) (*Database, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	lock, _, err := flock.New(filepath.Join(dir, "LOCK"))
+	if err != nil {
+		return nil, err
+	}
+	db := &Database{
+		dir:    dir,
+		opts:   opts,
+		lock:   lock,
+		log:    log.New("database", dir),
+		blocks: newBlockCache(opts.blockCache),
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	db.cond = sync.NewCond(&db.mu)
+	db.tables = newTableCache(db, opts.openTables)
+
+	if err := db.recover(); err != nil {
+		db.tables.close()
+		lock.Release()
+		return nil, err
+	}
+	db.wg.Add(1)
+	go db.background()
+	db.schedule()
+
+	return db, nil
+}
+
+// recover loads the manifest, flushes the writes of the leftover logs into a new
+// table, and starts a new log.
+func (db *Database) recover() error {
+	m, err := readManifest(db.dir)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = &manifest{NextFile: 1}
+	}
+	db.nextFile = m.NextFile
+	db.current = newVersion(db, m.Levels)
+
+	// Collect the files in the directory, dropping the ones not in use anymore
+	files, err := ioutil.ReadDir(db.dir)
+	if err != nil {
+		return err
+	}
+	live := make(map[uint64]bool)
+	for _, tables := range m.Levels {
+		for _, t := range tables {
+			live[t.Num] = true
+		}
+	}
+	var logs []uint64
+	for _, file := range files {
+		name := file.Name()
+		num, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSuffix(name, ".log"), ".sst"), 10, 64)
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(name, ".log") && num >= m.Log:
+			logs = append(logs, num)
+		case strings.HasSuffix(name, ".log"), strings.HasSuffix(name, ".sst") && !live[num]:
+			os.Remove(filepath.Join(db.dir, name))
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool { return logs[i] < logs[j] })
+
+	// Replay the logs into a memory table, flushing it if anything was recovered
+	var (
+		mem     = newMemTable()
+		records uint64
+	)
+	for _, num := range logs {
+		err := replayLog(db.logPath(num), func(data []byte) error {
+			records++
+			return mem.add(records, data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	levels := m.Levels
+	if !mem.empty() {
+		db.log.Info("Recovered database writes from log", "writes", records, "logs", len(logs))
+
+		meta, err := db.writeTable(db.allocFile(), mem.newIterator(records))
+		if err != nil {
+			return err
+		}
+		levels[0] = append([]*tableMeta{meta}, levels[0]...)
+	}
+	// Start a new log and record it, only then drop the replayed ones
+	db.logNum = db.allocFile()
+	if db.logFile, err = os.OpenFile(db.logPath(db.logNum), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return err
+	}
+	if err := writeManifest(db.dir, &manifest{NextFile: db.nextFile, Log: db.logNum, Levels: levels}); err != nil {
+		db.logFile.Close()
+		return err
+	}
+	db.installVersion(levels)
+	db.mem = newMemTable()
+
+	for _, num := range logs {
+		os.Remove(db.logPath(num))
+	}
+	return nil
+}
+
+// logPath returns the path of a log file.
+func (db *Database) logPath(num uint64) string {
+	return filepath.Join(db.dir, fmt.Sprintf("%06d.log", num))
+}
+
+// tablePath returns the path of a table file.
+func (db *Database) tablePath(num uint64) string {
+	return filepath.Join(db.dir, fmt.Sprintf("%06d.sst", num))
+}
+
+// allocFile reserves the number of a new log or table file.
+func (db *Database) allocFile() uint64 {
+	num := db.nextFile
+	db.nextFile++
+	return num
+}
+
+// installVersion replaces the current version with one of the given levels. The
+// lock must be held, or the database not yet in use.
+func (db *Database) installVersion(levels [numLevels][]*tableMeta) {
+	old := db.current
+	db.current = newVersion(db, levels)
+	old.release()
+}
+
+// deleteTable removes a table file not referred to by any version anymore.
+func (db *Database) deleteTable(num uint64) {
+	db.tables.evict(num)
+	if err := os.Remove(db.tablePath(num)); err != nil && !os.IsNotExist(err) {
+		db.log.Error("Failed to delete table", "table", num, "err", err)
+	}
+}
+
+// acquire returns the memory tables, the last write visible in them and the
+// version to read from. The version must be released after use.
+func (db *Database) acquire() (*memTable, *memTable, uint64, *version, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil, nil, 0, nil, errClosed
+	}
+	db.current.retain()
+	return db.mem, db.imm, db.seq, db.current, nil
+}
+
+// Put inserts the given value into the database.
+func (db *Database) Put(key []byte, value []byte) error {
+	return db.write(appendEntry(nil, kindPut, key, value))
+}
+
+// Delete removes the key from the database.
+func (db *Database) Delete(key []byte) error {
+	return db.write(appendEntry(nil, kindDelete, key, nil))
+}
+
+// Has retrieves if a key is present in the database.
+func (db *Database) Has(key []byte) (bool, error) {
+	if _, err := db.Get(key); err != nil {
+		if err == errNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Get retrieves the value of a key if it's present in the database.
+func (db *Database) Get(key []byte) ([]byte, error) {
+	mem, imm, seq, v, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer v.release()
+
+	e := mem.get(key, seq)
+	if e == nil && imm != nil {
+		e = imm.get(key, seq)
+	}
+	if e == nil {
+		if e, err = v.get(key); err != nil {
+			return nil, err
+		}
+	}
+	if e == nil || e.kind == kindDelete {
+		return nil, errNotFound
+	}
+	return common.CopyBytes(e.value), nil
+}
+
+// NewBatch creates a write-only batch committing its changes atomically.
+func (db *Database) NewBatch() ethdb.Batch {
+	return &batch{db: db}
+}
+
+// NewIterator creates an iterator over all the key-value pairs of the database,
+// in ascending key order. The iterator sees the database as of its creation.
+func (db *Database) NewIterator() ethdb.Iterator {
+	return db.newIterator(nil, nil)
+}
+
+// NewIteratorWithStart creates an iterator over the key-value pairs of the
+// database whose keys are not less than the given start, in ascending key order.
+// The iterator sees the database as of its creation.
+func (db *Database) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return db.newIterator(nil, start)
+}
+
+// NewIteratorWithPrefix creates an iterator over the key-value pairs of the
+// database whose keys start with the given prefix, in ascending key order. The
+// iterator sees the database as of its creation.
+func (db *Database) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return db.newIterator(prefix, prefix)
+}
+
+// newIterator creates an iterator over the key-value pairs with the given prefix,
+// starting at the given key.
+func (db *Database) newIterator(prefix []byte, start []byte) ethdb.Iterator {
+	mem, imm, seq, v, err := db.acquire()
+	if err != nil {
+		return &dbIterator{err: err}
+	}
+	sources := []internalIterator{mem.newIterator(seq)}
+	if imm != nil {
+		sources = append(sources, imm.newIterator(seq))
+	}
+	sources = append(sources, v.iterators()...)
+
+	return &dbIterator{
+		merged:  &mergingIterator{sources: sources},
+		version: v,
+		prefix:  common.CopyBytes(prefix),
+		start:   common.CopyBytes(start),
+	}
+}
+
+// write logs encoded entries and inserts them into the memory table.
+func (db *Database) write(data []byte) error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	if err := db.makeRoomForWrite(); err != nil {
+		return err
+	}
+	if err := writeRecord(db.logFile, data); err != nil {
+		return err
+	}
+	// Insert the entries tagged with a new sequence number, only making them
+	// visible to the readers once all of them are in place
+	seq := db.seq + 1
+	if err := db.mem.add(seq, data); err != nil {
+		return err
+	}
+	db.mu.Lock()
+	db.seq = seq
+	db.mu.Unlock()
+	return nil
+}
+
+// makeRoomForWrite stalls the writes while the tables are too far behind, and
+// rotates the memory table once it is full.
+func (db *Database) makeRoomForWrite() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for {
+		switch {
+		case db.closed:
+			return errClosed
+
+		case db.bgErr != nil:
+			return db.bgErr
+
+		case len(db.current.levels[0]) >= l0StopTrigger:
+			db.cond.Wait()
+
+		case db.mem.size() < db.opts.writeBuffer:
+			return nil
+
+		case db.imm != nil:
+			db.cond.Wait()
+
+		default:
+			// Memory table full, move it aside
+			return db.rotate()
+		}
+	}
+}
+
+// flushMemory moves the memory table aside and waits until it is flushed into a
+// table, so that all the data is in tables.
+func (db *Database) flushMemory() error {
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for {
+		switch {
+		case db.closed:
+			return errClosed
+
+		case db.bgErr != nil:
+			return db.bgErr
+
+		case db.imm != nil:
+			db.cond.Wait()
+
+		case db.mem.empty():
+			return nil
+
+		default:
+			if err := db.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// rotate moves the memory table aside to be flushed, and starts a new log for
+// its successor. Both locks must be held and no memory table be in the flush.
+func (db *Database) rotate() error {
+	num := db.allocFile()
+	file, err := os.OpenFile(db.logPath(num), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	db.logFile.Close()
+	db.imm, db.immLog = db.mem, db.logNum
+	db.mem, db.logNum, db.logFile = newMemTable(), num, file
+	db.schedule()
+	return nil
+}
+
+// schedule notifies the background goroutine that there may be work to do.
+func (db *Database) schedule() {
+	select {
+	case db.wake <- struct{}{}:
+	default:
+	}
+}
+
+// background flushes the full memory tables and compacts the tables, until the
+// database is closed.
+func (db *Database) background() {
+	defer db.wg.Done()
+
+	for {
+		select {
+		case <-db.wake:
+		case <-db.quit:
+			return
+		}
+		for {
+			select {
+			case <-db.quit:
+				return
+			default:
+			}
+			done, err := db.step()
+			if err != nil {
+				db.log.Error("Database background work failed", "err", err)
+
+				db.mu.Lock()
+				db.bgErr = err
+				db.mu.Unlock()
+			}
+			db.cond.Broadcast()
+			if !done || err != nil {
+				break
+			}
+		}
+	}
+}
+
+// step does one unit of background work: flushing the full memory table, or
+// else one compaction. It returns false if there was nothing to do.
+func (db *Database) step() (bool, error) {
+	db.mu.Lock()
+	imm := db.imm
+	db.mu.Unlock()
+
+	if imm != nil {
+		return true, db.flush(imm)
+	}
+	return db.compact()
+}
+
+// flush writes the full memory table into a level 0 table.
+func (db *Database) flush(imm *memTable) error {
+	db.mu.Lock()
+	num := db.allocFile()
+	db.mu.Unlock()
+
+	meta, err := db.writeTable(num, imm.newIterator(math.MaxUint64))
+	if err != nil {
+		return err
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	levels := db.current.levels
+	levels[0] = append([]*tableMeta{meta}, levels[0]...)
+
+	db.imm = nil
+	if err := db.commit(levels); err != nil {
+		db.imm = imm
+		return err
+	}
+	os.Remove(db.logPath(db.immLog))
+	return nil
+}
+
+// commit records a new set of tables in the manifest, along with the oldest log
+// not yet flushed, and installs it as current version. The lock must be held.
+func (db *Database) commit(levels [numLevels][]*tableMeta) error {
+	m := &manifest{NextFile: db.nextFile, Log: db.logNum, Levels: levels}
+	if db.imm != nil {
+		m.Log = db.immLog
+	}
+	if err := writeManifest(db.dir, m); err != nil {
+		return err
+	}
+	db.installVersion(levels)
+	return nil
+}
+
+// writeTable writes all the entries of an iterator into a new table.
+func (db *Database) writeTable(num uint64, it internalIterator) (*tableMeta, error) {
+	defer it.release()
+
+	w, err := newTableWriter(db.tablePath(num))
+	if err != nil {
+		return nil, err
+	}
+	for it.seek(nil); it.valid(); it.next() {
+		e := it.entry()
+		if err := w.add(e.kind, e.key, e.value); err != nil {
+			w.abort()
+			return nil, err
+		}
+	}
+	if err := it.error(); err != nil {
+		w.abort()
+		return nil, err
+	}
+	meta := &tableMeta{Num: num, Smallest: w.smallest, Largest: common.CopyBytes(w.largest)}
+	if meta.Size, err = w.finish(); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// Stat returns a statistic of the database. The only property is "stats", with
+// an optional "lsmdb." prefix, giving an overview of the memory tables and the
+// tables of every level.
+func (db *Database) Stat(property string) (string, error) {
+	if strings.TrimPrefix(property, EngineName+".") != "stats" {
+		return "", fmt.Errorf("unknown property %q", property)
+	}
+	mem, imm, _, v, err := db.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer v.release()
+
+	memSize := mem.size()
+	if imm != nil {
+		memSize += imm.size()
+	}
+	stats := fmt.Sprintf("Memory tables: %v\n", common.StorageSize(memSize))
+	stats += " Level | Tables |   Size(MB)\n"
+	stats += "-------+--------+------------\n"
+	for level, tables := range v.levels {
+		if len(tables) > 0 {
+			stats += fmt.Sprintf(" %5d | %6d | %10.5f\n", level, len(tables), float64(v.size(level))/1048576.0)
+		}
+	}
+	return stats, nil
+}
+
+// Close stops the background work and closes the database. The writes not yet
+// flushed into tables are recovered from the log on the next open.
+func (db *Database) Close() {
+	db.mu.Lock()
+	if db.closed {
+		db.mu.Unlock()
+		return
+	}
+	db.closed = true
+	db.cond.Broadcast()
+	db.mu.Unlock()
+
+	close(db.quit)
+	db.wg.Wait()
+
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+
+	if err := db.logFile.Sync(); err != nil {
+		db.log.Error("Failed to sync database log", "err", err)
+	}
+	db.logFile.Close()
+	db.tables.close()
+	db.lock.Release()
+
+	db.log.Info("Database closed")
+}
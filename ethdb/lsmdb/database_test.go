@@ -0,0 +1,167 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/dbtest"
+)
+
+// testOptions are tiny database limits, so that a few thousand entries go through
+// all the flushing and compaction paths.
+var testOptions = options{
+	writeBuffer:   16 * 1024,
+	blockCache:    64 * 1024,
+	openTables:    4,
+	tableSize:     8 * 1024,
+	level1MaxSize: 32 * 1024,
+}
+
+func TestConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var count int
+	dbtest.TestDatabaseSuite(t, func() ethdb.Database {
+		count++
+		db, err := open(filepath.Join(dir, fmt.Sprint(count)), testOptions)
+		if err != nil {
+			t.Fatalf("failed to open database: %v", err)
+		}
+		return db
+	})
+}
+
+// Tests that entries survive flushes, compactions and reopening the database, and
+// that deletions keep shadowing the older values throughout.
+func TestFlushCompactReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := open(dir, testOptions)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	// Write enough entries to fill a few levels, overwriting and deleting some
+	const entries = 5000
+	value := func(i, round int) []byte {
+		return bytes.Repeat([]byte{byte(i), byte(round)}, 16)
+	}
+	for round := 0; round < 3; round++ {
+		b := db.NewBatch()
+		for i := 0; i < entries; i++ {
+			key := []byte(fmt.Sprintf("key-%05d", (i*7919)%entries))
+			if round == 2 && i%3 == 0 {
+				b.Delete(key)
+			} else {
+				b.Put(key, value(i, round))
+			}
+			if b.ValueSize() > 4*1024 {
+				if err := b.Write(); err != nil {
+					t.Fatalf("round %d: batch write failed: %v", round, err)
+				}
+				b.Reset()
+			}
+		}
+		if err := b.Write(); err != nil {
+			t.Fatalf("round %d: batch write failed: %v", round, err)
+		}
+	}
+	check := func(db *Database) {
+		t.Helper()
+		for i := 0; i < entries; i++ {
+			key := []byte(fmt.Sprintf("key-%05d", (i*7919)%entries))
+			data, err := db.Get(key)
+			if i%3 == 0 {
+				if err == nil {
+					t.Fatalf("entry %d: deleted key still present", i)
+				}
+				continue
+			}
+			if err != nil || !bytes.Equal(data, value(i, 2)) {
+				t.Fatalf("entry %d: value mismatch: have %x (%v), want %x", i, data, err, value(i, 2))
+			}
+		}
+		it := db.NewIteratorWithPrefix([]byte("key-"))
+		defer it.Release()
+
+		var count int
+		for it.Next() {
+			count++
+		}
+		if err := it.Error(); err != nil {
+			t.Fatalf("iteration failed: %v", err)
+		}
+		if want := entries - (entries+2)/3; count != want {
+			t.Fatalf("live entry count mismatch: have %d, want %d", count, want)
+		}
+	}
+	check(db)
+
+	db.mu.Lock()
+	var deep bool
+	for _, tables := range db.current.levels[2:] {
+		deep = deep || len(tables) > 0
+	}
+	db.mu.Unlock()
+	if !deep {
+		t.Errorf("entries not compacted into the deeper levels")
+	}
+	// Reopen the database, recovering the unflushed writes from the log
+	db.Close()
+	if db, err = open(dir, testOptions); err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	check(db)
+}
+
+// Tests that a database of one engine is not opened by another.
+func TestEngineMismatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := ethdb.OpenEngine(EngineName, dir, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	db.Close()
+
+	if _, err := ethdb.OpenEngine(ethdb.DefaultEngine, dir, 0, 0); err == nil {
+		t.Fatalf("database opened by another engine")
+	}
+	if db, err = ethdb.OpenEngine(EngineName, dir, 0, 0); err != nil {
+		t.Fatalf("failed to reopen database: %v", err)
+	}
+	db.Close()
+}
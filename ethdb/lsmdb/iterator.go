@@ -0,0 +1,303 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"bytes"
+	"sort"
+)
+
+// internalIterator iterates over the entries of a data source in ascending key
+// order, including the deletion markers.
+type internalIterator interface {
+	// seek positions the iterator at the first entry whose key is not less than
+	// the given one.
+	seek(key []byte)
+
+	// next moves the iterator to the next entry.
+	next()
+
+	// valid returns whether the iterator is positioned at an entry.
+	valid() bool
+
+	// entry returns the entry the iterator is positioned at.
+	entry() *entry
+
+	// error returns any failure that stopped the iteration.
+	error() error
+
+	// release frees up the resources held by the iterator.
+	release()
+}
+
+// tableIterator iterates over the entries of a table.
+type tableIterator struct {
+	r     *tableReader
+	cache *blockCache
+
+	index int    // Index of the current block
+	blk   *block // Current block, nil if exhausted
+	pos   int    // Position of the current entry in the block
+	err   error
+}
+
+func (it *tableIterator) seek(key []byte) {
+	it.index = sort.Search(len(it.r.index), func(i int) bool {
+		return bytes.Compare(it.r.index[i].last, key) >= 0
+	})
+	if it.load() {
+		it.pos = it.blk.search(key)
+		it.skipEmpty()
+	}
+}
+
+func (it *tableIterator) next() {
+	it.pos++
+	it.skipEmpty()
+}
+
+func (it *tableIterator) valid() bool   { return it.blk != nil }
+func (it *tableIterator) entry() *entry { return &it.blk.entries[it.pos] }
+func (it *tableIterator) error() error  { return it.err }
+func (it *tableIterator) release()      { it.blk = nil }
+
+// load retrieves the current block, returning whether there is one.
+func (it *tableIterator) load() bool {
+	it.blk = nil
+	if it.index >= len(it.r.index) || it.err != nil {
+		return false
+	}
+	it.blk, it.err = it.r.readBlock(it.index, it.cache)
+	return it.err == nil
+}
+
+// skipEmpty moves on to the next block while the current one is exhausted.
+func (it *tableIterator) skipEmpty() {
+	for it.blk != nil && it.pos >= len(it.blk.entries) {
+		it.index++
+		it.pos = 0
+		it.load()
+	}
+}
+
+// levelIterator iterates over the entries of a sorted run of tables with disjoint
+// key ranges, opening them one after the other.
+type levelIterator struct {
+	db     *Database
+	tables []*tableMeta
+
+	index int            // Index of the current table
+	cur   *tableIterator // Iterator of the current table, nil if exhausted
+	err   error
+}
+
+func (it *levelIterator) seek(key []byte) {
+	it.index = sort.Search(len(it.tables), func(i int) bool {
+		return bytes.Compare(it.tables[i].Largest, key) >= 0
+	})
+	if it.open() {
+		it.cur.seek(key)
+		it.skipEmpty()
+	}
+}
+
+func (it *levelIterator) next() {
+	it.cur.next()
+	it.skipEmpty()
+}
+
+func (it *levelIterator) valid() bool   { return it.cur != nil && it.cur.valid() }
+func (it *levelIterator) entry() *entry { return it.cur.entry() }
+
+func (it *levelIterator) error() error {
+	if it.err == nil && it.cur != nil {
+		return it.cur.error()
+	}
+	return it.err
+}
+
+func (it *levelIterator) release() { it.close() }
+
+// open opens the current table, returning whether there is one.
+func (it *levelIterator) open() bool {
+	it.close()
+	if it.index >= len(it.tables) || it.err != nil {
+		return false
+	}
+	r, err := it.db.tables.get(it.tables[it.index].Num)
+	if err != nil {
+		it.err = err
+		return false
+	}
+	it.cur = &tableIterator{r: r, cache: it.db.blocks}
+	return true
+}
+
+// close releases the current table.
+func (it *levelIterator) close() {
+	if it.cur != nil {
+		if it.err == nil {
+			it.err = it.cur.error()
+		}
+		it.db.tables.release(it.cur.r)
+		it.cur = nil
+	}
+}
+
+// skipEmpty moves on to the next table while the current one is exhausted.
+func (it *levelIterator) skipEmpty() {
+	for it.cur != nil && !it.cur.valid() {
+		if it.cur.error() != nil {
+			it.close()
+			return
+		}
+		it.index++
+		if it.open() {
+			it.cur.seek(nil)
+		}
+	}
+}
+
+// mergingIterator merges the entries of multiple sources, ordered from the newest
+// to the oldest. Of the entries of a key, only the one of the newest source is
+// returned.
+type mergingIterator struct {
+	sources []internalIterator
+	cur     internalIterator // Source holding the current entry, nil if exhausted
+	key     []byte           // Copy of the current key
+}
+
+func (it *mergingIterator) seek(key []byte) {
+	for _, src := range it.sources {
+		src.seek(key)
+	}
+	it.pick()
+}
+
+func (it *mergingIterator) next() {
+	it.key = append(it.key[:0], it.cur.entry().key...)
+	for _, src := range it.sources {
+		if src.valid() && bytes.Equal(src.entry().key, it.key) {
+			src.next()
+		}
+	}
+	it.pick()
+}
+
+func (it *mergingIterator) valid() bool   { return it.cur != nil }
+func (it *mergingIterator) entry() *entry { return it.cur.entry() }
+
+func (it *mergingIterator) error() error {
+	for _, src := range it.sources {
+		if err := src.error(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (it *mergingIterator) release() {
+	for _, src := range it.sources {
+		src.release()
+	}
+}
+
+// pick selects the source with the smallest current key, the newest one if more
+// sources hold the key. An error in any source ends the iteration.
+func (it *mergingIterator) pick() {
+	it.cur = nil
+	if it.error() != nil {
+		return
+	}
+	for _, src := range it.sources {
+		if !src.valid() {
+			continue
+		}
+		if it.cur == nil || bytes.Compare(src.entry().key, it.cur.entry().key) < 0 {
+			it.cur = src
+		}
+	}
+}
+
+// dbIterator iterates over the live key-value pairs of the database with a given
+// prefix from a given start, as of the moment it was created. It implements
+// ethdb.Iterator.
+type dbIterator struct {
+	merged  *mergingIterator
+	version *version
+	prefix  []byte
+	start   []byte
+	started bool
+	err     error
+}
+
+// Next moves the iterator to the next key-value pair, returning whether there
+// is one.
+func (it *dbIterator) Next() bool {
+	if it.merged == nil {
+		return false
+	}
+	if !it.started {
+		it.started = true
+		it.merged.seek(it.start)
+	} else if it.merged.valid() {
+		it.merged.next()
+	}
+	for it.merged.valid() && it.merged.entry().kind == kindDelete {
+		it.merged.next()
+	}
+	if it.merged.valid() && bytes.HasPrefix(it.merged.entry().key, it.prefix) {
+		return true
+	}
+	// Iteration finished, free up the resources early
+	it.err = it.merged.error()
+	it.Release()
+	return false
+}
+
+// Key returns the key of the current pair, valid until the iterator is moved.
+func (it *dbIterator) Key() []byte {
+	if it.merged == nil || !it.merged.valid() {
+		return nil
+	}
+	return it.merged.entry().key
+}
+
+// Value returns the value of the current pair, valid until the iterator is moved.
+func (it *dbIterator) Value() []byte {
+	if it.merged == nil || !it.merged.valid() {
+		return nil
+	}
+	return it.merged.entry().value
+}
+
+// Error returns any failure that stopped the iteration.
+func (it *dbIterator) Error() error {
+	if it.merged == nil {
+		return it.err
+	}
+	return it.merged.error()
+}
+
+// Release frees up the resources held by the iterator.
+func (it *dbIterator) Release() {
+	if it.merged != nil {
+		it.merged.release()
+		it.version.release()
+		it.merged = nil
+	}
+}
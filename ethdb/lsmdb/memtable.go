@@ -0,0 +1,142 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/memdb"
+)
+
+// memComparer orders the keys of a memory table, made of the user key followed
+// by the sequence number of the write: by user key, then newest write first.
+type memComparer struct{}
+
+func (memComparer) Compare(a, b []byte) int {
+	if c := bytes.Compare(a[:len(a)-8], b[:len(b)-8]); c != 0 {
+		return c
+	}
+	return bytes.Compare(b[len(b)-8:], a[len(a)-8:])
+}
+
+// memTable is a sorted in-memory table of the recent writes. It keeps every
+// version of a key tagged with the sequence number of its write, so that readers
+// can see the table as of an earlier write.
+type memTable struct {
+	db *memdb.DB
+}
+
+// newMemTable creates an empty memory table.
+func newMemTable() *memTable {
+	return &memTable{db: memdb.New(memComparer{}, 0)}
+}
+
+// memKey appends the sequence number of a write to a user key.
+func memKey(key []byte, seq uint64) []byte {
+	buf := make([]byte, len(key)+8)
+	copy(buf, key)
+	binary.BigEndian.PutUint64(buf[len(key):], seq)
+	return buf
+}
+
+// add inserts the encoded entries of a write into the table, the kind prepended
+// to the value.
+func (m *memTable) add(seq uint64, data []byte) error {
+	var buf []byte
+	for len(data) > 0 {
+		kind, key, value, rest, err := decodeEntry(data)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf[:0], kind), value...)
+		if err := m.db.Put(memKey(key, seq), buf); err != nil {
+			return err
+		}
+		data = rest
+	}
+	return nil
+}
+
+// get looks up the newest entry of a key written no later than the given write.
+func (m *memTable) get(key []byte, seq uint64) *entry {
+	ikey, value, err := m.db.Find(memKey(key, seq))
+	if err != nil || !bytes.Equal(ikey[:len(ikey)-8], key) {
+		return nil
+	}
+	return &entry{kind: value[0], key: key, value: value[1:]}
+}
+
+// size returns the amount of data in the table.
+func (m *memTable) size() int {
+	return m.db.Size()
+}
+
+// empty returns whether nothing was written to the table.
+func (m *memTable) empty() bool {
+	return m.db.Len() == 0
+}
+
+// memIterator iterates over the newest entries of the keys of a memory table,
+// written no later than a given write.
+type memIterator struct {
+	it  iterator.Iterator
+	seq uint64
+	cur entry
+	ok  bool
+}
+
+// newIterator creates an iterator over the table as of the given write.
+func (m *memTable) newIterator(seq uint64) *memIterator {
+	return &memIterator{it: m.db.NewIterator(nil), seq: seq}
+}
+
+func (it *memIterator) seek(key []byte) {
+	it.ok = it.it.Seek(memKey(key, it.seq))
+	it.skipNewer()
+}
+
+func (it *memIterator) next() {
+	// Skip the older versions of the current key
+	key := it.cur.key
+	for it.ok = it.it.Next(); it.ok; it.ok = it.it.Next() {
+		ikey := it.it.Key()
+		if !bytes.Equal(ikey[:len(ikey)-8], key) {
+			break
+		}
+	}
+	it.skipNewer()
+}
+
+func (it *memIterator) valid() bool   { return it.ok }
+func (it *memIterator) entry() *entry { return &it.cur }
+func (it *memIterator) error() error  { return it.it.Error() }
+func (it *memIterator) release()      { it.it.Release() }
+
+// skipNewer moves past the entries written after the iterator was created, and
+// loads the entry it stopped at.
+func (it *memIterator) skipNewer() {
+	for ; it.ok; it.ok = it.it.Next() {
+		ikey := it.it.Key()
+		if binary.BigEndian.Uint64(ikey[len(ikey)-8:]) <= it.seq {
+			value := it.it.Value()
+			it.cur = entry{kind: value[0], key: ikey[:len(ikey)-8], value: value[1:]}
+			return
+		}
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"hash/fnv"
+	"os"
+	"sort"
+)
+
+const (
+	tableBlockSize  = 4 * 1024           // Size of the data blocks, after which a new one is started
+	tableFooterSize = 40                 // Size of the fixed footer at the end of the table files
+	tableMagic      = 0x6c736d6462746231 // Magic number at the end of the table files ("lsmdbtb1")
+
+	bloomBitsPerKey = 10 // Number of filter bits per key, yielding about 1% false positives
+	bloomProbes     = 7  // Number of bits set per key
+)
+
+// errCorruptTable is returned if a table file fails its checksums.
+var errCorruptTable = errors.New("corrupt table")
+
+// A table is an immutable file of entries sorted by key, consisting of:
+//   - data blocks of encoded entries, each followed by its checksum
+//   - an index, holding the last key and position of every data block
+//   - a bloom filter of all the keys in the table
+//   - a footer with the positions of the index and the filter
+//
+// Tables are written once, by flushing a memory table or by compacting other
+// tables, and deleted once no version of the database refers to them anymore.
+
+// blockHandle is the index entry of a data block.
+type blockHandle struct {
+	last   []byte // Last key in the block
+	offset uint64 // Position of the block in the file
+	length uint64 // Length of the block, including its checksum
+}
+
+// entry is a decoded entry of a data block.
+type entry struct {
+	kind  byte
+	key   []byte
+	value []byte
+}
+
+// block is a decoded data block, with its entries sorted by key.
+type block struct {
+	entries []entry
+	size    int
+}
+
+// decodeBlock verifies the checksum of a raw data block and decodes its entries.
+func decodeBlock(data []byte) (*block, error) {
+	if len(data) < 4 {
+		return nil, errCorruptTable
+	}
+	body := data[:len(data)-4]
+	if crc32.Checksum(body, crcTable) != binary.BigEndian.Uint32(data[len(data)-4:]) {
+		return nil, errCorruptTable
+	}
+	blk := &block{size: len(data)}
+	for len(body) > 0 {
+		kind, key, value, rest, err := decodeEntry(body)
+		if err != nil {
+			return nil, errCorruptTable
+		}
+		blk.entries = append(blk.entries, entry{kind: kind, key: key, value: value})
+		body = rest
+	}
+	return blk, nil
+}
+
+// search returns the index of the first entry whose key is not less than the
+// given one.
+func (b *block) search(key []byte) int {
+	return sort.Search(len(b.entries), func(i int) bool {
+		return bytes.Compare(b.entries[i].key, key) >= 0
+	})
+}
+
+// bloomHashes derives the two base hashes of a key for double hashing.
+func bloomHashes(key []byte) (uint32, uint32) {
+	h := fnv.New64a()
+	h.Write(key)
+	sum := h.Sum64()
+	return uint32(sum), uint32(sum>>32) | 1
+}
+
+// bloomFilter is a bloom filter over the keys of a table.
+type bloomFilter []byte
+
+// newBloomFilter creates a filter holding the given key hashes.
+func newBloomFilter(hashes [][2]uint32) bloomFilter {
+	bits := len(hashes) * bloomBitsPerKey
+	if bits < 64 {
+		bits = 64
+	}
+	filter := make(bloomFilter, (bits+7)/8)
+	bits = len(filter) * 8
+
+	for _, h := range hashes {
+		for i := uint32(0); i < bloomProbes; i++ {
+			bit := (h[0] + i*h[1]) % uint32(bits)
+			filter[bit/8] |= 1 << (bit % 8)
+		}
+	}
+	return filter
+}
+
+// mayContain returns false if the key is surely not in the filter.
+func (f bloomFilter) mayContain(key []byte) bool {
+	if len(f) == 0 {
+		return true
+	}
+	var (
+		h1, h2 = bloomHashes(key)
+		bits   = uint32(len(f) * 8)
+	)
+	for i := uint32(0); i < bloomProbes; i++ {
+		bit := (h1 + i*h2) % bits
+		if f[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// tableWriter builds a table file from entries added in ascending key order.
+type tableWriter struct {
+	path string
+	file *os.File
+	buf  *bufio.Writer
+
+	offset   uint64      // Number of bytes written to the file
+	block    []byte      // Entries of the block being filled
+	index    []byte      // Encoded index of the finished blocks
+	hashes   [][2]uint32 // Bloom hashes of the added keys
+	smallest []byte      // First key added to the table
+	largest  []byte      // Last key added to the table
+}
+
+// newTableWriter creates a new table file to be filled with entries.
+func newTableWriter(path string) (*tableWriter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &tableWriter{path: path, file: file, buf: bufio.NewWriterSize(file, 64*1024)}, nil
+}
+
+// add appends an entry to the table, which must sort after all earlier ones.
+func (w *tableWriter) add(kind byte, key, value []byte) error {
+	if w.smallest == nil {
+		w.smallest = append([]byte{}, key...)
+	}
+	w.largest = append(w.largest[:0], key...)
+	h1, h2 := bloomHashes(key)
+	w.hashes = append(w.hashes, [2]uint32{h1, h2})
+
+	w.block = appendEntry(w.block, kind, key, value)
+	if len(w.block) >= tableBlockSize {
+		return w.flushBlock()
+	}
+	return nil
+}
+
+// size returns the approximate size of the table written so far.
+func (w *tableWriter) size() uint64 {
+	return w.offset + uint64(len(w.block))
+}
+
+// empty returns whether no entry was added to the table.
+func (w *tableWriter) empty() bool {
+	return w.smallest == nil
+}
+
+// flushBlock writes out the block being filled, and indexes it.
+func (w *tableWriter) flushBlock() error {
+	if len(w.block) == 0 {
+		return nil
+	}
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(w.block, crcTable))
+	w.block = append(w.block, crc[:]...)
+
+	if _, err := w.buf.Write(w.block); err != nil {
+		return err
+	}
+	var size [binary.MaxVarintLen64]byte
+	w.index = append(w.index, size[:binary.PutUvarint(size[:], uint64(len(w.largest)))]...)
+	w.index = append(w.index, w.largest...)
+	w.index = append(w.index, size[:binary.PutUvarint(size[:], w.offset)]...)
+	w.index = append(w.index, size[:binary.PutUvarint(size[:], uint64(len(w.block)))]...)
+
+	w.offset += uint64(len(w.block))
+	w.block = w.block[:0]
+	return nil
+}
+
+// finish writes out the index, the filter and the footer, and syncs the file to
+// disk, returning its final size.
+func (w *tableWriter) finish() (uint64, error) {
+	if err := w.flushBlock(); err != nil {
+		w.abort()
+		return 0, err
+	}
+	var crc [4]byte
+	binary.BigEndian.PutUint32(crc[:], crc32.Checksum(w.index, crcTable))
+	w.index = append(w.index, crc[:]...)
+
+	filter := newBloomFilter(w.hashes)
+
+	var footer [tableFooterSize]byte
+	binary.BigEndian.PutUint64(footer[0:], w.offset)
+	binary.BigEndian.PutUint64(footer[8:], uint64(len(w.index)))
+	binary.BigEndian.PutUint64(footer[16:], w.offset+uint64(len(w.index)))
+	binary.BigEndian.PutUint64(footer[24:], uint64(len(filter)))
+	binary.BigEndian.PutUint64(footer[32:], tableMagic)
+
+	for _, data := range [][]byte{w.index, filter, footer[:]} {
+		if _, err := w.buf.Write(data); err != nil {
+			w.abort()
+			return 0, err
+		}
+	}
+	size := w.offset + uint64(len(w.index)+len(filter)+len(footer))
+	if err := w.buf.Flush(); err != nil {
+		w.abort()
+		return 0, err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.abort()
+		return 0, err
+	}
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.path)
+		return 0, err
+	}
+	return size, nil
+}
+
+// abort discards the table being written.
+func (w *tableWriter) abort() {
+	w.file.Close()
+	os.Remove(w.path)
+}
+
+// tableReader provides access to the entries of a table file, keeping its index
+// and filter in memory.
+type tableReader struct {
+	num    uint64
+	file   *os.File
+	index  []blockHandle
+	filter bloomFilter
+
+	refs    int  // Number of users of the reader, tracked by the table cache
+	evicted bool // Whether the reader was dropped from the table cache
+}
+
+// openTable opens a table file and loads its index and filter.
+func openTable(path string, num uint64) (*tableReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := loadTable(file, num)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// loadTable reads the index and filter of an opened table file.
+func loadTable(file *os.File, num uint64) (*tableReader, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.Size() < tableFooterSize {
+		return nil, errCorruptTable
+	}
+	var footer [tableFooterSize]byte
+	if _, err := file.ReadAt(footer[:], stat.Size()-tableFooterSize); err != nil {
+		return nil, err
+	}
+	if binary.BigEndian.Uint64(footer[32:]) != tableMagic {
+		return nil, errCorruptTable
+	}
+	var (
+		indexOffset  = binary.BigEndian.Uint64(footer[0:])
+		indexLength  = binary.BigEndian.Uint64(footer[8:])
+		filterOffset = binary.BigEndian.Uint64(footer[16:])
+		filterLength = binary.BigEndian.Uint64(footer[24:])
+	)
+	if indexLength < 4 || filterOffset+filterLength+tableFooterSize != uint64(stat.Size()) || indexOffset+indexLength != filterOffset {
+		return nil, errCorruptTable
+	}
+	meta := make([]byte, indexLength+filterLength)
+	if _, err := file.ReadAt(meta, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+	index := meta[:indexLength-4]
+	if crc32.Checksum(index, crcTable) != binary.BigEndian.Uint32(meta[indexLength-4:]) {
+		return nil, errCorruptTable
+	}
+	r := &tableReader{num: num, file: file, filter: bloomFilter(meta[indexLength:])}
+	for len(index) > 0 {
+		last, rest, err := decodeBytes(index)
+		if err != nil {
+			return nil, errCorruptTable
+		}
+		offset, n := binary.Uvarint(rest)
+		if n <= 0 {
+			return nil, errCorruptTable
+		}
+		length, m := binary.Uvarint(rest[n:])
+		if m <= 0 {
+			return nil, errCorruptTable
+		}
+		r.index = append(r.index, blockHandle{last: last, offset: offset, length: length})
+		index = rest[n+m:]
+	}
+	return r, nil
+}
+
+// readBlock retrieves a data block of the table, from the cache if possible.
+func (r *tableReader) readBlock(i int, cache *blockCache) (*block, error) {
+	handle := r.index[i]
+	key := blockKey{file: r.num, offset: handle.offset}
+	if blk := cache.get(key); blk != nil {
+		return blk, nil
+	}
+	data := make([]byte, handle.length)
+	if _, err := r.file.ReadAt(data, int64(handle.offset)); err != nil {
+		return nil, err
+	}
+	blk, err := decodeBlock(data)
+	if err != nil {
+		return nil, err
+	}
+	cache.add(key, blk)
+	return blk, nil
+}
+
+// get looks up the entry of a key in the table.
+func (r *tableReader) get(key []byte, cache *blockCache) (*entry, error) {
+	if !r.filter.mayContain(key) {
+		return nil, nil
+	}
+	i := sort.Search(len(r.index), func(i int) bool {
+		return bytes.Compare(r.index[i].last, key) >= 0
+	})
+	if i == len(r.index) {
+		return nil, nil
+	}
+	blk, err := r.readBlock(i, cache)
+	if err != nil {
+		return nil, err
+	}
+	if j := blk.search(key); j < len(blk.entries) && bytes.Equal(blk.entries[j].key, key) {
+		return &blk.entries[j], nil
+	}
+	return nil, nil
+}
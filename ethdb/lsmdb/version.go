@@ -0,0 +1,207 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package lsmdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+)
+
+// numLevels is the number of table levels. Level 0 holds the flushed memory tables
+// with overlapping key ranges, newest first. Every deeper level holds tables with
+// disjoint key ranges, sorted by key.
+const numLevels = 7
+
+// manifestName is the file recording the tables of every level. Its name also
+// tells the database directories of this engine apart.
+const manifestName = "LSMDB-MANIFEST"
+
+// tableMeta describes a table file of the database.
+type tableMeta struct {
+	Num      uint64 `json:"num"`
+	Size     uint64 `json:"size"`
+	Smallest []byte `json:"smallest"`
+	Largest  []byte `json:"largest"`
+
+	refs int32 // Number of versions referring to the table
+}
+
+// overlaps returns whether the key range of the table intersects the given one.
+func (t *tableMeta) overlaps(smallest, largest []byte) bool {
+	return bytes.Compare(t.Largest, smallest) >= 0 && bytes.Compare(t.Smallest, largest) <= 0
+}
+
+// version is an immutable set of tables making up the database, along with the
+// memory tables. Readers hold a reference to the version they use, so that its
+// tables are deleted only after the last reader is done with them.
+type version struct {
+	db     *Database
+	levels [numLevels][]*tableMeta
+	refs   int32
+}
+
+// newVersion creates a version from the given levels, referencing their tables.
+func newVersion(db *Database, levels [numLevels][]*tableMeta) *version {
+	v := &version{db: db, levels: levels, refs: 1}
+	for _, tables := range levels {
+		for _, t := range tables {
+			atomic.AddInt32(&t.refs, 1)
+		}
+	}
+	return v
+}
+
+// retain adds a reference to the version.
+func (v *version) retain() {
+	atomic.AddInt32(&v.refs, 1)
+}
+
+// release drops a reference to the version, deleting the tables not referred to
+// anymore once the version is unused.
+func (v *version) release() {
+	if atomic.AddInt32(&v.refs, -1) != 0 {
+		return
+	}
+	for _, tables := range v.levels {
+		for _, t := range tables {
+			if atomic.AddInt32(&t.refs, -1) == 0 {
+				v.db.deleteTable(t.Num)
+			}
+		}
+	}
+}
+
+// overlapping returns the tables of a level intersecting the given key range.
+func (v *version) overlapping(level int, smallest, largest []byte) []*tableMeta {
+	var tables []*tableMeta
+	for _, t := range v.levels[level] {
+		if t.overlaps(smallest, largest) {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// get looks up the newest entry of a key in the tables of the version.
+func (v *version) get(key []byte) (*entry, error) {
+	for level, tables := range v.levels {
+		if level > 0 {
+			// Deeper levels are sorted, only one table can hold the key
+			i := sort.Search(len(tables), func(i int) bool {
+				return bytes.Compare(tables[i].Largest, key) >= 0
+			})
+			if i == len(tables) {
+				continue
+			}
+			tables = tables[i : i+1]
+		}
+		for _, t := range tables {
+			if !t.overlaps(key, key) {
+				continue
+			}
+			r, err := v.db.tables.get(t.Num)
+			if err != nil {
+				return nil, err
+			}
+			e, err := r.get(key, v.db.blocks)
+			v.db.tables.release(r)
+
+			if e != nil || err != nil {
+				return e, err
+			}
+		}
+	}
+	return nil, nil
+}
+
+// iterators creates an iterator for every sorted run of tables, newest first.
+func (v *version) iterators() []internalIterator {
+	var its []internalIterator
+	for _, t := range v.levels[0] {
+		its = append(its, &levelIterator{db: v.db, tables: []*tableMeta{t}})
+	}
+	for _, tables := range v.levels[1:] {
+		if len(tables) > 0 {
+			its = append(its, &levelIterator{db: v.db, tables: tables})
+		}
+	}
+	return its
+}
+
+// size returns the total size of the tables of a level.
+func (v *version) size(level int) uint64 {
+	var size uint64
+	for _, t := range v.levels[level] {
+		size += t.Size
+	}
+	return size
+}
+
+// manifest is the persisted state of the database, recording its tables and the
+// log holding the writes not yet flushed to them.
+type manifest struct {
+	NextFile uint64                  `json:"nextFile"`
+	Log      uint64                  `json:"log"`
+	Levels   [numLevels][]*tableMeta `json:"levels"`
+}
+
+// readManifest loads the manifest of a database directory, or nil if there is
+// none yet.
+func readManifest(dir string) (*manifest, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(dir, manifestName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m := new(manifest)
+	if err := json.Unmarshal(blob, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// writeManifest atomically replaces the manifest of a database directory.
+func writeManifest(dir string, m *manifest) error {
+	blob, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, manifestName+".tmp")
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, manifestName))
+}
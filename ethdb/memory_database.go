@@ -18,6 +18,9 @@ package ethdb
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -80,6 +83,45 @@ func (db *MemDatabase) Keys() [][]byte {
 	return keys
 }
 
+// NewIterator returns an iterator over a copy of the entire database.
+func (db *MemDatabase) NewIterator() Iterator {
+	return db.newIterator("", "")
+}
+
+// NewIteratorWithStart returns an iterator over a copy of the entries whose keys
+// are not less than the given start.
+func (db *MemDatabase) NewIteratorWithStart(start []byte) Iterator {
+	return db.newIterator("", string(start))
+}
+
+// NewIteratorWithPrefix returns an iterator over a copy of the entries whose keys
+// start with the given prefix.
+func (db *MemDatabase) NewIteratorWithPrefix(prefix []byte) Iterator {
+	return db.newIterator(string(prefix), "")
+}
+
+// newIterator copies the entries with the given prefix and from the given start
+// into a sorted iterator.
+func (db *MemDatabase) newIterator(prefix string, start string) Iterator {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var (
+		keys   = make([]string, 0, len(db.db))
+		values = make([][]byte, 0, len(db.db))
+	)
+	for key := range db.db {
+		if strings.HasPrefix(key, prefix) && key >= start {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values = append(values, db.db[key])
+	}
+	return &memIterator{keys: keys, values: values, index: -1}
+}
+
 func (db *MemDatabase) Delete(key []byte) error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
@@ -88,6 +130,27 @@ func (db *MemDatabase) Delete(key []byte) error {
 	return nil
 }
 
+// Stat returns a statistic of the database. Memory databases only provide the
+// "stats" overview of their content.
+func (db *MemDatabase) Stat(property string) (string, error) {
+	if property != "stats" {
+		return "", fmt.Errorf("unknown property %q", property)
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var size int
+	for key, value := range db.db {
+		size += len(key) + len(value)
+	}
+	return fmt.Sprintf("Entries: %d\nSize:    %v\n", len(db.db), common.StorageSize(size)), nil
+}
+
+// Compact does nothing, memory databases don't need compaction.
+func (db *MemDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
 func (db *MemDatabase) Close() {}
 
 func (db *MemDatabase) NewBatch() Batch {
@@ -96,6 +159,40 @@ func (db *MemDatabase) NewBatch() Batch {
 
 func (db *MemDatabase) Len() int { return len(db.db) }
 
+// memIterator iterates over a sorted copy of the entries of a memory database.
+type memIterator struct {
+	keys   []string
+	values [][]byte
+	index  int
+}
+
+func (it *memIterator) Next() bool {
+	if it.index < len(it.keys) {
+		it.index++
+	}
+	return it.index < len(it.keys)
+}
+
+func (it *memIterator) Error() error { return nil }
+
+func (it *memIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return []byte(it.keys[it.index])
+}
+
+func (it *memIterator) Value() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+func (it *memIterator) Release() {
+	it.keys, it.values = nil, nil
+}
+
 type kv struct {
 	k, v []byte
 	del  bool
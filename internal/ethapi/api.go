@@ -22,7 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"strings"
+	"sort"
 	"time"
 
 	"github.com/davecgh/go-spew/spew"
@@ -37,13 +37,12 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
-	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 const (
@@ -118,7 +117,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range pending {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, s.b.ChainConfig())
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -126,7 +125,7 @@ func (s *PublicTxPoolAPI) Content() map[string]map[string]map[string]*RPCTransac
 	for account, txs := range queue {
 		dump := make(map[string]*RPCTransaction)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, s.b.ChainConfig())
 		}
 		content["queued"][account.Hex()] = dump
 	}
@@ -142,6 +141,12 @@ func (s *PublicTxPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+// Rejections returns the most recent transactions the pool refused to admit due
+// to its local admission policies, together with the reasons.
+func (s *PublicTxPoolAPI) Rejections() []core.TxRejection {
+	return s.b.TxPoolRejections()
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
 func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
@@ -177,6 +182,141 @@ func (s *PublicTxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	return content
 }
 
+// ContentFrom returns the transactions of a single account contained within the
+// transaction pool.
+func (s *PublicTxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCTransaction {
+	content := map[string]map[string]*RPCTransaction{
+		"pending": make(map[string]*RPCTransaction),
+		"queued":  make(map[string]*RPCTransaction),
+	}
+	pending, queue := s.b.TxPoolContentFrom(addr)
+
+	for _, tx := range pending {
+		content["pending"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, s.b.ChainConfig())
+	}
+	for _, tx := range queue {
+		content["queued"][fmt.Sprintf("%d", tx.Nonce())] = newRPCPendingTransaction(tx, s.b.ChainConfig())
+	}
+	return content
+}
+
+// maxTxPoolPageSize is the maximum number of transactions returned in a single
+// page of the transaction pool content.
+const maxTxPoolPageSize = 1000
+
+// RPCPoolTransaction is a pooled transaction tagged with its status.
+type RPCPoolTransaction struct {
+	*RPCTransaction
+	Status string `json:"status"`
+}
+
+// RPCTxPoolPage is a page of the transaction pool content.
+type RPCTxPoolPage struct {
+	Transactions []*RPCPoolTransaction `json:"transactions"`
+	Total        hexutil.Uint          `json:"total"`
+	Next         *hexutil.Uint         `json:"next"` // Offset of the next page, nil on the last one
+}
+
+// ContentPage returns a page of at most limit transactions of the transaction
+// pool, starting at offset. Pending transactions are listed before queued ones,
+// each sorted by sender and nonce.
+func (s *PublicTxPoolAPI) ContentPage(offset hexutil.Uint, limit hexutil.Uint) (*RPCTxPoolPage, error) {
+	if limit == 0 || limit > maxTxPoolPageSize {
+		return nil, fmt.Errorf("page limit must be between 1 and %d", maxTxPoolPageSize)
+	}
+	var (
+		pending, queue = s.b.TxPoolContent()
+		all            []*RPCPoolTransaction
+	)
+	for _, section := range []struct {
+		status string
+		txs    map[common.Address]types.Transactions
+	}{{"pending", pending}, {"queued", queue}} {
+		accounts := make([]common.Address, 0, len(section.txs))
+		for addr := range section.txs {
+			accounts = append(accounts, addr)
+		}
+		sort.Slice(accounts, func(i, j int) bool { return bytes.Compare(accounts[i][:], accounts[j][:]) < 0 })
+
+		for _, addr := range accounts {
+			for _, tx := range section.txs[addr] {
+				all = append(all, &RPCPoolTransaction{newRPCPendingTransaction(tx, s.b.ChainConfig()), section.status})
+			}
+		}
+	}
+	page := &RPCTxPoolPage{Transactions: []*RPCPoolTransaction{}, Total: hexutil.Uint(len(all))}
+	if int(offset) >= len(all) {
+		return page, nil
+	}
+	end := int(offset + limit)
+	if end < len(all) {
+		next := hexutil.Uint(end)
+		page.Next = &next
+	} else {
+		end = len(all)
+	}
+	page.Transactions = all[offset:end]
+	return page, nil
+}
+
+// PrivateTxPoolAPI offers an API to evict transactions from the transaction pool.
+type PrivateTxPoolAPI struct {
+	b Backend
+}
+
+// NewPrivateTxPoolAPI creates a new tx pool service that allows evicting transactions.
+func NewPrivateTxPoolAPI(b Backend) *PrivateTxPoolAPI {
+	return &PrivateTxPoolAPI{b}
+}
+
+// Remove evicts the transaction with the given hash, returning whether it was
+// contained in the pool. Subsequent transactions of the sender are moved back
+// to the queue.
+func (s *PrivateTxPoolAPI) Remove(hash common.Hash) bool {
+	return s.b.TxPoolRemove(hash)
+}
+
+// RemoveSender evicts all the transactions of an account, returning their number.
+func (s *PrivateTxPoolAPI) RemoveSender(addr common.Address) hexutil.Uint {
+	return hexutil.Uint(s.b.TxPoolRemoveSender(addr))
+}
+
+// Clear evicts all the transactions of the pool, returning their number.
+func (s *PrivateTxPoolAPI) Clear() hexutil.Uint {
+	return hexutil.Uint(s.b.TxPoolClear())
+}
+
+// ExportSnapshot returns a dump of the full content of the pool, which can be
+// imported into another node with ImportSnapshot.
+func (s *PrivateTxPoolAPI) ExportSnapshot() *core.TxPoolSnapshot {
+	return s.b.TxPoolSnapshot()
+}
+
+// TxPoolImportResult is the outcome of importing a pool snapshot.
+type TxPoolImportResult struct {
+	Imported hexutil.Uint           `json:"imported"`
+	Errors   map[common.Hash]string `json:"errors"`
+}
+
+// ImportSnapshot injects the transactions of a pool snapshot into the pool,
+// reporting the ones that were refused.
+func (s *PrivateTxPoolAPI) ImportSnapshot(ctx context.Context, snap core.TxPoolSnapshot) (*TxPoolImportResult, error) {
+	for i, entry := range snap.Transactions {
+		if entry == nil || entry.Tx == nil {
+			return nil, fmt.Errorf("snapshot entry %d without transaction", i)
+		}
+	}
+	result := &TxPoolImportResult{Errors: make(map[common.Hash]string)}
+	for i, err := range s.b.TxPoolRestore(ctx, &snap) {
+		if err != nil {
+			result.Errors[snap.Transactions[i].Tx.Hash()] = err.Error()
+			continue
+		}
+		result.Imported++
+	}
+	return result, nil
+}
+
 // PublicAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type PublicAccountAPI struct {
@@ -491,6 +631,17 @@ func (s *PublicBlockChainAPI) BlockNumber() hexutil.Uint64 {
 	return hexutil.Uint64(header.Number.Uint64())
 }
 
+// TxIndexTail returns the number of the oldest block whose transactions can be
+// looked up by hash, or nil if the transactions of all blocks are indexed. Hashes
+// not found in the index nor the pool are reported as possibly unindexed then.
+func (s *PublicBlockChainAPI) TxIndexTail() *hexutil.Uint64 {
+	tail := rawdb.ReadTxIndexTail(s.b.ChainDb())
+	if tail == nil || *tail == 0 {
+		return nil
+	}
+	return (*hexutil.Uint64)(tail)
+}
+
 // GetBalance returns the amount of wei for the given address in the state of the
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
@@ -670,6 +821,10 @@ func (s *PublicBlockChainAPI) doCall(ctx context.Context, args CallArgs, blockNr
 	if err := vmError(); err != nil {
 		return nil, 0, false, err
 	}
+	// Report the state that failed to load instead of running on missing data
+	if err := state.Error(); err != nil {
+		return nil, 0, false, err
+	}
 	return res, gas, failed, err
 }
 
@@ -793,7 +948,7 @@ func FormatLogs(logs []vm.StructLog) []StructLogRes {
 // RPCMarshalBlock converts the given block to the RPC output which depends on fullTx. If inclTx is true transactions are
 // returned. When fullTx is true the returned block contains full transaction details, otherwise it will only contain
 // transaction hashes.
-func RPCMarshalBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
+func RPCMarshalBlock(b *types.Block, inclTx bool, fullTx bool, config *params.ChainConfig) (map[string]interface{}, error) {
 	head := b.Header() // copies the header once
 	fields := map[string]interface{}{
 		"number":           (*hexutil.Big)(head.Number),
@@ -821,7 +976,7 @@ func RPCMarshalBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]inter
 		}
 		if fullTx {
 			formatTx = func(tx *types.Transaction) (interface{}, error) {
-				return newRPCTransactionFromBlockHash(b, tx.Hash()), nil
+				return newRPCTransactionFromBlockHash(b, tx.Hash(), config), nil
 			}
 		}
 		txs := b.Transactions()
@@ -848,7 +1003,7 @@ func RPCMarshalBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]inter
 // rpcOutputBlock uses the generalized output filler, then adds the total difficulty field, which requires
 // a `PublicBlockchainAPI`.
 func (s *PublicBlockChainAPI) rpcOutputBlock(b *types.Block, inclTx bool, fullTx bool) (map[string]interface{}, error) {
-	fields, err := RPCMarshalBlock(b, inclTx, fullTx)
+	fields, err := RPCMarshalBlock(b, inclTx, fullTx, s.b.ChainConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -876,12 +1031,12 @@ type RPCTransaction struct {
 
 // newRPCTransaction returns a transaction that will serialize to the RPC
 // representation, with the given location metadata set (if available).
-func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64) *RPCTransaction {
+func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber uint64, index uint64, config *params.ChainConfig) *RPCTransaction {
 	var signer types.Signer = types.FrontierSigner{}
 	if tx.Protected() {
 		signer = types.NewEIP155Signer(tx.ChainId())
 	}
-	from, _ := types.Sender(signer, tx)
+	from, _ := types.Sender(core.WithImpersonation(config, signer), tx)
 	v, r, s := tx.RawSignatureValues()
 
 	result := &RPCTransaction{
@@ -906,17 +1061,17 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 }
 
 // newRPCPendingTransaction returns a pending transaction that will serialize to the RPC representation
-func newRPCPendingTransaction(tx *types.Transaction) *RPCTransaction {
-	return newRPCTransaction(tx, common.Hash{}, 0, 0)
+func newRPCPendingTransaction(tx *types.Transaction, config *params.ChainConfig) *RPCTransaction {
+	return newRPCTransaction(tx, common.Hash{}, 0, 0, config)
 }
 
 // newRPCTransactionFromBlockIndex returns a transaction that will serialize to the RPC representation.
-func newRPCTransactionFromBlockIndex(b *types.Block, index uint64) *RPCTransaction {
+func newRPCTransactionFromBlockIndex(b *types.Block, index uint64, config *params.ChainConfig) *RPCTransaction {
 	txs := b.Transactions()
 	if index >= uint64(len(txs)) {
 		return nil
 	}
-	return newRPCTransaction(txs[index], b.Hash(), b.NumberU64(), index)
+	return newRPCTransaction(txs[index], b.Hash(), b.NumberU64(), index, config)
 }
 
 // newRPCRawTransactionFromBlockIndex returns the bytes of a transaction given a block and a transaction index.
@@ -930,10 +1085,10 @@ func newRPCRawTransactionFromBlockIndex(b *types.Block, index uint64) hexutil.By
 }
 
 // newRPCTransactionFromBlockHash returns a transaction that will serialize to the RPC representation.
-func newRPCTransactionFromBlockHash(b *types.Block, hash common.Hash) *RPCTransaction {
+func newRPCTransactionFromBlockHash(b *types.Block, hash common.Hash, config *params.ChainConfig) *RPCTransaction {
 	for idx, tx := range b.Transactions() {
 		if tx.Hash() == hash {
-			return newRPCTransactionFromBlockIndex(b, uint64(idx))
+			return newRPCTransactionFromBlockIndex(b, uint64(idx), config)
 		}
 	}
 	return nil
@@ -971,7 +1126,7 @@ func (s *PublicTransactionPoolAPI) GetBlockTransactionCountByHash(ctx context.Co
 // GetTransactionByBlockNumberAndIndex returns the transaction for the given block number and index.
 func (s *PublicTransactionPoolAPI) GetTransactionByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) *RPCTransaction {
 	if block, _ := s.b.BlockByNumber(ctx, blockNr); block != nil {
-		return newRPCTransactionFromBlockIndex(block, uint64(index))
+		return newRPCTransactionFromBlockIndex(block, uint64(index), s.b.ChainConfig())
 	}
 	return nil
 }
@@ -979,7 +1134,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionByBlockNumberAndIndex(ctx conte
 // GetTransactionByBlockHashAndIndex returns the transaction for the given block hash and index.
 func (s *PublicTransactionPoolAPI) GetTransactionByBlockHashAndIndex(ctx context.Context, blockHash common.Hash, index hexutil.Uint) *RPCTransaction {
 	if block, _ := s.b.GetBlock(ctx, blockHash); block != nil {
-		return newRPCTransactionFromBlockIndex(block, uint64(index))
+		return newRPCTransactionFromBlockIndex(block, uint64(index), s.b.ChainConfig())
 	}
 	return nil
 }
@@ -1011,16 +1166,26 @@ func (s *PublicTransactionPoolAPI) GetTransactionCount(ctx context.Context, addr
 }
 
 // GetTransactionByHash returns the transaction for the given hash
-func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) *RPCTransaction {
+func (s *PublicTransactionPoolAPI) GetTransactionByHash(ctx context.Context, hash common.Hash) (*RPCTransaction, error) {
 	// Try to return an already finalized transaction
 	if tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash); tx != nil {
-		return newRPCTransaction(tx, blockHash, blockNumber, index)
+		return newRPCTransaction(tx, blockHash, blockNumber, index, s.b.ChainConfig()), nil
 	}
 	// No finalized transaction, try to retrieve it from the pool
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
-		return newRPCPendingTransaction(tx)
+		return newRPCPendingTransaction(tx, s.b.ChainConfig()), nil
+	}
+	// Transaction unknown, return as such unless it may be in an unindexed block
+	return nil, unindexedTxError(s.b.ChainDb())
+}
+
+// unindexedTxError returns the error of a transaction not found by its hash if
+// the lookups of the transactions of old blocks were dropped, so that it may be
+// in one of them, or nil otherwise.
+func unindexedTxError(db ethdb.Database) error {
+	if tail := rawdb.ReadTxIndexTail(db); tail != nil && *tail > 0 {
+		return fmt.Errorf("transaction not found, the transactions of blocks before #%d are not indexed", *tail)
 	}
-	// Transaction unknown, return as such
 	return nil
 }
 
@@ -1032,7 +1197,7 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 	if tx, _, _, _ = rawdb.ReadTransaction(s.b.ChainDb(), hash); tx == nil {
 		if tx = s.b.GetPoolTransaction(hash); tx == nil {
 			// Transaction not found anywhere, abort
-			return nil, nil
+			return nil, unindexedTxError(s.b.ChainDb())
 		}
 	}
 	// Serialize to RLP and return
@@ -1043,7 +1208,11 @@ func (s *PublicTransactionPoolAPI) GetRawTransactionByHash(ctx context.Context,
 func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(s.b.ChainDb(), hash)
 	if tx == nil {
-		return nil, nil
+		if s.b.GetPoolTransaction(hash) != nil {
+			// Transaction still pending, no receipt yet
+			return nil, nil
+		}
+		return nil, unindexedTxError(s.b.ChainDb())
 	}
 	receipts, err := s.b.GetReceipts(ctx, blockHash)
 	if err != nil {
@@ -1058,7 +1227,7 @@ func (s *PublicTransactionPoolAPI) GetTransactionReceipt(ctx context.Context, ha
 	if tx.Protected() {
 		signer = types.NewEIP155Signer(tx.ChainId())
 	}
-	from, _ := types.Sender(signer, tx)
+	from, _ := types.Sender(core.WithImpersonation(s.b.ChainConfig(), signer), tx)
 
 	fields := map[string]interface{}{
 		"blockHash":         blockHash,
@@ -1181,7 +1350,7 @@ func submitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 		return common.Hash{}, err
 	}
 	if tx.To() == nil {
-		signer := types.MakeSigner(b.ChainConfig(), b.CurrentBlock().Number())
+		signer := core.MakeSigner(b.ChainConfig(), b.CurrentBlock().Number())
 		from, err := types.Sender(signer, tx)
 		if err != nil {
 			return common.Hash{}, err
@@ -1202,7 +1371,7 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	account := accounts.Account{Address: args.From}
 
 	wallet, err := s.b.AccountManager().Find(account)
-	if err != nil {
+	if err != nil && !s.b.Impersonating(args.From) {
 		return common.Hash{}, err
 	}
 
@@ -1224,7 +1393,12 @@ func (s *PublicTransactionPoolAPI) SendTransaction(ctx context.Context, args Sen
 	if config := s.b.ChainConfig(); config.IsEIP155(s.b.CurrentBlock().Number()) {
 		chainID = config.ChainID
 	}
-	signed, err := wallet.SignTx(account, tx, chainID)
+	var signed *types.Transaction
+	if wallet == nil {
+		signed, err = core.Impersonate(tx, types.MakeSigner(s.b.ChainConfig(), s.b.CurrentBlock().Number()), args.From)
+	} else {
+		signed, err = wallet.SignTx(account, tx, chainID)
+	}
 	if err != nil {
 		return common.Hash{}, err
 	}
@@ -1318,9 +1492,9 @@ func (s *PublicTransactionPoolAPI) PendingTransactions() ([]*RPCTransaction, err
 		if tx.Protected() {
 			signer = types.NewEIP155Signer(tx.ChainId())
 		}
-		from, _ := types.Sender(signer, tx)
+		from, _ := types.Sender(core.WithImpersonation(s.b.ChainConfig(), signer), tx)
 		if _, exists := accounts[from]; exists {
-			transactions = append(transactions, newRPCPendingTransaction(tx))
+			transactions = append(transactions, newRPCPendingTransaction(tx, s.b.ChainConfig()))
 		}
 	}
 	return transactions, nil
@@ -1425,32 +1599,19 @@ func NewPrivateDebugAPI(b Backend) *PrivateDebugAPI {
 	return &PrivateDebugAPI{b: b}
 }
 
-// ChaindbProperty returns leveldb properties of the chain database.
+// ChaindbProperty returns a property of the chain database, the general stats
+// if none is given.
 func (api *PrivateDebugAPI) ChaindbProperty(property string) (string, error) {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return "", fmt.Errorf("chaindbProperty does not work for memory databases")
-	}
 	if property == "" {
-		property = "leveldb.stats"
-	} else if !strings.HasPrefix(property, "leveldb.") {
-		property = "leveldb." + property
+		property = "stats"
 	}
-	return ldb.LDB().GetProperty(property)
+	return api.b.ChainDb().Stat(property)
 }
 
 func (api *PrivateDebugAPI) ChaindbCompact() error {
-	ldb, ok := api.b.ChainDb().(interface {
-		LDB() *leveldb.DB
-	})
-	if !ok {
-		return fmt.Errorf("chaindbCompact does not work for memory databases")
-	}
 	for b := byte(0); b < 255; b++ {
 		log.Info("Compacting chain database", "range", fmt.Sprintf("0x%0.2X-0x%0.2X", b, b+1))
-		err := ldb.LDB().CompactRange(util.Range{Start: []byte{b}, Limit: []byte{b + 1}})
+		err := api.b.ChainDb().Compact([]byte{b}, []byte{b + 1})
 		if err != nil {
 			log.Error("Database compaction failed", "err", err)
 			return err
@@ -44,6 +44,7 @@ type Backend interface {
 	ChainDb() ethdb.Database
 	EventMux() *event.TypeMux
 	AccountManager() *accounts.Manager
+	Impersonating(addr common.Address) bool
 
 	// BlockChain API
 	SetHead(number uint64)
@@ -65,6 +66,13 @@ type Backend interface {
 	GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error)
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
+	TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions)
+	TxPoolRemove(hash common.Hash) bool
+	TxPoolRemoveSender(addr common.Address) int
+	TxPoolClear() int
+	TxPoolSnapshot() *core.TxPoolSnapshot
+	TxPoolRestore(ctx context.Context, snap *core.TxPoolSnapshot) []error
+	TxPoolRejections() []core.TxRejection
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
 
 	ChainConfig() *params.ChainConfig
@@ -94,6 +102,10 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 			Version:   "1.0",
 			Service:   NewPublicTxPoolAPI(apiBackend),
 			Public:    true,
+		}, {
+			Namespace: "txpool",
+			Version:   "1.0",
+			Service:   NewPrivateTxPoolAPI(apiBackend),
 		}, {
 			Namespace: "debug",
 			Version:   "1.0",
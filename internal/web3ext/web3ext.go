@@ -23,6 +23,7 @@ var Modules = map[string]string{
 	"clique":     Clique_JS,
 	"ethash":     Ethash_JS,
 	"debug":      Debug_JS,
+	"dev":        Dev_JS,
 	"eth":        Eth_JS,
 	"miner":      Miner_JS,
 	"net":        Net_JS,
@@ -173,6 +174,16 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'exportArchive',
+			call: 'admin_exportArchive',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'importArchive',
+			call: 'admin_importArchive',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
@@ -396,6 +407,32 @@ web3._extend({
 			params: 2,
 			inputFormatter: [null, null]
 		}),
+		new web3._extend.Method({
+			name: 'stackTraceTransaction',
+			call: 'debug_stackTraceTransaction',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'startCoverage',
+			call: 'debug_startCoverage',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'stopCoverage',
+			call: 'debug_stopCoverage',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'coverage',
+			call: 'debug_coverage',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'coverageReport',
+			call: 'debug_coverageReport',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'preimage',
 			call: 'debug_preimage',
@@ -429,6 +466,86 @@ web3._extend({
 });
 `
 
+const Dev_JS = `
+web3._extend({
+	property: 'dev',
+	methods: [
+		new web3._extend.Method({
+			name: 'setNextBlockTimestamp',
+			call: 'dev_setNextBlockTimestamp',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'increaseTime',
+			call: 'dev_increaseTime',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal],
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'freezeClock',
+			call: 'dev_freezeClock',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'snapshot',
+			call: 'dev_snapshot',
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'revert',
+			call: 'dev_revert',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setBalance',
+			call: 'dev_setBalance',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setCode',
+			call: 'dev_setCode',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'setStorageAt',
+			call: 'dev_setStorageAt',
+			params: 3,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'setNonce',
+			call: 'dev_setNonce',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'impersonateAccount',
+			call: 'dev_impersonateAccount',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'stopImpersonatingAccount',
+			call: 'dev_stopImpersonatingAccount',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+	],
+	properties: [
+		new web3._extend.Property({
+			name: 'now',
+			getter: 'dev_now',
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+	]
+});
+`
+
 const Eth_JS = `
 web3._extend({
 	property: 'eth',
@@ -484,6 +601,11 @@ web3._extend({
 				return formatted;
 			}
 		}),
+		new web3._extend.Property({
+			name: 'txIndexTail',
+			getter: 'eth_txIndexTail',
+			outputFormatter: function(tail) { return tail === null ? null : web3._extend.utils.toDecimal(tail); }
+		}),
 	]
 });
 `
@@ -652,7 +774,46 @@ web3._extend({
 const TxPool_JS = `
 web3._extend({
 	property: 'txpool',
-	methods: [],
+	methods: [
+		new web3._extend.Method({
+			name: 'contentFrom',
+			call: 'txpool_contentFrom',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'contentPage',
+			call: 'txpool_contentPage',
+			params: 2,
+			inputFormatter: [web3._extend.utils.fromDecimal, web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'remove',
+			call: 'txpool_remove',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeSender',
+			call: 'txpool_removeSender',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'clear',
+			call: 'txpool_clear',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'exportSnapshot',
+			call: 'txpool_exportSnapshot',
+			params: 0
+		}),
+		new web3._extend.Method({
+			name: 'importSnapshot',
+			call: 'txpool_importSnapshot',
+			params: 1
+		}),
+	],
 	properties:
 	[
 		new web3._extend.Property({
@@ -663,6 +824,10 @@ web3._extend({
 			name: 'inspect',
 			getter: 'txpool_inspect'
 		}),
+		new web3._extend.Property({
+			name: 'rejections',
+			getter: 'txpool_rejections'
+		}),
 		new web3._extend.Property({
 			name: 'status',
 			getter: 'txpool_status',
@@ -139,10 +139,75 @@ func (b *LesApiBackend) TxPoolContent() (map[common.Address]types.Transactions,
 	return b.eth.txPool.Content()
 }
 
+func (b *LesApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	pending, queued := b.eth.txPool.Content()
+	return pending[addr], queued[addr]
+}
+
+func (b *LesApiBackend) TxPoolRemove(hash common.Hash) bool {
+	if b.eth.txPool.GetTransaction(hash) == nil {
+		return false
+	}
+	b.eth.txPool.RemoveTx(hash)
+	return true
+}
+
+func (b *LesApiBackend) TxPoolRemoveSender(addr common.Address) int {
+	pending, _ := b.eth.txPool.Content()
+	for _, tx := range pending[addr] {
+		b.eth.txPool.RemoveTx(tx.Hash())
+	}
+	return len(pending[addr])
+}
+
+func (b *LesApiBackend) TxPoolClear() int {
+	pending, _ := b.eth.txPool.Content()
+	removed := 0
+	for _, txs := range pending {
+		for _, tx := range txs {
+			b.eth.txPool.RemoveTx(tx.Hash())
+		}
+		removed += len(txs)
+	}
+	return removed
+}
+
+func (b *LesApiBackend) TxPoolSnapshot() *core.TxPoolSnapshot {
+	pending, _ := b.eth.txPool.Content()
+	snap := new(core.TxPoolSnapshot)
+	for _, txs := range pending {
+		for _, tx := range txs {
+			snap.Transactions = append(snap.Transactions, &core.TxPoolEntry{Tx: tx, Local: true, Status: core.TxPoolEntryPending})
+		}
+	}
+	return snap
+}
+
+func (b *LesApiBackend) TxPoolRestore(ctx context.Context, snap *core.TxPoolSnapshot) []error {
+	errs := make([]error, len(snap.Transactions))
+	for i, entry := range snap.Transactions {
+		errs[i] = b.eth.txPool.Add(ctx, entry.Tx)
+	}
+	return errs
+}
+
+func (b *LesApiBackend) TxPoolRejections() []core.TxRejection {
+	return nil
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.eth.txPool.SubscribeNewTxsEvent(ch)
 }
 
+// SubscribeTxLifecycleEvent returns a subscription that never fires, the light
+// pool does not track the lifecycle of its transactions.
+func (b *LesApiBackend) SubscribeTxLifecycleEvent(ch chan<- core.TxLifecycleEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.eth.blockchain.SubscribeChainEvent(ch)
 }
@@ -187,6 +252,10 @@ func (b *LesApiBackend) AccountManager() *accounts.Manager {
 	return b.eth.accountManager
 }
 
+func (b *LesApiBackend) Impersonating(addr common.Address) bool {
+	return false
+}
+
 func (b *LesApiBackend) BloomStatus() (uint64, uint64) {
 	if b.eth.bloomIndexer == nil {
 		return 0, 0
@@ -145,6 +145,15 @@ func (self *Miner) SetExtra(extra []byte) error {
 	return nil
 }
 
+// SetTxOrdering sets the policy ordering the transactions of new blocks.
+func (self *Miner) SetTxOrdering(ordering TxOrdering) error {
+	if err := ordering.validate(); err != nil {
+		return err
+	}
+	self.worker.setTxOrdering(ordering)
+	return nil
+}
+
 // SetRecommitInterval sets the interval for sealing work resubmitting.
 func (self *Miner) SetRecommitInterval(interval time.Duration) {
 	self.worker.setRecommitInterval(interval)
@@ -0,0 +1,96 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Orderings of the transactions of different senders within a block. The
+// transactions of a single sender are always ordered by nonce.
+const (
+	TxOrderPrice        = "price"      // Highest gas price first, ties broken arbitrarily
+	TxOrderFIFO         = "fifo"       // First seen locally first
+	TxOrderPriceArrival = "price-fifo" // Highest gas price first, ties first seen first
+)
+
+// TxOrdering is the policy ordering the pending transactions of new blocks.
+type TxOrdering struct {
+	Mode     string           // Ordering of the other senders, price ordering if empty
+	Priority []common.Address // Senders whose transactions go first, in this order
+}
+
+// validate checks that the ordering mode is known.
+func (o TxOrdering) validate() error {
+	switch o.Mode {
+	case "", TxOrderPrice, TxOrderFIFO, TxOrderPriceArrival:
+		return nil
+	}
+	return fmt.Errorf("unknown transaction ordering %q (want %s, %s or %s)", o.Mode, TxOrderPrice, TxOrderFIFO, TxOrderPriceArrival)
+}
+
+// order returns the comparator of the policy, deriving the transaction senders
+// with the given signer.
+func (o TxOrdering) order(signer types.Signer) types.TxOrder {
+	var base types.TxOrder
+	switch o.Mode {
+	case TxOrderFIFO:
+		base = types.TxArrivalOrder
+	case TxOrderPriceArrival:
+		base = types.TxPriceArrivalOrder
+	default:
+		base = types.TxPriceOrder
+	}
+	if len(o.Priority) == 0 {
+		return base
+	}
+	rank := make(map[common.Address]int)
+	for i, addr := range o.Priority {
+		if _, ok := rank[addr]; !ok {
+			rank[addr] = i
+		}
+	}
+	lookup := func(tx *types.Transaction) int {
+		from, _ := types.Sender(signer, tx)
+		if r, ok := rank[from]; ok {
+			return r
+		}
+		return len(o.Priority)
+	}
+	return func(a, b *types.Transaction) bool {
+		if ra, rb := lookup(a), lookup(b); ra != rb {
+			return ra < rb
+		}
+		return base(a, b)
+	}
+}
+
+// prioritized moves the transactions of the priority senders out of the given
+// pending set into a set of their own.
+func (o TxOrdering) prioritized(pending map[common.Address]types.Transactions) map[common.Address]types.Transactions {
+	txs := make(map[common.Address]types.Transactions)
+	for _, addr := range o.Priority {
+		if list := pending[addr]; len(list) > 0 {
+			delete(pending, addr)
+			txs[addr] = list
+		}
+	}
+	return txs
+}
@@ -0,0 +1,88 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that the transactions of priority senders are picked first in the
+// listed order, followed by the others in the order of the mode.
+func TestTxOrderingPriority(t *testing.T) {
+	signer := types.HomesteadSigner{}
+
+	var (
+		senders []common.Address
+		pending = make(map[common.Address]types.Transactions)
+	)
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		senders = append(senders, addr)
+
+		// Later senders pay more to check that priority beats price
+		for nonce := uint64(0); nonce < 2; nonce++ {
+			tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(int64(i+1)), nil), signer, key)
+			pending[addr] = append(pending[addr], tx)
+		}
+	}
+	ordering := TxOrdering{Mode: TxOrderPrice, Priority: []common.Address{senders[1], senders[0]}}
+	if err := ordering.validate(); err != nil {
+		t.Fatalf("failed to validate ordering: %v", err)
+	}
+	txset := types.NewTransactionsByOrderAndNonce(signer, pending, ordering.order(signer))
+
+	want := []common.Address{senders[1], senders[1], senders[0], senders[0], senders[3], senders[3], senders[2], senders[2]}
+	for i, addr := range want {
+		tx := txset.Peek()
+		if tx == nil {
+			t.Fatalf("tx #%d: missing", i)
+		}
+		if from, _ := types.Sender(signer, tx); from != addr {
+			t.Errorf("tx #%d: sender mismatch: have %x, want %x", i, from, addr)
+		}
+		txset.Shift()
+	}
+	if tx := txset.Peek(); tx != nil {
+		t.Errorf("unexpected leftover transaction %x", tx.Hash())
+	}
+}
+
+// Tests that the priority senders are split off the pending transactions and
+// that unknown modes are rejected.
+func TestTxOrderingSplit(t *testing.T) {
+	a, b, c := common.Address{1}, common.Address{2}, common.Address{3}
+	pending := map[common.Address]types.Transactions{
+		a: {types.NewTransaction(0, common.Address{}, nil, 0, nil, nil)},
+		b: {types.NewTransaction(0, common.Address{}, nil, 0, nil, nil)},
+	}
+	priority := TxOrdering{Priority: []common.Address{b, c}}.prioritized(pending)
+	if len(priority) != 1 || len(priority[b]) != 1 {
+		t.Errorf("priority set mismatch: have %v", priority)
+	}
+	if len(pending) != 1 || len(pending[a]) != 1 {
+		t.Errorf("remaining set mismatch: have %v", pending)
+	}
+	if err := (TxOrdering{Mode: "random"}).validate(); err == nil {
+		t.Error("unknown ordering mode accepted")
+	}
+}
@@ -91,6 +91,7 @@ type environment struct {
 	header   *types.Header
 	txs      []*types.Transaction
 	receipts []*types.Receipt
+	coverage *vm.CoverageTracer // Code coverage of the included transactions, nil if not collected
 }
 
 // task contains all information for consensus engine sealing and result submitting.
@@ -98,6 +99,7 @@ type task struct {
 	receipts  []*types.Receipt
 	state     *state.StateDB
 	block     *types.Block
+	coverage  map[common.Hash]*vm.CodeCoverage // Code coverage to count once the block is sealed
 	createdAt time.Time
 }
 
@@ -153,9 +155,10 @@ type worker struct {
 	possibleUncles map[common.Hash]*types.Block // A set of side blocks as the possible uncle blocks.
 	unconfirmed    *unconfirmedBlocks           // A set of locally mined blocks pending canonicalness confirmations.
 
-	mu       sync.RWMutex // The lock used to protect the coinbase and extra fields
+	mu       sync.RWMutex // The lock used to protect the coinbase, extra and ordering fields
 	coinbase common.Address
 	extra    []byte
+	ordering TxOrdering
 
 	pendingMu    sync.RWMutex
 	pendingTasks map[common.Hash]*task
@@ -235,6 +238,20 @@ func (w *worker) setExtra(extra []byte) {
 	w.extra = extra
 }
 
+// setTxOrdering sets the policy ordering the transactions of new blocks.
+func (w *worker) setTxOrdering(ordering TxOrdering) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ordering = ordering
+}
+
+// txOrdering returns the policy ordering the transactions of new blocks.
+func (w *worker) txOrdering() TxOrdering {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.ordering
+}
+
 // setRecommitInterval updates the interval for miner sealing work recommitting.
 func (w *worker) setRecommitInterval(interval time.Duration) {
 	w.resubmitIntervalCh <- interval
@@ -439,16 +456,14 @@ func (w *worker) mainLoop() {
 			// already included in the current mining block. These transactions will
 			// be automatically eliminated.
 			if !w.isRunning() && w.current != nil {
-				w.mu.RLock()
-				coinbase := w.coinbase
-				w.mu.RUnlock()
+				coinbase := w.feeRecipient(w.current.header)
 
 				txs := make(map[common.Address]types.Transactions)
 				for _, tx := range ev.Txs {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs)
+				txset := types.NewTransactionsByOrderAndNonce(w.current.signer, txs, w.txOrdering().order(w.current.signer))
 				w.commitTransactions(txset, coinbase, nil)
 				w.updateSnapshot()
 			} else {
@@ -568,6 +583,11 @@ func (w *worker) resultLoop() {
 			log.Info("Successfully sealed new block", "number", block.Number(), "sealhash", sealhash, "hash", hash,
 				"elapsed", common.PrettyDuration(time.Since(task.createdAt)))
 
+			// Count the code coverage of the transactions now that they made it on chain
+			if tracer, ok := w.chain.VMConfig().Tracer.(*vm.CoverageTracer); ok && task.coverage != nil {
+				tracer.Merge(task.coverage)
+			}
+
 			// Broadcast the block and announce chain insertion event
 			w.mux.Post(core.NewMinedBlockEvent{Block: block})
 
@@ -597,7 +617,7 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 		return err
 	}
 	env := &environment{
-		signer:    types.NewEIP155Signer(w.config.ChainID),
+		signer:    core.WithImpersonation(w.config, types.NewEIP155Signer(w.config.ChainID)),
 		state:     state,
 		ancestors: mapset.NewSet(),
 		family:    mapset.NewSet(),
@@ -669,14 +689,42 @@ func (w *worker) updateSnapshot() {
 	w.snapshotState = w.current.state.Copy()
 }
 
+// feeRecipient returns the account credited with the fees of the transactions
+// included in the block of the given header.
+func (w *worker) feeRecipient(header *types.Header) common.Address {
+	// Thunder fixes the coinbase to its configured fee recipient when preparing
+	// the header, independently of the etherbase.
+	if w.config.Thunder != nil {
+		return header.Coinbase
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.coinbase
+}
+
 func (w *worker) commitTransaction(tx *types.Transaction, coinbase common.Address) ([]*types.Log, error) {
 	snap := w.current.state.Snapshot()
 
-	receipt, _, err := core.ApplyTransaction(w.config, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, vm.Config{})
+	// Collect the code coverage of the transaction apart, as it only counts once
+	// the block is sealed, not on every recommit
+	config := w.chain.VMConfig()
+	var coverage *vm.CoverageTracer
+	if _, ok := config.Tracer.(*vm.CoverageTracer); ok {
+		coverage = vm.NewCoverageTracer()
+		config.Tracer = coverage
+	}
+	receipt, _, err := core.ApplyTransaction(w.config, w.chain, &coinbase, w.current.gasPool, w.current.state, w.current.header, tx, &w.current.header.GasUsed, config)
 	if err != nil {
 		w.current.state.RevertToSnapshot(snap)
 		return nil, err
 	}
+	if coverage != nil {
+		if w.current.coverage == nil {
+			w.current.coverage = vm.NewCoverageTracer()
+		}
+		w.current.coverage.Merge(coverage.Coverage())
+	}
 	w.current.txs = append(w.current.txs, tx)
 	w.current.receipts = append(w.current.receipts, receipt)
 
@@ -807,8 +855,10 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	if parent.Time().Cmp(new(big.Int).SetInt64(timestamp)) >= 0 {
 		timestamp = parent.Time().Int64() + 1
 	}
-	// this will ensure we're not going off too far in the future
-	if now := time.Now().Unix(); timestamp > now+1 {
+	// this will ensure we're not going off too far in the future, unless the block
+	// time is controlled by the dev clock of Thunder and may run ahead on purpose
+	devClock := w.config.Thunder != nil && w.config.Thunder.Dev
+	if now := time.Now().Unix(); timestamp > now+1 && !devClock {
 		wait := time.Duration(timestamp-now) * time.Second
 		log.Info("Mining too far in the future", "wait", common.PrettyDuration(wait))
 		time.Sleep(wait)
@@ -894,7 +944,11 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.updateSnapshot()
 		return
 	}
-	// Split the pending transactions into locals and remotes
+	// Split the pending transactions into priority senders, locals and remotes
+	ordering := w.txOrdering()
+	order := ordering.order(w.current.signer)
+
+	priorityTxs := ordering.prioritized(pending)
 	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
 	for _, account := range w.eth.TxPool().Locals() {
 		if txs := remoteTxs[account]; len(txs) > 0 {
@@ -902,15 +956,21 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 			localTxs[account] = txs
 		}
 	}
+	if len(priorityTxs) > 0 {
+		txs := types.NewTransactionsByOrderAndNonce(w.current.signer, priorityTxs, order)
+		if w.commitTransactions(txs, w.feeRecipient(header), interrupt) {
+			return
+		}
+	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
+		txs := types.NewTransactionsByOrderAndNonce(w.current.signer, localTxs, order)
+		if w.commitTransactions(txs, w.feeRecipient(header), interrupt) {
 			return
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
+		txs := types.NewTransactionsByOrderAndNonce(w.current.signer, remoteTxs, order)
+		if w.commitTransactions(txs, w.feeRecipient(header), interrupt) {
 			return
 		}
 	}
@@ -931,12 +991,16 @@ func (w *worker) commit(uncles []*types.Header, interval func(), update bool, st
 	if err != nil {
 		return err
 	}
+	var coverage map[common.Hash]*vm.CodeCoverage
+	if w.current.coverage != nil {
+		coverage = w.current.coverage.Coverage()
+	}
 	if w.isRunning() {
 		if interval != nil {
 			interval()
 		}
 		select {
-		case w.taskCh <- &task{receipts: receipts, state: s, block: block, createdAt: time.Now()}:
+		case w.taskCh <- &task{receipts: receipts, state: s, block: block, coverage: coverage, createdAt: time.Now()}:
 			w.unconfirmed.Shift(block.NumberU64() - 1)
 
 			feesWei := new(big.Int)
@@ -48,6 +48,10 @@ var (
 	testUserKey, _  = crypto.GenerateKey()
 	testUserAddress = crypto.PubkeyToAddress(testUserKey.PublicKey)
 
+	// Test contract, adding two numbers
+	testContractAddress = common.HexToAddress("0xc0de")
+	testContractCode    = []byte{0x60, 0x01, 0x60, 0x02, 0x01, 0x50, 0x00}
+
 	// Test transactions
 	pendingTxs []*types.Transaction
 	newTxs     []*types.Transaction
@@ -82,7 +86,10 @@ func newTestWorkerBackend(t *testing.T, chainConfig *params.ChainConfig, engine
 		db    = ethdb.NewMemDatabase()
 		gspec = core.Genesis{
 			Config: chainConfig,
-			Alloc:  core.GenesisAlloc{testBankAddress: {Balance: testBankFunds}},
+			Alloc: core.GenesisAlloc{
+				testBankAddress:     {Balance: testBankFunds},
+				testContractAddress: {Balance: new(big.Int), Code: testContractCode},
+			},
 		}
 	)
 
@@ -451,3 +458,52 @@ func testAdjustInterval(t *testing.T, chainConfig *params.ChainConfig, engine co
 		t.Error("interval reset timeout")
 	}
 }
+
+// Tests that the code coverage of mined transactions is only counted once their
+// block is sealed, not for every work they were committed to.
+func TestSealedCoverage(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, ethashChainConfig, engine, 0)
+	defer w.close()
+
+	tracer := vm.NewCoverageTracer()
+	b.chain.SetVMConfig(vm.Config{Debug: true, Tracer: tracer})
+
+	tx, _ := types.SignTx(types.NewTransaction(1, testContractAddress, new(big.Int), 100000, nil, nil), types.HomesteadSigner{}, testBankKey)
+	b.txPool.AddLocal(tx)
+
+	// Wait for the pending work to include the call, counting nothing yet
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if block := w.pendingBlock(); block != nil && block.Transaction(tx.Hash()) != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("pending work without the contract call")
+		}
+	}
+	if coverage := tracer.Coverage(); len(coverage) != 0 {
+		t.Fatalf("coverage of pending work counted: %v", coverage)
+	}
+	// Seal the block and ensure the call is counted once
+	heads := make(chan core.ChainHeadEvent, 1)
+	sub := b.chain.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	w.start()
+	select {
+	case <-heads:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("block not sealed")
+	}
+	coverage := tracer.Coverage()[crypto.Keccak256Hash(testContractCode)]
+	if coverage == nil {
+		t.Fatalf("no coverage of the sealed call")
+	}
+	for _, pc := range []uint64{0, 2, 4, 5, 6} {
+		if hits := coverage.Hits[pc]; hits != 1 {
+			t.Errorf("pc %d: hit count mismatch: have %d, want 1", pc, hits)
+		}
+	}
+}
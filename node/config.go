@@ -67,6 +67,10 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// DBEngine is the storage engine of the databases in the data directory, one of
+	// the engines registered in ethdb. If empty, LevelDB is used.
+	DBEngine string `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -196,8 +196,32 @@ func (c *CliqueConfig) String() string {
 	return "clique"
 }
 
-// ThunderConfig is the consensus engine config placeholder for Thunder chain
+// ThunderConfig is the consensus engine config for Thunder chain.
+//
+// Transaction fees are credited to the first fee recipient, which the engine
+// sets as the coinbase of every block, and are then split between all the fee
+// recipients by weight when the block is finalized. Block rewards, if any, are
+// split the same way. Without fee recipients the coinbase is the zero address
+// and both fees and block rewards are burnt to it.
 type ThunderConfig struct {
+	FeeRecipients []ThunderFeeRecipient `json:"feeRecipients,omitempty"`
+	BlockReward   *big.Int              `json:"blockReward,omitempty"` // Wei minted per block (nil = no reward)
+	Dev           bool                  `json:"dev,omitempty"`         // Allows controlling block timestamps, local chains only
+}
+
+// ThunderFeeRecipient is an account receiving a share of the fees and rewards.
+type ThunderFeeRecipient struct {
+	Address common.Address `json:"address"`
+	Weight  uint64         `json:"weight"` // Share relative to the sum of all weights
+}
+
+// Coinbase returns the coinbase of Thunder blocks, the first fee recipient or
+// the zero address if there are none.
+func (c *ThunderConfig) Coinbase() common.Address {
+	if len(c.FeeRecipients) == 0 {
+		return common.Address{}
+	}
+	return c.FeeRecipients[0].Address
 }
 
 // String implements the stringer interface, returning the consensus engine details.
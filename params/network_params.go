@@ -52,3 +52,8 @@ const (
 	// is generated
 	HelperTrieProcessConfirmations = 256
 )
+
+// ImmutabilityThreshold is the number of blocks after which a chain segment is
+// considered immutable (i.e. soft finality). It is used by the freezer to move
+// old chain data out of the key-value store.
+const ImmutabilityThreshold = 90000
@@ -53,6 +53,10 @@ func (t *StateTest) UnmarshalJSON(in []byte) error {
 	return json.Unmarshal(in, &t.json)
 }
 
+func (t *StateTest) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&t.json)
+}
+
 type stJSON struct {
 	Env  stEnv                    `json:"env"`
 	Pre  core.GenesisAlloc        `json:"pre"`
@@ -68,7 +72,7 @@ type stPostState struct {
 		Data  int `json:"data"`
 		Gas   int `json:"gas"`
 		Value int `json:"value"`
-	}
+	} `json:"indexes"`
 }
 
 //go:generate gencodec -type stEnv -field-override stEnvMarshaling -out gen_stenv.go
@@ -121,17 +125,61 @@ func (t *StateTest) Subtests() []StateSubtest {
 
 // Run executes a specific subtest.
 func (t *StateTest) Run(subtest StateSubtest, vmconfig vm.Config) (*state.StateDB, error) {
-	config, ok := Forks[subtest.Fork]
+	post := t.json.Post[subtest.Fork][subtest.Index]
+	statedb, root, logs, err := t.execute(subtest.Fork, post, vmconfig)
+	if err != nil {
+		return statedb, err
+	}
+	if logs != common.Hash(post.Logs) {
+		return statedb, fmt.Errorf("post state logs hash mismatch: got %x, want %x", logs, post.Logs)
+	}
+	if root != common.Hash(post.Root) {
+		return statedb, fmt.Errorf("post state root mismatch: got %x, want %x", root, post.Root)
+	}
+	return statedb, nil
+}
+
+// Fill turns a state test filler into a fixture. It executes every combination
+// of the transaction's data, gas limit and value entries under the given forks
+// and records the resulting post state roots and logs hashes, replacing any
+// previously expected post states.
+func (t *StateTest) Fill(forks []string, vmconfig vm.Config) error {
+	t.json.Post = make(map[string][]stPostState)
+	for _, fork := range forks {
+		var posts []stPostState
+		for d := range t.json.Tx.Data {
+			for g := range t.json.Tx.GasLimit {
+				for v := range t.json.Tx.Value {
+					var post stPostState
+					post.Indexes.Data, post.Indexes.Gas, post.Indexes.Value = d, g, v
+
+					_, root, logs, err := t.execute(fork, post, vmconfig)
+					if err != nil {
+						return err
+					}
+					post.Root, post.Logs = common.UnprefixedHash(root), common.UnprefixedHash(logs)
+					posts = append(posts, post)
+				}
+			}
+		}
+		t.json.Post[fork] = posts
+	}
+	return nil
+}
+
+// execute runs the transaction selected by the post state indexes under the
+// given fork, returning the resulting state root and logs hash.
+func (t *StateTest) execute(fork string, post stPostState, vmconfig vm.Config) (*state.StateDB, common.Hash, common.Hash, error) {
+	config, ok := Forks[fork]
 	if !ok {
-		return nil, UnsupportedForkError{subtest.Fork}
+		return nil, common.Hash{}, common.Hash{}, UnsupportedForkError{fork}
 	}
 	block := t.genesis(config).ToBlock(nil)
 	statedb := MakePreState(ethdb.NewMemDatabase(), t.json.Pre)
 
-	post := t.json.Post[subtest.Fork][subtest.Index]
 	msg, err := t.json.Tx.toMessage(post)
 	if err != nil {
-		return nil, err
+		return nil, common.Hash{}, common.Hash{}, err
 	}
 	context := core.NewEVMContext(msg, block.Header(), nil, &t.json.Env.Coinbase)
 	context.GetHash = vmTestBlockHash
@@ -143,9 +191,8 @@ func (t *StateTest) Run(subtest StateSubtest, vmconfig vm.Config) (*state.StateD
 	if _, _, _, err := core.ApplyMessage(evm, msg, gaspool); err != nil {
 		statedb.RevertToSnapshot(snapshot)
 	}
-	if logs := rlpHash(statedb.Logs()); logs != common.Hash(post.Logs) {
-		return statedb, fmt.Errorf("post state logs hash mismatch: got %x, want %x", logs, post.Logs)
-	}
+	logs := rlpHash(statedb.Logs())
+
 	// Commit block
 	statedb.Commit(config.IsEIP158(block.Number()))
 	// Add 0-value mining reward. This only makes a difference in the cases
@@ -158,10 +205,7 @@ func (t *StateTest) Run(subtest StateSubtest, vmconfig vm.Config) (*state.StateD
 	root := statedb.IntermediateRoot(config.IsEIP158(block.Number()))
 	// N.B: We need to do this in a two-step process, because the first Commit takes care
 	// of suicides, and we need to touch the coinbase _after_ it has potentially suicided.
-	if root != common.Hash(post.Root) {
-		return statedb, fmt.Errorf("post state root mismatch: got %x, want %x", root, post.Root)
-	}
-	return statedb, nil
+	return statedb, root, logs, nil
 }
 
 func (t *StateTest) gasLimit(subtest StateSubtest) uint64 {
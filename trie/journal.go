@@ -0,0 +1,165 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// journalNode is the journaled form of a cached trie node, along with the
+// external references it holds. The meta-root holding the references of the
+// live tries is journaled with an empty hash and blob.
+type journalNode struct {
+	Hash     common.Hash
+	Blob     []byte
+	Children []journalChild
+}
+
+// journalChild is an external reference of a journaled node.
+type journalChild struct {
+	Hash  common.Hash
+	Count uint16
+}
+
+// Journal writes all the nodes cached in memory into the writer, along with the
+// references keeping them alive, so that they survive a restart. The preimages
+// are not journaled.
+func (db *Database) Journal(w io.Writer) (int, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	// Write the nodes in the order of the flush-list, children before parents
+	nodes := 0
+	for hash := db.oldest; hash != (common.Hash{}); hash = db.nodes[hash].flushNext {
+		node := db.nodes[hash]
+		if err := rlp.Encode(w, &journalNode{Hash: hash, Blob: node.rlp(), Children: journalChildren(node)}); err != nil {
+			return nodes, err
+		}
+		nodes++
+	}
+	meta := &journalNode{Children: journalChildren(db.nodes[common.Hash{}])}
+	if err := rlp.Encode(w, meta); err != nil {
+		return nodes, err
+	}
+	return nodes, nil
+}
+
+// journalChildren returns the external references of a cached node.
+func journalChildren(node *cachedNode) []journalChild {
+	children := make([]journalChild, 0, len(node.children))
+	for hash, count := range node.children {
+		children = append(children, journalChild{Hash: hash, Count: count})
+	}
+	return children
+}
+
+// LoadJournal reads the nodes of a journal written by Journal into the memory
+// cache, restoring their references. Nodes already cached or stored on disk are
+// skipped. Nothing is loaded if the journal is corrupt.
+func (db *Database) LoadJournal(r io.Reader) (int, error) {
+	var (
+		stream  = rlp.NewStream(r, 0)
+		entries []*journalNode
+		nodes   []node
+	)
+	for {
+		entry := new(journalNode)
+		if err := stream.Decode(entry); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+		if entry.Hash == (common.Hash{}) {
+			entries = append(entries, entry)
+			nodes = append(nodes, nil)
+			continue
+		}
+		n, err := decodeNode(entry.Hash[:], entry.Blob, 0)
+		if err != nil {
+			return 0, fmt.Errorf("node %x: %v", entry.Hash, err)
+		}
+		entries = append(entries, entry)
+		nodes = append(nodes, collapseNode(n))
+	}
+	if len(entries) == 0 || entries[len(entries)-1].Hash != (common.Hash{}) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	// Insert the missing nodes first, children before parents to count the
+	// internal references
+	loaded := make(map[common.Hash]struct{})
+	for i, entry := range entries {
+		if nodes[i] == nil {
+			continue
+		}
+		if _, ok := db.nodes[entry.Hash]; ok {
+			continue
+		}
+		if ok, _ := db.diskdb.Has(entry.Hash[:]); ok {
+			continue
+		}
+		db.insert(entry.Hash, entry.Blob, nodes[i])
+		loaded[entry.Hash] = struct{}{}
+	}
+	// Restore the external references of the loaded nodes and the live tries
+	for _, entry := range entries {
+		if _, ok := loaded[entry.Hash]; !ok && entry.Hash != (common.Hash{}) {
+			continue
+		}
+		parent := db.nodes[entry.Hash]
+		for _, child := range entry.Children {
+			node, ok := db.nodes[child.Hash]
+			if !ok {
+				continue
+			}
+			if parent.children == nil {
+				parent.children = make(map[common.Hash]uint16)
+			}
+			parent.children[child.Hash] += child.Count
+			node.parents += child.Count
+		}
+	}
+	return len(loaded), nil
+}
+
+// collapseNode converts a node decoded from its rlp encoding into the collapsed
+// form cached by the database, with the keys of short nodes in compact encoding.
+func collapseNode(n node) node {
+	switch n := n.(type) {
+	case *shortNode:
+		return &shortNode{Key: hexToCompact(n.Key), Val: collapseNode(n.Val)}
+
+	case *fullNode:
+		collapsed := &fullNode{}
+		for i, child := range n.Children {
+			if child != nil {
+				collapsed.Children[i] = collapseNode(child)
+			}
+		}
+		return collapsed
+
+	default:
+		return n
+	}
+}
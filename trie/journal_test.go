@@ -0,0 +1,88 @@
+// Copyright 2018 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the nodes cached in memory survive a journal round trip, along with
+// the references keeping them alive.
+func TestJournal(t *testing.T) {
+	triedb, trie, content := makeTestTrie()
+	root := trie.Hash()
+	triedb.Reference(root, common.Hash{})
+
+	// Link another trie below the first one, like a storage trie of an account
+	sub, _ := New(common.Hash{}, triedb)
+	sub.Update([]byte("key"), []byte("value"))
+	subroot, _ := sub.Commit(nil)
+	triedb.Reference(subroot, root)
+
+	buf := new(bytes.Buffer)
+	if _, err := triedb.Journal(buf); err != nil {
+		t.Fatalf("failed to journal trie cache: %v", err)
+	}
+	blob := buf.Bytes()
+
+	// Load the journal into a fresh database and check the tries
+	loaded := NewDatabase(ethdb.NewMemDatabase())
+	if _, err := loaded.LoadJournal(bytes.NewReader(blob)); err != nil {
+		t.Fatalf("failed to load trie cache journal: %v", err)
+	}
+	if have, want := len(loaded.Nodes()), len(triedb.Nodes()); have != want {
+		t.Fatalf("cached node count mismatch: have %d, want %d", have, want)
+	}
+	if have, want := loaded.nodesSize, triedb.nodesSize; have != want {
+		t.Fatalf("cache size mismatch: have %v, want %v", have, want)
+	}
+	checkTrieContents(t, loaded, root[:], content)
+	checkTrieContents(t, loaded, subroot[:], map[string][]byte{"key": []byte("value")})
+
+	// Dereferencing the root must release all nodes
+	loaded.Dereference(root)
+	if nodes := loaded.Nodes(); len(nodes) != 0 {
+		t.Fatalf("dangling nodes after dereference: %d", len(nodes))
+	}
+	// Nodes already on disk are not reloaded
+	if err := triedb.Commit(subroot, false); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	partial := NewDatabase(triedb.diskdb)
+	if _, err := partial.LoadJournal(bytes.NewReader(blob)); err != nil {
+		t.Fatalf("failed to load trie cache journal: %v", err)
+	}
+	if have, want := len(partial.Nodes()), len(triedb.Nodes()); have != want {
+		t.Fatalf("cached node count mismatch: have %d, want %d", have, want)
+	}
+	partial.Dereference(root)
+	if nodes := partial.Nodes(); len(nodes) != 0 {
+		t.Fatalf("dangling nodes after dereference: %d", len(nodes))
+	}
+	// A truncated journal must not be loaded at all
+	corrupt := NewDatabase(ethdb.NewMemDatabase())
+	if _, err := corrupt.LoadJournal(bytes.NewReader(blob[:len(blob)/2])); err == nil {
+		t.Fatalf("truncated journal loaded")
+	}
+	if nodes := corrupt.Nodes(); len(nodes) != 0 {
+		t.Fatalf("nodes loaded from truncated journal: %d", len(nodes))
+	}
+}
@@ -18,6 +18,7 @@ package trie
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -114,7 +115,7 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 		if err != nil {
 			return nil, i, fmt.Errorf("bad proof node %d: %v", i, err)
 		}
-		keyrest, cld := get(n, key)
+		keyrest, cld := get(n, key, true)
 		switch cld := cld.(type) {
 		case nil:
 			// The trie doesn't contain the key.
@@ -128,7 +129,10 @@ func VerifyProof(rootHash common.Hash, key []byte, proofDb DatabaseReader) (valu
 	}
 }
 
-func get(tn node, key []byte) ([]byte, node) {
+// get walks down the given node along the key, returning the rest of the key
+// and the first node not resolved yet. If skipResolved is false, it returns after
+// the first step instead.
+func get(tn node, key []byte, skipResolved bool) ([]byte, node) {
 	for {
 		switch n := tn.(type) {
 		case *shortNode:
@@ -137,9 +141,15 @@ func get(tn node, key []byte) ([]byte, node) {
 			}
 			tn = n.Val
 			key = key[len(n.Key):]
+			if !skipResolved {
+				return key, tn
+			}
 		case *fullNode:
 			tn = n.Children[key[0]]
 			key = key[1:]
+			if !skipResolved {
+				return key, tn
+			}
 		case hashNode:
 			return key, n
 		case nil:
@@ -151,3 +161,333 @@ func get(tn node, key []byte) ([]byte, node) {
 		}
 	}
 }
+
+// proofToPath resolves the nodes on the path to key from a merkle proof, linking
+// them into the given partial trie, or into a new one if root is nil. Children
+// off the path are left as hash nodes. It returns the root of the partial trie
+// along with the value of key, if the trie holds one. A proof of absence of key
+// is accepted only if allowNonExistent is set.
+func proofToPath(rootHash common.Hash, root node, key []byte, proofDb DatabaseReader, allowNonExistent bool) (node, []byte, error) {
+	resolve := func(hash []byte) (node, error) {
+		buf, _ := proofDb.Get(hash)
+		if buf == nil {
+			return nil, fmt.Errorf("proof node (hash %064x) missing", hash)
+		}
+		n, err := decodeNode(hash, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("bad proof node: %v", err)
+		}
+		return n, nil
+	}
+	if root == nil {
+		n, err := resolve(rootHash[:])
+		if err != nil {
+			return nil, nil, err
+		}
+		root = n
+	}
+	key, parent := keybytesToHex(key), root
+	for {
+		keyrest, child := get(parent, key, false)
+		switch cld := child.(type) {
+		case nil:
+			// The trie doesn't contain the key, the resolved nodes prove its absence
+			if allowNonExistent {
+				return root, nil, nil
+			}
+			return nil, nil, errors.New("key not contained in trie")
+		case *shortNode, *fullNode:
+			// Already resolved, typically a node embedded into its parent
+			key, parent = keyrest, child
+			continue
+		case hashNode:
+			n, err := resolve(cld)
+			if err != nil {
+				return nil, nil, err
+			}
+			// Link the resolved child into its parent
+			switch pnode := parent.(type) {
+			case *shortNode:
+				pnode.Val = n
+			case *fullNode:
+				pnode.Children[key[0]] = n
+			}
+			key, parent = keyrest, n
+		case valueNode:
+			return root, cld, nil
+		}
+	}
+}
+
+// unsetInternal removes all the nodes of a partial trie lying strictly between
+// the paths of the left and right keys, along with the values on the paths. What
+// is left are the nodes outside of the range, to be complemented by the range
+// entries. It returns whether the whole trie is covered by the range.
+func unsetInternal(n node, left []byte, right []byte) (bool, error) {
+	left, right = keybytesToHex(left), keybytesToHex(right)
+
+	// Step down to the fork point of the two paths, either a short node that
+	// does not match one of the keys, or a full node they take different
+	// branches of
+	var (
+		pos    = 0
+		parent node
+
+		// Comparison of the keys with the short node at the fork point
+		shortForkLeft, shortForkRight int
+	)
+findFork:
+	for {
+		switch rn := n.(type) {
+		case *shortNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			if len(left)-pos < len(rn.Key) {
+				shortForkLeft = bytes.Compare(left[pos:], rn.Key)
+			} else {
+				shortForkLeft = bytes.Compare(left[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if len(right)-pos < len(rn.Key) {
+				shortForkRight = bytes.Compare(right[pos:], rn.Key)
+			} else {
+				shortForkRight = bytes.Compare(right[pos:pos+len(rn.Key)], rn.Key)
+			}
+			if shortForkLeft != 0 || shortForkRight != 0 {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Val, pos+len(rn.Key)
+
+		case *fullNode:
+			rn.flags = nodeFlag{dirty: true}
+
+			leftnode, rightnode := rn.Children[left[pos]], rn.Children[right[pos]]
+			if leftnode == nil || rightnode == nil || left[pos] != right[pos] {
+				break findFork
+			}
+			parent = n
+			n, pos = rn.Children[left[pos]], pos+1
+
+		default:
+			return false, fmt.Errorf("invalid node on range path: %T", n)
+		}
+	}
+	switch rn := n.(type) {
+	case *shortNode:
+		// Both keys on the same side of the short node leave nothing in range
+		if shortForkLeft == shortForkRight && shortForkLeft != 0 {
+			return false, errors.New("empty range")
+		}
+		// The short node lies entirely within the range, remove it
+		if shortForkLeft != 0 && shortForkRight != 0 {
+			if parent == nil {
+				return true, nil
+			}
+			parent.(*fullNode).Children[left[pos-1]] = nil
+			return false, nil
+		}
+		// One of the keys runs through the short node, the other one is off it
+		if shortForkRight != 0 {
+			if _, ok := rn.Val.(valueNode); ok {
+				if parent == nil {
+					return true, nil
+				}
+				parent.(*fullNode).Children[left[pos-1]] = nil
+				return false, nil
+			}
+			return false, unset(rn, rn.Val, left[pos:], len(rn.Key), false)
+		}
+		if _, ok := rn.Val.(valueNode); ok {
+			if parent == nil {
+				return true, nil
+			}
+			parent.(*fullNode).Children[right[pos-1]] = nil
+			return false, nil
+		}
+		return false, unset(rn, rn.Val, right[pos:], len(rn.Key), true)
+
+	case *fullNode:
+		// Remove the branches between the two paths, then trim the paths
+		for i := left[pos] + 1; i < right[pos]; i++ {
+			rn.Children[i] = nil
+		}
+		if err := unset(rn, rn.Children[left[pos]], left[pos:], 1, false); err != nil {
+			return false, err
+		}
+		if err := unset(rn, rn.Children[right[pos]], right[pos:], 1, true); err != nil {
+			return false, err
+		}
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("invalid node on range path: %T", n)
+	}
+}
+
+// unset removes the nodes on one side of the path of key below the given child,
+// the left side if removeLeft is set, the right one otherwise. Nodes within the
+// range are removed, the ones outside of it are kept along with their hashes.
+func unset(parent node, child node, key []byte, pos int, removeLeft bool) error {
+	switch cld := child.(type) {
+	case *fullNode:
+		if removeLeft {
+			for i := 0; i < int(key[pos]); i++ {
+				cld.Children[i] = nil
+			}
+		} else {
+			for i := key[pos] + 1; i < 16; i++ {
+				cld.Children[i] = nil
+			}
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Children[key[pos]], key, pos+1, removeLeft)
+
+	case *shortNode:
+		if len(key[pos:]) < len(cld.Key) || !bytes.Equal(cld.Key, key[pos:pos+len(cld.Key)]) {
+			// The path forks off at the short node, remove it if it lies within
+			// the range. Its parent must be a full node.
+			if removeLeft {
+				if bytes.Compare(cld.Key, key[pos:]) < 0 {
+					parent.(*fullNode).Children[key[pos-1]] = nil
+				}
+			} else {
+				if bytes.Compare(cld.Key, key[pos:]) > 0 {
+					parent.(*fullNode).Children[key[pos-1]] = nil
+				}
+			}
+			return nil
+		}
+		if _, ok := cld.Val.(valueNode); ok {
+			// The value on the path is part of the range entries
+			parent.(*fullNode).Children[key[pos-1]] = nil
+			return nil
+		}
+		cld.flags = nodeFlag{dirty: true}
+		return unset(cld, cld.Val, key, pos+len(cld.Key), removeLeft)
+
+	case nil:
+		// The path ends at an empty branch of a full node
+		return nil
+
+	default:
+		return fmt.Errorf("invalid node on range path: %T", child)
+	}
+}
+
+// hasRightElement returns whether the partial trie holds any entry to the right
+// of the path of key. The path must be resolved.
+func hasRightElement(n node, key []byte) bool {
+	pos, key := 0, keybytesToHex(key)
+	for n != nil {
+		switch rn := n.(type) {
+		case *fullNode:
+			for i := key[pos] + 1; i < 16; i++ {
+				if rn.Children[i] != nil {
+					return true
+				}
+			}
+			n, pos = rn.Children[key[pos]], pos+1
+		case *shortNode:
+			if len(key)-pos < len(rn.Key) || !bytes.Equal(rn.Key, key[pos:pos+len(rn.Key)]) {
+				return bytes.Compare(rn.Key, key[pos:]) > 0
+			}
+			n, pos = rn.Val, pos+len(rn.Key)
+		default:
+			// A value node means the whole path is resolved
+			return false
+		}
+	}
+	return false
+}
+
+// VerifyRangeProof checks that the given sorted keys and values are all the
+// entries of the trie with the given root hash, starting at origin up to the
+// last key. The proof must contain the paths of origin and the last key.
+//
+// A nil proof states that the entries make up the whole trie, in which case
+// origin is ignored. An empty range must come with the proof of absence of
+// origin, showing no entries follow it.
+//
+// It returns whether the trie holds more entries after the range.
+func VerifyRangeProof(rootHash common.Hash, origin []byte, keys [][]byte, values [][]byte, proofDb DatabaseReader) (bool, error) {
+	if len(keys) != len(values) {
+		return false, fmt.Errorf("inconsistent proof data, keys: %d, values: %d", len(keys), len(values))
+	}
+	for i := 0; i < len(keys)-1; i++ {
+		if bytes.Compare(keys[i], keys[i+1]) >= 0 {
+			return false, errors.New("range is not monotonically increasing")
+		}
+	}
+	for _, value := range values {
+		if len(value) == 0 {
+			return false, errors.New("range contains deletion")
+		}
+	}
+	// Without a proof the range must be the whole trie
+	if proofDb == nil {
+		tr, _ := New(common.Hash{}, NewDatabase(ethdb.NewMemDatabase()))
+		for i, key := range keys {
+			tr.Update(key, values[i])
+		}
+		if have := tr.Hash(); have != rootHash {
+			return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+		}
+		return false, nil
+	}
+	// An empty range must prove there are no entries from origin on
+	if len(keys) == 0 {
+		root, val, err := proofToPath(rootHash, nil, origin, proofDb, true)
+		if err != nil {
+			return false, err
+		}
+		if val != nil || hasRightElement(root, origin) {
+			return false, errors.New("more entries available")
+		}
+		return false, nil
+	}
+	last := keys[len(keys)-1]
+	if bytes.Compare(origin, keys[0]) > 0 {
+		return false, errors.New("range starts before origin")
+	}
+	if len(origin) != len(last) {
+		return false, errors.New("inconsistent edge keys")
+	}
+	// A single entry at origin is proven by its plain merkle proof
+	if bytes.Equal(origin, last) {
+		root, val, err := proofToPath(rootHash, nil, origin, proofDb, false)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(val, values[0]) {
+			return false, errors.New("correct proof but invalid data")
+		}
+		return hasRightElement(root, origin), nil
+	}
+	// Resolve the two edge paths into a partial trie, drop everything between
+	// them and fill the gap with the range entries. The result must hash to the
+	// root if the range is complete.
+	root, _, err := proofToPath(rootHash, nil, origin, proofDb, true)
+	if err != nil {
+		return false, err
+	}
+	if root, _, err = proofToPath(rootHash, root, last, proofDb, true); err != nil {
+		return false, err
+	}
+	empty, err := unsetInternal(root, origin, last)
+	if err != nil {
+		return false, err
+	}
+	tr := &Trie{root: root, db: NewDatabase(ethdb.NewMemDatabase())}
+	if empty {
+		tr.root = nil
+	}
+	for i, key := range keys {
+		if err := tr.TryUpdate(key, values[i]); err != nil {
+			return false, err
+		}
+	}
+	if have := tr.Hash(); have != rootHash {
+		return false, fmt.Errorf("invalid proof, want hash %x, got %x", rootHash, have)
+	}
+	return hasRightElement(tr.root, last), nil
+}
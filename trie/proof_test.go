@@ -20,6 +20,7 @@ import (
 	"bytes"
 	crand "crypto/rand"
 	mrand "math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -154,6 +155,141 @@ func mutateByte(b []byte) {
 	}
 }
 
+// sortedEntries returns the entries of a random trie sorted by key.
+func sortedEntries(vals map[string]*kv) []*kv {
+	var entries []*kv
+	for _, kv := range vals {
+		entries = append(entries, kv)
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].k, entries[j].k) < 0 })
+	return entries
+}
+
+// proveRange creates the range proof of the given entries starting at origin.
+func proveRange(trie *Trie, origin []byte, entries []*kv) (*ethdb.MemDatabase, [][]byte, [][]byte) {
+	proof := ethdb.NewMemDatabase()
+	if err := trie.Prove(origin, 0, proof); err != nil {
+		panic(err)
+	}
+	var keys, vals [][]byte
+	for _, kv := range entries {
+		keys = append(keys, kv.k)
+		vals = append(vals, kv.v)
+	}
+	if len(entries) > 0 {
+		if err := trie.Prove(entries[len(entries)-1].k, 0, proof); err != nil {
+			panic(err)
+		}
+	}
+	return proof, keys, vals
+}
+
+// Tests that random ranges of a trie are proven, starting both at existing and
+// non-existing keys.
+func TestRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 200; i++ {
+		start := mrand.Intn(len(entries))
+		end := start + 1 + mrand.Intn(len(entries)-start)
+
+		origin := entries[start].k
+		if i%2 == 1 {
+			// Start right after the previous entry instead
+			origin = common.CopyBytes(origin)
+			if start > 0 {
+				origin = increaseKey(common.CopyBytes(entries[start-1].k))
+			}
+		}
+		proof, keys, vals := proveRange(trie, origin, entries[start:end])
+		more, err := VerifyRangeProof(trie.Hash(), origin, keys, vals, proof)
+		if err != nil {
+			t.Fatalf("range %d-%d: verification failed: %v", start, end, err)
+		}
+		if more != (end < len(entries)) {
+			t.Fatalf("range %d-%d: more entries mismatch: have %v, want %v", start, end, more, end < len(entries))
+		}
+	}
+}
+
+// Tests that the whole trie is proven without edge proofs, and that an empty
+// range after the last entry is proven by the absence of the origin.
+func TestRangeProofEdges(t *testing.T) {
+	trie, vals := randomTrie(1024)
+	entries := sortedEntries(vals)
+
+	_, keys, values := proveRange(trie, nil, entries)
+	if more, err := VerifyRangeProof(trie.Hash(), nil, keys, values, nil); err != nil || more {
+		t.Fatalf("whole trie: verification failed: more %v, err %v", more, err)
+	}
+	if _, err := VerifyRangeProof(trie.Hash(), nil, keys[1:], values[1:], nil); err == nil {
+		t.Fatalf("partial trie accepted as whole")
+	}
+	origin := increaseKey(common.CopyBytes(entries[len(entries)-1].k))
+	proof, _, _ := proveRange(trie, origin, nil)
+	if more, err := VerifyRangeProof(trie.Hash(), origin, nil, nil, proof); err != nil || more {
+		t.Fatalf("empty tail: verification failed: more %v, err %v", more, err)
+	}
+	origin = increaseKey(common.CopyBytes(entries[len(entries)-2].k))
+	proof, _, _ = proveRange(trie, origin, nil)
+	if _, err := VerifyRangeProof(trie.Hash(), origin, nil, nil, proof); err == nil {
+		t.Fatalf("empty range with entries after it accepted")
+	}
+}
+
+// Tests that tampered ranges are rejected.
+func TestBadRangeProof(t *testing.T) {
+	trie, vals := randomTrie(4096)
+	entries := sortedEntries(vals)
+
+	for i := 0; i < 200; i++ {
+		start := mrand.Intn(len(entries) - 3)
+		end := start + 3 + mrand.Intn(len(entries)-start-3)
+
+		origin := entries[start].k
+		proof, keys, vals := proveRange(trie, origin, entries[start:end])
+
+		switch mrand.Intn(4) {
+		case 0:
+			// Modify a value
+			index := mrand.Intn(len(vals))
+			vals[index] = randBytes(20)
+		case 1:
+			// Drop an inner entry
+			index := 1 + mrand.Intn(len(keys)-2)
+			keys = append(keys[:index:index], keys[index+1:]...)
+			vals = append(vals[:index:index], vals[index+1:]...)
+		case 2:
+			// Insert a new entry after the first one
+			key := increaseKey(common.CopyBytes(keys[0]))
+			if bytes.Equal(key, keys[1]) {
+				continue
+			}
+			keys = append([][]byte{keys[0], key}, keys[1:]...)
+			vals = append([][]byte{vals[0], randBytes(20)}, vals[1:]...)
+		case 3:
+			// Swap two entries
+			index := mrand.Intn(len(keys) - 1)
+			keys[index], keys[index+1] = keys[index+1], keys[index]
+		}
+		if _, err := VerifyRangeProof(trie.Hash(), origin, keys, vals, proof); err == nil {
+			t.Fatalf("range %d-%d: tampered range accepted", start, end)
+		}
+	}
+}
+
+// increaseKey returns the key following the given one.
+func increaseKey(key []byte) []byte {
+	for i := len(key) - 1; i >= 0; i-- {
+		key[i]++
+		if key[i] != 0x0 {
+			break
+		}
+	}
+	return key
+}
+
 func BenchmarkProve(b *testing.B) {
 	trie, vals := randomTrie(100)
 	var keys []string